@@ -0,0 +1,136 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/spf13/cobra"
+
+	"github.com/deliveroo/bnt-internal-test-go/internal/apikeys"
+	"github.com/deliveroo/bnt-internal-test-go/internal/config"
+	"github.com/deliveroo/bnt-internal-test-go/internal/dependencies"
+)
+
+func newAPIKeysCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "apikeys",
+		Short: "Mints, lists and revokes the API keys of partners and tools",
+	}
+
+	var (
+		grant     apikeys.Key
+		expiresIn time.Duration
+	)
+	mint := &cobra.Command{
+		Use:   "mint",
+		Short: "Mints an API key, which is printed once and cannot be recovered",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			return withAPIKeyStore(cmd.Context(), func(store apikeys.Store) error {
+				if expiresIn > 0 {
+					expiresAt := time.Now().Add(expiresIn)
+					grant.ExpiresAt = &expiresAt
+				}
+
+				key, minted, err := apikeys.Mint(cmd.Context(), store, grant)
+				if err != nil {
+					return err //nolint:wrapcheck // already describes the failure
+				}
+				fmt.Fprintf(cmd.OutOrStdout(), "Minted API key %s for %s. Store it now, as it cannot be shown again:\n\n%s\n", minted.ID, minted.Owner, key)
+				return nil
+			})
+		},
+	}
+	mint.Flags().StringVar(&grant.Owner, "owner", "", "who the key is for, e.g. a partner or a tool")
+	mint.Flags().StringSliceVar(&grant.Scopes, "scope", nil, "a scope granted to the key, e.g. orders:read (repeatable)")
+	mint.Flags().StringSliceVar(&grant.Roles, "role", nil, "a role of the key, e.g. service (repeatable)")
+	mint.Flags().DurationVar(&expiresIn, "expires-in", 0, "how long until the key expires, e.g. 2160h; it never expires when unset")
+	_ = mint.MarkFlagRequired("owner")
+	cmd.AddCommand(mint)
+
+	cmd.AddCommand(&cobra.Command{
+		Use:   "list",
+		Short: "Lists API keys, newest first",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			return withAPIKeyStore(cmd.Context(), func(store apikeys.Store) error {
+				keys, err := store.List(cmd.Context())
+				if err != nil {
+					return err //nolint:wrapcheck // already describes the failure
+				}
+				return printAPIKeys(cmd.OutOrStdout(), keys, time.Now())
+			})
+		},
+	})
+
+	cmd.AddCommand(&cobra.Command{
+		Use:   "revoke ID",
+		Short: "Revokes an API key, which is rejected once caches expire (AUTH_API_KEY_CACHE_TTL)",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			id, err := uuid.Parse(args[0])
+			if err != nil {
+				return fmt.Errorf("invalid API key ID %q: %w", args[0], err)
+			}
+			return withAPIKeyStore(cmd.Context(), func(store apikeys.Store) error {
+				if err := store.Revoke(cmd.Context(), id); err != nil {
+					return err //nolint:wrapcheck // already describes the failure
+				}
+				fmt.Fprintf(cmd.OutOrStdout(), "Revoked API key %s.\n", id)
+				return nil
+			})
+		},
+	})
+
+	return cmd
+}
+
+// withAPIKeyStore calls fn with the API keys stored in the database of the
+// configuration.
+func withAPIKeyStore(ctx context.Context, fn func(apikeys.Store) error) error {
+	cfg, err := config.Load()
+	if err != nil {
+		return fmt.Errorf("could not load configuration: %w", err)
+	}
+
+	pool, err := dependencies.OpenDatabase(ctx, cfg.Database.URL.Value(), cfg.Database)
+	if err != nil {
+		return err //nolint:wrapcheck // already describes the failure
+	}
+	defer pool.Close()
+
+	return fn(apikeys.NewPostgresStore(pool))
+}
+
+func printAPIKeys(out io.Writer, keys []apikeys.Key, now time.Time) error {
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tPREFIX\tOWNER\tSCOPES\tROLES\tCREATED\tEXPIRES\tLAST USED\tSTATUS")
+	for _, key := range keys {
+		status := "active"
+		switch {
+		case key.RevokedAt != nil:
+			status = "revoked"
+		case !key.Active(now):
+			status = "expired"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\n",
+			key.ID, key.Prefix, key.Owner, strings.Join(key.Scopes, " "), strings.Join(key.Roles, " "),
+			formatTime(&key.CreatedAt, "-"), formatTime(key.ExpiresAt, "never"), formatTime(key.LastUsedAt, "never"), status)
+	}
+	if err := w.Flush(); err != nil {
+		return fmt.Errorf("failed to print API keys: %w", err)
+	}
+	return nil
+}
+
+func formatTime(t *time.Time, unset string) string {
+	if t == nil {
+		return unset
+	}
+	return t.UTC().Format(time.RFC3339)
+}
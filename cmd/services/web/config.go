@@ -0,0 +1,63 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+
+	"github.com/deliveroo/bnt-internal-test-go/internal/config"
+)
+
+func newConfigCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "config",
+		Short: "Inspects the service configuration",
+	}
+
+	cmd.AddCommand(&cobra.Command{
+		Use:   "print",
+		Short: "Prints the effective configuration, with secrets redacted, and where each value came from",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			return printConfig(cmd.OutOrStdout())
+		},
+	})
+
+	return cmd
+}
+
+// printConfig prints the configuration even when it is invalid, followed by
+// every validation problem.
+func printConfig(out io.Writer) error {
+	cfg, err := config.Load()
+	var validationErr *config.ValidationError
+	if err != nil && !errors.As(err, &validationErr) {
+		return err //nolint:wrapcheck // already describes the failure
+	}
+
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "FIELD\tVARIABLE\tVALUE\tSOURCE")
+	for _, setting := range config.Describe(&cfg) {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", setting.Field, setting.Variable, setting.Value, setting.Source)
+	}
+	if err := w.Flush(); err != nil {
+		return fmt.Errorf("failed to print config: %w", err)
+	}
+
+	for _, warning := range cfg.Warnings() {
+		fmt.Fprintf(out, "\nWarning: %s\n", warning)
+	}
+
+	if validationErr != nil {
+		fmt.Fprintf(out, "\nThe configuration is invalid for the %s environment:\n", validationErr.Environment)
+		for _, fieldErr := range validationErr.Errors {
+			fmt.Fprintf(out, "  - %s\n", fieldErr)
+		}
+		return errors.New("invalid configuration")
+	}
+
+	return nil
+}
@@ -10,14 +10,40 @@ import (
 	"os/signal"
 	"syscall"
 
+	"github.com/spf13/cobra"
 	"go.uber.org/zap"
 
 	"github.com/deliveroo/bnt-internal-test-go/internal/config"
+	"github.com/deliveroo/bnt-internal-test-go/internal/database"
 	"github.com/deliveroo/bnt-internal-test-go/internal/dependencies"
 	"github.com/deliveroo/bnt-internal-test-go/internal/httpserver"
 )
 
 func main() {
+	if err := newRootCommand().Execute(); err != nil {
+		os.Exit(1)
+	}
+}
+
+func newRootCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:          "web",
+		Short:        "Runs the HTTP server",
+		Args:         cobra.NoArgs,
+		SilenceUsage: true,
+		Run: func(*cobra.Command, []string) {
+			serve()
+		},
+	}
+
+	cmd.AddCommand(newConfigCommand())
+	cmd.AddCommand(newAPIKeysCommand())
+	cmd.AddCommand(newMigrateCommand())
+
+	return cmd
+}
+
+func serve() {
 	cfg, err := config.Load()
 	if err != nil {
 		log.Fatalf("could not load configuration: %s", err)
@@ -28,10 +54,29 @@ func main() {
 		log.Fatalf("could not load dependencies: %s", err)
 	}
 
-	ctx := context.Background()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
 	log := deps.APM.Logger()
+	for _, warning := range cfg.Warnings() {
+		log.Warn(warning)
+	}
+	if cfg.Database.MigrateOnStart {
+		if err := database.Migrate(ctx, deps.WriterDB); err != nil {
+			log.Fatal("could not migrate the database", zap.Error(err))
+		}
+	}
 	log.Info("Server has booted!", zap.Int("port", cfg.Server.Port))
 
+	if cfg.Settings.RuntimeFile != "" {
+		go deps.Settings.WatchFile(ctx, cfg.Settings.RuntimeFile, cfg.Settings.RuntimePollInterval)
+	}
+	handleDebugSignal(ctx, func() {
+		if _, err := deps.Settings.ToggleDebug("signal SIGUSR1"); err != nil {
+			log.Error("failed to toggle debug logging", zap.Error(err))
+		}
+	})
+
 	server := http.Server{
 		Addr:         fmt.Sprintf(":%d", cfg.Server.Port),
 		Handler:      httpserver.NewRouter(deps),
@@ -78,3 +123,23 @@ func handleShutdownSignal(onSignalReceived func()) <-chan struct{} {
 
 	return shutdown
 }
+
+// handleDebugSignal calls onSignalReceived each time SIGUSR1 is received, until
+// ctx is done.
+func handleDebugSignal(ctx context.Context, onSignalReceived func()) {
+	sigNotifier := make(chan os.Signal, 1)
+	signal.Notify(sigNotifier, syscall.SIGUSR1)
+
+	go func() {
+		defer signal.Stop(sigNotifier)
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-sigNotifier:
+				onSignalReceived()
+			}
+		}
+	}()
+}
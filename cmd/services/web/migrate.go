@@ -0,0 +1,43 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	"github.com/deliveroo/bnt-internal-test-go/internal/config"
+	"github.com/deliveroo/bnt-internal-test-go/internal/database"
+	"github.com/deliveroo/bnt-internal-test-go/internal/dependencies"
+)
+
+func newMigrateCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "migrate",
+		Short: "Applies the database migrations which have not been applied yet",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			if err := migrate(cmd.Context()); err != nil {
+				return err
+			}
+			fmt.Fprintln(cmd.OutOrStdout(), "The database is up to date.")
+			return nil
+		},
+	}
+}
+
+// migrate applies the migrations to the database of the configuration.
+func migrate(ctx context.Context) error {
+	cfg, err := config.Load()
+	if err != nil {
+		return fmt.Errorf("could not load configuration: %w", err)
+	}
+
+	pool, err := dependencies.OpenDatabase(ctx, cfg.Database.URL.Value(), cfg.Database)
+	if err != nil {
+		return err //nolint:wrapcheck // already describes the failure
+	}
+	defer pool.Close()
+
+	return database.Migrate(ctx, pool) //nolint:wrapcheck // already describes the failure
+}
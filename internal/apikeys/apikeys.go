@@ -0,0 +1,116 @@
+// Package apikeys authenticates partner and internal tooling clients with API
+// keys.
+//
+// Keys are minted by an operator with the `web apikeys` command, and shown
+// once. Only their SHA-256 hash is stored, with their owner, scopes and expiry,
+// so that a leaked database does not leak usable keys.
+package apikeys
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// keyPrefix starts every key, so that leaked keys are easy to recognise, e.g.
+// by secret scanners.
+const keyPrefix = "bntk_"
+
+// ErrNotFound is returned for keys which do not exist.
+var ErrNotFound = errors.New("API key not found")
+
+// Key describes an API key. The key itself is not kept.
+type Key struct {
+	ID uuid.UUID
+	// Prefix is the start of the key, shown to tell keys apart.
+	Prefix string
+	// Owner is who the key was minted for, e.g. a partner or a tool.
+	Owner string
+	// Scopes are the scopes granted to the key.
+	Scopes []string
+	// Roles are the roles of the key, which authorization policies refer to.
+	Roles []string
+	// ExpiresAt is when the key expires, or nil when it does not.
+	ExpiresAt *time.Time
+	// LastUsedAt is when the key was last used, to the nearest minute, or nil
+	// when it has not been used.
+	LastUsedAt *time.Time
+	// RevokedAt is when the key was revoked, or nil when it was not.
+	RevokedAt *time.Time
+	CreatedAt time.Time
+}
+
+// Active returns whether the key can be used at now.
+func (k Key) Active(now time.Time) bool {
+	return k.RevokedAt == nil && (k.ExpiresAt == nil || now.Before(*k.ExpiresAt))
+}
+
+// Store stores API keys by their hash.
+type Store interface {
+	// Create stores key with the hash of the key itself, returning it as
+	// stored.
+	Create(ctx context.Context, key Key, hash []byte) (Key, error)
+	// FindByHash returns the key whose hash is hash, or nil when there is none.
+	FindByHash(ctx context.Context, hash []byte) (*Key, error)
+	// List returns every key, newest first.
+	List(ctx context.Context) ([]Key, error)
+	// Revoke revokes the key identified by id. It returns ErrNotFound when
+	// there is no such key.
+	Revoke(ctx context.Context, id uuid.UUID) error
+	// MarkUsed records that the key identified by id was used at.
+	MarkUsed(ctx context.Context, id uuid.UUID, at time.Time) error
+}
+
+// Hash returns the hash a key is stored by.
+func Hash(key string) []byte {
+	hash := sha256.Sum256([]byte(key))
+	return hash[:]
+}
+
+// Mint creates a key for the owner of grant, with its scopes, roles and
+// expiry. It returns the key, which cannot be recovered later, and its
+// description.
+func Mint(ctx context.Context, store Store, grant Key) (string, Key, error) {
+	if grant.Owner == "" {
+		return "", Key{}, errors.New("an owner is required")
+	}
+
+	prefix := make([]byte, 4)
+	secret := make([]byte, 32)
+	if _, err := rand.Read(prefix); err != nil {
+		return "", Key{}, fmt.Errorf("failed to generate API key: %w", err)
+	}
+	if _, err := rand.Read(secret); err != nil {
+		return "", Key{}, fmt.Errorf("failed to generate API key: %w", err)
+	}
+
+	key := Key{
+		ID:        uuid.New(),
+		Prefix:    keyPrefix + hex.EncodeToString(prefix),
+		Owner:     grant.Owner,
+		Scopes:    append([]string{}, grant.Scopes...),
+		Roles:     append([]string{}, grant.Roles...),
+		ExpiresAt: grant.ExpiresAt,
+	}
+	plaintext := key.Prefix + "_" + base64.RawURLEncoding.EncodeToString(secret)
+
+	created, err := store.Create(ctx, key, Hash(plaintext))
+	if err != nil {
+		return "", Key{}, fmt.Errorf("failed to store API key: %w", err)
+	}
+	return plaintext, created, nil
+}
+
+// looksLikeKey returns whether value has the form of a key, so that values
+// which cannot be keys are rejected without looking them up.
+func looksLikeKey(value string) bool {
+	return strings.HasPrefix(value, keyPrefix) && len(value) <= 128
+}
@@ -0,0 +1,205 @@
+package apikeys
+
+import (
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	"github.com/deliveroo/apm-go"
+	"github.com/deliveroo/bnt-internal-test-go/internal/auth"
+)
+
+const (
+	// Header carries the API key.
+	Header = "X-API-Key"
+	// SubjectPrefix starts the Subject of the principals of API keys, which
+	// is followed by the ID of the key.
+	SubjectPrefix = "apikey:"
+
+	// metricPrefix starts the names of the metrics reported per key.
+	metricPrefix = "apikeys."
+
+	defaultCacheTTL = 30 * time.Second
+	// maxCacheSize bounds the number of keys cached, including unknown ones,
+	// so that requests with random keys cannot exhaust memory.
+	maxCacheSize = 10_000
+	// markUsedInterval is how often the use of a key is recorded.
+	markUsedInterval = time.Minute
+)
+
+// Authenticator authenticates requests by the API key in their X-API-Key
+// header. Keys are cached, including unknown ones, so that most requests do
+// not reach the store; revoking a key takes effect once its cache entry
+// expires.
+type Authenticator struct {
+	store    Store
+	cacheTTL time.Duration
+	metrics  apm.Metrics
+	logger   *zap.Logger
+	now      func() time.Time
+
+	mu       sync.Mutex
+	cache    map[string]cacheEntry
+	markedAt map[uuid.UUID]time.Time
+}
+
+type cacheEntry struct {
+	// key is nil for unknown keys.
+	key       *Key
+	expiresAt time.Time
+}
+
+var _ auth.Authenticator = (*Authenticator)(nil)
+
+// Option configures an Authenticator.
+type Option func(*Authenticator)
+
+// WithCacheTTL sets how long keys are cached. Defaults to 30s.
+func WithCacheTTL(ttl time.Duration) Option {
+	return func(a *Authenticator) {
+		a.cacheTTL = ttl
+	}
+}
+
+// WithLogger sets the logger for failures of the store. Defaults to a no-op
+// logger.
+func WithLogger(logger *zap.Logger) Option {
+	return func(a *Authenticator) {
+		a.logger = logger
+	}
+}
+
+// WithClock sets the source of the current time. Defaults to time.Now.
+func WithClock(now func() time.Time) Option {
+	return func(a *Authenticator) {
+		a.now = now
+	}
+}
+
+// NewAuthenticator returns an Authenticator of the keys in store, reporting
+// the use of each key to metrics.
+func NewAuthenticator(store Store, metrics apm.Metrics, opts ...Option) *Authenticator {
+	a := &Authenticator{
+		store:    store,
+		cacheTTL: defaultCacheTTL,
+		metrics:  metrics,
+		logger:   zap.NewNop(),
+		now:      time.Now,
+		cache:    map[string]cacheEntry{},
+		markedAt: map[uuid.UUID]time.Time{},
+	}
+	for _, opt := range opts {
+		opt(a)
+	}
+	return a
+}
+
+// Authenticate returns the principal authenticated by the API key of r.
+func (a *Authenticator) Authenticate(r *http.Request) (*auth.Principal, error) {
+	value := r.Header.Get(Header)
+	if value == "" {
+		return nil, auth.ErrNoCredentials
+	}
+	if !looksLikeKey(value) {
+		return nil, a.reject("malformed")
+	}
+
+	key, err := a.lookup(r, value)
+	if err != nil {
+		a.metrics.Incr(metricPrefix+"errors", 1)
+		a.logger.Error("failed to look up API key", zap.Error(err))
+		return nil, err
+	}
+
+	now := a.now()
+	switch {
+	case key == nil:
+		return nil, a.reject("unknown")
+	case key.RevokedAt != nil:
+		return nil, a.reject("revoked", "key_id", key.ID.String(), "owner", key.Owner)
+	case !key.Active(now):
+		return nil, a.reject("expired", "key_id", key.ID.String(), "owner", key.Owner)
+	}
+
+	a.metrics.Incr(metricPrefix+"requests", 1, "key_id", key.ID.String(), "owner", key.Owner)
+	a.markUsed(r, key.ID, now)
+
+	principal := &auth.Principal{
+		Subject:  SubjectPrefix + key.ID.String(),
+		ClientID: key.Owner,
+		Scopes:   key.Scopes,
+		Roles:    key.Roles,
+	}
+	if key.ExpiresAt != nil {
+		principal.ExpiresAt = *key.ExpiresAt
+	}
+	return principal, nil
+}
+
+func (a *Authenticator) reject(reason string, tagPairs ...string) error {
+	a.metrics.Incr(metricPrefix+"rejected", 1, append([]string{"reason", reason}, tagPairs...)...)
+	return fmt.Errorf("%w: API key is %s", auth.ErrInvalidToken, reason)
+}
+
+// lookup returns the key value, from the cache when it was looked up recently.
+func (a *Authenticator) lookup(r *http.Request, value string) (*Key, error) {
+	hash := Hash(value)
+	cacheKey := string(hash)
+	now := a.now()
+
+	a.mu.Lock()
+	entry, ok := a.cache[cacheKey]
+	a.mu.Unlock()
+	if ok && now.Before(entry.expiresAt) {
+		return entry.key, nil
+	}
+
+	key, err := a.store.FindByHash(r.Context(), hash)
+	if err != nil {
+		return nil, err //nolint:wrapcheck // the store describes the failure
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if len(a.cache) >= maxCacheSize {
+		a.evict(now)
+	}
+	a.cache[cacheKey] = cacheEntry{key: key, expiresAt: now.Add(a.cacheTTL)}
+	return key, nil
+}
+
+// evict removes expired entries from the cache, or every entry when none have
+// expired. It must be called with mu held.
+func (a *Authenticator) evict(now time.Time) {
+	for cacheKey, entry := range a.cache {
+		if !now.Before(entry.expiresAt) {
+			delete(a.cache, cacheKey)
+		}
+	}
+	if len(a.cache) >= maxCacheSize {
+		a.cache = map[string]cacheEntry{}
+	}
+}
+
+// markUsed records the use of the key identified by id, at most once per
+// markUsedInterval, so that busy keys do not write on every request.
+func (a *Authenticator) markUsed(r *http.Request, id uuid.UUID, now time.Time) {
+	a.mu.Lock()
+	last, ok := a.markedAt[id]
+	due := !ok || now.Sub(last) >= markUsedInterval
+	if due {
+		a.markedAt[id] = now
+	}
+	a.mu.Unlock()
+
+	if !due {
+		return
+	}
+	if err := a.store.MarkUsed(r.Context(), id, now); err != nil {
+		a.logger.Warn("failed to record use of API key", zap.Stringer("key_id", id), zap.Error(err))
+	}
+}
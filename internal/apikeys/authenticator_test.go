@@ -0,0 +1,223 @@
+package apikeys_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/deliveroo/bnt-internal-test-go/internal/apikeys"
+	"github.com/deliveroo/bnt-internal-test-go/internal/auth"
+	"github.com/deliveroo/bnt-internal-test-go/internal/metricstest"
+)
+
+// stubStore keeps keys in memory, counting their lookups and uses, and fails
+// lookups on demand.
+type stubStore struct {
+	mu      sync.Mutex
+	keys    map[string]apikeys.Key
+	lookups int
+	used    int
+	err     error
+}
+
+func newStubStore() *stubStore {
+	return &stubStore{keys: map[string]apikeys.Key{}}
+}
+
+func (s *stubStore) Create(_ context.Context, key apikeys.Key, hash []byte) (apikeys.Key, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.keys[string(hash)] = key
+	return key, nil
+}
+
+func (s *stubStore) FindByHash(_ context.Context, hash []byte) (*apikeys.Key, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.lookups++
+	if s.err != nil {
+		return nil, s.err
+	}
+	key, ok := s.keys[string(hash)]
+	if !ok {
+		return nil, nil
+	}
+	return &key, nil
+}
+
+func (s *stubStore) List(context.Context) ([]apikeys.Key, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	keys := make([]apikeys.Key, 0, len(s.keys))
+	for _, key := range s.keys {
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
+
+func (s *stubStore) Revoke(_ context.Context, id uuid.UUID) error {
+	return s.update(id, func(key *apikeys.Key) {
+		now := time.Now()
+		key.RevokedAt = &now
+	})
+}
+
+func (s *stubStore) MarkUsed(_ context.Context, id uuid.UUID, at time.Time) error {
+	s.mu.Lock()
+	s.used++
+	s.mu.Unlock()
+	return s.update(id, func(key *apikeys.Key) { key.LastUsedAt = &at })
+}
+
+func (s *stubStore) update(id uuid.UUID, update func(*apikeys.Key)) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for hash, key := range s.keys {
+		if key.ID == id {
+			update(&key)
+			s.keys[hash] = key
+			return nil
+		}
+	}
+	return apikeys.ErrNotFound
+}
+
+func authenticate(a *apikeys.Authenticator, key string) (*auth.Principal, error) {
+	req := httptest.NewRequest(http.MethodGet, "/orders", nil)
+	if key != "" {
+		req.Header.Set(apikeys.Header, key)
+	}
+	return a.Authenticate(req)
+}
+
+func TestAuthenticator(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+	clock := func() time.Time { return now }
+
+	newAuthenticator := func(t *testing.T) (*apikeys.Authenticator, *stubStore, *metricstest.Recorder) {
+		t.Helper()
+
+		store := newStubStore()
+		metrics := metricstest.NewRecorder()
+		return apikeys.NewAuthenticator(store, metrics, apikeys.WithClock(clock)), store, metrics
+	}
+
+	t.Run("authenticates a key as its owner with its scopes and roles", func(t *testing.T) {
+		a, store, metrics := newAuthenticator(t)
+		expiresAt := now.Add(time.Hour)
+		key, minted, _ := apikeys.Mint(ctx, store, apikeys.Key{Owner: "partner", Scopes: []string{"orders:read"}, Roles: []string{"service"}, ExpiresAt: &expiresAt})
+
+		principal, err := authenticate(a, key)
+		assert.Nil(t, err)
+		if assert.NotNil(t, principal) {
+			assert.Equal(t, apikeys.SubjectPrefix+minted.ID.String(), principal.Subject)
+			assert.Equal(t, "partner", principal.ClientID)
+			assert.Equal(t, []string{"orders:read"}, principal.Scopes)
+			assert.Equal(t, []string{"service"}, principal.Roles)
+			assert.Equal(t, expiresAt, principal.ExpiresAt)
+		}
+		assert.Equal(t, []string{"key_id", minted.ID.String(), "owner", "partner"}, metrics.Tags("apikeys.requests"))
+	})
+
+	t.Run("returns no credentials without a key", func(t *testing.T) {
+		a, _, _ := newAuthenticator(t)
+
+		_, err := authenticate(a, "")
+		assert.ErrorIs(t, err, auth.ErrNoCredentials)
+	})
+
+	t.Run("rejects malformed keys without looking them up", func(t *testing.T) {
+		a, store, metrics := newAuthenticator(t)
+
+		_, err := authenticate(a, "not-a-key")
+		assert.ErrorIs(t, err, auth.ErrInvalidToken)
+		assert.Equal(t, 0, store.lookups)
+		assert.Equal(t, []string{"reason", "malformed"}, metrics.Tags("apikeys.rejected"))
+	})
+
+	t.Run("rejects unknown keys", func(t *testing.T) {
+		a, _, metrics := newAuthenticator(t)
+
+		_, err := authenticate(a, "bntk_00000000_unknown")
+		assert.ErrorIs(t, err, auth.ErrInvalidToken)
+		assert.Equal(t, []string{"reason", "unknown"}, metrics.Tags("apikeys.rejected"))
+	})
+
+	t.Run("rejects expired keys", func(t *testing.T) {
+		a, store, metrics := newAuthenticator(t)
+		expiresAt := now.Add(-time.Second)
+		key, minted, _ := apikeys.Mint(ctx, store, apikeys.Key{Owner: "partner", ExpiresAt: &expiresAt})
+
+		_, err := authenticate(a, key)
+		assert.ErrorIs(t, err, auth.ErrInvalidToken)
+		assert.Equal(t, []string{"reason", "expired", "key_id", minted.ID.String(), "owner", "partner"}, metrics.Tags("apikeys.rejected"))
+	})
+
+	t.Run("rejects revoked keys once their cache entry expires", func(t *testing.T) {
+		store := newStubStore()
+		current := now
+		a := apikeys.NewAuthenticator(store, metricstest.NewRecorder(), apikeys.WithCacheTTL(time.Second), apikeys.WithClock(func() time.Time { return current }))
+		key, minted, _ := apikeys.Mint(ctx, store, apikeys.Key{Owner: "partner"})
+
+		_, err := authenticate(a, key)
+		assert.Nil(t, err)
+		assert.Nil(t, store.Revoke(ctx, minted.ID))
+
+		_, err = authenticate(a, key)
+		assert.Nil(t, err)
+
+		current = current.Add(time.Second)
+		_, err = authenticate(a, key)
+		assert.ErrorIs(t, err, auth.ErrInvalidToken)
+	})
+
+	t.Run("caches keys, including unknown ones", func(t *testing.T) {
+		a, store, _ := newAuthenticator(t)
+		key, _, _ := apikeys.Mint(ctx, store, apikeys.Key{Owner: "partner"})
+
+		for i := 0; i < 3; i++ {
+			_, _ = authenticate(a, key)
+			_, _ = authenticate(a, "bntk_00000000_unknown")
+		}
+		assert.Equal(t, 2, store.lookups)
+	})
+
+	t.Run("records the use of a key at most once a minute", func(t *testing.T) {
+		store := newStubStore()
+		current := now
+		a := apikeys.NewAuthenticator(store, metricstest.NewRecorder(), apikeys.WithClock(func() time.Time { return current }))
+		key, minted, _ := apikeys.Mint(ctx, store, apikeys.Key{Owner: "partner"})
+
+		_, _ = authenticate(a, key)
+		_, _ = authenticate(a, key)
+		assert.Equal(t, 1, store.used)
+
+		current = current.Add(time.Minute)
+		_, _ = authenticate(a, key)
+		assert.Equal(t, 2, store.used)
+
+		keys, _ := store.List(ctx)
+		if assert.Len(t, keys, 1) && assert.NotNil(t, keys[0].LastUsedAt) {
+			assert.Equal(t, minted.ID, keys[0].ID)
+			assert.Equal(t, current, *keys[0].LastUsedAt)
+		}
+	})
+
+	t.Run("returns errors from the store", func(t *testing.T) {
+		a, store, metrics := newAuthenticator(t)
+		store.err = errors.New("database is down")
+
+		_, err := authenticate(a, "bntk_00000000_key")
+		assert.ErrorIs(t, err, store.err)
+		assert.NotErrorIs(t, err, auth.ErrInvalidToken)
+		assert.Equal(t, 1, metrics.Calls("apikeys.errors"))
+	})
+}
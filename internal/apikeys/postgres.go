@@ -0,0 +1,109 @@
+package apikeys
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/deliveroo/bnt-internal-test-go/internal/database/dberrors"
+)
+
+const keyColumns = `id, prefix, owner, scopes, roles, expires_at, last_used_at, revoked_at, created_at`
+
+// PostgresStore is a Store in the api_keys table.
+type PostgresStore struct {
+	pool *pgxpool.Pool
+}
+
+var _ Store = (*PostgresStore)(nil)
+
+// NewPostgresStore returns a Store in the database of pool, which must be
+// writable.
+func NewPostgresStore(pool *pgxpool.Pool) *PostgresStore {
+	return &PostgresStore{pool: pool}
+}
+
+// Create stores key with hash.
+func (s *PostgresStore) Create(ctx context.Context, key Key, hash []byte) (Key, error) {
+	row := s.pool.QueryRow(ctx, `
+		INSERT INTO api_keys (id, prefix, hash, owner, scopes, roles, expires_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		RETURNING `+keyColumns,
+		key.ID, key.Prefix, hash, key.Owner, key.Scopes, key.Roles, key.ExpiresAt,
+	)
+	created, err := scanKey(row)
+	if err != nil {
+		return Key{}, fmt.Errorf("failed to insert API key: %w", dberrors.Map(err))
+	}
+	return created, nil
+}
+
+// FindByHash returns the key whose hash is hash, or nil when there is none.
+func (s *PostgresStore) FindByHash(ctx context.Context, hash []byte) (*Key, error) {
+	row := s.pool.QueryRow(ctx, `SELECT `+keyColumns+` FROM api_keys WHERE hash = $1`, hash)
+	key, err := scanKey(row)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to find API key: %w", dberrors.Map(err))
+	}
+	return &key, nil
+}
+
+// List returns every key, newest first.
+func (s *PostgresStore) List(ctx context.Context) ([]Key, error) {
+	rows, err := s.pool.Query(ctx, `SELECT `+keyColumns+` FROM api_keys ORDER BY created_at DESC, id`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list API keys: %w", dberrors.Map(err))
+	}
+	keys, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (Key, error) {
+		return scanKey(row)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list API keys: %w", dberrors.Map(err))
+	}
+	return keys, nil
+}
+
+// Revoke revokes the key identified by id. Revoking a revoked key keeps the
+// time it was first revoked.
+func (s *PostgresStore) Revoke(ctx context.Context, id uuid.UUID) error {
+	tag, err := s.pool.Exec(ctx, `
+		UPDATE api_keys SET revoked_at = coalesce(revoked_at, now())
+		WHERE id = $1`,
+		id,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to revoke API key: %w", dberrors.Map(err))
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// MarkUsed records that the key identified by id was used at, unless it has
+// been recorded as used since.
+func (s *PostgresStore) MarkUsed(ctx context.Context, id uuid.UUID, at time.Time) error {
+	_, err := s.pool.Exec(ctx, `
+		UPDATE api_keys SET last_used_at = $2
+		WHERE id = $1 AND (last_used_at IS NULL OR last_used_at < $2)`,
+		id, at,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to record use of API key: %w", dberrors.Map(err))
+	}
+	return nil
+}
+
+func scanKey(row pgx.Row) (Key, error) {
+	var key Key
+	err := row.Scan(&key.ID, &key.Prefix, &key.Owner, &key.Scopes, &key.Roles, &key.ExpiresAt, &key.LastUsedAt, &key.RevokedAt, &key.CreatedAt)
+	return key, err //nolint:wrapcheck // wrapped by the caller
+}
@@ -0,0 +1,117 @@
+package apikeys_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/deliveroo/bnt-internal-test-go/internal/apikeys"
+	"github.com/deliveroo/bnt-internal-test-go/internal/database/databasetest"
+)
+
+func TestPostgresStore(t *testing.T) {
+	ctx := context.Background()
+	expiresAt := time.Now().Add(time.Hour).Truncate(time.Second)
+	newStore := func(t *testing.T) *apikeys.PostgresStore {
+		t.Helper()
+		return apikeys.NewPostgresStore(databasetest.NewPool(t))
+	}
+
+	t.Run("Mint stores a key found by its hash", func(t *testing.T) {
+		store := newStore(t)
+
+		plaintext, minted, err := apikeys.Mint(ctx, store, apikeys.Key{Owner: "partner", Scopes: []string{"orders:read"}, Roles: []string{"service"}, ExpiresAt: &expiresAt})
+		assert.Nil(t, err)
+
+		found, err := store.FindByHash(ctx, apikeys.Hash(plaintext))
+		if assert.Nil(t, err) && assert.NotNil(t, found) {
+			assert.Equal(t, minted.ID, found.ID)
+			assert.Equal(t, minted.Prefix, found.Prefix)
+			assert.Equal(t, "partner", found.Owner)
+			assert.Equal(t, []string{"orders:read"}, found.Scopes)
+			assert.Equal(t, []string{"service"}, found.Roles)
+			if assert.NotNil(t, found.ExpiresAt) {
+				assert.True(t, expiresAt.Equal(*found.ExpiresAt))
+			}
+			assert.Nil(t, found.LastUsedAt)
+			assert.Nil(t, found.RevokedAt)
+			assert.False(t, found.CreatedAt.IsZero())
+		}
+	})
+
+	t.Run("Mint stores a key without scopes, roles or expiry", func(t *testing.T) {
+		store := newStore(t)
+
+		plaintext, _, err := apikeys.Mint(ctx, store, apikeys.Key{Owner: "tool"})
+		assert.Nil(t, err)
+
+		found, err := store.FindByHash(ctx, apikeys.Hash(plaintext))
+		if assert.Nil(t, err) && assert.NotNil(t, found) {
+			assert.Empty(t, found.Scopes)
+			assert.Empty(t, found.Roles)
+			assert.Nil(t, found.ExpiresAt)
+		}
+	})
+
+	t.Run("FindByHash returns nil for unknown keys", func(t *testing.T) {
+		store := newStore(t)
+
+		found, err := store.FindByHash(ctx, apikeys.Hash("bntk_unknown"))
+		assert.Nil(t, err)
+		assert.Nil(t, found)
+	})
+
+	t.Run("List returns every key, newest first", func(t *testing.T) {
+		store := newStore(t)
+		_, first, _ := apikeys.Mint(ctx, store, apikeys.Key{Owner: "first"})
+		time.Sleep(10 * time.Millisecond)
+		_, second, _ := apikeys.Mint(ctx, store, apikeys.Key{Owner: "second"})
+
+		keys, err := store.List(ctx)
+		assert.Nil(t, err)
+		if assert.Len(t, keys, 2) {
+			assert.Equal(t, second.ID, keys[0].ID)
+			assert.Equal(t, first.ID, keys[1].ID)
+		}
+	})
+
+	t.Run("Revoke revokes a key", func(t *testing.T) {
+		store := newStore(t)
+		plaintext, minted, _ := apikeys.Mint(ctx, store, apikeys.Key{Owner: "partner"})
+
+		assert.Nil(t, store.Revoke(ctx, minted.ID))
+
+		found, _ := store.FindByHash(ctx, apikeys.Hash(plaintext))
+		if assert.NotNil(t, found) && assert.NotNil(t, found.RevokedAt) {
+			assert.False(t, found.Active(time.Now()))
+
+			revokedAt := *found.RevokedAt
+			assert.Nil(t, store.Revoke(ctx, minted.ID))
+			found, _ = store.FindByHash(ctx, apikeys.Hash(plaintext))
+			assert.True(t, revokedAt.Equal(*found.RevokedAt))
+		}
+	})
+
+	t.Run("Revoke rejects unknown keys", func(t *testing.T) {
+		store := newStore(t)
+
+		assert.ErrorIs(t, store.Revoke(ctx, uuid.New()), apikeys.ErrNotFound)
+	})
+
+	t.Run("MarkUsed records the latest use", func(t *testing.T) {
+		store := newStore(t)
+		plaintext, minted, _ := apikeys.Mint(ctx, store, apikeys.Key{Owner: "partner"})
+		usedAt := time.Now().Truncate(time.Second)
+
+		assert.Nil(t, store.MarkUsed(ctx, minted.ID, usedAt))
+		assert.Nil(t, store.MarkUsed(ctx, minted.ID, usedAt.Add(-time.Minute)))
+
+		found, _ := store.FindByHash(ctx, apikeys.Hash(plaintext))
+		if assert.NotNil(t, found) && assert.NotNil(t, found.LastUsedAt) {
+			assert.True(t, usedAt.Equal(*found.LastUsedAt))
+		}
+	})
+}
@@ -0,0 +1,131 @@
+// Package authtest signs tokens with local keys, so that authentication can be
+// tested without an identity provider.
+package authtest
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+
+	"github.com/deliveroo/bnt-internal-test-go/internal/auth"
+)
+
+const (
+	// Issuer is the issuer of tokens signed by a Signer, unless overridden.
+	Issuer = "https://identity.test"
+	// Audience is the audience of tokens signed by a Signer, unless
+	// overridden.
+	Audience = "bnt-internal-test-go"
+)
+
+// Signer signs tokens with a key generated for a test. It is also the
+// auth.Keys holding its public key.
+type Signer struct {
+	// KeyID identifies the key in tokens and the key set.
+	KeyID  string
+	method jwt.SigningMethod
+	key    crypto.Signer
+}
+
+var _ auth.Keys = (*Signer)(nil)
+
+// NewRSASigner returns a Signer of RS256 tokens.
+func NewRSASigner(tb testing.TB) *Signer {
+	tb.Helper()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		tb.Fatalf("failed to generate RSA key: %s", err)
+	}
+	return &Signer{KeyID: "rsa-test", method: jwt.SigningMethodRS256, key: key}
+}
+
+// NewECSigner returns a Signer of ES256 tokens.
+func NewECSigner(tb testing.TB) *Signer {
+	tb.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		tb.Fatalf("failed to generate EC key: %s", err)
+	}
+	return &Signer{KeyID: "ec-test", method: jwt.SigningMethodES256, key: key}
+}
+
+// Verifier returns a Verifier of the tokens signed by s with the default
+// issuer and audience.
+func (s *Signer) Verifier(opts ...auth.VerifierOption) *auth.Verifier {
+	return auth.NewVerifier(s, Issuer, Audience, opts...)
+}
+
+// Keyfunc returns the public key of s, if token was signed with its key ID.
+func (s *Signer) Keyfunc(token *jwt.Token) (interface{}, error) {
+	if kid := token.Header["kid"]; kid != s.KeyID {
+		return nil, fmt.Errorf("unknown key %v", kid)
+	}
+	return s.key.Public(), nil
+}
+
+// Claims returns claims for a token granted scopes, valid for an hour from
+// now, which a Verifier from s accepts.
+func Claims(scopes ...string) map[string]interface{} {
+	now := time.Now()
+	claims := map[string]interface{}{
+		"iss": Issuer,
+		"sub": "test-service",
+		"aud": Audience,
+		"iat": now.Unix(),
+		"exp": now.Add(time.Hour).Unix(),
+	}
+	if len(scopes) > 0 {
+		claims["scope"] = strings.Join(scopes, " ")
+	}
+	return claims
+}
+
+// Token returns a token with claims, signed by s.
+func (s *Signer) Token(tb testing.TB, claims map[string]interface{}) string {
+	tb.Helper()
+
+	token := jwt.NewWithClaims(s.method, jwt.MapClaims(claims))
+	token.Header["kid"] = s.KeyID
+	signed, err := token.SignedString(s.key)
+	if err != nil {
+		tb.Fatalf("failed to sign token: %s", err)
+	}
+	return signed
+}
+
+// JWKS returns the key set holding the public key of s.
+func (s *Signer) JWKS(tb testing.TB) []byte {
+	tb.Helper()
+
+	key := map[string]string{"kid": s.KeyID, "use": "sig", "alg": s.method.Alg()}
+	switch public := s.key.Public().(type) {
+	case *rsa.PublicKey:
+		key["kty"] = "RSA"
+		key["n"] = base64.RawURLEncoding.EncodeToString(public.N.Bytes())
+		key["e"] = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(public.E)).Bytes())
+	case *ecdsa.PublicKey:
+		key["kty"] = "EC"
+		key["crv"] = "P-256"
+		key["x"] = base64.RawURLEncoding.EncodeToString(public.X.FillBytes(make([]byte, 32)))
+		key["y"] = base64.RawURLEncoding.EncodeToString(public.Y.FillBytes(make([]byte, 32)))
+	}
+
+	data, err := json.Marshal(map[string]interface{}{"keys": []interface{}{key}})
+	if err != nil {
+		tb.Fatalf("failed to encode JWKS: %s", err)
+	}
+	return data
+}
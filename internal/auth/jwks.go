@@ -0,0 +1,82 @@
+package auth
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/MicahParks/keyfunc/v2"
+	"go.uber.org/zap"
+)
+
+// minRefreshInterval is the least time between loads of the key set prompted
+// by tokens signed with an unknown key, so that forged key IDs cannot flood
+// the identity provider.
+const minRefreshInterval = time.Minute
+
+// KeySetOption configures the loading of a key set.
+type KeySetOption func(*keyfunc.Options)
+
+// WithHTTPClient sets the client used to load key sets from URLs. Defaults to
+// http.DefaultClient.
+func WithHTTPClient(client *http.Client) KeySetOption {
+	return func(o *keyfunc.Options) {
+		o.Client = client
+	}
+}
+
+// WithLogger sets the logger for failures to reload the key set. Defaults to a
+// no-op logger.
+func WithLogger(logger *zap.Logger) KeySetOption {
+	return func(o *keyfunc.Options) {
+		o.RefreshErrorHandler = func(err error) {
+			logger.Warn("failed to refresh JWKS", zap.Error(err))
+		}
+	}
+}
+
+// WithRefreshInterval sets how often a key set loaded from a URL is reloaded,
+// to pick up rotated keys. Defaults to an hour.
+func WithRefreshInterval(interval time.Duration) KeySetOption {
+	return func(o *keyfunc.Options) {
+		o.RefreshInterval = interval
+	}
+}
+
+// LoadKeySet loads the JSON Web Key Set at source: an http(s) URL, or the path
+// of a file. Sets loaded from URLs are reloaded in the background until ctx is
+// done, and when asked for a key they do not hold, at most once a minute.
+// Files are read once.
+func LoadKeySet(ctx context.Context, source string, opts ...KeySetOption) (Keys, error) {
+	if !strings.HasPrefix(source, "http://") && !strings.HasPrefix(source, "https://") {
+		data, err := os.ReadFile(source)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read JWKS from %s: %w", source, err)
+		}
+		keys, err := keyfunc.NewJSON(data)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse JWKS from %s: %w", source, err)
+		}
+		return keys, nil
+	}
+
+	options := keyfunc.Options{
+		Ctx:               ctx,
+		Client:            http.DefaultClient,
+		RefreshInterval:   time.Hour,
+		RefreshRateLimit:  minRefreshInterval,
+		RefreshUnknownKID: true,
+	}
+	for _, opt := range opts {
+		opt(&options)
+	}
+
+	keys, err := keyfunc.Get(source, options)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load JWKS from %s: %w", source, err)
+	}
+	return keys, nil
+}
@@ -0,0 +1,120 @@
+package auth_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/deliveroo/bnt-internal-test-go/internal/auth"
+	"github.com/deliveroo/bnt-internal-test-go/internal/auth/authtest"
+)
+
+// jwksServer serves the key set of whichever signer is current, counting the
+// requests for it.
+type jwksServer struct {
+	*httptest.Server
+	mu       sync.Mutex
+	signer   *authtest.Signer
+	requests atomic.Int32
+}
+
+func newJWKSServer(t *testing.T, signer *authtest.Signer) *jwksServer {
+	s := &jwksServer{signer: signer}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.requests.Add(1)
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		_, _ = w.Write(s.signer.JWKS(t))
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+func (s *jwksServer) rotate(signer *authtest.Signer) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.signer = signer
+}
+
+func TestLoadKeySet(t *testing.T) {
+	rsaSigner := authtest.NewRSASigner(t)
+	ecSigner := authtest.NewECSigner(t)
+	load := func(t *testing.T, source string, opts ...auth.KeySetOption) *auth.Verifier {
+		t.Helper()
+
+		ctx, stop := context.WithCancel(context.Background())
+		t.Cleanup(stop)
+		keys, err := auth.LoadKeySet(ctx, source, opts...)
+		if !assert.Nil(t, err) {
+			t.FailNow()
+		}
+		return auth.NewVerifier(keys, authtest.Issuer, authtest.Audience)
+	}
+
+	t.Run("loads keys from a file", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "jwks.json")
+		assert.Nil(t, os.WriteFile(path, ecSigner.JWKS(t), 0o600))
+
+		_, err := load(t, path).Verify(ecSigner.Token(t, authtest.Claims()))
+		assert.Nil(t, err)
+	})
+
+	t.Run("loads keys from a URL", func(t *testing.T) {
+		server := newJWKSServer(t, rsaSigner)
+
+		_, err := load(t, server.URL).Verify(rsaSigner.Token(t, authtest.Claims()))
+		assert.Nil(t, err)
+		assert.Equal(t, int32(1), server.requests.Load())
+	})
+
+	t.Run("does not reload for unknown keys more than once a minute", func(t *testing.T) {
+		server := newJWKSServer(t, rsaSigner)
+		verifier := load(t, server.URL)
+		forged := authtest.NewRSASigner(t)
+		forged.KeyID = "forged"
+
+		for i := 0; i < 3; i++ {
+			_, err := verifier.Verify(forged.Token(t, authtest.Claims()))
+			assert.ErrorIs(t, err, auth.ErrInvalidToken)
+		}
+		assert.LessOrEqual(t, server.requests.Load(), int32(2))
+	})
+
+	t.Run("picks up rotated keys on refresh", func(t *testing.T) {
+		server := newJWKSServer(t, rsaSigner)
+		verifier := load(t, server.URL, auth.WithRefreshInterval(10*time.Millisecond))
+
+		server.rotate(ecSigner)
+		token := ecSigner.Token(t, authtest.Claims())
+		assert.Eventually(t, func() bool {
+			_, err := verifier.Verify(token)
+			return err == nil
+		}, time.Second, 10*time.Millisecond)
+	})
+
+	t.Run("fails to load a set which cannot be read", func(t *testing.T) {
+		server := httptest.NewServer(http.NotFoundHandler())
+		defer server.Close()
+
+		for _, source := range []string{server.URL, filepath.Join(t.TempDir(), "missing.json")} {
+			_, err := auth.LoadKeySet(context.Background(), source)
+			assert.NotNil(t, err, source)
+		}
+	})
+
+	t.Run("does not trust keys which cannot sign tokens", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "jwks.json")
+		assert.Nil(t, os.WriteFile(path, []byte(`{"keys":[{"kty":"oct","kid":"rsa-test","k":"c2VjcmV0"}]}`), 0o600))
+
+		_, err := load(t, path).Verify(rsaSigner.Token(t, authtest.Claims()))
+		assert.ErrorIs(t, err, auth.ErrInvalidToken)
+	})
+}
@@ -0,0 +1,107 @@
+// Package auth authenticates requests from other services, which carry a JWT
+// issued by the identity provider as a bearer token.
+//
+// Tokens are verified with golang-jwt against the provider's public keys,
+// published as a JSON Web Key Set (JWKS), and must be signed with RS256 or
+// ES256. The verified Principal is put in the request context, and routes can
+// require it to hold scopes.
+package auth
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// ErrInvalidToken is returned for tokens which cannot be trusted, wrapped with
+// the reason.
+var ErrInvalidToken = errors.New("invalid token")
+
+// Keys finds the public key which signed a token. It is satisfied by the key
+// sets returned by LoadKeySet.
+type Keys interface {
+	// Keyfunc returns the key which signed token, identified by its kid
+	// header.
+	Keyfunc(token *jwt.Token) (interface{}, error)
+}
+
+// Verifier verifies tokens, checking their signature, issuer, audience and
+// lifetime.
+type Verifier struct {
+	keys     Keys
+	issuer   string
+	audience string
+	leeway   time.Duration
+	now      func() time.Time
+	parser   *jwt.Parser
+}
+
+// VerifierOption configures a Verifier.
+type VerifierOption func(*Verifier)
+
+// WithLeeway sets how far the clocks of the issuer and the service may
+// disagree, when checking the lifetime of tokens. Defaults to 30s.
+func WithLeeway(leeway time.Duration) VerifierOption {
+	return func(v *Verifier) {
+		v.leeway = leeway
+	}
+}
+
+// WithClock sets the source of the current time. Defaults to time.Now.
+func WithClock(now func() time.Time) VerifierOption {
+	return func(v *Verifier) {
+		v.now = now
+	}
+}
+
+// NewVerifier returns a Verifier of tokens signed by keys, which must be issued
+// by issuer for audience.
+func NewVerifier(keys Keys, issuer, audience string, opts ...VerifierOption) *Verifier {
+	v := &Verifier{
+		keys:     keys,
+		issuer:   issuer,
+		audience: audience,
+		leeway:   30 * time.Second,
+		now:      time.Now,
+	}
+	for _, opt := range opts {
+		opt(v)
+	}
+
+	// Only asymmetric algorithms are accepted, so that a public key can never
+	// be used as a shared secret.
+	v.parser = jwt.NewParser(
+		jwt.WithValidMethods([]string{jwt.SigningMethodRS256.Alg(), jwt.SigningMethodES256.Alg()}),
+		jwt.WithIssuer(v.issuer),
+		jwt.WithAudience(v.audience),
+		jwt.WithExpirationRequired(),
+		jwt.WithLeeway(v.leeway),
+		jwt.WithTimeFunc(v.now),
+	)
+	return v
+}
+
+// Claims are the claims of a token which are checked or kept in its Principal.
+type Claims struct {
+	jwt.RegisteredClaims
+	ClientID string `json:"client_id,omitempty"`
+	// Scope lists the scopes granted to the token, separated by spaces.
+	Scope string `json:"scope,omitempty"`
+	// Roles lists the roles of the service, which authorization policies
+	// refer to.
+	Roles []string `json:"roles,omitempty"`
+	// RestaurantID is the restaurant a restaurant's token acts for.
+	RestaurantID string `json:"restaurant_id,omitempty"`
+}
+
+// Verify returns the principal authenticated by token. Errors for tokens which
+// cannot be trusted wrap ErrInvalidToken.
+func (v *Verifier) Verify(token string) (*Principal, error) {
+	var claims Claims
+	if _, err := v.parser.ParseWithClaims(token, &claims, v.keys.Keyfunc); err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidToken, err)
+	}
+	return newPrincipal(claims), nil
+}
@@ -0,0 +1,98 @@
+package auth_test
+
+import (
+	"encoding/base64"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/deliveroo/bnt-internal-test-go/internal/auth"
+	"github.com/deliveroo/bnt-internal-test-go/internal/auth/authtest"
+)
+
+func TestVerifier(t *testing.T) {
+	rsaSigner := authtest.NewRSASigner(t)
+	ecSigner := authtest.NewECSigner(t)
+
+	t.Run("verifies RS256 and ES256 tokens", func(t *testing.T) {
+		for _, signer := range []*authtest.Signer{rsaSigner, ecSigner} {
+			principal, err := signer.Verifier().Verify(signer.Token(t, authtest.Claims("orders:read", "orders:write")))
+			assert.Nil(t, err, signer.KeyID)
+			if assert.NotNil(t, principal, signer.KeyID) {
+				assert.Equal(t, "test-service", principal.Subject)
+				assert.Equal(t, authtest.Issuer, principal.Issuer)
+				assert.Equal(t, []string{"orders:read", "orders:write"}, principal.Scopes)
+				assert.True(t, principal.HasScopes("orders:read"))
+				assert.False(t, principal.HasScopes("orders:read", "admin"))
+			}
+		}
+	})
+
+	t.Run("keeps the roles and restaurant of the token", func(t *testing.T) {
+		claims := authtest.Claims()
+		claims["roles"] = []string{"restaurant"}
+		claims["restaurant_id"] = "r-1"
+
+		principal, err := rsaSigner.Verifier().Verify(rsaSigner.Token(t, claims))
+		if assert.Nil(t, err) {
+			assert.Equal(t, []string{"restaurant"}, principal.Roles)
+			assert.Equal(t, map[string]string{auth.AttrRestaurantID: "r-1"}, principal.Attributes)
+		}
+	})
+
+	t.Run("accepts an audience among several", func(t *testing.T) {
+		claims := authtest.Claims()
+		claims["aud"] = []string{"other", authtest.Audience}
+
+		_, err := rsaSigner.Verifier().Verify(rsaSigner.Token(t, claims))
+		assert.Nil(t, err)
+	})
+
+	t.Run("rejects tokens which cannot be trusted", func(t *testing.T) {
+		claimsWith := func(name string, value interface{}) map[string]interface{} {
+			claims := authtest.Claims()
+			if value == nil {
+				delete(claims, name)
+			} else {
+				claims[name] = value
+			}
+			return claims
+		}
+		valid := rsaSigner.Token(t, authtest.Claims())
+		parts := strings.Split(valid, ".")
+
+		for name, token := range map[string]string{
+			"malformed":          "not-a-token",
+			"wrong issuer":       rsaSigner.Token(t, claimsWith("iss", "https://elsewhere.test")),
+			"wrong audience":     rsaSigner.Token(t, claimsWith("aud", "other")),
+			"expired":            rsaSigner.Token(t, claimsWith("exp", time.Now().Add(-time.Hour).Unix())),
+			"without expiry":     rsaSigner.Token(t, claimsWith("exp", nil)),
+			"not yet valid":      rsaSigner.Token(t, claimsWith("nbf", time.Now().Add(time.Hour).Unix())),
+			"signed by another":  authtest.NewRSASigner(t).Token(t, authtest.Claims()),
+			"with another key":   ecSigner.Token(t, authtest.Claims()),
+			"tampered":           parts[0] + "." + base64.RawURLEncoding.EncodeToString([]byte(`{"iss":"`+authtest.Issuer+`"}`)) + "." + parts[2],
+			"unsigned":           base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"none","kid":"rsa-test"}`)) + "." + parts[1] + ".",
+			"symmetric":          base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"HS256","kid":"rsa-test"}`)) + "." + parts[1] + "." + parts[2],
+			"truncated":          parts[0] + "." + parts[1],
+			"malformed claims":   parts[0] + ".e30x." + parts[2],
+			"malformed audience": rsaSigner.Token(t, claimsWith("aud", 1)),
+		} {
+			principal, err := rsaSigner.Verifier().Verify(token)
+			assert.ErrorIs(t, err, auth.ErrInvalidToken, name)
+			assert.Nil(t, principal, name)
+		}
+	})
+
+	t.Run("allows for clock skew", func(t *testing.T) {
+		token := rsaSigner.Token(t, authtest.Claims())
+		later := func() time.Time { return time.Now().Add(time.Hour + 10*time.Second) }
+
+		_, err := rsaSigner.Verifier(auth.WithClock(later)).Verify(token)
+		assert.Nil(t, err)
+
+		_, err = rsaSigner.Verifier(auth.WithClock(later), auth.WithLeeway(0)).Verify(token)
+		assert.ErrorIs(t, err, auth.ErrInvalidToken)
+	})
+}
@@ -0,0 +1,92 @@
+package auth
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/deliveroo/bnt-internal-test-go/internal/httpserver/gorillautils"
+)
+
+// ErrNoCredentials is returned by an Authenticator for requests without
+// credentials of its kind.
+var ErrNoCredentials = errors.New("no credentials")
+
+// Authenticator authenticates requests by the credentials they carry.
+type Authenticator interface {
+	// Authenticate returns the principal r is authenticated as. It returns
+	// ErrNoCredentials when r carries no credentials of its kind, and an error
+	// wrapping ErrInvalidToken when they cannot be trusted. Other errors mean
+	// the credentials could not be checked, which the Authenticator should
+	// log.
+	Authenticate(r *http.Request) (*Principal, error)
+}
+
+// Authenticate returns the principal authenticated by the bearer token in the
+// Authorization header of r.
+func (v *Verifier) Authenticate(r *http.Request) (*Principal, error) {
+	header := r.Header.Get("Authorization")
+	token := strings.TrimPrefix(header, "Bearer ")
+	if token == header || token == "" {
+		return nil, ErrNoCredentials
+	}
+	return v.Verify(token)
+}
+
+type firstOf []Authenticator
+
+// FirstOf returns an Authenticator which authenticates requests with the first
+// of authenticators whose credentials they carry.
+func FirstOf(authenticators ...Authenticator) Authenticator {
+	return firstOf(authenticators)
+}
+
+func (a firstOf) Authenticate(r *http.Request) (*Principal, error) {
+	for _, authenticator := range a {
+		principal, err := authenticator.Authenticate(r)
+		if !errors.Is(err, ErrNoCredentials) {
+			return principal, err
+		}
+	}
+	return nil, ErrNoCredentials
+}
+
+// Middleware authenticates requests with authenticator, putting the principal
+// in their context. Requests without valid credentials are rejected with 401
+// Unauthorized, and those whose principal lacks any of scopes with 403
+// Forbidden, as problem details with a WWW-Authenticate header, see RFC 6750.
+// Requests whose credentials could not be checked are rejected with 503
+// Service Unavailable.
+func Middleware(authenticator Authenticator, scopes ...string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			principal, err := authenticator.Authenticate(r)
+			switch {
+			case errors.Is(err, ErrNoCredentials):
+				w.Header().Set("WWW-Authenticate", "Bearer")
+				renderProblem(w, http.StatusUnauthorized, "Credentials are required.")
+				return
+			case errors.Is(err, ErrInvalidToken):
+				w.Header().Set("WWW-Authenticate", `Bearer error="invalid_token"`)
+				renderProblem(w, http.StatusUnauthorized, "The credentials are invalid.")
+				return
+			case err != nil:
+				renderProblem(w, http.StatusServiceUnavailable, "The credentials could not be checked.")
+				return
+			}
+
+			if !principal.HasScopes(scopes...) {
+				w.Header().Set("WWW-Authenticate", fmt.Sprintf(`Bearer error="insufficient_scope", scope=%q`, strings.Join(scopes, " ")))
+				renderProblem(w, http.StatusForbidden, "The credentials lack the scopes "+strings.Join(scopes, ", ")+".")
+				return
+			}
+
+			next.ServeHTTP(w, r.WithContext(ContextWithPrincipal(r.Context(), principal)))
+		})
+	}
+}
+
+func renderProblem(w http.ResponseWriter, status int, detail string) {
+	_ = gorillautils.RenderProblem(w, gorillautils.Problem{Status: status, Detail: detail})
+}
@@ -0,0 +1,92 @@
+package auth_test
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/deliveroo/bnt-internal-test-go/internal/auth"
+	"github.com/deliveroo/bnt-internal-test-go/internal/auth/authtest"
+)
+
+func TestMiddleware(t *testing.T) {
+	signer := authtest.NewRSASigner(t)
+
+	var principal *auth.Principal
+	handler := auth.Middleware(signer.Verifier(), "orders:read")(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		principal = auth.PrincipalFromContext(r.Context())
+	}))
+	serve := func(authorization string) *httptest.ResponseRecorder {
+		principal = nil
+		req := httptest.NewRequest(http.MethodGet, "/orders", nil)
+		if authorization != "" {
+			req.Header.Set("Authorization", authorization)
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	t.Run("puts the principal in the request context", func(t *testing.T) {
+		rec := serve("Bearer " + signer.Token(t, authtest.Claims("orders:read")))
+		assert.Equal(t, http.StatusOK, rec.Code)
+		if assert.NotNil(t, principal) {
+			assert.Equal(t, "test-service", principal.Subject)
+		}
+	})
+
+	t.Run("rejects requests without a token", func(t *testing.T) {
+		for _, authorization := range []string{"", "Basic dXNlcjpwYXNz", "Bearer "} {
+			rec := serve(authorization)
+			assert.Equal(t, http.StatusUnauthorized, rec.Code, authorization)
+			assert.Equal(t, "Bearer", rec.Header().Get("WWW-Authenticate"), authorization)
+			assert.Equal(t, "application/problem+json", rec.Header().Get("Content-Type"), authorization)
+			assert.Nil(t, principal, authorization)
+		}
+	})
+
+	t.Run("rejects invalid tokens", func(t *testing.T) {
+		rec := serve("Bearer " + authtest.NewRSASigner(t).Token(t, authtest.Claims("orders:read")))
+		assert.Equal(t, http.StatusUnauthorized, rec.Code)
+		assert.Equal(t, `Bearer error="invalid_token"`, rec.Header().Get("WWW-Authenticate"))
+		assert.Nil(t, principal)
+	})
+
+	t.Run("rejects tokens without the required scopes", func(t *testing.T) {
+		rec := serve("Bearer " + signer.Token(t, authtest.Claims("orders:write")))
+		assert.Equal(t, http.StatusForbidden, rec.Code)
+		assert.Equal(t, `Bearer error="insufficient_scope", scope="orders:read"`, rec.Header().Get("WWW-Authenticate"))
+		assert.Nil(t, principal)
+	})
+}
+
+type failingAuthenticator struct{}
+
+func (failingAuthenticator) Authenticate(*http.Request) (*auth.Principal, error) {
+	return nil, errors.New("database is down")
+}
+
+func TestFirstOf(t *testing.T) {
+	signer := authtest.NewRSASigner(t)
+	handler := auth.Middleware(auth.FirstOf(signer.Verifier(), failingAuthenticator{}))(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	serve := func(authorization string) int {
+		req := httptest.NewRequest(http.MethodGet, "/orders", nil)
+		if authorization != "" {
+			req.Header.Set("Authorization", authorization)
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	t.Run("authenticates with the first authenticator given credentials", func(t *testing.T) {
+		assert.Equal(t, http.StatusOK, serve("Bearer "+signer.Token(t, authtest.Claims())))
+	})
+
+	t.Run("responds unavailable when credentials cannot be checked", func(t *testing.T) {
+		assert.Equal(t, http.StatusServiceUnavailable, serve(""))
+	})
+}
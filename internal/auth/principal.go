@@ -0,0 +1,81 @@
+package auth
+
+import (
+	"context"
+	"strings"
+	"time"
+)
+
+// Principal is the service a request was authenticated as.
+type Principal struct {
+	// Subject identifies the service, e.g. its client ID, or the API key it
+	// used.
+	Subject string
+	// Issuer is the identity provider which issued its token.
+	Issuer string
+	// ClientID is the OAuth client the token was issued to, when it differs
+	// from Subject.
+	ClientID string
+	// Scopes are the scopes granted to the token.
+	Scopes []string
+	// Roles are the roles of the service, which authorization policies refer
+	// to.
+	Roles []string
+	// Attributes are further facts about the service, which authorization
+	// policies refer to, e.g. AttrRestaurantID.
+	Attributes map[string]string
+	// ExpiresAt is when the token expires.
+	ExpiresAt time.Time
+}
+
+// AttrRestaurantID is the attribute of principals acting for a restaurant,
+// naming the restaurant.
+const AttrRestaurantID = "restaurant_id"
+
+func newPrincipal(claims Claims) *Principal {
+	p := &Principal{
+		Subject:   claims.Subject,
+		Issuer:    claims.Issuer,
+		ClientID:  claims.ClientID,
+		Scopes:    strings.Fields(claims.Scope),
+		Roles:     claims.Roles,
+		ExpiresAt: claims.ExpiresAt.Time,
+	}
+	if claims.RestaurantID != "" {
+		p.Attributes = map[string]string{AttrRestaurantID: claims.RestaurantID}
+	}
+	return p
+}
+
+// HasScopes returns whether the principal was granted every one of scopes.
+func (p *Principal) HasScopes(scopes ...string) bool {
+	for _, scope := range scopes {
+		if !p.hasScope(scope) {
+			return false
+		}
+	}
+	return true
+}
+
+func (p *Principal) hasScope(scope string) bool {
+	for _, granted := range p.Scopes {
+		if granted == scope {
+			return true
+		}
+	}
+	return false
+}
+
+type principalKey struct{}
+
+// ContextWithPrincipal returns a copy of ctx carrying p.
+func ContextWithPrincipal(ctx context.Context, p *Principal) context.Context {
+	return context.WithValue(ctx, principalKey{}, p)
+}
+
+// PrincipalFromContext returns the principal carried by ctx, or nil when the
+// request was not authenticated.
+func PrincipalFromContext(ctx context.Context) *Principal {
+	p, _ := ctx.Value(principalKey{}).(*Principal)
+	return p
+}
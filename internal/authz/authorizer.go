@@ -0,0 +1,85 @@
+package authz
+
+import (
+	"context"
+	"strings"
+
+	"go.uber.org/zap"
+
+	"github.com/deliveroo/bnt-internal-test-go/internal/auth"
+)
+
+// Attributes of the subjects described by SubjectOf, besides those of the
+// principal itself, such as auth.AttrRestaurantID.
+const (
+	AttrRole     = "role"
+	AttrScope    = "scope"
+	AttrClientID = "client_id"
+)
+
+// SubjectOf describes p as a Subject: its roles, scopes and client ID, and
+// its attributes.
+func SubjectOf(p *auth.Principal) Subject {
+	subject := Subject{Attributes: map[string][]string{}}
+	if p == nil {
+		return subject
+	}
+
+	subject.ID = p.Subject
+	subject.Attributes[AttrRole] = p.Roles
+	subject.Attributes[AttrScope] = p.Scopes
+	if p.ClientID != "" {
+		subject.Attributes[AttrClientID] = []string{p.ClientID}
+	}
+	for name, value := range p.Attributes {
+		subject.Attributes[name] = []string{value}
+	}
+	return subject
+}
+
+// Authorizer enforces a policy on the principals of requests, auditing the
+// actions it denies.
+type Authorizer struct {
+	policy *Policy
+	logger *zap.Logger
+}
+
+// NewAuthorizer returns an Authorizer enforcing policy, which audits denials
+// to logger.
+func NewAuthorizer(policy *Policy, logger *zap.Logger) *Authorizer {
+	return &Authorizer{policy: policy, logger: logger}
+}
+
+// Allows reports whether the principal in ctx may perform action on resource.
+// Unlike Authorize, it does not log denials.
+func (a *Authorizer) Allows(ctx context.Context, action Action, resource Resource) bool {
+	return a.policy.Decide(SubjectOf(auth.PrincipalFromContext(ctx)), action, resource).Allowed
+}
+
+// Authorize returns nil when the principal in ctx may perform action on
+// resource, and a *DeniedError otherwise. Requests without a principal are
+// denied.
+func (a *Authorizer) Authorize(ctx context.Context, action Action, resource Resource) error {
+	principal := auth.PrincipalFromContext(ctx)
+	subject := SubjectOf(principal)
+
+	if a.policy.Decide(subject, action, resource).Allowed {
+		return nil
+	}
+
+	fields := []zap.Field{
+		zap.String("subject", subject.ID),
+		zap.String("action", string(action)),
+		zap.String("resource_type", resource.Type),
+		zap.String("resource_id", resource.ID),
+	}
+	if principal != nil {
+		fields = append(fields,
+			zap.String("client_id", principal.ClientID),
+			zap.String("roles", strings.Join(principal.Roles, " ")),
+		)
+	}
+	a.logger.Warn("authorization denied", fields...)
+
+	return &DeniedError{Subject: subject.ID, Action: action, Resource: resource}
+}
@@ -0,0 +1,59 @@
+package authz_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+
+	"github.com/deliveroo/bnt-internal-test-go/internal/auth"
+	"github.com/deliveroo/bnt-internal-test-go/internal/authz"
+)
+
+func TestAuthorizer(t *testing.T) {
+	policy := authz.MustNewPolicy(authz.Rule{
+		Name:     "restaurants read their own orders",
+		Resource: "order",
+		Actions:  []authz.Action{"read"},
+		Subject:  map[string][]string{authz.AttrRole: {"restaurant"}},
+		Match:    map[string]string{"restaurant_id": auth.AttrRestaurantID},
+	})
+	core, logs := observer.New(zapcore.InfoLevel)
+	authorizer := authz.NewAuthorizer(policy, zap.New(core))
+	order := authz.Resource{Type: "order", ID: "1", Attributes: map[string]string{"restaurant_id": "r-1"}}
+	as := func(restaurantID string) context.Context {
+		return auth.ContextWithPrincipal(context.Background(), &auth.Principal{
+			Subject:    "restaurant-app",
+			Roles:      []string{"restaurant"},
+			Attributes: map[string]string{auth.AttrRestaurantID: restaurantID},
+		})
+	}
+
+	t.Run("allows what the policy allows", func(t *testing.T) {
+		assert.Nil(t, authorizer.Authorize(as("r-1"), "read", order))
+	})
+
+	t.Run("denies and audits what the policy does not allow", func(t *testing.T) {
+		err := authorizer.Authorize(as("r-2"), "read", order)
+		assert.ErrorIs(t, err, authz.ErrDenied)
+		assert.EqualError(t, err, "restaurant-app may not read order 1")
+
+		entries := logs.FilterMessage("authorization denied").AllUntimed()
+		if assert.Len(t, entries, 1) {
+			fields := entries[0].ContextMap()
+			assert.Equal(t, "restaurant-app", fields["subject"])
+			assert.Equal(t, "read", fields["action"])
+			assert.Equal(t, "order", fields["resource_type"])
+			assert.Equal(t, "1", fields["resource_id"])
+			assert.Equal(t, "restaurant", fields["roles"])
+		}
+	})
+
+	t.Run("denies requests without a principal", func(t *testing.T) {
+		err := authorizer.Authorize(context.Background(), "read", order)
+		assert.ErrorIs(t, err, authz.ErrDenied)
+	})
+}
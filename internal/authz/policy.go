@@ -0,0 +1,173 @@
+// Package authz decides whether authenticated callers may perform actions on
+// resources, by declarative policies.
+//
+// A Policy is a list of rules, each allowing some actions on a type of
+// resource to subjects with certain attributes, e.g. a role, optionally only
+// when attributes of the resource match those of the subject, e.g. the
+// restaurant of an order. Anything no rule allows is denied. Domain services
+// enforce policies with an Authorizer, which audits denials.
+package authz
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Action is something done to a resource, e.g. "cancel".
+type Action string
+
+// Resource is what an action is done to.
+type Resource struct {
+	// Type is the type of the resource, e.g. "order".
+	Type string
+	// ID identifies the resource, or is empty for actions on every resource
+	// of its type, such as listing them.
+	ID string
+	// Attributes are facts about the resource which rules may match, e.g.
+	// its restaurant.
+	Attributes map[string]string
+}
+
+// Subject is who performs an action, described by attributes which may have
+// several values, e.g. their roles.
+type Subject struct {
+	// ID identifies the subject in audit logs.
+	ID         string
+	Attributes map[string][]string
+}
+
+// Rule allows some actions on a type of resource to some subjects.
+type Rule struct {
+	// Name describes the rule, and identifies it in audit logs.
+	Name string
+	// Resource is the type of resource the rule applies to.
+	Resource string
+	// Actions are the actions the rule allows.
+	Actions []Action
+	// Subject lists the attributes subjects must have, with the values
+	// allowed, e.g. {"role": {"ops"}}. Subjects must have one of the allowed
+	// values of every attribute listed.
+	Subject map[string][]string
+	// Match lists the attributes of resources which must equal an attribute
+	// of the subject, keyed by the resource attribute, e.g.
+	// {"restaurant_id": "restaurant_id"}. Resources or subjects without the
+	// attribute do not match.
+	Match map[string]string
+}
+
+// allows returns whether the rule allows subject to perform action on
+// resource.
+func (r Rule) allows(subject Subject, action Action, resource Resource) bool {
+	if r.Resource != resource.Type || !containsAction(r.Actions, action) {
+		return false
+	}
+	for name, allowed := range r.Subject {
+		if !intersects(subject.Attributes[name], allowed) {
+			return false
+		}
+	}
+	for resourceAttr, subjectAttr := range r.Match {
+		value, ok := resource.Attributes[resourceAttr]
+		if !ok || value == "" || !intersects(subject.Attributes[subjectAttr], []string{value}) {
+			return false
+		}
+	}
+	return true
+}
+
+// Policy allows the actions its rules allow, and denies everything else.
+type Policy struct {
+	rules []Rule
+}
+
+// NewPolicy returns the policy of rules. Every rule must have a name, a
+// resource type and actions, and must restrict its subjects, so that no rule
+// allows anyone to do anything by mistake.
+func NewPolicy(rules ...Rule) (*Policy, error) {
+	for i, rule := range rules {
+		switch {
+		case rule.Name == "":
+			return nil, fmt.Errorf("rule %d has no name", i)
+		case rule.Resource == "":
+			return nil, fmt.Errorf("rule %q has no resource type", rule.Name)
+		case len(rule.Actions) == 0:
+			return nil, fmt.Errorf("rule %q allows no actions", rule.Name)
+		case len(rule.Subject) == 0 && len(rule.Match) == 0:
+			return nil, fmt.Errorf("rule %q does not restrict its subjects", rule.Name)
+		}
+	}
+	return &Policy{rules: rules}, nil
+}
+
+// MustNewPolicy is like NewPolicy but panics if a rule is invalid. It
+// simplifies the initialization of global variables holding policies.
+func MustNewPolicy(rules ...Rule) *Policy {
+	policy, err := NewPolicy(rules...)
+	if err != nil {
+		panic(`authz: NewPolicy: ` + err.Error())
+	}
+	return policy
+}
+
+// Decision is the outcome of checking a policy.
+type Decision struct {
+	Allowed bool
+	// Rule names the rule which allowed the action, if any.
+	Rule string
+}
+
+// Decide returns whether the policy allows subject to perform action on
+// resource, and by which rule.
+func (p *Policy) Decide(subject Subject, action Action, resource Resource) Decision {
+	for _, rule := range p.rules {
+		if rule.allows(subject, action, resource) {
+			return Decision{Allowed: true, Rule: rule.Name}
+		}
+	}
+	return Decision{}
+}
+
+// ErrDenied is returned for actions the policy does not allow.
+var ErrDenied = errors.New("not allowed")
+
+// DeniedError describes an action which was denied. It wraps ErrDenied.
+type DeniedError struct {
+	Subject  string
+	Action   Action
+	Resource Resource
+}
+
+func (e *DeniedError) Error() string {
+	subject := e.Subject
+	if subject == "" {
+		subject = "unauthenticated caller"
+	}
+	if e.Resource.ID == "" {
+		return fmt.Sprintf("%s may not %s %ss", subject, e.Action, e.Resource.Type)
+	}
+	return fmt.Sprintf("%s may not %s %s %s", subject, e.Action, e.Resource.Type, e.Resource.ID)
+}
+
+func (e *DeniedError) Unwrap() error {
+	return ErrDenied
+}
+
+func containsAction(actions []Action, action Action) bool {
+	for _, a := range actions {
+		if a == action {
+			return true
+		}
+	}
+	return false
+}
+
+func intersects(values, allowed []string) bool {
+	for _, value := range values {
+		for _, a := range allowed {
+			if value == a {
+				return true
+			}
+		}
+	}
+	return false
+}
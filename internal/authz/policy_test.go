@@ -0,0 +1,54 @@
+package authz
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPolicy(t *testing.T) {
+	policy := MustNewPolicy(
+		Rule{
+			Name:     "ops read every widget",
+			Resource: "widget",
+			Actions:  []Action{"read"},
+			Subject:  map[string][]string{AttrRole: {"ops", "admin"}},
+		},
+		Rule{
+			Name:     "shops manage their own widgets",
+			Resource: "widget",
+			Actions:  []Action{"read", "delete"},
+			Subject:  map[string][]string{AttrRole: {"shop"}},
+			Match:    map[string]string{"shop_id": "shop_id"},
+		},
+	)
+	widget := Resource{Type: "widget", ID: "1", Attributes: map[string]string{"shop_id": "s-1"}}
+	ops := Subject{ID: "ops", Attributes: map[string][]string{AttrRole: {"ops"}}}
+	shop := func(id string) Subject {
+		return Subject{ID: "shop", Attributes: map[string][]string{AttrRole: {"shop"}, "shop_id": {id}}}
+	}
+
+	t.Run("allows subjects with an allowed attribute", func(t *testing.T) {
+		assert.Equal(t, Decision{Allowed: true, Rule: "ops read every widget"}, policy.Decide(ops, "read", widget))
+	})
+
+	t.Run("allows subjects whose attributes match the resource", func(t *testing.T) {
+		assert.True(t, policy.Decide(shop("s-1"), "delete", widget).Allowed)
+	})
+
+	t.Run("denies subjects whose attributes do not match the resource", func(t *testing.T) {
+		assert.False(t, policy.Decide(shop("s-2"), "delete", widget).Allowed)
+		assert.False(t, policy.Decide(shop("s-1"), "delete", Resource{Type: "widget", ID: "2"}).Allowed)
+	})
+
+	t.Run("denies actions no rule allows", func(t *testing.T) {
+		assert.False(t, policy.Decide(ops, "delete", widget).Allowed)
+		assert.False(t, policy.Decide(ops, "read", Resource{Type: "gadget"}).Allowed)
+		assert.False(t, policy.Decide(Subject{}, "read", widget).Allowed)
+	})
+
+	t.Run("rejects rules which do not restrict their subjects", func(t *testing.T) {
+		_, err := NewPolicy(Rule{Name: "everyone", Resource: "widget", Actions: []Action{"read"}})
+		assert.EqualError(t, err, `rule "everyone" does not restrict its subjects`)
+	})
+}
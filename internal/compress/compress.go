@@ -0,0 +1,259 @@
+// Package compress compresses HTTP responses with gzip or brotli, whichever
+// the client prefers by its Accept-Encoding header.
+//
+// Responses are buffered until they reach a minimum size, below which
+// compressing them costs more than it saves, and are sent uncompressed when
+// they end, or are flushed, before reaching it. Every response varies by
+// Accept-Encoding, so that caches keep its encodings apart.
+package compress
+
+import (
+	"bufio"
+	"compress/gzip"
+	"io"
+	"net"
+	"net/http"
+
+	"github.com/andybalholm/brotli"
+
+	"github.com/deliveroo/bnt-internal-test-go/internal/httpserver/gorillautils"
+)
+
+// Content codings of responses.
+const (
+	Brotli = "br"
+	Gzip   = "gzip"
+)
+
+const (
+	defaultMinSize = 1024
+	// defaultBrotliLevel trades compression for speed, as responses are
+	// compressed as they are served.
+	defaultBrotliLevel = 4
+)
+
+type middleware struct {
+	minSize     int
+	gzipLevel   int
+	brotliLevel int
+}
+
+// Option configures the Middleware.
+type Option func(*middleware)
+
+// WithMinSize sets the size in bytes below which responses are sent
+// uncompressed. Defaults to 1024.
+func WithMinSize(size int) Option {
+	return func(m *middleware) {
+		m.minSize = size
+	}
+}
+
+// WithGzipLevel sets the compression level of gzip, from gzip.BestSpeed to
+// gzip.BestCompression. Defaults to gzip.DefaultCompression.
+func WithGzipLevel(level int) Option {
+	return func(m *middleware) {
+		m.gzipLevel = level
+	}
+}
+
+// WithBrotliLevel sets the compression level of brotli, from
+// brotli.BestSpeed to brotli.BestCompression. Defaults to 4.
+func WithBrotliLevel(level int) Option {
+	return func(m *middleware) {
+		m.brotliLevel = level
+	}
+}
+
+// Middleware compresses the responses of clients which accept gzip or brotli,
+// preferring brotli when they accept both equally. Responses which are
+// already encoded, or smaller than the minimum size, are sent as they are.
+func Middleware(opts ...Option) func(http.Handler) http.Handler {
+	m := &middleware{
+		minSize:     defaultMinSize,
+		gzipLevel:   gzip.DefaultCompression,
+		brotliLevel: defaultBrotliLevel,
+	}
+	for _, opt := range opts {
+		opt(m)
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			m.serve(next, w, r)
+		})
+	}
+}
+
+func (m *middleware) serve(next http.Handler, w http.ResponseWriter, r *http.Request) {
+	w.Header().Add("Vary", "Accept-Encoding")
+
+	encoding := NegotiateEncoding(r)
+	if encoding == "" || r.Method == http.MethodHead {
+		next.ServeHTTP(w, r)
+		return
+	}
+
+	cw := &compressWriter{ResponseWriter: w, m: m, encoding: encoding, status: http.StatusOK}
+	defer cw.close()
+	next.ServeHTTP(cw, r)
+}
+
+// NegotiateEncoding returns the content coding of r's response which its
+// Accept-Encoding header prefers, or "" when it should not be compressed.
+func NegotiateEncoding(r *http.Request) string {
+	qualities := gorillautils.ParseQualities(r.Header.Get("Accept-Encoding"))
+
+	best, bestQ := "", 0.0
+	for _, encoding := range []string{Brotli, Gzip} {
+		q, ok := qualities[encoding]
+		if !ok {
+			q = qualities["*"]
+		}
+		if q > bestQ {
+			best, bestQ = encoding, q
+		}
+	}
+	return best
+}
+
+// compressWriter buffers the start of a response until it is known whether it
+// is large enough to compress, and compresses it from then on.
+type compressWriter struct {
+	http.ResponseWriter
+	m        *middleware
+	encoding string
+
+	status      int
+	wroteHeader bool
+	buf         []byte
+	// started is set once the headers have been sent, and encoder once the
+	// response is being compressed.
+	started bool
+	encoder io.WriteCloser
+}
+
+func (w *compressWriter) WriteHeader(status int) {
+	if w.wroteHeader || w.started {
+		return
+	}
+	// Informational responses are sent straight away, and are not the final
+	// status.
+	if status >= 100 && status < 200 {
+		w.ResponseWriter.WriteHeader(status)
+		return
+	}
+	w.status = status
+	w.wroteHeader = true
+}
+
+func (w *compressWriter) Write(p []byte) (int, error) {
+	w.wroteHeader = true
+	if w.started {
+		return w.write(p)
+	}
+
+	w.buf = append(w.buf, p...)
+	if len(w.buf) < w.m.minSize {
+		return len(p), nil
+	}
+	if err := w.start(); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// write writes p to the response, through the encoder when compressing.
+func (w *compressWriter) write(p []byte) (int, error) {
+	if w.encoder != nil {
+		return w.encoder.Write(p) //nolint:wrapcheck // passes on the error of the wrapped writer
+	}
+	return w.ResponseWriter.Write(p) //nolint:wrapcheck // passes on the error of the wrapped writer
+}
+
+// start sends the headers of the response, compressing it when the buffered
+// body has reached the minimum size, then writes the buffered body.
+func (w *compressWriter) start() error {
+	w.started = true
+
+	header := w.Header()
+	if len(w.buf) >= w.m.minSize && w.compressible(header) {
+		if header.Get("Content-Type") == "" {
+			// Sniff the type of the uncompressed body, as net/http would
+			// otherwise sniff the compressed one.
+			header.Set("Content-Type", http.DetectContentType(w.buf))
+		}
+		header.Del("Content-Length")
+		header.Set("Content-Encoding", w.encoding)
+		w.encoder = w.newEncoder()
+	}
+	w.ResponseWriter.WriteHeader(w.status)
+
+	buf := w.buf
+	w.buf = nil
+	if len(buf) == 0 {
+		return nil
+	}
+	_, err := w.write(buf)
+	return err
+}
+
+// compressible reports whether the response may be compressed, given its
+// status and header.
+func (w *compressWriter) compressible(header http.Header) bool {
+	if w.status < http.StatusOK || w.status == http.StatusNoContent || w.status == http.StatusNotModified {
+		return false
+	}
+	return header.Get("Content-Encoding") == "" && header.Get("Content-Range") == ""
+}
+
+func (w *compressWriter) newEncoder() io.WriteCloser {
+	if w.encoding == Brotli {
+		return brotli.NewWriterLevel(w.ResponseWriter, w.m.brotliLevel)
+	}
+	encoder, err := gzip.NewWriterLevel(w.ResponseWriter, w.m.gzipLevel)
+	if err != nil {
+		// Only reached with an invalid level.
+		encoder = gzip.NewWriter(w.ResponseWriter)
+	}
+	return encoder
+}
+
+// close sends what is left of the response, completing its compression.
+func (w *compressWriter) close() {
+	if !w.started {
+		if !w.wroteHeader {
+			// Nothing was written, e.g. because the handler panicked.
+			return
+		}
+		_ = w.start()
+	}
+	if w.encoder != nil {
+		_ = w.encoder.Close()
+	}
+}
+
+// Flush sends the response so far, uncompressed if it has not reached the
+// minimum size, so that streamed responses are not held back.
+func (w *compressWriter) Flush() {
+	if !w.started {
+		_ = w.start()
+	}
+	if flusher, ok := w.encoder.(interface{ Flush() error }); ok {
+		_ = flusher.Flush()
+	}
+	if flusher, ok := w.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// Hijack lets handlers take over the connection, e.g. for WebSockets, which
+// are not compressed.
+func (w *compressWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hijacker, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, http.ErrNotSupported
+	}
+	w.started = true
+	return hijacker.Hijack() //nolint:wrapcheck // passes on the error of the wrapped writer
+}
@@ -0,0 +1,136 @@
+package compress_test
+
+import (
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/andybalholm/brotli"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/deliveroo/bnt-internal-test-go/internal/compress"
+)
+
+func TestMiddleware(t *testing.T) {
+	large := strings.Repeat(`{"ID":1,"Status":"NEW"}`, 100)
+	// respond writes body in chunks, as handlers encoding JSON do.
+	respond := func(body string) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			for len(body) > 0 {
+				n := 100
+				if n > len(body) {
+					n = len(body)
+				}
+				_, _ = w.Write([]byte(body[:n]))
+				body = body[n:]
+			}
+		})
+	}
+	serve := func(handler http.Handler, acceptEncoding string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		if acceptEncoding != "" {
+			req.Header.Set("Accept-Encoding", acceptEncoding)
+		}
+		rec := httptest.NewRecorder()
+		compress.Middleware(compress.WithMinSize(1024))(handler).ServeHTTP(rec, req)
+		return rec
+	}
+
+	t.Run("compresses with gzip", func(t *testing.T) {
+		rec := serve(respond(large), "gzip")
+		assert.Equal(t, "gzip", rec.Header().Get("Content-Encoding"))
+		assert.Equal(t, "application/json", rec.Header().Get("Content-Type"))
+		assert.Less(t, rec.Body.Len(), len(large))
+
+		reader, err := gzip.NewReader(rec.Body)
+		assert.Nil(t, err)
+		body, err := io.ReadAll(reader)
+		assert.Nil(t, err)
+		assert.Equal(t, large, string(body))
+	})
+
+	t.Run("compresses with brotli", func(t *testing.T) {
+		rec := serve(respond(large), "br")
+		assert.Equal(t, "br", rec.Header().Get("Content-Encoding"))
+
+		body, err := io.ReadAll(brotli.NewReader(rec.Body))
+		assert.Nil(t, err)
+		assert.Equal(t, large, string(body))
+	})
+
+	t.Run("negotiates the encoding the client prefers", func(t *testing.T) {
+		for acceptEncoding, want := range map[string]string{
+			"gzip, br":             "br",
+			"gzip;q=1, br;q=0.5":   "gzip",
+			"*":                    "br",
+			"br;q=0, *":            "gzip",
+			"deflate":              "",
+			"gzip;q=0, br;q=0":     "",
+			"identity, gzip;q=0.1": "gzip",
+		} {
+			assert.Equal(t, want, serve(respond(large), acceptEncoding).Header().Get("Content-Encoding"), acceptEncoding)
+		}
+	})
+
+	t.Run("sends responses below the minimum size uncompressed", func(t *testing.T) {
+		rec := serve(respond(`{"ID":1}`), "gzip, br")
+		assert.Empty(t, rec.Header().Get("Content-Encoding"))
+		assert.Equal(t, `{"ID":1}`, rec.Body.String())
+	})
+
+	t.Run("sends responses uncompressed to clients which do not accept compression", func(t *testing.T) {
+		rec := serve(respond(large), "")
+		assert.Empty(t, rec.Header().Get("Content-Encoding"))
+		assert.Equal(t, large, rec.Body.String())
+	})
+
+	t.Run("varies by Accept-Encoding whether or not it compresses", func(t *testing.T) {
+		assert.Equal(t, "Accept-Encoding", serve(respond(large), "gzip").Header().Get("Vary"))
+		assert.Equal(t, "Accept-Encoding", serve(respond(`{}`), "gzip").Header().Get("Vary"))
+		assert.Equal(t, "Accept-Encoding", serve(respond(large), "").Header().Get("Vary"))
+	})
+
+	t.Run("keeps the status and drops the length of compressed responses", func(t *testing.T) {
+		rec := serve(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Length", "2300")
+			w.WriteHeader(http.StatusCreated)
+			_, _ = w.Write([]byte(large))
+		}), "gzip")
+		assert.Equal(t, http.StatusCreated, rec.Code)
+		assert.Equal(t, "gzip", rec.Header().Get("Content-Encoding"))
+		assert.Empty(t, rec.Header().Get("Content-Length"))
+	})
+
+	t.Run("sends responses which are already encoded as they are", func(t *testing.T) {
+		rec := serve(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Encoding", "br")
+			_, _ = w.Write([]byte(large))
+		}), "gzip")
+		assert.Equal(t, "br", rec.Header().Get("Content-Encoding"))
+		assert.Equal(t, large, rec.Body.String())
+	})
+
+	t.Run("sends responses without a body as they are", func(t *testing.T) {
+		rec := serve(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusNoContent)
+		}), "gzip")
+		assert.Equal(t, http.StatusNoContent, rec.Code)
+		assert.Empty(t, rec.Header().Get("Content-Encoding"))
+		assert.Zero(t, rec.Body.Len())
+	})
+
+	t.Run("sends flushed responses below the minimum size uncompressed", func(t *testing.T) {
+		rec := serve(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			_, _ = w.Write([]byte("data: 1\n\n"))
+			w.(http.Flusher).Flush()
+			_, _ = w.Write([]byte("data: 2\n\n"))
+		}), "gzip")
+		assert.True(t, rec.Flushed)
+		assert.Empty(t, rec.Header().Get("Content-Encoding"))
+		assert.Equal(t, "data: 1\n\ndata: 2\n\n", rec.Body.String())
+	})
+}
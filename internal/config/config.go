@@ -1,11 +1,12 @@
 package config
 
 import (
-	"fmt"
 	"net/url"
 	"time"
 
-	"github.com/kelseyhightower/envconfig"
+	"go.uber.org/zap/zapcore"
+
+	"github.com/deliveroo/bnt-internal-test-go/internal/rate"
 )
 
 const (
@@ -17,18 +18,22 @@ type Server struct {
 	// IdleTimeout is the maximum amount of time to wait for an open connection
 	// when processing no requests and keep-alives are enabled. If this value is
 	// 0, ReadTimeout value be used.
-	IdleTimeout time.Duration `envconfig:"HTTP_SERVER_IDLE_TIMEOUT" default:"60s"`
+	IdleTimeout time.Duration `envconfig:"HTTP_SERVER_IDLE_TIMEOUT" default:"60s" validate:"min=0s"`
 
 	// Port is the HTTP server port.
-	Port int `envconfig:"PORT" default:"3000"`
+	Port int `envconfig:"PORT" default:"3000" validate:"min=1,max=65535"`
 
 	// ReadTimeout is the maximum duration for reading the entire request,
 	// including the body.
-	ReadTimeout time.Duration `envconfig:"HTTP_SERVER_READ_TIMEOUT" default:"1s"`
+	ReadTimeout time.Duration `envconfig:"HTTP_SERVER_READ_TIMEOUT" default:"1s" validate:"min=1ms"`
 
 	// WriteTimeout is the maximum duration before timing out
 	// writes of the response.
-	WriteTimeout time.Duration `envconfig:"HTTP_SERVER_WRITE_TIMEOUT" default:"2s"`
+	WriteTimeout time.Duration `envconfig:"HTTP_SERVER_WRITE_TIMEOUT" default:"2s" validate:"min=1ms"`
+
+	// ValidateResponses logs responses which do not match the OpenAPI
+	// document. It is ignored in production.
+	ValidateResponses bool `envconfig:"HTTP_VALIDATE_RESPONSES" default:"false"`
 }
 
 // Settings holds application-specific config.
@@ -36,20 +41,105 @@ type Settings struct {
 	SpanLogging     bool `envconfig:"SPAN_LOGGING" envDefault:"false"`   // Write spans to logger, for debug purpose
 	StatsDLogging   bool `envconfig:"STATSD_LOGGING" envDefault:"false"` // Write statsd events to logger
 	SuppressLogging bool `envconfig:"SUPPRESS_LOGGING" default:"false"`  // Replaces the logger with a Noop
+
+	// LogLevel is the initial log level. When unset, development
+	// environments log at debug level and others at info level.
+	LogLevel *zapcore.Level `envconfig:"LOG_LEVEL"`
+	// LoggerLevels overrides LogLevel for named loggers, e.g.
+	// "orders:debug,httpclient:warn".
+	LoggerLevels map[string]zapcore.Level `envconfig:"LOGGER_LEVELS"`
+
+	// RuntimeFile is a YAML file of runtime settings, which is watched for
+	// changes. Runtime settings can be changed without restarting the service.
+	// A relative path is resolved against CONFIG_DIR.
+	RuntimeFile string `envconfig:"RUNTIME_SETTINGS_FILE"`
+	// RuntimePollInterval is how often RuntimeFile is checked for changes.
+	RuntimePollInterval time.Duration `envconfig:"RUNTIME_SETTINGS_POLL_INTERVAL" default:"10s" validate:"min=100ms"`
+}
+
+// Admin contains configuration for the admin endpoints.
+type Admin struct {
+	// Token is the bearer token required by the admin endpoints. The
+	// endpoints are disabled when it is empty.
+	Token Secret `envconfig:"ADMIN_TOKEN"`
+}
+
+// Auth contains configuration for authenticating requests from other
+// services, with JWTs issued by the identity provider, and from partners and
+// tools, with API keys.
+type Auth struct {
+	// JWKS is the URL or file of the identity provider's public keys. Requests
+	// are not authenticated when it is empty.
+	JWKS string `envconfig:"AUTH_JWKS" validate:"required_in=production|staging"`
+	// JWKSRefreshInterval is how often keys loaded from a URL are reloaded,
+	// to pick up rotated keys.
+	JWKSRefreshInterval time.Duration `envconfig:"AUTH_JWKS_REFRESH_INTERVAL" default:"1h" validate:"min=1m"`
+	// Issuer is the issuer tokens must be issued by.
+	Issuer string `envconfig:"AUTH_ISSUER" validate:"required_in=production|staging"`
+	// Audience is the audience tokens must be issued for.
+	Audience string `envconfig:"AUTH_AUDIENCE" default:"bnt-internal-test-go"`
+	// Leeway is how far the clocks of the identity provider and the service
+	// may disagree.
+	Leeway time.Duration `envconfig:"AUTH_LEEWAY" default:"30s" validate:"min=0s"`
+	// APIKeys enables authentication with the API keys minted by the
+	// `web apikeys` command. It needs the api_keys table, created by
+	// `web migrate`.
+	APIKeys bool `envconfig:"AUTH_API_KEYS" default:"false"`
+	// APIKeyCacheTTL is how long API keys are cached, and so how long a
+	// revoked key may still be accepted.
+	APIKeyCacheTTL time.Duration `envconfig:"AUTH_API_KEY_CACHE_TTL" default:"30s" validate:"min=0s,max=5m"`
 }
 
 // Hopper contains parameters injected from Hopper.
 type Hopper struct {
-	AppName     string `envconfig:"HOPPER_APP_NAME" default:"bnt-internal-test-go"`
-	Environment string `envconfig:"HOPPER_ENVIRONMENT" default:"development"`
-	ReleaseID   string `envconfig:"HOPPER_RELEASE_ID"`
-	ServiceName string `envconfig:"HOPPER_SERVICE_NAME"`
+	AppName     string `envconfig:"HOPPER_APP_NAME" default:"bnt-internal-test-go" validate:"required"`
+	Environment string `envconfig:"HOPPER_ENVIRONMENT" default:"development" validate:"required"`
+	ReleaseID   string `envconfig:"HOPPER_RELEASE_ID" validate:"required_in=production|staging"`
+	ServiceName string `envconfig:"HOPPER_SERVICE_NAME" validate:"required_in=production|staging"`
 }
 
-// Database contains configuration for the Postgres Database.
+// Database contains configuration for the Postgres Database. The URLs may
+// contain credentials, so are SecretURLs.
 type Database struct {
-	URL       string `envconfig:"DATABASE_URL" default:"postgres://localhost:5434/service_template_go_development?sslmode=disable"`
-	ReaderURL string `envconfig:"DATABASE_URL_READER" default:"postgres://localhost:5434/service_template_go_development?sslmode=disable"`
+	URL       SecretURL `envconfig:"DATABASE_URL" default:"postgres://localhost:5434/service_template_go_development?sslmode=disable" validate:"required,url"`
+	ReaderURL SecretURL `envconfig:"DATABASE_URL_READER" default:"postgres://localhost:5434/service_template_go_development?sslmode=disable" validate:"required,url"`
+
+	// MaxConns and MinConns bound the number of connections in each pool. Idle
+	// connections are closed down to MinConns.
+	MaxConns int32 `envconfig:"DATABASE_MAX_CONNS" default:"10" validate:"min=1"`
+	MinConns int32 `envconfig:"DATABASE_MIN_CONNS" default:"0" validate:"min=0"`
+
+	// MaxConnLifetime is how long a connection is used before it is replaced,
+	// and MaxConnIdleTime how long it may be idle before it is closed.
+	MaxConnLifetime time.Duration `envconfig:"DATABASE_MAX_CONN_LIFETIME" default:"1h" validate:"min=1s"`
+	MaxConnIdleTime time.Duration `envconfig:"DATABASE_MAX_CONN_IDLE_TIME" default:"30m" validate:"min=1s"`
+
+	// HealthCheckPeriod is how often idle connections are checked.
+	HealthCheckPeriod time.Duration `envconfig:"DATABASE_HEALTH_CHECK_PERIOD" default:"1m" validate:"min=1s"`
+
+	// ConnectTimeout is the longest to wait when opening a connection.
+	ConnectTimeout time.Duration `envconfig:"DATABASE_CONNECT_TIMEOUT" default:"5s" validate:"min=100ms"`
+
+	// StatementTimeout is the default Postgres statement_timeout, after which
+	// queries are cancelled. IdleInTransactionTimeout is the Postgres
+	// idle_in_transaction_session_timeout, after which connections left in a
+	// transaction are closed. Zero disables them.
+	StatementTimeout         time.Duration `envconfig:"DATABASE_STATEMENT_TIMEOUT" default:"30s" validate:"min=0s"`
+	IdleInTransactionTimeout time.Duration `envconfig:"DATABASE_IDLE_IN_TRANSACTION_TIMEOUT" default:"1m" validate:"min=0s"`
+
+	// ReaderMaxLag is the replication lag beyond which reads are sent to the
+	// writer instead of the reader. ReaderCheckInterval is how often the
+	// reader's health and lag are checked.
+	ReaderMaxLag        time.Duration `envconfig:"DATABASE_READER_MAX_LAG" default:"10s" validate:"min=0s"`
+	ReaderCheckInterval time.Duration `envconfig:"DATABASE_READER_CHECK_INTERVAL" default:"5s" validate:"min=100ms"`
+
+	// StatsInterval is how often the pools report the statistics which
+	// pgxv5trace, reporting every 10s, does not.
+	StatsInterval time.Duration `envconfig:"DATABASE_STATS_INTERVAL" default:"10s" validate:"min=1s"`
+
+	// MigrateOnStart applies the migrations which have not been applied yet
+	// before the server starts, for deploys which do not run `web migrate`.
+	MigrateOnStart bool `envconfig:"DATABASE_MIGRATE_ON_START" default:"false"`
 }
 
 // Datadog contains configuration for the Datadog APM.
@@ -57,50 +147,204 @@ type Datadog struct {
 	AppName     string `envconfig:"HOPPER_APP_NAME" default:"bnt-internal-test-go"`
 	ServiceName string `envconfig:"HOPPER_SERVICE_NAME" default:"app"`
 	Env         string `envconfig:"STATSD_ENV" default:"development"`
-	Host        string `envconfig:"STATSD_HOST"`
-	StatsDPort  uint   `envconfig:"STATSD_PORT"`
-	TracerPort  uint   `envconfig:"DATADOG_TRACER_PORT"`
+	Host        string `envconfig:"STATSD_HOST" validate:"required_in=production|staging"`
+	StatsDPort  uint   `envconfig:"STATSD_PORT" validate:"required_in=production|staging,max=65535"`
+	TracerPort  uint   `envconfig:"DATADOG_TRACER_PORT" validate:"required_in=production|staging,max=65535"`
 }
 
+// Determinator contains configuration for the Determinator feature flag client.
 type Determinator struct {
-	URL       *url.URL      `envconfig:"DETERMINATOR_URL"`
-	Username  string        `envconfig:"DETERMINATOR_USERNAME"`
-	Password  string        `envconfig:"DETERMINATOR_PASSWORD"`
-	CacheTTL  time.Duration `envconfig:"DETERMINATOR_CACHE_TTL"`
+	URL       *url.URL      `envconfig:"DETERMINATOR_URL" validate:"required_in=production|staging,url"`
+	Username  string        `envconfig:"DETERMINATOR_USERNAME" validate:"required_in=production|staging"`
+	Password  Secret        `envconfig:"DETERMINATOR_PASSWORD" validate:"required_in=production|staging"`
+	CacheTTL  time.Duration `envconfig:"DETERMINATOR_CACHE_TTL" validate:"min=0s"`
 	UserAgent string        `envconfig:"DETERMINATOR_USER_AGENT"`
 }
 
+// Pagination contains configuration for paginating collections.
+type Pagination struct {
+	// CursorKey signs pagination cursors, and must be shared by every instance
+	// of the service. When empty, a random key is used, so cursors only work
+	// with the instance which issued them.
+	CursorKey Secret `envconfig:"PAGINATION_CURSOR_KEY" validate:"required_in=production|staging"`
+}
+
+// Idempotency contains configuration for requests with an Idempotency-Key.
+type Idempotency struct {
+	// LockFor is how long a request holds its key, after which a retry may
+	// process it again. It should exceed the longest a request can take.
+	LockFor time.Duration `envconfig:"IDEMPOTENCY_LOCK_FOR" default:"30s" validate:"min=1s"`
+	// KeepFor is how long responses are kept to be replayed to retries.
+	KeepFor time.Duration `envconfig:"IDEMPOTENCY_KEEP_FOR" default:"24h" validate:"min=1m"`
+}
+
+// RateLimit contains configuration for limiting the rate of requests from
+// each caller, identified by their principal, API key or address.
+type RateLimit struct {
+	// Enabled enables rate limiting of the routes called by other services,
+	// partners and tools.
+	Enabled bool `envconfig:"RATE_LIMIT_ENABLED" default:"true"`
+	// Store is where requests are counted: "memory" counts in each instance,
+	// which allows each caller the limit per instance, and "postgres" counts
+	// in the database, shared by every instance.
+	Store string `envconfig:"RATE_LIMIT_STORE" default:"memory" validate:"oneof=memory|postgres"`
+	// Default is the limit of each route not listed in Routes, written as
+	// requests/period, e.g. "600/1m".
+	Default rate.Limit `envconfig:"RATE_LIMIT_DEFAULT" default:"600/1m"`
+	// Routes overrides Default for routes by name, e.g.
+	// "createOrder:60/1m,listOrders:1200/1m".
+	Routes map[string]rate.Limit `envconfig:"RATE_LIMIT_ROUTES"`
+	// TrustForwardedFor identifies callers without a principal or API key by
+	// the address the load balancer adds to X-Forwarded-For. It must only be
+	// enabled behind a load balancer which adds it.
+	TrustForwardedFor bool `envconfig:"RATE_LIMIT_TRUST_FORWARDED_FOR" default:"false"`
+}
+
+// LoadShedding contains configuration for rejecting requests beyond the
+// concurrency the service can handle, which adapts to their latency.
+type LoadShedding struct {
+	// Enabled enables load shedding of every route but health checks and the
+	// admin endpoints.
+	Enabled bool `envconfig:"LOAD_SHEDDING_ENABLED" default:"true"`
+	// InitialLimit is the number of concurrent requests allowed on start-up,
+	// which then adapts between MinLimit and MaxLimit.
+	InitialLimit int `envconfig:"LOAD_SHEDDING_INITIAL_LIMIT" default:"100" validate:"min=1"`
+	MinLimit     int `envconfig:"LOAD_SHEDDING_MIN_LIMIT" default:"10" validate:"min=1"`
+	MaxLimit     int `envconfig:"LOAD_SHEDDING_MAX_LIMIT" default:"1000" validate:"min=1"`
+	// TargetLatency is the latency beyond which requests decrease the limit.
+	// It should exceed the latency of healthy requests.
+	TargetLatency time.Duration `envconfig:"LOAD_SHEDDING_TARGET_LATENCY" default:"500ms" validate:"min=1ms"`
+	// Backoff is the factor the limit is multiplied by when it decreases.
+	Backoff float64 `envconfig:"LOAD_SHEDDING_BACKOFF" default:"0.9" validate:"min=0.1,max=0.99"`
+}
+
+// Compression contains configuration for compressing responses with gzip or
+// brotli.
+type Compression struct {
+	Enabled bool `envconfig:"COMPRESSION_ENABLED" default:"true"`
+	// MinSize is the size in bytes below which responses are sent
+	// uncompressed, as compressing them costs more than it saves.
+	MinSize int `envconfig:"COMPRESSION_MIN_SIZE" default:"1024" validate:"min=0"`
+	// GzipLevel and BrotliLevel trade the speed of compression for the size
+	// of the responses.
+	GzipLevel   int `envconfig:"COMPRESSION_GZIP_LEVEL" default:"6" validate:"min=1,max=9"`
+	BrotliLevel int `envconfig:"COMPRESSION_BROTLI_LEVEL" default:"4" validate:"min=0,max=11"`
+}
+
+// CORS contains configuration for letting browser-based tools on other
+// origins, such as back-office tools, call the service.
+type CORS struct {
+	// AllowedOrigins are the origins allowed to call the service, e.g.
+	// https://backoffice.example.com, or * for any. CORS is disabled when
+	// there are none.
+	AllowedOrigins []string `envconfig:"CORS_ALLOWED_ORIGINS"`
+	AllowedMethods []string `envconfig:"CORS_ALLOWED_METHODS" default:"GET,POST,PUT,PATCH,DELETE"`
+	// AllowedHeaders are the request headers allowed, or * for any.
+	AllowedHeaders []string `envconfig:"CORS_ALLOWED_HEADERS" default:"Authorization,Content-Type,Idempotency-Key,X-API-Key,X-Request-Timeout-Ms"`
+	// ExposedHeaders are the response headers the tools may read.
+	ExposedHeaders []string `envconfig:"CORS_EXPOSED_HEADERS" default:"Link,Location,Retry-After,RateLimit-Limit,RateLimit-Remaining,RateLimit-Reset,RateLimit-Policy"`
+	// AllowCredentials lets browsers send cookies and Authorization headers.
+	// It has no effect when any origin is allowed.
+	AllowCredentials bool `envconfig:"CORS_ALLOW_CREDENTIALS"`
+	// MaxAge is how long browsers may cache the answers to preflight
+	// requests.
+	MaxAge time.Duration `envconfig:"CORS_MAX_AGE" default:"10m" validate:"min=0s,max=24h"`
+}
+
+// SecurityHeaders contains configuration for the security headers set on
+// every response. The defaults suit production; they are relaxed for local
+// development in config/development.yaml.
+type SecurityHeaders struct {
+	// HSTSMaxAge is how long browsers should only reach the service over
+	// HTTPS. The Strict-Transport-Security header is not sent when zero.
+	HSTSMaxAge            time.Duration `envconfig:"SECURITY_HEADERS_HSTS_MAX_AGE" default:"8760h" validate:"min=0s"`
+	HSTSIncludeSubdomains bool          `envconfig:"SECURITY_HEADERS_HSTS_INCLUDE_SUBDOMAINS" default:"true"`
+	// FrameOptions is the X-Frame-Options header, which is not sent when
+	// empty.
+	FrameOptions string `envconfig:"SECURITY_HEADERS_FRAME_OPTIONS" default:"DENY" validate:"oneof=DENY|SAMEORIGIN|"`
+	// ContentSecurityPolicy is the Content-Security-Policy header, which is
+	// not sent when empty. The default forbids responses from loading any
+	// content, or being framed, as the service only serves JSON.
+	ContentSecurityPolicy string `envconfig:"SECURITY_HEADERS_CONTENT_SECURITY_POLICY" default:"default-src 'none'; frame-ancestors 'none'"`
+	ReferrerPolicy        string `envconfig:"SECURITY_HEADERS_REFERRER_POLICY" default:"no-referrer"`
+}
+
 // Circuit contains configuration for HTTP circuit breaking.
 // Missing options use the defaults provided by the hystrix package.
 // Applications may want to create separate configuration for different HTTP
 // clients, to allow per-service circuit breaking configuration.
+//
+// The json and yaml tags name the values in runtime settings, which can change
+// them while the service is running.
 type Circuit struct {
-	Timeout                int `envconfig:"HTTP_CIRCUIT_TIMEOUT"`
-	MaxConcurrentRequests  int `envconfig:"HTTP_CIRCUIT_MAX_CONCURRENT_REQUESTS"`
-	RequestVolumeThreshold int `envconfig:"HTTP_CIRCUIT_REQUEST_VOLUME_THRESHOLD"`
-	SleepWindow            int `envconfig:"HTTP_CIRCUIT_SLEEP_WINDOW"`
-	ErrorPercentThreshold  int `envconfig:"HTTP_CIRCUIT_ERROR_PERCENT_THRESHOLD"`
+	Timeout                int `envconfig:"HTTP_CIRCUIT_TIMEOUT" validate:"min=0" json:"timeout" yaml:"timeout"`
+	MaxConcurrentRequests  int `envconfig:"HTTP_CIRCUIT_MAX_CONCURRENT_REQUESTS" validate:"min=0" json:"max_concurrent_requests" yaml:"max_concurrent_requests"`
+	RequestVolumeThreshold int `envconfig:"HTTP_CIRCUIT_REQUEST_VOLUME_THRESHOLD" validate:"min=0" json:"request_volume_threshold" yaml:"request_volume_threshold"`
+	SleepWindow            int `envconfig:"HTTP_CIRCUIT_SLEEP_WINDOW" validate:"min=0" json:"sleep_window" yaml:"sleep_window"`
+	ErrorPercentThreshold  int `envconfig:"HTTP_CIRCUIT_ERROR_PERCENT_THRESHOLD" validate:"min=0,max=100" json:"error_percent_threshold" yaml:"error_percent_threshold"`
 }
 
 // Config is the global config struct.
+//
+// Values are loaded in layers, each taking precedence over the previous one:
+// the `default` struct tags, the YAML file for the Hopper environment in
+// CONFIG_DIR, environment variables named by the `envconfig` struct tags, and
+// files named after those variables in SECRETS_DIR.
 type Config struct {
-	Env          string // APP_ENV
-	Settings     Settings
-	Server       Server
-	Hopper       Hopper
-	Database     Database
-	Datadog      Datadog
-	Circuit      Circuit
-	Determinator Determinator
+	Env             string // APP_ENV
+	Settings        Settings
+	Server          Server
+	Admin           Admin
+	Auth            Auth
+	Hopper          Hopper
+	Database        Database
+	Datadog         Datadog
+	Circuit         Circuit
+	Determinator    Determinator
+	Pagination      Pagination
+	Idempotency     Idempotency
+	RateLimit       RateLimit
+	LoadShedding    LoadShedding
+	Compression     Compression
+	CORS            CORS
+	SecurityHeaders SecurityHeaders
+
+	// sources records which layer supplied each value, by field path.
+	sources map[string]string
+	// warnings are problems found while loading which do not prevent the
+	// service from starting.
+	warnings []string
 }
 
-// Load configuration from environment.
+// Load configuration from its layers, and validate it. When the configuration
+// is invalid, the loaded configuration is returned alongside a
+// *ValidationError listing every problem.
 func Load() (Config, error) {
 	config := Config{}
 
-	if err := envconfig.Process(envPrefix, &config); err != nil {
-		return config, fmt.Errorf("failed to load config from environment: %w", err)
+	if err := load(&config); err != nil {
+		return config, err
+	}
+
+	if err := config.Validate(); err != nil {
+		return config, err
 	}
 
 	return config, nil
 }
+
+// Source returns where the value of the field at path, e.g. Server.Port, was
+// loaded from: "default", "file <path>", "env <variable>", "secret <path>" or
+// "unset".
+func (c *Config) Source(path string) string {
+	if source, ok := c.sources[path]; ok {
+		return source
+	}
+	return SourceUnset
+}
+
+// Warnings returns the problems found while loading the configuration which
+// do not prevent the service from starting, such as a missing config file.
+func (c *Config) Warnings() []string {
+	return c.warnings
+}
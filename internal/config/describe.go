@@ -0,0 +1,64 @@
+package config
+
+import (
+	"net/url"
+	"strconv"
+)
+
+// redacted replaces secret values when configuration is displayed.
+const redacted = "[REDACTED]"
+
+// Sources of configuration values, in increasing order of precedence.
+const (
+	SourceDefault = "default"
+	SourceFile    = "file"
+	SourceEnv     = "env"
+	SourceSecret  = "secret"
+	SourceUnset   = "unset"
+)
+
+// Setting is a single configuration value, prepared for display.
+type Setting struct {
+	// Field is the Go path of the value within Config, e.g. Server.Port.
+	Field string
+	// Variable is the environment variable which sets the value.
+	Variable string
+	// Value is the effective value, with secrets redacted.
+	Value string
+	// Source is where the value came from, e.g. "env PORT" or "default".
+	// See Config.Source.
+	Source string
+}
+
+// Describe returns every value in cfg with secrets redacted, noting where each
+// value came from.
+func Describe(cfg *Config) []Setting {
+	all := fields(cfg)
+	settings := make([]Setting, 0, len(all))
+
+	for _, f := range all {
+		settings = append(settings, Setting{
+			Field:    f.Path,
+			Variable: f.Variable(),
+			Value:    displayValue(f),
+			Source:   cfg.Source(f.Path),
+		})
+	}
+
+	return settings
+}
+
+// displayValue formats the value of f, redacting secrets and the passwords of
+// URLs.
+func displayValue(f field) string {
+	if secret, _ := strconv.ParseBool(f.Tags.Get("secret")); secret && !f.isZero() {
+		return redacted
+	}
+
+	value := f.String()
+	if u, err := url.Parse(value); err == nil && u.User != nil {
+		return u.Redacted()
+	}
+
+	return value
+}
@@ -0,0 +1,121 @@
+package config
+
+import (
+	"encoding"
+	"fmt"
+	"os"
+	"reflect"
+	"strings"
+)
+
+// field is a single configuration value, named the way envconfig names it.
+type field struct {
+	// Path is the Go path of the field within Config, e.g. Server.Port.
+	Path string
+	// Key is the prefixed environment variable, e.g. SETTINGS_SERVER_PORT.
+	Key string
+	// Alt is the unprefixed environment variable from the envconfig tag,
+	// e.g. PORT. It is empty when the field has no envconfig tag.
+	Alt string
+
+	Tags  reflect.StructTag
+	Value reflect.Value
+}
+
+// Variable returns the environment variable conventionally used to set the
+// field.
+func (f field) Variable() string {
+	if f.Alt != "" {
+		return f.Alt
+	}
+	return f.Key
+}
+
+// lookupEnv returns the value of the field in the environment, checking the
+// prefixed variable before the unprefixed one as envconfig does.
+func (f field) lookupEnv() (string, string, bool) {
+	if value, ok := os.LookupEnv(f.Key); ok {
+		return f.Key, value, true
+	}
+	if f.Alt != "" {
+		if value, ok := os.LookupEnv(f.Alt); ok {
+			return f.Alt, value, true
+		}
+	}
+	return "", "", false
+}
+
+// String formats the value of the field for display.
+func (f field) String() string {
+	v := f.Value
+	if v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return ""
+		}
+		v = v.Elem()
+	}
+	if s, ok := v.Addr().Interface().(fmt.Stringer); ok {
+		return s.String()
+	}
+	return fmt.Sprint(v.Interface())
+}
+
+// isZero reports whether the field holds no value. Pointers to empty values,
+// such as the zero url.URL envconfig creates for unset URLs, are zero.
+func (f field) isZero() bool {
+	v := f.Value
+	if v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return true
+		}
+		v = v.Elem()
+	}
+	return v.IsZero()
+}
+
+// fields returns every leaf configuration value in cfg, in declaration order.
+func fields(cfg *Config) []field {
+	return gatherFields(strings.ToUpper(envPrefix), "", reflect.ValueOf(cfg).Elem())
+}
+
+func gatherFields(prefix, path string, s reflect.Value) []field {
+	var out []field
+
+	for i := 0; i < s.NumField(); i++ {
+		structField := s.Type().Field(i)
+		value := s.Field(i)
+		if !structField.IsExported() {
+			continue
+		}
+
+		info := field{
+			Path:  strings.TrimPrefix(path+"."+structField.Name, "."),
+			Alt:   strings.ToUpper(structField.Tag.Get("envconfig")),
+			Tags:  structField.Tag,
+			Value: value,
+		}
+		info.Key = structField.Name
+		if info.Alt != "" {
+			info.Key = info.Alt
+		}
+		info.Key = strings.ToUpper(prefix + "_" + info.Key)
+
+		if value.Kind() == reflect.Struct && !isLeaf(value) {
+			out = append(out, gatherFields(info.Key, info.Path, value)...)
+			continue
+		}
+
+		out = append(out, info)
+	}
+
+	return out
+}
+
+// isLeaf reports whether a struct is decoded from a single value, rather than
+// being a group of configuration values.
+func isLeaf(v reflect.Value) bool {
+	ptr := v.Addr().Interface()
+	_, text := ptr.(encoding.TextUnmarshaler)
+	_, binary := ptr.(encoding.BinaryUnmarshaler)
+	return text || binary
+}
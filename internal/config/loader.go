@@ -0,0 +1,290 @@
+package config
+
+import (
+	"encoding"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/kelseyhightower/envconfig"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	defaultEnvironment = "development"
+
+	// ConfigDirEnvVar names the directory holding a YAML file per Hopper
+	// environment, e.g. config/development.yaml.
+	ConfigDirEnvVar  = "CONFIG_DIR"
+	defaultConfigDir = "config"
+
+	// SecretsDirEnvVar names the directory where secrets are mounted, one
+	// file per environment variable, e.g. /run/secrets/DATABASE_URL.
+	SecretsDirEnvVar  = "SECRETS_DIR"
+	defaultSecretsDir = "/run/secrets"
+)
+
+// layer is a source of configuration values, keyed by environment variable
+// name.
+type layer struct {
+	// name is the Source recorded for values the layer supplies.
+	name string
+	// lookup returns the value of a field, and where it was found.
+	lookup func(f field) (value string, location string, ok bool)
+}
+
+// load populates cfg from each layer in turn, so later layers take precedence:
+// built-in defaults, the YAML file for the environment, environment variables
+// and finally the secrets directory. Each field is decoded from the value of
+// the last layer supplying one, the way envconfig decodes environment
+// variables, without changing the environment.
+func load(cfg *Config) error {
+	// The environment selects the config file, so it is read from the
+	// environment alone.
+	environment := getenv("HOPPER_ENVIRONMENT", defaultEnvironment)
+	configDir := getenv(ConfigDirEnvVar, defaultConfigDir)
+	path := filepath.Join(configDir, environment+".yaml")
+	file, found, err := fileLayer(path)
+	if err != nil {
+		return err
+	}
+	if !found && environment != defaultEnvironment {
+		cfg.warnings = append(cfg.warnings, fmt.Sprintf("config file %s does not exist, so only environment variables and secrets are used", path))
+	}
+
+	layers := []layer{
+		defaultLayer(),
+		file,
+		envLayer(),
+		secretsLayer(getenv(SecretsDirEnvVar, defaultSecretsDir)),
+	}
+
+	cfg.sources = map[string]string{}
+	for _, f := range fields(cfg) {
+		value, source := "", SourceUnset
+		for _, l := range layers {
+			if v, location, ok := l.lookup(f); ok {
+				value, source = v, strings.TrimSpace(l.name+" "+location)
+			}
+		}
+		cfg.sources[f.Path] = source
+
+		if source == SourceUnset {
+			// Unset pointers to structs are left empty rather than nil, as
+			// envconfig leaves them, e.g. DETERMINATOR_URL.
+			if f.Value.Kind() == reflect.Ptr && f.Value.IsNil() && f.Value.Type().Elem().Kind() == reflect.Struct {
+				f.Value.Set(reflect.New(f.Value.Type().Elem()))
+			}
+			continue
+		}
+		if err := decode(f.Value, value); err != nil {
+			return fmt.Errorf("failed to parse %s from %s: %w", f.Variable(), source, err)
+		}
+	}
+
+	if runtimeFile := cfg.Settings.RuntimeFile; runtimeFile != "" && !filepath.IsAbs(runtimeFile) {
+		cfg.Settings.RuntimeFile = filepath.Join(configDir, runtimeFile)
+	}
+	cfg.warnings = append(cfg.warnings, secretWarnings(cfg)...)
+
+	return nil
+}
+
+// decode sets v to text, decoded as envconfig decodes environment variables:
+// by the envconfig.Decoder, encoding.TextUnmarshaler or
+// encoding.BinaryUnmarshaler of v, as a number, duration, boolean or string,
+// or as a list ("a,b") or mapping ("k:v,k:v") of such values.
+func decode(v reflect.Value, text string) error {
+	if v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			v.Set(reflect.New(v.Type().Elem()))
+		}
+		v = v.Elem()
+	}
+
+	switch u := v.Addr().Interface().(type) {
+	case envconfig.Decoder:
+		return u.Decode(text) //nolint:wrapcheck // wrapped with the variable by load
+	case encoding.TextUnmarshaler:
+		return u.UnmarshalText([]byte(text)) //nolint:wrapcheck // wrapped with the variable by load
+	case encoding.BinaryUnmarshaler:
+		return u.UnmarshalBinary([]byte(text)) //nolint:wrapcheck // wrapped with the variable by load
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(text)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if v.Type() == reflect.TypeOf(time.Duration(0)) {
+			d, err := time.ParseDuration(text)
+			if err != nil {
+				return err //nolint:wrapcheck // wrapped with the variable by load
+			}
+			v.SetInt(int64(d))
+			return nil
+		}
+		n, err := strconv.ParseInt(text, 0, v.Type().Bits())
+		if err != nil {
+			return err //nolint:wrapcheck // wrapped with the variable by load
+		}
+		v.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(text, 0, v.Type().Bits())
+		if err != nil {
+			return err //nolint:wrapcheck // wrapped with the variable by load
+		}
+		v.SetUint(n)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(text)
+		if err != nil {
+			return err //nolint:wrapcheck // wrapped with the variable by load
+		}
+		v.SetBool(b)
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(text, v.Type().Bits())
+		if err != nil {
+			return err //nolint:wrapcheck // wrapped with the variable by load
+		}
+		v.SetFloat(f)
+	case reflect.Slice:
+		items := reflect.MakeSlice(v.Type(), 0, 0)
+		if strings.TrimSpace(text) != "" {
+			for _, item := range strings.Split(text, ",") {
+				value := reflect.New(v.Type().Elem()).Elem()
+				if err := decode(value, item); err != nil {
+					return err
+				}
+				items = reflect.Append(items, value)
+			}
+		}
+		v.Set(items)
+	case reflect.Map:
+		entries := reflect.MakeMap(v.Type())
+		if strings.TrimSpace(text) != "" {
+			for _, entry := range strings.Split(text, ",") {
+				k, item, ok := strings.Cut(entry, ":")
+				if !ok || strings.Contains(item, ":") {
+					return fmt.Errorf("invalid map item: %q", entry)
+				}
+				key := reflect.New(v.Type().Key()).Elem()
+				if err := decode(key, k); err != nil {
+					return err
+				}
+				value := reflect.New(v.Type().Elem()).Elem()
+				if err := decode(value, item); err != nil {
+					return err
+				}
+				entries.SetMapIndex(key, value)
+			}
+		}
+		v.Set(entries)
+	default:
+		return fmt.Errorf("unsupported type %s", v.Type())
+	}
+
+	return nil
+}
+
+func defaultLayer() layer {
+	return layer{
+		name: SourceDefault,
+		lookup: func(f field) (string, string, bool) {
+			value := f.Tags.Get("default")
+			return value, "", value != ""
+		},
+	}
+}
+
+// fileLayer reads a flat YAML mapping of environment variable names to
+// values. A missing file supplies no values, and is reported by found.
+func fileLayer(path string) (l layer, found bool, err error) {
+	values := map[string]interface{}{}
+
+	raw, err := os.ReadFile(path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return layer{}, false, fmt.Errorf("failed to read config file: %w", err)
+	}
+	found = err == nil
+	if err := yaml.Unmarshal(raw, &values); err != nil {
+		return layer{}, false, fmt.Errorf("failed to parse config file %s: %w", path, err)
+	}
+
+	return layer{
+		name: SourceFile,
+		lookup: func(f field) (string, string, bool) {
+			for _, key := range []string{f.Key, f.Alt} {
+				if value, ok := values[key]; ok && key != "" {
+					return envValue(value), path, true
+				}
+			}
+			return "", "", false
+		},
+	}, found, nil
+}
+
+// envValue formats a YAML value the way envconfig expects it in an
+// environment variable: lists as "a,b" and mappings as "k:v,k:v".
+func envValue(value interface{}) string {
+	switch value := value.(type) {
+	case nil:
+		return ""
+	case []interface{}:
+		items := make([]string, len(value))
+		for i, item := range value {
+			items[i] = envValue(item)
+		}
+		return strings.Join(items, ",")
+	case map[string]interface{}:
+		items := make([]string, 0, len(value))
+		for k, v := range value {
+			items = append(items, k+":"+envValue(v))
+		}
+		sort.Strings(items)
+		return strings.Join(items, ",")
+	default:
+		return fmt.Sprint(value)
+	}
+}
+
+func envLayer() layer {
+	return layer{
+		name: SourceEnv,
+		lookup: func(f field) (string, string, bool) {
+			variable, value, ok := f.lookupEnv()
+			return value, variable, ok
+		},
+	}
+}
+
+// secretsLayer reads values from files named after environment variables.
+// Trailing newlines, which most tools add when writing secrets, are removed.
+func secretsLayer(dir string) layer {
+	return layer{
+		name: SourceSecret,
+		lookup: func(f field) (string, string, bool) {
+			for _, key := range []string{f.Key, f.Alt} {
+				if key == "" {
+					continue
+				}
+				path := filepath.Join(dir, key)
+				if raw, err := os.ReadFile(path); err == nil {
+					return strings.TrimRight(string(raw), "\r\n"), path, true
+				}
+			}
+			return "", "", false
+		},
+	}
+}
+
+func getenv(key, fallback string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return fallback
+}
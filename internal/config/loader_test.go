@@ -0,0 +1,154 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap/zapcore"
+
+	"github.com/deliveroo/bnt-internal-test-go/internal/rate"
+)
+
+func TestLoadLayers(t *testing.T) {
+	configDir := t.TempDir()
+	secretsDir := t.TempDir()
+	t.Setenv(ConfigDirEnvVar, configDir)
+	t.Setenv(SecretsDirEnvVar, secretsDir)
+	t.Setenv("HOPPER_ENVIRONMENT", "staging")
+
+	writeFile(t, filepath.Join(configDir, "staging.yaml"), `
+PORT: 4000
+HTTP_SERVER_READ_TIMEOUT: 5s
+DETERMINATOR_USERNAME: from-file
+DETERMINATOR_PASSWORD: from-file
+`)
+	t.Setenv("HTTP_SERVER_READ_TIMEOUT", "3s")
+	t.Setenv("DETERMINATOR_PASSWORD", "from-env")
+	writeFile(t, filepath.Join(secretsDir, "DETERMINATOR_PASSWORD"), "from-secret\n")
+
+	cfg, err := Load()
+	assert.NotNil(t, err, "staging requires values which are not set")
+
+	assert.Equal(t, 4000, cfg.Server.Port)
+	assert.Equal(t, "file "+filepath.Join(configDir, "staging.yaml"), cfg.Source("Server.Port"))
+
+	assert.Equal(t, 3*time.Second, cfg.Server.ReadTimeout)
+	assert.Equal(t, "env HTTP_SERVER_READ_TIMEOUT", cfg.Source("Server.ReadTimeout"))
+
+	assert.Equal(t, "from-secret", cfg.Determinator.Password.Value())
+	assert.Equal(t, "secret "+filepath.Join(secretsDir, "DETERMINATOR_PASSWORD"), cfg.Source("Determinator.Password"))
+
+	assert.Equal(t, "from-file", cfg.Determinator.Username)
+	assert.Equal(t, 2*time.Second, cfg.Server.WriteTimeout)
+	assert.Equal(t, "default", cfg.Source("Server.WriteTimeout"))
+	assert.Equal(t, "unset", cfg.Source("Datadog.Host"))
+}
+
+func TestLoadLogLevels(t *testing.T) {
+	t.Setenv(ConfigDirEnvVar, t.TempDir())
+	t.Setenv("LOG_LEVEL", "warn")
+	t.Setenv("LOGGER_LEVELS", "orders:debug,httpclient:error")
+
+	cfg, err := Load()
+	assert.Nil(t, err)
+
+	level := zapcore.WarnLevel
+	assert.Equal(t, &level, cfg.Settings.LogLevel)
+	assert.Equal(t, map[string]zapcore.Level{"orders": zapcore.DebugLevel, "httpclient": zapcore.ErrorLevel}, cfg.Settings.LoggerLevels)
+}
+
+func TestLoadRateLimits(t *testing.T) {
+	t.Setenv(ConfigDirEnvVar, t.TempDir())
+	t.Setenv("RATE_LIMIT_ROUTES", "createOrder:60/1m,listOrders:20/s")
+
+	cfg, err := Load()
+	assert.Nil(t, err)
+
+	assert.Equal(t, rate.Limit{Requests: 600, Per: time.Minute}, cfg.RateLimit.Default)
+	assert.Equal(t, map[string]rate.Limit{
+		"createOrder": {Requests: 60, Per: time.Minute},
+		"listOrders":  {Requests: 20, Per: time.Second},
+	}, cfg.RateLimit.Routes)
+}
+
+func TestLoadInvalidValue(t *testing.T) {
+	t.Setenv(ConfigDirEnvVar, t.TempDir())
+	t.Setenv("PORT", "not-a-port")
+
+	_, err := Load()
+	assert.EqualError(t, err, `failed to parse PORT from env PORT: strconv.ParseInt: parsing "not-a-port": invalid syntax`)
+}
+
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatalf("failed to write %s: %s", path, err)
+	}
+}
+
+func TestLoadBrowserHeaders(t *testing.T) {
+	t.Run("defaults to strict headers and no CORS", func(t *testing.T) {
+		t.Setenv(ConfigDirEnvVar, t.TempDir())
+		t.Setenv("HOPPER_ENVIRONMENT", "production")
+
+		var cfg Config
+		assert.Nil(t, load(&cfg))
+		assert.Empty(t, cfg.CORS.AllowedOrigins)
+		assert.Equal(t, []string{"GET", "POST", "PUT", "PATCH", "DELETE"}, cfg.CORS.AllowedMethods)
+		assert.Equal(t, 8760*time.Hour, cfg.SecurityHeaders.HSTSMaxAge)
+		assert.Equal(t, "DENY", cfg.SecurityHeaders.FrameOptions)
+	})
+
+	t.Run("relaxes them in development", func(t *testing.T) {
+		t.Setenv(ConfigDirEnvVar, filepath.Join("..", "..", "config"))
+		t.Setenv("HOPPER_ENVIRONMENT", "development")
+
+		var cfg Config
+		assert.Nil(t, load(&cfg))
+		assert.Equal(t, []string{"*"}, cfg.CORS.AllowedOrigins)
+		assert.Zero(t, cfg.SecurityHeaders.HSTSMaxAge)
+	})
+}
+
+func TestLoadFileValues(t *testing.T) {
+	configDir := t.TempDir()
+	t.Setenv(ConfigDirEnvVar, configDir)
+	writeFile(t, filepath.Join(configDir, "development.yaml"), `
+CORS_ALLOWED_ORIGINS: [https://backoffice.example.com, https://tools.example.com]
+LOGGER_LEVELS: {orders: debug, httpclient: error}
+RUNTIME_SETTINGS_FILE: runtime.yaml
+`)
+
+	var cfg Config
+	assert.Nil(t, load(&cfg))
+
+	assert.Equal(t, []string{"https://backoffice.example.com", "https://tools.example.com"}, cfg.CORS.AllowedOrigins)
+	assert.Equal(t, map[string]zapcore.Level{"orders": zapcore.DebugLevel, "httpclient": zapcore.ErrorLevel}, cfg.Settings.LoggerLevels)
+	assert.Equal(t, filepath.Join(configDir, "runtime.yaml"), cfg.Settings.RuntimeFile)
+
+	_, set := os.LookupEnv("SETTINGS_CORS_ALLOWED_ORIGINS")
+	assert.False(t, set, "values from the file are not left in the environment")
+}
+
+func TestLoadMissingFile(t *testing.T) {
+	t.Setenv(ConfigDirEnvVar, t.TempDir())
+
+	t.Run("warns when the file of a deployed environment is missing", func(t *testing.T) {
+		t.Setenv("HOPPER_ENVIRONMENT", "production")
+
+		var cfg Config
+		assert.Nil(t, load(&cfg))
+		assert.Len(t, cfg.Warnings(), 1)
+		assert.Contains(t, cfg.Warnings()[0], "production.yaml does not exist")
+	})
+
+	t.Run("does not warn in development", func(t *testing.T) {
+		var cfg Config
+		assert.Nil(t, load(&cfg))
+		assert.Empty(t, cfg.Warnings())
+	})
+}
@@ -0,0 +1,213 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"reflect"
+	"strings"
+)
+
+// Secret is a configuration value, such as a password, which must not be
+// displayed. It cannot be printed, JSON-encoded or logged in clear text: use
+// Value to read it.
+type Secret struct {
+	value string
+}
+
+// NewSecret returns a Secret holding value.
+func NewSecret(value string) Secret {
+	return Secret{value: value}
+}
+
+// Value returns the secret in clear text.
+func (s Secret) Value() string {
+	return s.value
+}
+
+// String returns a redacted placeholder, or an empty string when the secret is
+// not set.
+func (s Secret) String() string {
+	if s.value == "" {
+		return ""
+	}
+	return redacted
+}
+
+// GoString redacts the secret when formatted with %#v.
+func (s Secret) GoString() string {
+	return fmt.Sprintf("config.Secret(%q)", s.String())
+}
+
+// Format redacts the secret whichever verb it is formatted with.
+func (s Secret) Format(f fmt.State, verb rune) {
+	if verb == 'v' && f.Flag('#') {
+		_, _ = fmt.Fprint(f, s.GoString())
+		return
+	}
+	_, _ = fmt.Fprint(f, s.String())
+}
+
+// MarshalJSON encodes the redacted placeholder.
+func (s Secret) MarshalJSON() ([]byte, error) {
+	return json.Marshal(s.String()) //nolint:wrapcheck // strings always encode
+}
+
+// MarshalText encodes the redacted placeholder, e.g. for YAML.
+func (s Secret) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+// UnmarshalText loads the secret from configuration.
+func (s *Secret) UnmarshalText(text []byte) error {
+	s.value = string(text)
+	return nil
+}
+
+// SecretURL is a URL which may contain credentials, such as a database URL.
+// Its password cannot be printed, JSON-encoded or logged in clear text: use
+// Value to read it.
+type SecretURL struct {
+	value string
+}
+
+// NewSecretURL returns a SecretURL holding value.
+func NewSecretURL(value string) SecretURL {
+	return SecretURL{value: value}
+}
+
+// Value returns the URL including its password.
+func (u SecretURL) Value() string {
+	return u.value
+}
+
+// Password returns the password in the URL, if it has one.
+func (u SecretURL) Password() string {
+	parsed, err := url.Parse(u.value)
+	if err != nil || parsed.User == nil {
+		return ""
+	}
+	password, _ := parsed.User.Password()
+	return password
+}
+
+// String returns the URL with its password masked. URLs which cannot be parsed
+// are redacted entirely, as the password cannot be found.
+func (u SecretURL) String() string {
+	if u.value == "" {
+		return ""
+	}
+	parsed, err := url.Parse(u.value)
+	if err != nil {
+		return redacted
+	}
+	return parsed.Redacted()
+}
+
+// GoString masks the password when formatted with %#v.
+func (u SecretURL) GoString() string {
+	return fmt.Sprintf("config.SecretURL(%q)", u.String())
+}
+
+// Format masks the password whichever verb the URL is formatted with.
+func (u SecretURL) Format(f fmt.State, verb rune) {
+	if verb == 'v' && f.Flag('#') {
+		_, _ = fmt.Fprint(f, u.GoString())
+		return
+	}
+	_, _ = fmt.Fprint(f, u.String())
+}
+
+// MarshalJSON encodes the URL with its password masked.
+func (u SecretURL) MarshalJSON() ([]byte, error) {
+	return json.Marshal(u.String()) //nolint:wrapcheck // strings always encode
+}
+
+// MarshalText encodes the URL with its password masked, e.g. for YAML.
+func (u SecretURL) MarshalText() ([]byte, error) {
+	return []byte(u.String()), nil
+}
+
+// UnmarshalText loads the URL from configuration.
+func (u *SecretURL) UnmarshalText(text []byte) error {
+	u.value = string(text)
+	return nil
+}
+
+// Secrets returns the clear text of every secret in cfg: Secret values, the
+// passwords of SecretURLs and fields tagged `secret:"true"`. They can be
+// removed from logs, as a safeguard against them being written by mistake.
+// Secrets which are part of a value that is not secret, such as a placeholder
+// equal to HOPPER_ENVIRONMENT, are left out, as removing them would redact
+// that value too; SecretWarnings reports them.
+func Secrets(cfg *Config) []string {
+	var secrets []string
+
+	values, public := secretFields(cfg)
+	for _, value := range values {
+		if _, ok := exposedBy(value.secret, public); !ok {
+			secrets = append(secrets, value.secret)
+		}
+	}
+
+	return secrets
+}
+
+// secretWarnings describes the secrets left out by Secrets.
+func secretWarnings(cfg *Config) []string {
+	var warnings []string
+
+	values, public := secretFields(cfg)
+	for _, value := range values {
+		if f, ok := exposedBy(value.secret, public); ok {
+			warnings = append(warnings, fmt.Sprintf("%s is part of %s, which is not secret, so it is not scrubbed from logs", value.Variable(), f.Variable()))
+		}
+	}
+
+	return warnings
+}
+
+// secretField is a field holding a secret, with its clear text.
+type secretField struct {
+	field
+	secret string
+}
+
+// secretFields returns the fields of cfg which hold secrets, and those which
+// do not.
+func secretFields(cfg *Config) (secrets []secretField, public []field) {
+	for _, f := range fields(cfg) {
+		var value string
+		switch v := f.Value.Interface().(type) {
+		case Secret:
+			value = v.Value()
+		case SecretURL:
+			value = v.Password()
+			// The rest of the URL, such as the database name, is public.
+			public = append(public, f)
+		default:
+			if f.Tags.Get("secret") == "true" && f.Value.Kind() == reflect.String {
+				value = f.Value.String()
+			} else {
+				public = append(public, f)
+			}
+		}
+
+		if value != "" {
+			secrets = append(secrets, secretField{field: f, secret: value})
+		}
+	}
+
+	return secrets, public
+}
+
+// exposedBy returns the first of the public fields whose value contains
+// secret.
+func exposedBy(secret string, public []field) (field, bool) {
+	for _, f := range public {
+		if strings.Contains(f.String(), secret) {
+			return f, true
+		}
+	}
+	return field{}, false
+}
@@ -0,0 +1,87 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestSecret(t *testing.T) {
+	secret := NewSecret("hunter2")
+
+	t.Run("cannot be printed", func(t *testing.T) {
+		for _, format := range []string{"%v", "%+v", "%s", "%q", "%x", "%d"} {
+			assert.NotContains(t, fmt.Sprintf(format, secret), "hunter2", format)
+			assert.NotContains(t, fmt.Sprintf(format, Determinator{Password: secret}), "hunter2", format)
+		}
+		assert.Equal(t, `config.Secret("[REDACTED]")`, fmt.Sprintf("%#v", secret))
+	})
+
+	t.Run("cannot be JSON encoded", func(t *testing.T) {
+		raw, err := json.Marshal(Determinator{Password: secret})
+		assert.Nil(t, err)
+		assert.Contains(t, string(raw), `"Password":"[REDACTED]"`)
+	})
+
+	t.Run("cannot be logged", func(t *testing.T) {
+		core, logs := observer.New(zapcore.DebugLevel)
+		zap.New(core).Info("loaded", zap.Any("config", Determinator{Password: secret}), zap.Stringer("password", secret))
+
+		encoder := zapcore.NewJSONEncoder(zap.NewProductionEncoderConfig())
+		entry := logs.All()[0]
+		buf, err := encoder.EncodeEntry(entry.Entry, entry.Context)
+		assert.Nil(t, err)
+		assert.NotContains(t, buf.String(), "hunter2")
+	})
+
+	t.Run("returns its value when asked", func(t *testing.T) {
+		assert.Equal(t, "hunter2", secret.Value())
+		assert.Equal(t, "", NewSecret("").String())
+	})
+}
+
+func TestSecretURL(t *testing.T) {
+	u := NewSecretURL("postgres://app:s3cret@db:5432/app")
+
+	assert.Equal(t, "postgres://app:xxxxx@db:5432/app", u.String())
+	assert.Equal(t, "postgres://app:xxxxx@db:5432/app", fmt.Sprintf("%v", Database{URL: u}.URL))
+	assert.NotContains(t, fmt.Sprintf("%+v", Database{URL: u}), "s3cret")
+	assert.Equal(t, "s3cret", u.Password())
+	assert.Equal(t, "postgres://app:s3cret@db:5432/app", u.Value())
+
+	raw, err := json.Marshal(u)
+	assert.Nil(t, err)
+	assert.Equal(t, `"postgres://app:xxxxx@db:5432/app"`, string(raw))
+
+	assert.Equal(t, "[REDACTED]", NewSecretURL("postgres://app:s3cret@db:port/app").String())
+}
+
+func TestSecrets(t *testing.T) {
+	cfg := Config{
+		Database:     Database{URL: NewSecretURL("postgres://app:s3cret@db/app"), ReaderURL: NewSecretURL("postgres://db/app")},
+		Determinator: Determinator{Password: NewSecret("hunter2")},
+	}
+
+	assert.ElementsMatch(t, []string{"s3cret", "hunter2"}, Secrets(&cfg))
+}
+
+func TestSecretsExposedByPublicValues(t *testing.T) {
+	cfg := Config{
+		Hopper:       Hopper{Environment: "development"},
+		Admin:        Admin{Token: NewSecret("development")},
+		Determinator: Determinator{Password: NewSecret("hunter2")},
+	}
+
+	t.Run("leaves out secrets which are part of public values", func(t *testing.T) {
+		assert.Equal(t, []string{"hunter2"}, Secrets(&cfg))
+	})
+
+	t.Run("warns about them", func(t *testing.T) {
+		assert.Equal(t, []string{"ADMIN_TOKEN is part of HOPPER_ENVIRONMENT, which is not secret, so it is not scrubbed from logs"}, secretWarnings(&cfg))
+	})
+}
@@ -0,0 +1,149 @@
+package config
+
+import (
+	"fmt"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Validation rules are declared in the `validate` struct tag, separated by
+// commas. Rules taking several values separate them with "|".
+//
+//	required                        the value must be set
+//	required_in=production|staging  the value must be set in these environments
+//	min=N, max=N                    bounds for numbers and durations
+//	oneof=a|b                       the value must be one of these
+//	url                             the value must be an absolute URL
+const validateTag = "validate"
+
+// FieldError describes a single invalid configuration value.
+type FieldError struct {
+	// Field is the Go path of the value within Config, e.g. Server.Port.
+	Field string
+	// Variable is the environment variable which sets the value.
+	Variable string
+	// Problem describes what is wrong with the value.
+	Problem string
+}
+
+func (e FieldError) Error() string {
+	return fmt.Sprintf("%s (%s) %s", e.Variable, e.Field, e.Problem)
+}
+
+// ValidationError lists every invalid configuration value, so they can all be
+// fixed at once.
+type ValidationError struct {
+	Environment string
+	Errors      []FieldError
+}
+
+func (e *ValidationError) Error() string {
+	problems := make([]string, 0, len(e.Errors))
+	for _, err := range e.Errors {
+		problems = append(problems, err.Error())
+	}
+	return fmt.Sprintf("invalid configuration for %s environment: %s", e.Environment, strings.Join(problems, "; "))
+}
+
+// Validate checks every value in the config against the rules declared on it,
+// taking into account the Hopper environment the service runs in.
+func (c *Config) Validate() error {
+	result := &ValidationError{Environment: c.Hopper.Environment}
+
+	for _, f := range fields(c) {
+		for _, rule := range strings.Split(f.Tags.Get(validateTag), ",") {
+			if rule == "" {
+				continue
+			}
+			if problem := checkRule(c.Hopper.Environment, f, rule); problem != "" {
+				result.Errors = append(result.Errors, FieldError{Field: f.Path, Variable: f.Variable(), Problem: problem})
+			}
+		}
+	}
+
+	if len(result.Errors) > 0 {
+		return result
+	}
+	return nil
+}
+
+// checkRule returns a description of how f breaks rule, or an empty string
+// when it does not.
+func checkRule(environment string, f field, rule string) string {
+	name, arg, _ := strings.Cut(rule, "=")
+
+	switch name {
+	case "required":
+		if f.isZero() {
+			return "is required"
+		}
+	case "required_in":
+		for _, env := range strings.Split(arg, "|") {
+			if env == environment && f.isZero() {
+				return "is required in " + environment
+			}
+		}
+	case "min", "max":
+		value, bound, err := numbers(f.Value, arg)
+		if err != nil {
+			return fmt.Sprintf("has invalid %s rule: %s", name, err)
+		}
+		if name == "min" && value < bound {
+			return fmt.Sprintf("must be at least %s", arg)
+		}
+		if name == "max" && value > bound {
+			return fmt.Sprintf("must be at most %s", arg)
+		}
+	case "oneof":
+		value := f.String()
+		for _, allowed := range strings.Split(arg, "|") {
+			if value == allowed {
+				return ""
+			}
+		}
+		return fmt.Sprintf("must be one of %s", strings.ReplaceAll(arg, "|", ", "))
+	case "url":
+		if f.isZero() {
+			return ""
+		}
+		u, err := url.Parse(f.String())
+		if err != nil || u.Scheme == "" || u.Host == "" {
+			return "must be an absolute URL"
+		}
+	default:
+		return fmt.Sprintf("has unknown validation rule %q", name)
+	}
+
+	return ""
+}
+
+// numbers converts a numeric or duration field and the bound it is checked
+// against to comparable values.
+func numbers(v reflect.Value, bound string) (float64, float64, error) {
+	if v.Type() == reflect.TypeOf(time.Duration(0)) {
+		d, err := time.ParseDuration(bound)
+		if err != nil {
+			return 0, 0, err //nolint:wrapcheck // reported as part of a FieldError
+		}
+		return float64(v.Int()), float64(d), nil
+	}
+
+	b, err := strconv.ParseFloat(bound, 64)
+	if err != nil {
+		return 0, 0, err //nolint:wrapcheck // reported as part of a FieldError
+	}
+
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(v.Int()), b, nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(v.Uint()), b, nil
+	case reflect.Float32, reflect.Float64:
+		return v.Float(), b, nil
+	default:
+		return 0, 0, fmt.Errorf("%s is not a number", v.Type())
+	}
+}
@@ -0,0 +1,76 @@
+package config
+
+import (
+	"errors"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidate(t *testing.T) {
+	t.Run("the defaults are valid in development", func(t *testing.T) {
+		cfg, err := Load()
+		assert.Nil(t, err)
+		assert.Equal(t, "development", cfg.Hopper.Environment)
+	})
+
+	t.Run("production requires its dependencies to be configured", func(t *testing.T) {
+		t.Setenv("HOPPER_ENVIRONMENT", "production")
+		t.Setenv("HOPPER_RELEASE_ID", "abc123")
+		t.Setenv("HOPPER_SERVICE_NAME", "web")
+		t.Setenv("STATSD_HOST", "localhost")
+		t.Setenv("STATSD_PORT", "8125")
+		t.Setenv("DATADOG_TRACER_PORT", "8126")
+
+		_, err := Load()
+
+		var validationErr *ValidationError
+		assert.True(t, errors.As(err, &validationErr))
+		assert.Equal(t, "production", validationErr.Environment)
+		assert.Equal(t, []FieldError{
+			{Field: "Auth.JWKS", Variable: "AUTH_JWKS", Problem: "is required in production"},
+			{Field: "Auth.Issuer", Variable: "AUTH_ISSUER", Problem: "is required in production"},
+			{Field: "Determinator.URL", Variable: "DETERMINATOR_URL", Problem: "is required in production"},
+			{Field: "Determinator.Username", Variable: "DETERMINATOR_USERNAME", Problem: "is required in production"},
+			{Field: "Determinator.Password", Variable: "DETERMINATOR_PASSWORD", Problem: "is required in production"},
+			{Field: "Pagination.CursorKey", Variable: "PAGINATION_CURSOR_KEY", Problem: "is required in production"},
+		}, validationErr.Errors)
+	})
+
+	t.Run("reports every invalid value at once", func(t *testing.T) {
+		cfg, err := Load()
+		assert.Nil(t, err)
+
+		cfg.Server.Port = 70000
+		cfg.Database.URL = NewSecretURL("localhost")
+		cfg.Circuit.ErrorPercentThreshold = -1
+		cfg.Determinator.URL = &url.URL{Path: "/features"}
+
+		assert.EqualError(t, cfg.Validate(), "invalid configuration for development environment: "+
+			"PORT (Server.Port) must be at most 65535; "+
+			"DATABASE_URL (Database.URL) must be an absolute URL; "+
+			"HTTP_CIRCUIT_ERROR_PERCENT_THRESHOLD (Circuit.ErrorPercentThreshold) must be at least 0; "+
+			"DETERMINATOR_URL (Determinator.URL) must be an absolute URL")
+	})
+}
+
+func TestDescribe(t *testing.T) {
+	t.Setenv("PORT", "8080")
+	t.Setenv("DETERMINATOR_PASSWORD", "hunter2")
+	t.Setenv("DATABASE_URL", "postgres://app:s3cret@db:5432/app")
+
+	cfg, err := Load()
+	assert.Nil(t, err)
+
+	settings := map[string]Setting{}
+	for _, setting := range Describe(&cfg) {
+		settings[setting.Field] = setting
+	}
+
+	assert.Equal(t, Setting{Field: "Server.Port", Variable: "PORT", Value: "8080", Source: "env PORT"}, settings["Server.Port"])
+	assert.Equal(t, Setting{Field: "Server.ReadTimeout", Variable: "HTTP_SERVER_READ_TIMEOUT", Value: "1s", Source: "default"}, settings["Server.ReadTimeout"])
+	assert.Equal(t, "[REDACTED]", settings["Determinator.Password"].Value)
+	assert.Equal(t, "postgres://app:xxxxx@db:5432/app", settings["Database.URL"].Value)
+	assert.Equal(t, "unset", settings["Determinator.Username"].Source)
+}
@@ -0,0 +1,174 @@
+package contract
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+)
+
+// MockProvider stands in for a provider in consumer tests. It serves the
+// interactions added to it and, once verified, writes them to a pact file.
+type MockProvider struct {
+	t        *testing.T
+	consumer string
+	provider string
+	server   *httptest.Server
+
+	mu           sync.Mutex
+	interactions []Interaction
+	matched      []bool
+	unexpected   []string
+}
+
+// NewMockProvider starts a mock provider which is stopped when the test ends.
+func NewMockProvider(t *testing.T, consumer, provider string) *MockProvider {
+	t.Helper()
+
+	m := &MockProvider{t: t, consumer: consumer, provider: provider}
+	m.server = httptest.NewServer(http.HandlerFunc(m.serveHTTP))
+	t.Cleanup(m.server.Close)
+
+	return m
+}
+
+// AddInteraction registers an interaction the consumer is expected to perform
+// during the next call to Verify.
+func (m *MockProvider) AddInteraction(interaction Interaction) *MockProvider {
+	interaction.Request.Body, interaction.Request.MatchingRules = extractMatchers(interaction.Request.Body, interaction.Request.MatchingRules)
+	interaction.Response.Body, interaction.Response.MatchingRules = extractMatchers(interaction.Response.Body, interaction.Response.MatchingRules)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.interactions = append(m.interactions, interaction)
+	m.matched = append(m.matched, false)
+
+	return m
+}
+
+// URL returns the base URL of the mock provider.
+func (m *MockProvider) URL() *url.URL {
+	u, _ := url.Parse(m.server.URL)
+	return u
+}
+
+// Client returns an HTTP client which sends requests for any host to the mock
+// provider. It allows consumers with hard-coded provider URLs to be tested.
+func (m *MockProvider) Client() *http.Client {
+	return &http.Client{Transport: redirectTransport{target: m.URL(), inner: http.DefaultTransport}}
+}
+
+// Verify runs test, then checks that every registered interaction was
+// performed and that no unexpected requests were made. On success the
+// interactions are merged into the pact file. Interactions are cleared
+// afterwards, so Verify can be called once per scenario.
+func (m *MockProvider) Verify(test func() error) {
+	m.t.Helper()
+
+	testErr := test()
+
+	m.mu.Lock()
+	interactions, matched, unexpected := m.interactions, m.matched, m.unexpected
+	m.interactions, m.matched, m.unexpected = nil, nil, nil
+	m.mu.Unlock()
+
+	failed := false
+	if testErr != nil {
+		m.t.Errorf("consumer test failed: %s", testErr)
+		failed = true
+	}
+	for _, request := range unexpected {
+		m.t.Errorf("unexpected request: %s", request)
+		failed = true
+	}
+	for idx, interaction := range interactions {
+		if !matched[idx] {
+			m.t.Errorf("interaction %q was not performed", interaction.Description)
+			failed = true
+		}
+	}
+	if failed {
+		return
+	}
+
+	dir, err := Dir()
+	if err != nil {
+		m.t.Fatalf("failed to locate pact directory: %s", err)
+	}
+	if err := mergePact(dir, m.consumer, m.provider, interactions); err != nil {
+		m.t.Fatalf("failed to write pact: %s", err)
+	}
+}
+
+func (m *MockProvider) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	m.mu.Lock()
+	match := -1
+	for idx, interaction := range m.interactions {
+		if len(requestMismatches(interaction.Request, r, body)) == 0 {
+			match = idx
+			break
+		}
+	}
+	if match == -1 {
+		m.unexpected = append(m.unexpected, fmt.Sprintf("%s %s", r.Method, r.URL.RequestURI()))
+		m.mu.Unlock()
+		http.Error(w, "no interaction matches request", http.StatusInternalServerError)
+		return
+	}
+	m.matched[match] = true
+	response := m.interactions[match].Response
+	m.mu.Unlock()
+
+	raw, err := encodeBody(response.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	for name, value := range response.Headers {
+		w.Header().Set(name, value)
+	}
+	w.WriteHeader(response.Status)
+	_, _ = w.Write(raw)
+}
+
+// requestMismatches reports how r differs from the expected request. Request
+// bodies may not contain unexpected fields.
+func requestMismatches(expected Request, r *http.Request, body []byte) []string {
+	var mismatches []string
+	if expected.Method != r.Method {
+		mismatches = append(mismatches, fmt.Sprintf("method: expected %s but got %s", expected.Method, r.Method))
+	}
+	if expected.Path != r.URL.Path {
+		mismatches = append(mismatches, fmt.Sprintf("path: expected %s but got %s", expected.Path, r.URL.Path))
+	}
+	mismatches = append(mismatches, compareQuery(expected.Query, r.URL.RawQuery)...)
+	mismatches = append(mismatches, compareHeaders(expected.Headers, r.Header)...)
+	mismatches = append(mismatches, compareBody(expected.Body, body, expected.MatchingRules, false)...)
+
+	return mismatches
+}
+
+// redirectTransport sends every request to target, regardless of its host.
+type redirectTransport struct {
+	target *url.URL
+	inner  http.RoundTripper
+}
+
+func (t redirectTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	redirected := req.Clone(req.Context())
+	redirected.URL.Scheme = t.target.Scheme
+	redirected.URL.Host = t.target.Host
+	redirected.Host = ""
+
+	return t.inner.RoundTrip(redirected) //nolint:wrapcheck // the transport is transparent to callers
+}
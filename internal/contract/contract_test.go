@@ -0,0 +1,105 @@
+package contract
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestVerifyInteraction(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		_, _ = w.Write([]byte(`{"id":1,"status":"NEW","extra":true}`))
+	})
+
+	t.Run("allows extra response fields", func(t *testing.T) {
+		mismatches := verifyInteraction(handler, Interaction{
+			Request: Request{Method: http.MethodGet, Path: "/orders/1"},
+			Response: Response{
+				Status:  http.StatusOK,
+				Headers: map[string]string{"Content-Type": "application/json"},
+				Body:    map[string]interface{}{"id": 1, "status": "NEW"},
+			},
+		})
+		assert.Empty(t, mismatches)
+	})
+
+	t.Run("reports differing status, headers and fields", func(t *testing.T) {
+		mismatches := verifyInteraction(handler, Interaction{
+			Request: Request{Method: http.MethodGet, Path: "/orders/1"},
+			Response: Response{
+				Status:  http.StatusCreated,
+				Headers: map[string]string{"Cache-Control": "no-store"},
+				Body:    map[string]interface{}{"id": 1, "status": "FULFILLED", "restaurant": "roo"},
+			},
+		})
+		assert.Equal(t, []string{
+			"status: expected 201 but got 200",
+			`header Cache-Control: expected "no-store" but got ""`,
+			"$.body.restaurant: missing",
+			"$.body.status: expected FULFILLED but got NEW",
+		}, mismatches)
+	})
+}
+
+func TestMatchers(t *testing.T) {
+	body, rules := extractMatchers(map[string]interface{}{
+		"id":      Like(1),
+		"status":  Term("NEW", "^(NEW|FULFILLED)$"),
+		"created": Like("2024-01-01T12:00:00Z"),
+		"items":   EachLike(map[string]interface{}{"name": "chips", "quantity": Like(1)}, 1),
+	}, nil)
+	compare := func(actual string) []string {
+		return compareBody(body, []byte(actual), rules, true)
+	}
+
+	t.Run("writes matchers as examples and rules", func(t *testing.T) {
+		assert.Equal(t, map[string]interface{}{
+			"id":      1,
+			"status":  "NEW",
+			"created": "2024-01-01T12:00:00Z",
+			"items":   []interface{}{map[string]interface{}{"name": "chips", "quantity": 1}},
+		}, body)
+		assert.Equal(t, map[string]Rule{
+			"$.body.id":                {Match: "type"},
+			"$.body.status":            {Match: "regex", Regex: "^(NEW|FULFILLED)$"},
+			"$.body.created":           {Match: "type"},
+			"$.body.items":             {Match: "type", Min: 1},
+			"$.body.items[*].quantity": {Match: "type"},
+		}, rules)
+	})
+
+	t.Run("accepts other values matching the rules", func(t *testing.T) {
+		assert.Empty(t, compare(`{"id":42,"status":"FULFILLED","created":"2025-06-30T08:15:00Z","items":[{"name":"chips","quantity":2},{"name":"fries","quantity":3}]}`))
+	})
+
+	t.Run("reports values not matching the rules", func(t *testing.T) {
+		assert.Equal(t, []string{
+			"$.body.created: expected a string but got 1.7e+09",
+			"$.body.id: expected a number but got 42",
+			"$.body.items[0].name: expected a string but got 1",
+			"$.body.items[1].quantity: expected a number but got <nil>",
+			"$.body.status: expected a string matching ^(NEW|FULFILLED)$ but got CANCELLED",
+		}, compare(`{"id":"42","status":"CANCELLED","created":1700000000,"items":[{"name":1,"quantity":1},{"name":"chips","quantity":null}]}`))
+		assert.Equal(t, []string{"$.body.items: expected at least 1 elements but got 0"}, compare(`{"id":1,"status":"NEW","created":"","items":[]}`))
+	})
+
+	t.Run("reads rules from pact files", func(t *testing.T) {
+		mismatches := compareBody(map[string]interface{}{"ids": []interface{}{1.0}}, []byte(`{"ids":[2,3]}`), map[string]Rule{"$.body.ids": {Match: "type", Min: 1}}, true)
+		assert.Empty(t, mismatches)
+	})
+}
+
+func TestRequestMismatches(t *testing.T) {
+	expected := Request{
+		Method: http.MethodPost,
+		Path:   "/orders",
+		Query:  "a=1&b=2",
+		Body:   map[string]interface{}{"status": "NEW"},
+	}
+
+	r, _ := http.NewRequest(http.MethodPost, "/orders?b=2&a=1", nil)
+	assert.Empty(t, requestMismatches(expected, r, []byte(`{"status":"NEW"}`)))
+	assert.Equal(t, []string{"$.body.id: unexpected"}, requestMismatches(expected, r, []byte(`{"status":"NEW","id":1}`)))
+}
@@ -0,0 +1,208 @@
+package contract
+
+import (
+	"encoding/json"
+	"fmt"
+	"mime"
+	"net/http"
+	"net/url"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// encodeBody returns the wire form of a pact body.
+func encodeBody(body interface{}) ([]byte, error) {
+	switch b := body.(type) {
+	case nil:
+		return nil, nil
+	case string:
+		return []byte(b), nil
+	default:
+		raw, err := json.Marshal(b)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode body: %w", err)
+		}
+		return raw, nil
+	}
+}
+
+// normalize converts a Go value to the generic form produced by decoding JSON,
+// so that structs written by consumers compare equal to bodies read from files.
+func normalize(v interface{}) (interface{}, error) {
+	if s, ok := v.(string); ok {
+		return s, nil
+	}
+
+	raw, err := json.Marshal(v)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode body: %w", err)
+	}
+
+	var generic interface{}
+	if err := json.Unmarshal(raw, &generic); err != nil {
+		return nil, fmt.Errorf("failed to decode body: %w", err)
+	}
+
+	return generic, nil
+}
+
+// compareBody reports the differences between an expected pact body and the
+// raw body which was sent or received, relaxed by the matching rules. When
+// lenient is set, objects in actual may contain fields which are not expected,
+// following Postel's law for responses.
+func compareBody(expected interface{}, actual []byte, rules map[string]Rule, lenient bool) []string {
+	if expected == nil {
+		return nil
+	}
+
+	expected, err := normalize(expected)
+	if err != nil {
+		return []string{err.Error()}
+	}
+
+	if s, ok := expected.(string); ok {
+		if s != string(actual) {
+			return []string{fmt.Sprintf("body: expected %q but got %q", s, actual)}
+		}
+		return nil
+	}
+
+	set, err := compileRules(rules)
+	if err != nil {
+		return []string{err.Error()}
+	}
+
+	var decoded interface{}
+	if err := json.Unmarshal(actual, &decoded); err != nil {
+		return []string{fmt.Sprintf("body: expected JSON but got %q", actual)}
+	}
+
+	c := comparison{rules: set, lenient: lenient}
+	return c.values("$.body", expected, decoded)
+}
+
+// comparison compares decoded JSON values.
+type comparison struct {
+	rules   ruleSet
+	lenient bool
+}
+
+func (c comparison) values(path string, expected, actual interface{}) []string {
+	rule, exact := c.rules.lookup(path)
+	if rule != nil && rule.Match == matchRegex {
+		if s, ok := actual.(string); !ok || !rule.pattern.MatchString(s) {
+			return []string{fmt.Sprintf("%s: expected a string matching %s but got %v", path, rule.Regex, actual)}
+		}
+		return nil
+	}
+	byType := rule != nil && rule.Match == matchType
+
+	switch e := expected.(type) {
+	case map[string]interface{}:
+		a, ok := actual.(map[string]interface{})
+		if !ok {
+			return []string{fmt.Sprintf("%s: expected an object but got %v", path, actual)}
+		}
+
+		var mismatches []string
+		for _, key := range sortedKeys(e) {
+			value, ok := a[key]
+			if !ok {
+				mismatches = append(mismatches, fmt.Sprintf("%s: missing", fieldPath(path, key)))
+				continue
+			}
+			mismatches = append(mismatches, c.values(fieldPath(path, key), e[key], value)...)
+		}
+		if !c.lenient {
+			for _, key := range sortedKeys(a) {
+				if _, ok := e[key]; !ok {
+					mismatches = append(mismatches, fmt.Sprintf("%s: unexpected", fieldPath(path, key)))
+				}
+			}
+		}
+		return mismatches
+	case []interface{}:
+		a, ok := actual.([]interface{})
+		if !ok {
+			return []string{fmt.Sprintf("%s: expected an array but got %v", path, actual)}
+		}
+
+		var mismatches []string
+		if byType && exact && rule.Min > 0 && len(e) > 0 {
+			if len(a) < rule.Min {
+				return []string{fmt.Sprintf("%s: expected at least %d elements but got %d", path, rule.Min, len(a))}
+			}
+			for idx := range a {
+				mismatches = append(mismatches, c.values(fmt.Sprintf("%s[%d]", path, idx), e[0], a[idx])...)
+			}
+			return mismatches
+		}
+		if len(a) != len(e) {
+			return []string{fmt.Sprintf("%s: expected %v but got %v", path, e, actual)}
+		}
+		for idx := range e {
+			mismatches = append(mismatches, c.values(fmt.Sprintf("%s[%d]", path, idx), e[idx], a[idx])...)
+		}
+		return mismatches
+	default:
+		if byType {
+			if jsonType(expected) != jsonType(actual) {
+				return []string{fmt.Sprintf("%s: expected a %s but got %v", path, jsonType(expected), actual)}
+			}
+			return nil
+		}
+		if !reflect.DeepEqual(expected, actual) {
+			return []string{fmt.Sprintf("%s: expected %v but got %v", path, expected, actual)}
+		}
+		return nil
+	}
+}
+
+// compareHeaders reports expected headers which are missing or differ in
+// actual. Media types are compared without their parameters.
+func compareHeaders(expected map[string]string, actual http.Header) []string {
+	var mismatches []string
+	for _, name := range sortedKeys(expected) {
+		want, got := expected[name], actual.Get(name)
+		if strings.EqualFold(name, "Content-Type") {
+			want, got = mediaType(want), mediaType(got)
+		}
+		if want != got {
+			mismatches = append(mismatches, fmt.Sprintf("header %s: expected %q but got %q", name, want, got))
+		}
+	}
+	return mismatches
+}
+
+func compareQuery(expected, actual string) []string {
+	want, err := url.ParseQuery(expected)
+	if err != nil {
+		return []string{fmt.Sprintf("query: invalid expected query %q", expected)}
+	}
+	got, err := url.ParseQuery(actual)
+	if err != nil {
+		return []string{fmt.Sprintf("query: invalid query %q", actual)}
+	}
+	if want.Encode() != got.Encode() {
+		return []string{fmt.Sprintf("query: expected %q but got %q", want.Encode(), got.Encode())}
+	}
+	return nil
+}
+
+func mediaType(value string) string {
+	mediaType, _, err := mime.ParseMediaType(value)
+	if err != nil {
+		return value
+	}
+	return mediaType
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
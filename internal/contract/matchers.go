@@ -0,0 +1,182 @@
+package contract
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+const (
+	matchType  = "type"
+	matchRegex = "regex"
+)
+
+// Rule is a Pact specification v2 matching rule. It relaxes how the body value
+// at its path is compared, along with every value nested in it.
+type Rule struct {
+	// Match is "type" to accept any value of the same JSON type as the
+	// example, or "regex" to accept strings matching Regex.
+	Match string `json:"match"`
+	Regex string `json:"regex,omitempty"`
+	// Min is the least number of elements an array must have. Each element
+	// is compared with the first one in the example.
+	Min int `json:"min,omitempty"`
+}
+
+// Matcher is a body value compared by a Rule rather than for equality. The
+// example is sent, or served, in its place. Build one with Like, EachLike or
+// Term.
+type Matcher struct {
+	rule    Rule
+	example interface{}
+	each    bool
+}
+
+// Like matches any value of the same JSON type as example, such as an ID or
+// a timestamp which differs between runs. Values nested in example are
+// matched by type too.
+func Like(example interface{}) Matcher {
+	return Matcher{rule: Rule{Match: matchType}, example: example}
+}
+
+// EachLike matches an array of at least min elements, each of which is like
+// example.
+func EachLike(example interface{}, min int) Matcher {
+	if min < 1 {
+		min = 1
+	}
+	return Matcher{rule: Rule{Match: matchType, Min: min}, example: example, each: true}
+}
+
+// Term matches strings matching regex, with example sent or served in their
+// place.
+func Term(example, regex string) Matcher {
+	return Matcher{rule: Rule{Match: matchRegex, Regex: regex}, example: example}
+}
+
+// extractMatchers replaces the matchers in body with their examples. It
+// returns the rules of the matchers by path, added to rules.
+func extractMatchers(body interface{}, rules map[string]Rule) (interface{}, map[string]Rule) {
+	extracted := map[string]Rule{}
+	for path, rule := range rules {
+		extracted[path] = rule
+	}
+
+	body = extractRules("$.body", body, extracted)
+	if len(extracted) == 0 {
+		return body, nil
+	}
+	return body, extracted
+}
+
+func extractRules(path string, body interface{}, rules map[string]Rule) interface{} {
+	switch b := body.(type) {
+	case Matcher:
+		rules[path] = b.rule
+		if !b.each {
+			return extractRules(path, b.example, rules)
+		}
+		element := extractRules(path+"[*]", b.example, rules)
+		examples := make([]interface{}, b.rule.Min)
+		for idx := range examples {
+			examples[idx] = element
+		}
+		return examples
+	case map[string]interface{}:
+		values := make(map[string]interface{}, len(b))
+		for key, value := range b {
+			values[key] = extractRules(fieldPath(path, key), value, rules)
+		}
+		return values
+	case []interface{}:
+		values := make([]interface{}, len(b))
+		for idx, value := range b {
+			values[idx] = extractRules(fmt.Sprintf("%s[%d]", path, idx), value, rules)
+		}
+		return values
+	default:
+		return body
+	}
+}
+
+var identifier = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// fieldPath returns the path of the field key of the object at path.
+func fieldPath(path, key string) string {
+	if identifier.MatchString(key) {
+		return path + "." + key
+	}
+	return path + "['" + key + "']"
+}
+
+// ruleSet finds the rule applying to a path.
+type ruleSet []compiledRule
+
+type compiledRule struct {
+	Rule
+	path    *regexp.Regexp
+	depth   int
+	pattern *regexp.Regexp
+}
+
+// compileRules compiles rules, most specific first.
+func compileRules(rules map[string]Rule) (ruleSet, error) {
+	set := make(ruleSet, 0, len(rules))
+	for _, path := range sortedKeys(rules) {
+		rule := rules[path]
+		compiled := compiledRule{Rule: rule, depth: strings.Count(path, ".") + strings.Count(path, "[")}
+
+		expr := regexp.QuoteMeta(path)
+		expr = strings.ReplaceAll(expr, `\[\*\]`, `\[\d+\]`)
+		expr = strings.ReplaceAll(expr, `\.\*`, `(?:\.[^.\[]+|\['[^']*'\])`)
+		compiled.path = regexp.MustCompile("^" + expr + `(?:$|[.\[])`)
+
+		switch rule.Match {
+		case matchType:
+		case matchRegex:
+			pattern, err := regexp.Compile(rule.Regex)
+			if err != nil {
+				return nil, fmt.Errorf("invalid regex for %s: %w", path, err)
+			}
+			compiled.pattern = pattern
+		default:
+			return nil, fmt.Errorf("unsupported match %q for %s", rule.Match, path)
+		}
+		set = append(set, compiled)
+	}
+
+	sort.SliceStable(set, func(i, j int) bool { return set[i].depth > set[j].depth })
+	return set, nil
+}
+
+// lookup returns the most specific rule applying to path, and whether it was
+// declared for path itself rather than for a value path is nested in.
+func (s ruleSet) lookup(path string) (rule *compiledRule, exact bool) {
+	for idx := range s {
+		loc := s[idx].path.FindStringIndex(path)
+		if loc == nil {
+			continue
+		}
+		return &s[idx], loc[1] == len(path)
+	}
+	return nil, false
+}
+
+// jsonType returns the JSON type of a decoded JSON value.
+func jsonType(v interface{}) string {
+	switch v.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case float64:
+		return "number"
+	case string:
+		return "string"
+	case []interface{}:
+		return "array"
+	default:
+		return "object"
+	}
+}
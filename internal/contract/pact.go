@@ -0,0 +1,201 @@
+// Package contract implements consumer-driven contract testing using Pact
+// specification v2 files, without a Pact broker or the Pact mock service.
+//
+// Bodies are compared for equality, except where a Matcher (Like, EachLike or
+// Term) stands for a value which differs between runs, such as an ID or a
+// timestamp. Matchers are written to pact files as matching rules.
+//
+// Consumer tests use a MockProvider to describe the interactions they expect
+// from a provider, and write them to a pact file once the consumer code has
+// exercised them. Provider tests replay every pact file naming the provider
+// against an http.Handler with VerifyProvider.
+//
+// Pact files are read from and written to Dir().
+package contract
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// DirEnvVar overrides the directory pact files are read from and written to.
+const DirEnvVar = "PACT_DIR"
+
+const specificationVersion = "2.0.0"
+
+// Pact is a contract between a consumer and a provider.
+type Pact struct {
+	Consumer     Pacticipant   `json:"consumer"`
+	Provider     Pacticipant   `json:"provider"`
+	Interactions []Interaction `json:"interactions"`
+	Metadata     Metadata      `json:"metadata"`
+}
+
+// Pacticipant names one side of a Pact.
+type Pacticipant struct {
+	Name string `json:"name"`
+}
+
+// Metadata describes the pact file format.
+type Metadata struct {
+	PactSpecification PactSpecification `json:"pactSpecification"`
+}
+
+// PactSpecification is the version of the pact file format.
+type PactSpecification struct {
+	Version string `json:"version"`
+}
+
+// Interaction is a request the consumer sends, and the response it expects
+// when the provider is in ProviderState.
+type Interaction struct {
+	Description   string   `json:"description"`
+	ProviderState string   `json:"providerState,omitempty"`
+	Request       Request  `json:"request"`
+	Response      Response `json:"response"`
+}
+
+// Request is the request a consumer sends. Body is encoded as JSON, unless it
+// is a string, which is sent as-is. Values in Body may be Matchers.
+type Request struct {
+	Method  string            `json:"method"`
+	Path    string            `json:"path"`
+	Query   string            `json:"query,omitempty"`
+	Headers map[string]string `json:"headers,omitempty"`
+	Body    interface{}       `json:"body,omitempty"`
+	// MatchingRules relax how the values in Body are compared, by their
+	// path. They are filled in from the Matchers in Body.
+	MatchingRules map[string]Rule `json:"matchingRules,omitempty"`
+}
+
+// Response is the response a consumer expects. Headers are the minimum set
+// the provider must return, and object fields in Body are the minimum set the
+// provider must include. Values in Body may be Matchers.
+type Response struct {
+	Status  int               `json:"status"`
+	Headers map[string]string `json:"headers,omitempty"`
+	Body    interface{}       `json:"body,omitempty"`
+	// MatchingRules relax how the values in Body are compared, by their
+	// path. They are filled in from the Matchers in Body.
+	MatchingRules map[string]Rule `json:"matchingRules,omitempty"`
+}
+
+// Dir returns the directory holding pact files: DirEnvVar when set, otherwise
+// the pacts directory at the root of the module.
+func Dir() (string, error) {
+	if dir := os.Getenv(DirEnvVar); dir != "" {
+		return dir, nil
+	}
+
+	dir, err := os.Getwd()
+	if err != nil {
+		return "", fmt.Errorf("failed to get working directory: %w", err)
+	}
+	for {
+		if _, err := os.Stat(filepath.Join(dir, "go.mod")); err == nil {
+			return filepath.Join(dir, "pacts"), nil
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", errors.New("failed to find module root for pact directory")
+		}
+		dir = parent
+	}
+}
+
+// fileName returns the conventional pact file name for a consumer and provider.
+func fileName(consumer, provider string) string {
+	slug := func(name string) string {
+		return strings.ReplaceAll(strings.ToLower(name), " ", "_")
+	}
+	return slug(consumer) + "-" + slug(provider) + ".json"
+}
+
+func readPact(path string) (*Pact, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read pact file: %w", err)
+	}
+
+	var pact Pact
+	if err := json.Unmarshal(raw, &pact); err != nil {
+		return nil, fmt.Errorf("failed to parse pact file %s: %w", path, err)
+	}
+
+	return &pact, nil
+}
+
+// readPacts returns every pact in dir whose provider is named provider.
+func readPacts(dir, provider string) ([]*Pact, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list pact files: %w", err)
+	}
+
+	var pacts []*Pact
+	for _, path := range paths {
+		pact, err := readPact(path)
+		if err != nil {
+			return nil, err
+		}
+		if pact.Provider.Name == provider {
+			pacts = append(pacts, pact)
+		}
+	}
+
+	return pacts, nil
+}
+
+// mergePact adds interactions to the pact file in dir, replacing interactions
+// with the same description and provider state.
+func mergePact(dir, consumer, provider string, interactions []Interaction) error {
+	path := filepath.Join(dir, fileName(consumer, provider))
+
+	pact := &Pact{
+		Consumer: Pacticipant{Name: consumer},
+		Provider: Pacticipant{Name: provider},
+		Metadata: Metadata{PactSpecification: PactSpecification{Version: specificationVersion}},
+	}
+	if existing, err := readPact(path); err == nil {
+		pact.Interactions = existing.Interactions
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+
+	key := func(i Interaction) string {
+		return i.Description + "\x00" + i.ProviderState
+	}
+	for _, interaction := range interactions {
+		replaced := false
+		for idx := range pact.Interactions {
+			if key(pact.Interactions[idx]) == key(interaction) {
+				pact.Interactions[idx] = interaction
+				replaced = true
+			}
+		}
+		if !replaced {
+			pact.Interactions = append(pact.Interactions, interaction)
+		}
+	}
+	sort.SliceStable(pact.Interactions, func(i, j int) bool {
+		return key(pact.Interactions[i]) < key(pact.Interactions[j])
+	})
+
+	raw, err := json.MarshalIndent(pact, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode pact: %w", err)
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return fmt.Errorf("failed to create pact directory: %w", err)
+	}
+	if err := os.WriteFile(path, append(raw, '\n'), 0o600); err != nil {
+		return fmt.Errorf("failed to write pact file: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,113 @@
+package contract
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// StateHandler puts the provider into a named provider state before an
+// interaction is verified.
+type StateHandler func() error
+
+// VerifyRequest configures provider verification.
+type VerifyRequest struct {
+	// Provider is the name of the provider being verified. Every pact file
+	// naming it is verified.
+	Provider string
+
+	// Handler serves the provider's HTTP API.
+	Handler http.Handler
+
+	// StateHandlers maps provider states to the functions setting them up.
+	StateHandlers map[string]StateHandler
+
+	// BeforeEach, when set, is called before each interaction, typically to
+	// reset state left behind by the previous one.
+	BeforeEach func()
+}
+
+// VerifyProvider replays every interaction from the pact files for the provider
+// against its handler, and fails the test for each response that does not
+// satisfy the consumer's expectations.
+func VerifyProvider(t *testing.T, req VerifyRequest) {
+	t.Helper()
+
+	dir, err := Dir()
+	if err != nil {
+		t.Fatalf("failed to locate pact directory: %s", err)
+	}
+
+	pacts, err := readPacts(dir, req.Provider)
+	if err != nil {
+		t.Fatalf("failed to read pacts: %s", err)
+	}
+	if len(pacts) == 0 {
+		t.Skipf("no pacts found for provider %q in %s", req.Provider, dir)
+	}
+
+	for _, pact := range pacts {
+		for _, interaction := range pact.Interactions {
+			interaction := interaction
+			t.Run(pact.Consumer.Name+"/"+interaction.Description, func(t *testing.T) {
+				if req.BeforeEach != nil {
+					req.BeforeEach()
+				}
+
+				if interaction.ProviderState != "" {
+					setState, ok := req.StateHandlers[interaction.ProviderState]
+					if !ok {
+						t.Fatalf("no state handler for provider state %q", interaction.ProviderState)
+					}
+					if err := setState(); err != nil {
+						t.Fatalf("failed to set up provider state %q: %s", interaction.ProviderState, err)
+					}
+				}
+
+				for _, mismatch := range verifyInteraction(req.Handler, interaction) {
+					t.Error(mismatch)
+				}
+			})
+		}
+	}
+}
+
+// verifyInteraction sends the interaction's request to handler and reports how
+// the response differs from the expected one.
+func verifyInteraction(handler http.Handler, interaction Interaction) []string {
+	body, err := encodeBody(interaction.Request.Body)
+	if err != nil {
+		return []string{err.Error()}
+	}
+
+	target := interaction.Request.Path
+	if interaction.Request.Query != "" {
+		target += "?" + interaction.Request.Query
+	}
+	r := httptest.NewRequest(interaction.Request.Method, target, bytes.NewReader(body))
+	for name, value := range interaction.Request.Headers {
+		r.Header.Set(name, value)
+	}
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	res := w.Result()
+	defer res.Body.Close()
+
+	actual, err := io.ReadAll(res.Body)
+	if err != nil {
+		return []string{err.Error()}
+	}
+
+	var mismatches []string
+	if res.StatusCode != interaction.Response.Status {
+		mismatches = append(mismatches, fmt.Sprintf("status: expected %d but got %d", interaction.Response.Status, res.StatusCode))
+	}
+	mismatches = append(mismatches, compareHeaders(interaction.Response.Headers, res.Header)...)
+	mismatches = append(mismatches, compareBody(interaction.Response.Body, actual, interaction.Response.MatchingRules, true)...)
+
+	return mismatches
+}
@@ -0,0 +1,215 @@
+// Package cors lets browser-based tools on other origins call the service,
+// by answering their CORS preflight requests and marking the responses they
+// may read, see https://fetch.spec.whatwg.org/#http-cors-protocol.
+//
+// gorilla/mux only runs middleware for requests which match a route, and
+// preflight requests use the OPTIONS method, which no route is registered
+// for. PreflightHandler is registered for the OPTIONS requests of every path,
+// so that Middleware runs for them, and answers those for which a route
+// serves the requested method.
+package cors
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// Headers of the CORS protocol.
+const (
+	OriginHeader           = "Origin"
+	RequestMethodHeader    = "Access-Control-Request-Method"
+	RequestHeadersHeader   = "Access-Control-Request-Headers"
+	AllowOriginHeader      = "Access-Control-Allow-Origin"
+	AllowCredentialsHeader = "Access-Control-Allow-Credentials"
+	AllowMethodsHeader     = "Access-Control-Allow-Methods"
+	AllowHeadersHeader     = "Access-Control-Allow-Headers"
+	ExposeHeadersHeader    = "Access-Control-Expose-Headers"
+	MaxAgeHeader           = "Access-Control-Max-Age"
+)
+
+// Wildcard allows any origin or request header.
+const Wildcard = "*"
+
+// Policy is which cross-origin requests browsers may make.
+type Policy struct {
+	// AllowedOrigins are the origins allowed to call the service, e.g.
+	// https://backoffice.example.com, or Wildcard for any origin.
+	AllowedOrigins []string
+	// AllowedMethods are the methods of the requests allowed.
+	AllowedMethods []string
+	// AllowedHeaders are the request headers allowed, or Wildcard for any.
+	AllowedHeaders []string
+	// ExposedHeaders are the response headers, beyond the CORS-safelisted
+	// ones, which browsers let the caller read.
+	ExposedHeaders []string
+	// AllowCredentials lets browsers send cookies and Authorization headers,
+	// and read the responses. Credentials are not allowed for Wildcard
+	// origins, as browsers would reject them.
+	AllowCredentials bool
+	// MaxAge is how long browsers may cache the answers to preflight
+	// requests. When zero, browsers use their default of 5 seconds.
+	MaxAge time.Duration
+}
+
+type middleware struct {
+	policy         Policy
+	anyOrigin      bool
+	origins        map[string]struct{}
+	methods        map[string]struct{}
+	anyHeader      bool
+	headers        map[string]struct{}
+	allowMethods   string
+	allowHeaders   string
+	exposedHeaders string
+}
+
+// Middleware adds the CORS headers of policy to the responses to requests
+// from allowed origins, and to preflight requests for allowed methods and
+// headers. Other requests are served without them, so that browsers do not
+// let the caller read the response. Responses vary by Origin.
+func Middleware(policy Policy) func(http.Handler) http.Handler {
+	m := &middleware{
+		policy:         policy,
+		origins:        set(policy.AllowedOrigins, strings.ToLower),
+		methods:        set(policy.AllowedMethods, strings.ToUpper),
+		headers:        set(policy.AllowedHeaders, http.CanonicalHeaderKey),
+		allowMethods:   strings.Join(policy.AllowedMethods, ", "),
+		allowHeaders:   strings.Join(policy.AllowedHeaders, ", "),
+		exposedHeaders: strings.Join(policy.ExposedHeaders, ", "),
+	}
+	_, m.anyOrigin = m.origins[Wildcard]
+	_, m.anyHeader = m.headers[Wildcard]
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			m.serve(next, w, r)
+		})
+	}
+}
+
+// set returns the values, normalised by normalise, as a set.
+func set(values []string, normalise func(string) string) map[string]struct{} {
+	s := make(map[string]struct{}, len(values))
+	for _, value := range values {
+		if value = strings.TrimSpace(value); value != "" {
+			s[normalise(value)] = struct{}{}
+		}
+	}
+	return s
+}
+
+func (m *middleware) serve(next http.Handler, w http.ResponseWriter, r *http.Request) {
+	header := w.Header()
+	header.Add("Vary", OriginHeader)
+
+	origin := r.Header.Get(OriginHeader)
+	if isPreflight(r) {
+		header.Add("Vary", RequestMethodHeader)
+		header.Add("Vary", RequestHeadersHeader)
+		if m.allowsOrigin(origin) && m.allowsMethod(r.Header.Get(RequestMethodHeader)) && m.allowsHeaders(r.Header.Values(RequestHeadersHeader)) {
+			m.allowOrigin(header, origin)
+			header.Set(AllowMethodsHeader, m.allowMethods)
+			if m.anyHeader {
+				// Browsers do not accept a wildcard with credentials, so the
+				// headers requested are allowed by name.
+				header.Set(AllowHeadersHeader, strings.Join(r.Header.Values(RequestHeadersHeader), ", "))
+			} else if m.allowHeaders != "" {
+				header.Set(AllowHeadersHeader, m.allowHeaders)
+			}
+			if m.policy.MaxAge > 0 {
+				header.Set(MaxAgeHeader, strconv.Itoa(int(m.policy.MaxAge.Seconds())))
+			}
+		}
+		next.ServeHTTP(w, r)
+		return
+	}
+
+	if origin != "" && m.allowsOrigin(origin) {
+		m.allowOrigin(header, origin)
+		if m.exposedHeaders != "" {
+			header.Set(ExposeHeadersHeader, m.exposedHeaders)
+		}
+	}
+	next.ServeHTTP(w, r)
+}
+
+// allowOrigin lets browsers on origin read the response.
+func (m *middleware) allowOrigin(header http.Header, origin string) {
+	if m.anyOrigin {
+		header.Set(AllowOriginHeader, Wildcard)
+		return
+	}
+	header.Set(AllowOriginHeader, origin)
+	if m.policy.AllowCredentials {
+		header.Set(AllowCredentialsHeader, "true")
+	}
+}
+
+func (m *middleware) allowsOrigin(origin string) bool {
+	if origin == "" {
+		return false
+	}
+	if m.anyOrigin {
+		return true
+	}
+	_, ok := m.origins[strings.ToLower(origin)]
+	return ok
+}
+
+func (m *middleware) allowsMethod(method string) bool {
+	_, ok := m.methods[method]
+	return ok
+}
+
+// allowsHeaders reports whether every header named in the
+// Access-Control-Request-Headers values is allowed.
+func (m *middleware) allowsHeaders(values []string) bool {
+	if m.anyHeader {
+		return true
+	}
+	for _, value := range values {
+		for _, name := range strings.Split(value, ",") {
+			if name = strings.TrimSpace(name); name == "" {
+				continue
+			}
+			if _, ok := m.headers[http.CanonicalHeaderKey(name)]; !ok {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// isPreflight reports whether r is a CORS preflight request.
+func isPreflight(r *http.Request) bool {
+	return r.Method == http.MethodOptions && r.Header.Get(OriginHeader) != "" && r.Header.Get(RequestMethodHeader) != ""
+}
+
+// PreflightHandler answers preflight requests with 204 No Content when a route
+// of router serves the requested method at their path, and with 404 Not
+// Found otherwise. Other OPTIONS requests are answered with 405 Method Not
+// Allowed, as they would be without it. Register it for the OPTIONS method of
+// every path of router, e.g.
+//
+//	router.Methods(http.MethodOptions).Handler(cors.PreflightHandler(router))
+func PreflightHandler(router *mux.Router) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !isPreflight(r) {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+
+		requested := r.Clone(r.Context())
+		requested.Method = r.Header.Get(RequestMethodHeader)
+		var match mux.RouteMatch
+		if requested.Method == http.MethodOptions || !router.Match(requested, &match) || match.MatchErr != nil {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
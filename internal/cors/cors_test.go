@@ -0,0 +1,126 @@
+package cors_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/deliveroo/bnt-internal-test-go/internal/cors"
+)
+
+const backOffice = "https://backoffice.example.com"
+
+// newRouter returns a router serving GET and POST /orders, and answering
+// preflight requests with policy.
+func newRouter(policy cors.Policy) *mux.Router {
+	r := mux.NewRouter()
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Location", "/orders/1")
+	})
+	r.Handle("/orders", ok).Methods(http.MethodGet, http.MethodPost)
+	r.Methods(http.MethodOptions).Handler(cors.PreflightHandler(r))
+	r.Use(cors.Middleware(policy))
+	return r
+}
+
+func TestMiddleware(t *testing.T) {
+	policy := cors.Policy{
+		AllowedOrigins:   []string{backOffice},
+		AllowedMethods:   []string{http.MethodGet, http.MethodPost},
+		AllowedHeaders:   []string{"Authorization", "Content-Type"},
+		ExposedHeaders:   []string{"Location"},
+		AllowCredentials: true,
+		MaxAge:           10 * time.Minute,
+	}
+	router := newRouter(policy)
+
+	serve := func(router http.Handler, method, target string, header http.Header) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, target, nil)
+		for name, values := range header {
+			req.Header[name] = values
+		}
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		return rec
+	}
+	preflight := func(router http.Handler, origin, method, headers string) *httptest.ResponseRecorder {
+		return serve(router, http.MethodOptions, "/orders", http.Header{
+			cors.OriginHeader:         {origin},
+			cors.RequestMethodHeader:  {method},
+			cors.RequestHeadersHeader: {headers},
+		})
+	}
+
+	t.Run("answers preflight requests from allowed origins", func(t *testing.T) {
+		rec := preflight(router, backOffice, http.MethodPost, "content-type, authorization")
+		assert.Equal(t, http.StatusNoContent, rec.Code)
+		assert.Equal(t, backOffice, rec.Header().Get(cors.AllowOriginHeader))
+		assert.Equal(t, "true", rec.Header().Get(cors.AllowCredentialsHeader))
+		assert.Equal(t, "GET, POST", rec.Header().Get(cors.AllowMethodsHeader))
+		assert.Equal(t, "Authorization, Content-Type", rec.Header().Get(cors.AllowHeadersHeader))
+		assert.Equal(t, "600", rec.Header().Get(cors.MaxAgeHeader))
+		assert.Equal(t, []string{cors.OriginHeader, cors.RequestMethodHeader, cors.RequestHeadersHeader}, rec.Header().Values("Vary"))
+	})
+
+	t.Run("does not allow preflight requests beyond the policy", func(t *testing.T) {
+		for name, rec := range map[string]*httptest.ResponseRecorder{
+			"origin": preflight(router, "https://evil.example.com", http.MethodGet, ""),
+			"method": preflight(router, backOffice, http.MethodDelete, ""),
+			"header": preflight(router, backOffice, http.MethodGet, "X-Custom"),
+		} {
+			assert.Empty(t, rec.Header().Get(cors.AllowOriginHeader), name)
+			assert.Empty(t, rec.Header().Get(cors.AllowMethodsHeader), name)
+		}
+	})
+
+	t.Run("responds not found to preflight requests for methods no route serves", func(t *testing.T) {
+		allowDelete := newRouter(cors.Policy{AllowedOrigins: []string{backOffice}, AllowedMethods: []string{http.MethodDelete}})
+		assert.Equal(t, http.StatusNotFound, preflight(allowDelete, backOffice, http.MethodDelete, "").Code)
+		assert.Equal(t, http.StatusNotFound, serve(router, http.MethodOptions, "/missing", http.Header{
+			cors.OriginHeader:        {backOffice},
+			cors.RequestMethodHeader: {http.MethodGet},
+		}).Code)
+	})
+
+	t.Run("rejects OPTIONS requests which are not preflight requests", func(t *testing.T) {
+		assert.Equal(t, http.StatusMethodNotAllowed, serve(router, http.MethodOptions, "/orders", nil).Code)
+	})
+
+	t.Run("lets allowed origins read responses", func(t *testing.T) {
+		rec := serve(router, http.MethodGet, "/orders", http.Header{cors.OriginHeader: {backOffice}})
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, backOffice, rec.Header().Get(cors.AllowOriginHeader))
+		assert.Equal(t, "true", rec.Header().Get(cors.AllowCredentialsHeader))
+		assert.Equal(t, "Location", rec.Header().Get(cors.ExposeHeadersHeader))
+		assert.Equal(t, cors.OriginHeader, rec.Header().Get("Vary"))
+	})
+
+	t.Run("serves other origins without CORS headers", func(t *testing.T) {
+		for _, header := range []http.Header{{cors.OriginHeader: {"https://evil.example.com"}}, nil} {
+			rec := serve(router, http.MethodGet, "/orders", header)
+			assert.Equal(t, http.StatusOK, rec.Code)
+			assert.Empty(t, rec.Header().Get(cors.AllowOriginHeader))
+			assert.Equal(t, cors.OriginHeader, rec.Header().Get("Vary"))
+		}
+	})
+
+	t.Run("allows any origin without credentials", func(t *testing.T) {
+		anyOrigin := newRouter(cors.Policy{
+			AllowedOrigins:   []string{cors.Wildcard},
+			AllowedMethods:   []string{http.MethodGet},
+			AllowedHeaders:   []string{cors.Wildcard},
+			AllowCredentials: true,
+		})
+
+		rec := preflight(anyOrigin, "http://localhost:3001", http.MethodGet, "X-Custom")
+		assert.Equal(t, http.StatusNoContent, rec.Code)
+		assert.Equal(t, cors.Wildcard, rec.Header().Get(cors.AllowOriginHeader))
+		assert.Empty(t, rec.Header().Get(cors.AllowCredentialsHeader))
+		assert.Equal(t, "X-Custom", rec.Header().Get(cors.AllowHeadersHeader))
+		assert.Empty(t, rec.Header().Get(cors.MaxAgeHeader))
+	})
+}
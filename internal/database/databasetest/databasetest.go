@@ -0,0 +1,135 @@
+// Package databasetest provisions isolated Postgres schemas for integration
+// tests.
+//
+// Tests using it are skipped unless URLEnvVar names a database. Each call to
+// NewPool creates a schema of its own, applies the migrations to it and drops
+// it when the test completes, so tests can run in parallel against a shared
+// database without interfering with each other.
+package databasetest
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"net/url"
+	"os"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	pgxUUID "github.com/vgarvardt/pgx-google-uuid/v5"
+
+	"github.com/deliveroo/bnt-internal-test-go/internal/database"
+)
+
+// URLEnvVar names the database integration tests connect to.
+const URLEnvVar = "TEST_DATABASE_URL"
+
+var invalidSchemaChars = regexp.MustCompile(`[^a-z0-9_]+`)
+
+// NewPool returns a pool whose connections use a new, migrated schema. The
+// test is skipped when URLEnvVar is not set.
+func NewPool(tb testing.TB) *pgxpool.Pool {
+	tb.Helper()
+
+	databaseURL := NewURL(tb)
+
+	cfg, err := pgxpool.ParseConfig(databaseURL)
+	if err != nil {
+		tb.Fatalf("failed to parse %s: %s", databaseURL, err)
+	}
+	cfg.AfterConnect = func(ctx context.Context, conn *pgx.Conn) error {
+		pgxUUID.Register(conn.TypeMap())
+		return nil
+	}
+
+	pool, err := pgxpool.NewWithConfig(context.Background(), cfg)
+	if err != nil {
+		tb.Fatalf("failed to connect to %s: %s", databaseURL, err)
+	}
+	tb.Cleanup(pool.Close)
+
+	return pool
+}
+
+// NewURL returns the URL of the database named by URLEnvVar, whose
+// connections use a new, migrated schema, for tests of code which connects
+// itself. The test is skipped when URLEnvVar is not set.
+func NewURL(tb testing.TB) string {
+	tb.Helper()
+
+	base := os.Getenv(URLEnvVar)
+	if base == "" {
+		tb.Skipf("%s is not set, skipping test which needs Postgres (run `make start-containers` and `make test-integration`)", URLEnvVar)
+	}
+
+	ctx := context.Background()
+
+	admin, err := pgxpool.New(ctx, base)
+	if err != nil {
+		tb.Fatalf("failed to connect to %s: %s", URLEnvVar, err)
+	}
+	defer admin.Close()
+
+	schema := schemaName(tb)
+	if _, err := admin.Exec(ctx, `CREATE SCHEMA `+pgx.Identifier{schema}.Sanitize()); err != nil {
+		tb.Fatalf("failed to create schema %s: %s", schema, err)
+	}
+	tb.Cleanup(func() {
+		dropSchema(tb, base, schema)
+	})
+
+	u, err := url.Parse(base)
+	if err != nil {
+		tb.Fatalf("failed to parse %s: %s", URLEnvVar, err)
+	}
+	query := u.Query()
+	query.Set("search_path", schema)
+	u.RawQuery = query.Encode()
+
+	pool, err := pgxpool.New(ctx, u.String())
+	if err != nil {
+		tb.Fatalf("failed to connect to schema %s: %s", schema, err)
+	}
+	defer pool.Close()
+
+	if err := database.Migrate(ctx, pool); err != nil {
+		tb.Fatalf("failed to migrate schema %s: %s", schema, err)
+	}
+
+	return u.String()
+}
+
+func dropSchema(tb testing.TB, url, schema string) {
+	tb.Helper()
+
+	ctx := context.Background()
+
+	admin, err := pgxpool.New(ctx, url)
+	if err != nil {
+		tb.Errorf("failed to connect to drop schema %s: %s", schema, err)
+		return
+	}
+	defer admin.Close()
+
+	if _, err := admin.Exec(ctx, `DROP SCHEMA `+pgx.Identifier{schema}.Sanitize()+` CASCADE`); err != nil {
+		tb.Errorf("failed to drop schema %s: %s", schema, err)
+	}
+}
+
+// schemaName derives a unique, recognisable schema name from the test name.
+func schemaName(tb testing.TB) string {
+	suffix := make([]byte, 4)
+	if _, err := rand.Read(suffix); err != nil {
+		tb.Fatalf("failed to generate schema name: %s", err)
+	}
+
+	name := invalidSchemaChars.ReplaceAllString(strings.ToLower(tb.Name()), "_")
+	if len(name) > 40 {
+		name = name[:40]
+	}
+
+	return "test_" + name + "_" + hex.EncodeToString(suffix)
+}
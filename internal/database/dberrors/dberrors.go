@@ -0,0 +1,147 @@
+// Package dberrors converts errors from Postgres into errors the rest of the
+// application can act on without knowing about Postgres, such as a conflict
+// with an existing row.
+//
+// Repositories pass their errors through Map. Callers test the result with
+// errors.Is against the Err* kinds, and use errors.As with *Error to find the
+// constraint which was violated:
+//
+//	var dbErr *dberrors.Error
+//	if errors.Is(err, dberrors.ErrConflict) && errors.As(err, &dbErr) {
+//		// dbErr.Constraint names the unique constraint, e.g. "orders_pkey".
+//	}
+package dberrors
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// Kinds of database error. Errors returned by Map match one of them with
+// errors.Is.
+var (
+	// ErrConflict means a unique constraint was violated, e.g. by creating a
+	// row which already exists.
+	ErrConflict = errors.New("conflict")
+	// ErrInvalidReference means a foreign key constraint was violated, e.g. by
+	// referring to a row which does not exist.
+	ErrInvalidReference = errors.New("invalid reference")
+	// ErrValidation means a check or not-null constraint was violated.
+	ErrValidation = errors.New("validation failed")
+	// ErrTimeout means the query was cancelled before it completed, e.g. by
+	// the statement timeout or the context deadline.
+	ErrTimeout = errors.New("timeout")
+	// ErrUnavailable means the database could not be reached.
+	ErrUnavailable = errors.New("database unavailable")
+)
+
+// SQLSTATEs mapped to kinds of error. See
+// https://www.postgresql.org/docs/current/errcodes-appendix.html.
+const (
+	uniqueViolation      = "23505"
+	foreignKeyViolation  = "23503"
+	checkViolation       = "23514"
+	notNullViolation     = "23502"
+	queryCanceled        = "57014"
+	adminShutdown        = "57P01"
+	crashShutdown        = "57P02"
+	cannotConnectNow     = "57P03"
+	tooManyConnections   = "53300"
+	connectionExceptions = "08"
+)
+
+// Error is a database error of a known kind.
+type Error struct {
+	// Kind is one of the Err* kinds.
+	Kind error
+	// Constraint names the constraint which was violated, if any.
+	Constraint string
+	// Table and Column name what the constraint applies to, when Postgres
+	// reports them.
+	Table  string
+	Column string
+	// Err is the error returned by the database driver.
+	Err error
+}
+
+func (e *Error) Error() string {
+	if e.Constraint != "" {
+		return fmt.Sprintf("%s: violates %s: %s", e.Kind, e.Constraint, e.Err)
+	}
+	return fmt.Sprintf("%s: %s", e.Kind, e.Err)
+}
+
+// Is reports whether target is the kind of e.
+func (e *Error) Is(target error) bool {
+	return target == e.Kind
+}
+
+// Unwrap returns the error returned by the database driver.
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// Map returns err as an *Error when it is of a known kind, and unchanged
+// otherwise. nil maps to nil.
+func Map(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		if kind := kindOf(pgErr.Code); kind != nil {
+			return &Error{
+				Kind:       kind,
+				Constraint: pgErr.ConstraintName,
+				Table:      pgErr.TableName,
+				Column:     pgErr.ColumnName,
+				Err:        err,
+			}
+		}
+		return err
+	}
+
+	switch {
+	case errors.Is(err, context.DeadlineExceeded), pgconn.Timeout(err):
+		return &Error{Kind: ErrTimeout, Err: err}
+	case isConnectionFailure(err):
+		return &Error{Kind: ErrUnavailable, Err: err}
+	}
+
+	return err
+}
+
+// kindOf returns the kind of error with the SQLSTATE code, or nil when it is
+// not of a known kind.
+func kindOf(code string) error {
+	switch code {
+	case uniqueViolation:
+		return ErrConflict
+	case foreignKeyViolation:
+		return ErrInvalidReference
+	case checkViolation, notNullViolation:
+		return ErrValidation
+	case queryCanceled:
+		return ErrTimeout
+	case adminShutdown, crashShutdown, cannotConnectNow, tooManyConnections:
+		return ErrUnavailable
+	}
+	if strings.HasPrefix(code, connectionExceptions) {
+		return ErrUnavailable
+	}
+	return nil
+}
+
+// isConnectionFailure reports whether err means the connection to the
+// database failed or was lost.
+func isConnectionFailure(err error) bool {
+	var netErr net.Error
+	return errors.As(err, &netErr) || errors.Is(err, io.ErrUnexpectedEOF) || pgconn.SafeToRetry(err)
+}
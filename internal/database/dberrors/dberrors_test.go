@@ -0,0 +1,60 @@
+package dberrors
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"testing"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMap(t *testing.T) {
+	t.Run("maps constraint violations, keeping the constraint", func(t *testing.T) {
+		for code, kind := range map[string]error{
+			"23505": ErrConflict,
+			"23503": ErrInvalidReference,
+			"23514": ErrValidation,
+			"23502": ErrValidation,
+		} {
+			pgErr := &pgconn.PgError{Code: code, ConstraintName: "orders_constraint", TableName: "orders"}
+
+			err := Map(fmt.Errorf("failed to insert order: %w", pgErr))
+
+			assert.ErrorIs(t, err, kind, code)
+			var dbErr *Error
+			if assert.ErrorAs(t, err, &dbErr, code) {
+				assert.Equal(t, "orders_constraint", dbErr.Constraint)
+				assert.Equal(t, "orders", dbErr.Table)
+			}
+			assert.ErrorIs(t, err, pgErr, code)
+		}
+	})
+
+	t.Run("maps cancelled queries to timeouts", func(t *testing.T) {
+		assert.ErrorIs(t, Map(&pgconn.PgError{Code: "57014"}), ErrTimeout)
+		assert.ErrorIs(t, Map(fmt.Errorf("failed to query: %w", context.DeadlineExceeded)), ErrTimeout)
+	})
+
+	t.Run("maps connection failures to unavailable", func(t *testing.T) {
+		assert.ErrorIs(t, Map(&pgconn.PgError{Code: "08006"}), ErrUnavailable)
+		assert.ErrorIs(t, Map(&pgconn.PgError{Code: "57P01"}), ErrUnavailable)
+		assert.ErrorIs(t, Map(&pgconn.PgError{Code: "53300"}), ErrUnavailable)
+		assert.ErrorIs(t, Map(&net.OpError{Op: "dial", Err: errors.New("connection refused")}), ErrUnavailable)
+	})
+
+	t.Run("leaves other errors unchanged", func(t *testing.T) {
+		other := &pgconn.PgError{Code: "42P01"}
+		assert.Same(t, other, Map(other))
+		assert.Equal(t, pgx.ErrNoRows, Map(pgx.ErrNoRows))
+		assert.Nil(t, Map(nil))
+	})
+
+	t.Run("describes the violated constraint", func(t *testing.T) {
+		err := Map(&pgconn.PgError{Severity: "ERROR", Code: "23505", Message: "duplicate key", ConstraintName: "orders_pkey"})
+		assert.EqualError(t, err, "conflict: violates orders_pkey: ERROR: duplicate key (SQLSTATE 23505)")
+	})
+}
@@ -0,0 +1,5 @@
+-- Databases created before migrations were introduced already have orders.
+CREATE TABLE IF NOT EXISTS orders (
+    id     integer PRIMARY KEY,
+    status text    NOT NULL
+);
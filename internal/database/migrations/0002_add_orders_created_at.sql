@@ -0,0 +1,4 @@
+ALTER TABLE orders ADD COLUMN created_at timestamptz NOT NULL DEFAULT now();
+
+-- Orders are listed newest first, paginated by (created_at, id).
+CREATE INDEX orders_created_at_id_idx ON orders (created_at DESC, id DESC);
@@ -0,0 +1,4 @@
+-- Orders are created without an ID, which is generated. The identity starts
+-- past the existing orders, so that generated IDs do not conflict with them.
+ALTER TABLE orders ALTER COLUMN id ADD GENERATED BY DEFAULT AS IDENTITY;
+SELECT setval(pg_get_serial_sequence('orders', 'id'), coalesce(max(id), 0) + 1, false) FROM orders;
@@ -0,0 +1,16 @@
+CREATE TABLE idempotency_keys (
+    scope        text        NOT NULL,
+    key          text        NOT NULL,
+    fingerprint  text        NOT NULL,
+    -- The response is recorded once the request has been processed. Until
+    -- then, the key is locked for the request until locked_until.
+    status_code  integer,
+    headers      jsonb,
+    body         bytea,
+    locked_until timestamptz,
+    expires_at   timestamptz NOT NULL,
+    created_at   timestamptz NOT NULL DEFAULT now(),
+    PRIMARY KEY (scope, key)
+);
+
+CREATE INDEX idempotency_keys_expires_at_idx ON idempotency_keys (expires_at);
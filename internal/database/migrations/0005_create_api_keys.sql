@@ -0,0 +1,13 @@
+-- API keys are stored by the SHA-256 hash of the key, which is only shown when
+-- it is minted.
+CREATE TABLE api_keys (
+    id           uuid        PRIMARY KEY,
+    prefix       text        NOT NULL,
+    hash         bytea       NOT NULL UNIQUE,
+    owner        text        NOT NULL,
+    scopes       text[]      NOT NULL DEFAULT '{}',
+    expires_at   timestamptz,
+    last_used_at timestamptz,
+    revoked_at   timestamptz,
+    created_at   timestamptz NOT NULL DEFAULT now()
+);
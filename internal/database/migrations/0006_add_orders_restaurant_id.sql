@@ -0,0 +1,3 @@
+-- The restaurant an order is from, which restaurants are authorized by.
+-- Orders created before restaurants were recorded have none.
+ALTER TABLE orders ADD COLUMN restaurant_id text;
@@ -0,0 +1,2 @@
+-- The roles of API keys, which authorization policies refer to.
+ALTER TABLE api_keys ADD COLUMN roles text[] NOT NULL DEFAULT '{}';
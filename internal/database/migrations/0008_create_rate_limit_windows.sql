@@ -0,0 +1,11 @@
+-- Requests counted by the Postgres rate limiter, per key and fixed window.
+CREATE TABLE rate_limit_windows (
+    key          text        NOT NULL,
+    window_start timestamptz NOT NULL,
+    requests     integer     NOT NULL,
+    -- Windows are kept until the window after them has ended.
+    expires_at   timestamptz NOT NULL,
+    PRIMARY KEY (key, window_start)
+);
+
+CREATE INDEX rate_limit_windows_expires_at_idx ON rate_limit_windows (expires_at);
@@ -0,0 +1,149 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+const (
+	// defaultTxAttempts is how many times a transaction is attempted when
+	// TxOptions does not say.
+	defaultTxAttempts = 3
+	// defaultTxBackoff is the wait before the first retry when TxOptions does
+	// not say. It doubles with each retry.
+	defaultTxBackoff = 20 * time.Millisecond
+)
+
+// SQLSTATEs of failures which succeed when the transaction is retried.
+const (
+	serializationFailure = "40001"
+	deadlockDetected     = "40P01"
+)
+
+// TxOptions configures a transaction run by WithTx.
+type TxOptions struct {
+	// TxOptions sets the isolation level, access mode and deferrable mode of
+	// the transaction.
+	pgx.TxOptions
+
+	// MaxAttempts is how many times the transaction is attempted when it fails
+	// with a serialization failure or deadlock. Defaults to 3; 1 disables
+	// retries.
+	MaxAttempts int
+	// Backoff is the wait before the first retry, which doubles with each
+	// retry and is jittered. Defaults to 20ms.
+	Backoff time.Duration
+}
+
+// Querier runs queries against either a pool or a transaction.
+type Querier interface {
+	Exec(ctx context.Context, sql string, arguments ...any) (pgconn.CommandTag, error)
+	Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error)
+	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
+}
+
+type txKey struct{}
+
+// ContextWithTx returns a context carrying tx, so that repositories called with
+// it run their queries in tx. Use it inside WithTx to compose several
+// repository calls atomically.
+func ContextWithTx(ctx context.Context, tx pgx.Tx) context.Context {
+	return context.WithValue(ctx, txKey{}, tx)
+}
+
+// TxFromContext returns the transaction carried by ctx, if any.
+func TxFromContext(ctx context.Context) (pgx.Tx, bool) {
+	tx, ok := ctx.Value(txKey{}).(pgx.Tx)
+	return tx, ok
+}
+
+// QuerierFrom returns the transaction carried by ctx, or pool when there is
+// none.
+func QuerierFrom(ctx context.Context, pool *pgxpool.Pool) Querier {
+	if tx, ok := TxFromContext(ctx); ok {
+		return tx
+	}
+	return pool
+}
+
+// WithTx runs fn in a transaction on pool, committing when fn succeeds and
+// rolling back when it fails or panics. When the transaction fails with a
+// serialization failure or deadlock, it is retried from the start after a
+// backoff, so fn must be safe to run more than once.
+//
+// When ctx already carries a transaction, fn runs in a savepoint within it
+// instead, and is not retried. Its failure rolls back to the savepoint, which
+// the outer transaction survives, and is returned to the caller; only
+// serialization failures and deadlocks, which abort the outer transaction
+// regardless, need propagating so that the outer transaction is retried as a
+// whole.
+func WithTx(ctx context.Context, pool *pgxpool.Pool, opts TxOptions, fn func(pgx.Tx) error) error {
+	if outer, ok := TxFromContext(ctx); ok {
+		return runTx(ctx, func() (pgx.Tx, error) { return outer.Begin(ctx) }, fn)
+	}
+
+	attempts := opts.MaxAttempts
+	if attempts <= 0 {
+		attempts = defaultTxAttempts
+	}
+	backoff := opts.Backoff
+	if backoff <= 0 {
+		backoff = defaultTxBackoff
+	}
+
+	for attempt := 1; ; attempt++ {
+		err := runTx(ctx, func() (pgx.Tx, error) { return pool.BeginTx(ctx, opts.TxOptions) }, fn)
+		if err == nil || !IsRetryable(err) || attempt >= attempts {
+			return err
+		}
+
+		// Wait between half and all of the backoff, so that transactions which
+		// conflicted do not retry in step.
+		wait := backoff/2 + time.Duration(rand.Int63n(int64(backoff/2)+1)) //nolint:gosec // jitter needs no cryptographic randomness
+		select {
+		case <-time.After(wait):
+		case <-ctx.Done():
+			return fmt.Errorf("gave up retrying transaction: %w", err)
+		}
+		backoff *= 2
+	}
+}
+
+// runTx runs fn in the transaction started by begin.
+func runTx(ctx context.Context, begin func() (pgx.Tx, error), fn func(pgx.Tx) error) error {
+	tx, err := begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+
+	// Rolling back a committed transaction does nothing, so this only undoes
+	// transactions which failed or panicked.
+	defer func() { _ = tx.Rollback(context.Background()) }()
+
+	if err := fn(tx); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
+}
+
+// IsRetryable reports whether err is a serialization failure or deadlock,
+// which may succeed when the transaction is retried.
+func IsRetryable(err error) bool {
+	var pgErr *pgconn.PgError
+	if !errors.As(err, &pgErr) {
+		return false
+	}
+	return pgErr.Code == serializationFailure || pgErr.Code == deadlockDetected
+}
@@ -0,0 +1,122 @@
+package database_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/deliveroo/bnt-internal-test-go/internal/database"
+	"github.com/deliveroo/bnt-internal-test-go/internal/database/databasetest"
+)
+
+func TestIsRetryable(t *testing.T) {
+	assert.True(t, database.IsRetryable(&pgconn.PgError{Code: "40001"}))
+	assert.True(t, database.IsRetryable(fmt.Errorf("failed to update order: %w", &pgconn.PgError{Code: "40P01"})))
+	assert.False(t, database.IsRetryable(&pgconn.PgError{Code: "23505"}))
+	assert.False(t, database.IsRetryable(errors.New("boom")))
+}
+
+func TestWithTx(t *testing.T) {
+	ctx := context.Background()
+	pool := databasetest.NewPool(t)
+
+	count := func(t *testing.T, id int) int {
+		t.Helper()
+		var n int
+		if err := pool.QueryRow(ctx, `SELECT count(*) FROM orders WHERE id = $1`, id).Scan(&n); err != nil {
+			t.Fatalf("failed to count orders: %s", err)
+		}
+		return n
+	}
+	insert := func(id int) func(pgx.Tx) error {
+		return func(tx pgx.Tx) error {
+			_, err := tx.Exec(ctx, `INSERT INTO orders (id, status) VALUES ($1, 'NEW')`, id)
+			return err
+		}
+	}
+
+	t.Run("commits when the function succeeds", func(t *testing.T) {
+		err := database.WithTx(ctx, pool, database.TxOptions{}, insert(1))
+		assert.Nil(t, err)
+		assert.Equal(t, 1, count(t, 1))
+	})
+
+	t.Run("rolls back when the function fails", func(t *testing.T) {
+		err := database.WithTx(ctx, pool, database.TxOptions{}, func(tx pgx.Tx) error {
+			_ = insert(2)(tx)
+			return errors.New("boom")
+		})
+		assert.EqualError(t, err, "boom")
+		assert.Equal(t, 0, count(t, 2))
+	})
+
+	t.Run("rolls back when the function panics", func(t *testing.T) {
+		assert.Panics(t, func() {
+			_ = database.WithTx(ctx, pool, database.TxOptions{}, func(tx pgx.Tx) error {
+				_ = insert(3)(tx)
+				panic("boom")
+			})
+		})
+		assert.Equal(t, 0, count(t, 3))
+	})
+
+	t.Run("retries serialization failures", func(t *testing.T) {
+		attempts := 0
+		err := database.WithTx(ctx, pool, database.TxOptions{TxOptions: pgx.TxOptions{IsoLevel: pgx.Serializable}}, func(tx pgx.Tx) error {
+			attempts++
+			if attempts < 3 {
+				return &pgconn.PgError{Code: "40001"}
+			}
+			return insert(4)(tx)
+		})
+		assert.Nil(t, err)
+		assert.Equal(t, 3, attempts)
+		assert.Equal(t, 1, count(t, 4))
+	})
+
+	t.Run("gives up after the maximum attempts", func(t *testing.T) {
+		attempts := 0
+		err := database.WithTx(ctx, pool, database.TxOptions{MaxAttempts: 2}, func(tx pgx.Tx) error {
+			attempts++
+			return &pgconn.PgError{Code: "40P01"}
+		})
+		assert.True(t, database.IsRetryable(err))
+		assert.Equal(t, 2, attempts)
+	})
+
+	t.Run("does not retry other failures", func(t *testing.T) {
+		attempts := 0
+		err := database.WithTx(ctx, pool, database.TxOptions{}, func(tx pgx.Tx) error {
+			attempts++
+			return insert(1)(tx)
+		})
+		assert.NotNil(t, err)
+		assert.Equal(t, 1, attempts)
+	})
+
+	t.Run("composes calls through the context", func(t *testing.T) {
+		err := database.WithTx(ctx, pool, database.TxOptions{}, func(tx pgx.Tx) error {
+			ctx := database.ContextWithTx(ctx, tx)
+			if _, err := database.QuerierFrom(ctx, pool).Exec(ctx, `INSERT INTO orders (id, status) VALUES (5, 'NEW')`); err != nil {
+				return err
+			}
+
+			// A nested transaction is a savepoint, whose failure the outer
+			// transaction survives.
+			nested := database.WithTx(ctx, pool, database.TxOptions{}, func(tx pgx.Tx) error {
+				_ = insert(6)(tx)
+				return errors.New("boom")
+			})
+			assert.EqualError(t, nested, "boom")
+			return nil
+		})
+		assert.Nil(t, err)
+		assert.Equal(t, 1, count(t, 5))
+		assert.Equal(t, 0, count(t, 6))
+	})
+}
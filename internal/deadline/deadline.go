@@ -0,0 +1,75 @@
+// Package deadline propagates the deadlines of requests to the services they
+// call, so that callees give up once their caller has.
+//
+// The time left to respond is sent in the X-Request-Timeout-Ms header, in
+// milliseconds rather than as a point in time, so that it does not depend on
+// the clocks of caller and callee agreeing. Middleware applies the budget of
+// incoming requests to their context, and SetHeader sends what is left of it
+// with outgoing requests.
+package deadline
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Header carries the time left to respond to a request, in milliseconds.
+const Header = "X-Request-Timeout-Ms"
+
+// Remaining returns the time left until the deadline of ctx, and whether it
+// has one.
+func Remaining(ctx context.Context) (time.Duration, bool) {
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		return 0, false
+	}
+	return time.Until(deadline), true
+}
+
+// FromHeader returns the budget in header, and whether it has a valid one.
+func FromHeader(header http.Header) (time.Duration, bool) {
+	value := header.Get(Header)
+	if value == "" {
+		return 0, false
+	}
+	ms, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || ms <= 0 {
+		return 0, false
+	}
+	return time.Duration(ms) * time.Millisecond, true
+}
+
+// SetHeader sets the budget in header to the time left until the deadline of
+// ctx, if it has one. It returns context.DeadlineExceeded when no time is
+// left, so that requests which could not be answered in time are not sent.
+func SetHeader(ctx context.Context, header http.Header) error {
+	remaining, ok := Remaining(ctx)
+	if !ok {
+		return nil
+	}
+	if remaining < time.Millisecond {
+		return fmt.Errorf("no time left to send request: %w", context.DeadlineExceeded)
+	}
+	header.Set(Header, strconv.FormatInt(remaining.Milliseconds(), 10))
+	return nil
+}
+
+// Middleware gives requests with a budget in their X-Request-Timeout-Ms header
+// a context with that deadline, unless it already has an earlier one. Requests
+// without a valid budget are served as they are.
+func Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		budget, ok := FromHeader(r.Header)
+		if !ok {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(r.Context(), budget)
+		defer cancel()
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
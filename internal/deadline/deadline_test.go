@@ -0,0 +1,82 @@
+package deadline_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/deliveroo/bnt-internal-test-go/internal/deadline"
+)
+
+func TestSetHeader(t *testing.T) {
+	t.Run("sends the time left", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+		defer cancel()
+		header := http.Header{}
+
+		assert.Nil(t, deadline.SetHeader(ctx, header))
+		ms, err := strconv.Atoi(header.Get(deadline.Header))
+		assert.Nil(t, err)
+		assert.InDelta(t, 2000, ms, 100)
+	})
+
+	t.Run("sends nothing without a deadline", func(t *testing.T) {
+		header := http.Header{}
+
+		assert.Nil(t, deadline.SetHeader(context.Background(), header))
+		assert.Empty(t, header.Get(deadline.Header))
+	})
+
+	t.Run("fails when no time is left", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), -time.Second)
+		defer cancel()
+
+		assert.ErrorIs(t, deadline.SetHeader(ctx, http.Header{}), context.DeadlineExceeded)
+	})
+}
+
+func TestMiddleware(t *testing.T) {
+	remaining := func(header string, parent time.Duration) (time.Duration, bool) {
+		var left time.Duration
+		var ok bool
+		handler := deadline.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			left, ok = deadline.Remaining(r.Context())
+		}))
+
+		r := httptest.NewRequest(http.MethodGet, "/orders", nil)
+		if header != "" {
+			r.Header.Set(deadline.Header, header)
+		}
+		if parent > 0 {
+			ctx, cancel := context.WithTimeout(r.Context(), parent)
+			defer cancel()
+			r = r.WithContext(ctx)
+		}
+		handler.ServeHTTP(httptest.NewRecorder(), r)
+		return left, ok
+	}
+
+	t.Run("applies the budget of the request", func(t *testing.T) {
+		left, ok := remaining("1500", 0)
+		assert.True(t, ok)
+		assert.InDelta(t, float64(1500*time.Millisecond), float64(left), float64(100*time.Millisecond))
+	})
+
+	t.Run("keeps an earlier deadline", func(t *testing.T) {
+		left, ok := remaining("60000", time.Second)
+		assert.True(t, ok)
+		assert.LessOrEqual(t, left, time.Second)
+	})
+
+	t.Run("ignores invalid budgets", func(t *testing.T) {
+		for _, header := range []string{"", "soon", "0", "-5"} {
+			_, ok := remaining(header, 0)
+			assert.False(t, ok, header)
+		}
+	})
+}
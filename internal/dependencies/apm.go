@@ -7,10 +7,14 @@ import (
 	"github.com/deliveroo/bnt-internal-test-go/internal/config"
 )
 
+// NewAPM returns the APM service configured for the Hopper environment. opts
+// are applied after the configuration, so take precedence over it.
 func NewAPM(cfg *config.Config, opts ...apm.Option) (apm.Service, error) {
+	var defaults []apm.Option
+
 	switch cfg.Hopper.Environment {
 	case "development", "setup", "test":
-		opts = append(opts,
+		defaults = append(defaults,
 			apm.WithAppName(cfg.Hopper.AppName),
 			apm.WithEnvironment(cfg.Hopper.Environment),
 			apm.WithSpanLogging(true),
@@ -27,7 +31,7 @@ func NewAPM(cfg *config.Config, opts ...apm.Option) (apm.Service, error) {
 		if cfg.Datadog.Host != "" && cfg.Datadog.StatsDPort != 0 {
 			statsdAddr = fmt.Sprintf("%s:%d", cfg.Datadog.Host, cfg.Datadog.StatsDPort)
 		}
-		opts = append(opts,
+		defaults = append(defaults,
 			apm.WithAppName(cfg.Hopper.AppName),
 			apm.WithDataDogAgentAddr(datadogAddr),
 			apm.WithEnvironment(cfg.Hopper.Environment),
@@ -39,7 +43,7 @@ func NewAPM(cfg *config.Config, opts ...apm.Option) (apm.Service, error) {
 			apm.WithRuntimeMonitoring(true),
 		)
 	}
-	apmService, err := apm.New(opts...)
+	apmService, err := apm.New(append(defaults, opts...)...)
 	if err != nil {
 		return nil, fmt.Errorf("failed to initialise apm: %w", err)
 	}
@@ -0,0 +1,52 @@
+package dependencies
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"go.uber.org/zap"
+
+	"github.com/deliveroo/apm-go"
+	"github.com/deliveroo/bnt-internal-test-go/internal/apikeys"
+	"github.com/deliveroo/bnt-internal-test-go/internal/auth"
+	"github.com/deliveroo/bnt-internal-test-go/internal/config"
+)
+
+// NewAuth returns the verifier of tokens from other services, against the key
+// set loaded from AUTH_JWKS. The key set is reloaded until ctx is done. It
+// returns nil when AUTH_JWKS is not set, and requests are not authenticated.
+func NewAuth(ctx context.Context, cfg *config.Config, factory HTTPClientFactory, logger *zap.Logger) (*auth.Verifier, error) {
+	if cfg.Auth.JWKS == "" {
+		logger.Warn("AUTH_JWKS is not set, so requests are not authenticated")
+		return nil, nil
+	}
+
+	client, err := factory.Create("jwks", nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create JWKS client: %w", err)
+	}
+
+	keys, err := auth.LoadKeySet(ctx, cfg.Auth.JWKS,
+		auth.WithHTTPClient(client),
+		auth.WithLogger(logger),
+		auth.WithRefreshInterval(cfg.Auth.JWKSRefreshInterval),
+	)
+	if err != nil {
+		return nil, err //nolint:wrapcheck // already describes the key set
+	}
+
+	return auth.NewVerifier(keys, cfg.Auth.Issuer, cfg.Auth.Audience, auth.WithLeeway(cfg.Auth.Leeway)), nil
+}
+
+// NewAPIKeys returns the authenticator of the API keys stored in the database
+// of pool, or nil when AUTH_API_KEYS is disabled.
+func NewAPIKeys(cfg *config.Config, pool *pgxpool.Pool, metrics apm.Metrics, logger *zap.Logger) *apikeys.Authenticator {
+	if !cfg.Auth.APIKeys {
+		return nil
+	}
+	return apikeys.NewAuthenticator(apikeys.NewPostgresStore(pool), metrics,
+		apikeys.WithCacheTTL(cfg.Auth.APIKeyCacheTTL),
+		apikeys.WithLogger(logger),
+	)
+}
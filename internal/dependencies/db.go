@@ -3,6 +3,8 @@ package dependencies
 import (
 	"context"
 	"fmt"
+	"strconv"
+	"time"
 
 	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
@@ -10,27 +12,187 @@ import (
 
 	"github.com/deliveroo/apm-go"
 	"github.com/deliveroo/apm-go/integrations/pgxv5trace"
+	"github.com/deliveroo/bnt-internal-test-go/internal/config"
 )
 
-// InitDatabase initializes a Postgres database connection.
-func InitDatabase(url string, apm apm.Service) (*pgxpool.Pool, error) {
-	pgxConfig, err := pgxpool.ParseConfig(url)
+// poolMetricPrefix prefixes the names of the metrics reported by pools.
+const poolMetricPrefix = "pgxpool."
+
+// Database pool roles, which tag the metrics reported by each pool.
+const (
+	WriterRole = "writer"
+	ReaderRole = "reader"
+)
+
+// InitDatabase initializes a Postgres connection pool for the database at url,
+// tuned by cfg. The pool reports its metrics through pgxv5trace, named after
+// the database, e.g. pgxpool.orders.connections.active. role, such as
+// WriterRole, tags them.
+func InitDatabase(url string, cfg config.Database, role string, apm apm.Service) (*pgxpool.Pool, error) {
+	pgxConfig, err := poolConfig(url, cfg)
 	if err != nil {
-		return nil, fmt.Errorf("failed to parse database url: %w", err)
+		return nil, err
+	}
+
+	tagged := taggedService{Service: apm, tags: []string{"role", role}}
+	pgxConnPool, err := pgxv5trace.Connect(context.Background(), pgxConfig.ConnConfig.Database, pgxConfig, tagged)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to database: %w", err)
 	}
 
-	// Add github.com/google/uuid type support
-	pgxConfig.AfterConnect = func(ctx context.Context, conn *pgx.Conn) error {
-		pgxUUID.Register(conn.TypeMap())
-		return nil
+	return pgxConnPool, nil
+}
+
+// OpenDatabase connects to the database at url, tuned by cfg, without
+// reporting metrics, for commands run by operators.
+func OpenDatabase(ctx context.Context, url string, cfg config.Database) (*pgxpool.Pool, error) {
+	pgxConfig, err := poolConfig(url, cfg)
+	if err != nil {
+		return nil, err
 	}
 
-	pgxConnPool, err := pgxv5trace.Connect(context.Background(), pgxConfig.ConnConfig.Database, pgxConfig, apm)
+	pool, err := pgxpool.NewWithConfig(ctx, pgxConfig)
 	if err != nil {
 		return nil, fmt.Errorf("failed to connect to database: %w", err)
 	}
+	return pool, nil
+}
 
-	return pgxConnPool, nil
+// poolConfig returns the configuration of a pool for the database at url.
+func poolConfig(url string, cfg config.Database) (*pgxpool.Config, error) {
+	pgxConfig, err := pgxpool.ParseConfig(url)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse database url: %w", err)
+	}
+
+	if cfg.MinConns > cfg.MaxConns {
+		return nil, fmt.Errorf("database min connections (%d) exceed max connections (%d)", cfg.MinConns, cfg.MaxConns)
+	}
+
+	pgxConfig.MaxConns = cfg.MaxConns
+	pgxConfig.MinConns = cfg.MinConns
+	pgxConfig.MaxConnLifetime = cfg.MaxConnLifetime
+	pgxConfig.MaxConnIdleTime = cfg.MaxConnIdleTime
+	pgxConfig.HealthCheckPeriod = cfg.HealthCheckPeriod
+	pgxConfig.ConnConfig.ConnectTimeout = cfg.ConnectTimeout
+
+	// Timeouts in the URL take precedence, e.g. for a pool running migrations.
+	// Queries whose context is done sooner are canceled by pgx, which sends
+	// Postgres a cancel request.
+	setRuntimeParam(pgxConfig, "statement_timeout", cfg.StatementTimeout)
+	setRuntimeParam(pgxConfig, "idle_in_transaction_session_timeout", cfg.IdleInTransactionTimeout)
+
+	// Add github.com/google/uuid type support. pgxv5trace.Connect replaces
+	// the AfterConnect hook of the pool, so types are registered as
+	// connections are traced instead.
+	pgxConfig.ConnConfig.Tracer = registerTypes{}
+
+	return pgxConfig, nil
+}
+
+// setRuntimeParam sets the Postgres parameter name to timeout, in milliseconds,
+// unless it is already set.
+func setRuntimeParam(pgxConfig *pgxpool.Config, name string, timeout time.Duration) {
+	if _, ok := pgxConfig.ConnConfig.RuntimeParams[name]; ok {
+		return
+	}
+	pgxConfig.ConnConfig.RuntimeParams[name] = strconv.FormatInt(timeout.Milliseconds(), 10)
+}
+
+// registerTypes registers the types of github.com/google/uuid on every new
+// connection.
+type registerTypes struct{}
+
+func (registerTypes) TraceConnectStart(ctx context.Context, _ pgx.TraceConnectStartData) context.Context {
+	return ctx
+}
+
+func (registerTypes) TraceConnectEnd(_ context.Context, data pgx.TraceConnectEndData) {
+	if data.Err == nil {
+		pgxUUID.Register(data.Conn.TypeMap())
+	}
+}
+
+func (registerTypes) TraceQueryStart(ctx context.Context, _ *pgx.Conn, _ pgx.TraceQueryStartData) context.Context {
+	return ctx
+}
+
+func (registerTypes) TraceQueryEnd(context.Context, *pgx.Conn, pgx.TraceQueryEndData) {}
+
+// ReportPoolStats reports the statistics of pool which pgxv5trace does not,
+// every interval until ctx is done: the connections being opened as a gauge,
+// and the connections opened and closed since the last report as counts. The
+// metrics are named after the database, and role, such as WriterRole, tags
+// them.
+func ReportPoolStats(ctx context.Context, pool *pgxpool.Pool, role string, metrics apm.Metrics, interval time.Duration) {
+	prefix := poolMetricPrefix + pool.Config().ConnConfig.Database + "."
+	tags := []string{"role", role}
+	reported := map[string]int64{}
+	count := func(name string, total int64) {
+		metrics.Count(prefix+name, total-reported[name], 1, tags...)
+		reported[name] = total
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+
+		s := pool.Stat()
+		metrics.Gauge(prefix+"connections.constructing", float64(s.ConstructingConns()), 1, tags...)
+		count("connections.created", s.NewConnsCount())
+		count("connections.lifetime_destroyed", s.MaxLifetimeDestroyCount())
+		count("connections.idle_destroyed", s.MaxIdleDestroyCount())
+	}
+}
+
+// taggedService adds tags to the metrics reported through its StatsD client.
+type taggedService struct {
+	apm.Service
+	tags []string
+}
+
+func (s taggedService) StatsD() apm.Metrics {
+	return taggedMetrics{Metrics: s.Service.StatsD(), tags: s.tags}
+}
+
+// taggedMetrics adds tags to the metrics reported through it.
+type taggedMetrics struct {
+	apm.Metrics
+	tags []string
+}
+
+func (m taggedMetrics) Count(name string, value int64, rate float64, tagPairs ...string) {
+	m.Metrics.Count(name, value, rate, m.with(tagPairs)...)
+}
+
+func (m taggedMetrics) Distribution(name string, value float64, rate float64, tagPairs ...string) {
+	m.Metrics.Distribution(name, value, rate, m.with(tagPairs)...)
+}
+
+func (m taggedMetrics) Gauge(name string, value float64, rate float64, tagPairs ...string) {
+	m.Metrics.Gauge(name, value, rate, m.with(tagPairs)...)
+}
+
+func (m taggedMetrics) Histogram(name string, value float64, rate float64, tagPairs ...string) {
+	m.Metrics.Histogram(name, value, rate, m.with(tagPairs)...)
+}
+
+func (m taggedMetrics) Incr(name string, rate float64, tagPairs ...string) {
+	m.Metrics.Incr(name, rate, m.with(tagPairs)...)
+}
+
+func (m taggedMetrics) Timing(name string, value time.Duration, rate float64, tagPairs ...string) {
+	m.Metrics.Timing(name, value, rate, m.with(tagPairs)...)
+}
+
+func (m taggedMetrics) with(tagPairs []string) []string {
+	return append(append(make([]string, 0, len(tagPairs)+len(m.tags)), tagPairs...), m.tags...)
 }
 
 // CloseDatabaseConnection cleans up the connection to the db
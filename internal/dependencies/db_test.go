@@ -0,0 +1,118 @@
+package dependencies
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/deliveroo/bnt-internal-test-go/internal/config"
+	"github.com/deliveroo/bnt-internal-test-go/internal/database/databasetest"
+	"github.com/deliveroo/bnt-internal-test-go/internal/metricstest"
+)
+
+const testDatabaseURL = "postgres://localhost:5434/orders"
+
+func testDatabaseConfig() config.Database {
+	return config.Database{
+		MaxConns:                 8,
+		MinConns:                 2,
+		MaxConnLifetime:          time.Hour,
+		MaxConnIdleTime:          time.Minute,
+		HealthCheckPeriod:        30 * time.Second,
+		ConnectTimeout:           3 * time.Second,
+		StatementTimeout:         5 * time.Second,
+		IdleInTransactionTimeout: 10 * time.Second,
+		StatsInterval:            10 * time.Millisecond,
+	}
+}
+
+func TestPoolConfig(t *testing.T) {
+	t.Run("tunes the pool", func(t *testing.T) {
+		pgxConfig, err := poolConfig(testDatabaseURL, testDatabaseConfig())
+		assert.Nil(t, err)
+
+		assert.Equal(t, int32(8), pgxConfig.MaxConns)
+		assert.Equal(t, int32(2), pgxConfig.MinConns)
+		assert.Equal(t, time.Hour, pgxConfig.MaxConnLifetime)
+		assert.Equal(t, time.Minute, pgxConfig.MaxConnIdleTime)
+		assert.Equal(t, 30*time.Second, pgxConfig.HealthCheckPeriod)
+		assert.Equal(t, 3*time.Second, pgxConfig.ConnConfig.ConnectTimeout)
+		assert.Equal(t, "5000", pgxConfig.ConnConfig.RuntimeParams["statement_timeout"])
+		assert.Equal(t, "10000", pgxConfig.ConnConfig.RuntimeParams["idle_in_transaction_session_timeout"])
+		assert.IsType(t, registerTypes{}, pgxConfig.ConnConfig.Tracer)
+	})
+
+	t.Run("keeps timeouts set in the URL", func(t *testing.T) {
+		pgxConfig, err := poolConfig(testDatabaseURL+"?statement_timeout=0", testDatabaseConfig())
+		assert.Nil(t, err)
+
+		assert.Equal(t, "0", pgxConfig.ConnConfig.RuntimeParams["statement_timeout"])
+	})
+
+	t.Run("rejects more min connections than max", func(t *testing.T) {
+		cfg := testDatabaseConfig()
+		cfg.MinConns = 9
+
+		_, err := poolConfig(testDatabaseURL, cfg)
+		assert.EqualError(t, err, "database min connections (9) exceed max connections (8)")
+	})
+}
+
+func TestTaggedMetrics(t *testing.T) {
+	metrics := metricstest.NewRecorder()
+	tagged := taggedMetrics{Metrics: metrics, tags: []string{"role", ReaderRole}}
+
+	tagPairs := append(make([]string, 0, 4), "database", "orders")
+	tagged.Gauge("pgxpool.orders.connections.max", 8, 1, tagPairs...)
+
+	assert.Equal(t, []string{"database", "orders", "role", ReaderRole}, metrics.Tags("pgxpool.orders.connections.max"))
+	assert.Equal(t, []string{"database", "orders", "", ""}, tagPairs[:4], "the tags of the caller are left alone")
+}
+
+func TestReportPoolStats(t *testing.T) {
+	pgxConfig, err := poolConfig(testDatabaseURL, testDatabaseConfig())
+	assert.Nil(t, err)
+	pgxConfig.MinConns = 0
+
+	// Pools connect lazily, so no database is needed.
+	pool, err := pgxpool.NewWithConfig(context.Background(), pgxConfig)
+	assert.Nil(t, err)
+	defer pool.Close()
+
+	metrics := metricstest.NewRecorder()
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		ReportPoolStats(ctx, pool, ReaderRole, metrics, 10*time.Millisecond)
+		close(done)
+	}()
+
+	assert.Eventually(t, func() bool {
+		return metrics.Calls("pgxpool.orders.connections.constructing") > 0
+	}, time.Second, 5*time.Millisecond)
+	cancel()
+	<-done
+
+	assert.Positive(t, metrics.Calls("pgxpool.orders.connections.created"))
+	assert.Equal(t, []string{"role", ReaderRole}, metrics.Tags("pgxpool.orders.connections.constructing"))
+}
+
+func TestQueriesStopAtDeadline(t *testing.T) {
+	pool := databasetest.NewPool(t)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	started := time.Now()
+	_, err := pool.Exec(ctx, `SELECT pg_sleep(10)`)
+	assert.NotNil(t, err)
+	assert.Less(t, time.Since(started), 5*time.Second)
+
+	assert.Eventually(t, func() bool {
+		var running int
+		err := pool.QueryRow(context.Background(), `SELECT count(*) FROM pg_stat_activity WHERE query = 'SELECT pg_sleep(10)' AND state = 'active'`).Scan(&running)
+		return err == nil && running == 0
+	}, 5*time.Second, 50*time.Millisecond, "Postgres stops the query")
+}
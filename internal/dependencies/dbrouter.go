@@ -0,0 +1,154 @@
+package dependencies
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"go.uber.org/zap"
+
+	"github.com/deliveroo/apm-go"
+	"github.com/deliveroo/bnt-internal-test-go/internal/config"
+)
+
+// replicationLagQuery measures how far the reader is behind the writer. A
+// replica which has replayed everything it received is not lagging, however
+// long ago the last transaction was; a database which is not a replica never
+// lags.
+const replicationLagQuery = `
+SELECT CASE
+	WHEN NOT pg_is_in_recovery() THEN 0
+	WHEN pg_last_wal_receive_lsn() = pg_last_wal_replay_lsn() THEN 0
+	ELSE COALESCE(EXTRACT(EPOCH FROM now() - pg_last_xact_replay_timestamp()), 0)
+END`
+
+// DBRouter sends reads to the reader while it is healthy and keeping up with
+// the writer, and to the writer otherwise. Within a context prepared by
+// WithReadYourWrites, reads which follow a write go to the writer too, so they
+// see what was written.
+//
+// Until Monitor first finds the reader healthy, reads go to the writer.
+type DBRouter struct {
+	writer  *pgxpool.Pool
+	reader  *pgxpool.Pool
+	maxLag  time.Duration
+	every   time.Duration
+	logger  *zap.Logger
+	metrics apm.Metrics
+
+	// measureLag returns the replication lag of the reader, or an error when
+	// it is unhealthy.
+	measureLag func(ctx context.Context) (time.Duration, error)
+
+	useReader atomic.Bool
+}
+
+// NewDBRouter returns a DBRouter between the writer and reader pools, using the
+// replication lag threshold and check interval in cfg.
+func NewDBRouter(writer, reader *pgxpool.Pool, cfg config.Database, apm apm.Service) *DBRouter {
+	r := &DBRouter{
+		writer:  writer,
+		reader:  reader,
+		maxLag:  cfg.ReaderMaxLag,
+		every:   cfg.ReaderCheckInterval,
+		logger:  apm.Logger().Named("dbrouter"),
+		metrics: apm.StatsD(),
+	}
+	r.measureLag = r.queryLag
+	return r
+}
+
+// ForRead returns the reader, unless it is unhealthy or lagging, or ctx has
+// been written in.
+func (r *DBRouter) ForRead(ctx context.Context) *pgxpool.Pool {
+	if !r.useReader.Load() || hasWritten(ctx) {
+		return r.writer
+	}
+	return r.reader
+}
+
+// ForWrite returns the writer, and records that ctx has been written in.
+func (r *DBRouter) ForWrite(ctx context.Context) *pgxpool.Pool {
+	MarkWritten(ctx)
+	return r.writer
+}
+
+// Monitor checks the health and replication lag of the reader straight away
+// and then periodically, until ctx is done.
+func (r *DBRouter) Monitor(ctx context.Context) {
+	ticker := time.NewTicker(r.every)
+	defer ticker.Stop()
+
+	for {
+		r.check(ctx)
+
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// check measures the reader, and switches reads to or from it as needed.
+func (r *DBRouter) check(ctx context.Context) {
+	ctx, cancel := context.WithTimeout(ctx, r.every)
+	defer cancel()
+
+	lag, err := r.measureLag(ctx)
+	healthy := err == nil && lag <= r.maxLag
+
+	r.metrics.Gauge(poolMetricPrefix+"reader.lag", float64(lag.Milliseconds()), 1, "role", ReaderRole)
+	r.metrics.Gauge(poolMetricPrefix+"reader.healthy", boolGauge(healthy), 1, "role", ReaderRole)
+
+	if r.useReader.Swap(healthy) == healthy {
+		return
+	}
+	switch {
+	case healthy:
+		r.logger.Info("reading from the reader", zap.Duration("lag", lag))
+	case err != nil:
+		r.logger.Warn("reader is unhealthy, reading from the writer", zap.Error(err))
+	default:
+		r.logger.Warn("reader is lagging, reading from the writer", zap.Duration("lag", lag), zap.Duration("max_lag", r.maxLag))
+	}
+}
+
+func (r *DBRouter) queryLag(ctx context.Context) (time.Duration, error) {
+	var seconds float64
+	if err := r.reader.QueryRow(ctx, replicationLagQuery).Scan(&seconds); err != nil {
+		return 0, fmt.Errorf("failed to measure replication lag: %w", err)
+	}
+	return time.Duration(seconds * float64(time.Second)), nil
+}
+
+func boolGauge(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+type readYourWritesKey struct{}
+
+// WithReadYourWrites returns a context in which reads routed by a DBRouter go
+// to the writer once anything has been written, e.g. for the duration of a
+// request.
+func WithReadYourWrites(ctx context.Context) context.Context {
+	return context.WithValue(ctx, readYourWritesKey{}, new(atomic.Bool))
+}
+
+// MarkWritten records that ctx has been written in, so that later reads go to
+// the writer. It does nothing unless ctx was prepared by WithReadYourWrites.
+func MarkWritten(ctx context.Context) {
+	if written, ok := ctx.Value(readYourWritesKey{}).(*atomic.Bool); ok {
+		written.Store(true)
+	}
+}
+
+func hasWritten(ctx context.Context) bool {
+	written, ok := ctx.Value(readYourWritesKey{}).(*atomic.Bool)
+	return ok && written.Load()
+}
@@ -0,0 +1,84 @@
+package dependencies
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+
+	"github.com/deliveroo/bnt-internal-test-go/internal/metricstest"
+)
+
+func TestDBRouter(t *testing.T) {
+	writer, reader := &pgxpool.Pool{}, &pgxpool.Pool{}
+
+	newRouter := func(lag time.Duration, err error) *DBRouter {
+		return &DBRouter{
+			writer:     writer,
+			reader:     reader,
+			maxLag:     time.Second,
+			every:      time.Second,
+			logger:     zap.NewNop(),
+			metrics:    metricstest.NewRecorder(),
+			measureLag: func(context.Context) (time.Duration, error) { return lag, err },
+		}
+	}
+
+	t.Run("reads from the writer until the reader has been checked", func(t *testing.T) {
+		router := newRouter(0, nil)
+
+		assert.Same(t, writer, router.ForRead(context.Background()))
+	})
+
+	t.Run("reads from a healthy reader", func(t *testing.T) {
+		router := newRouter(500*time.Millisecond, nil)
+		router.check(context.Background())
+
+		assert.Same(t, reader, router.ForRead(context.Background()))
+		assert.Same(t, writer, router.ForWrite(context.Background()))
+	})
+
+	t.Run("reads from the writer when the reader is unhealthy", func(t *testing.T) {
+		router := newRouter(0, nil)
+		router.check(context.Background())
+
+		router.measureLag = func(context.Context) (time.Duration, error) { return 0, errors.New("connection refused") }
+		router.check(context.Background())
+
+		assert.Same(t, writer, router.ForRead(context.Background()))
+	})
+
+	t.Run("reads from the writer when the reader lags too far behind", func(t *testing.T) {
+		router := newRouter(2*time.Second, nil)
+		router.check(context.Background())
+
+		assert.Same(t, writer, router.ForRead(context.Background()))
+
+		assert.Equal(t, float64(2000), router.metrics.(*metricstest.Recorder).Value("pgxpool.reader.lag"))
+	})
+
+	t.Run("reads from the writer after writing, within the same context", func(t *testing.T) {
+		router := newRouter(0, nil)
+		router.check(context.Background())
+
+		ctx := WithReadYourWrites(context.Background())
+		assert.Same(t, reader, router.ForRead(ctx))
+
+		router.ForWrite(ctx)
+		assert.Same(t, writer, router.ForRead(ctx))
+		assert.Same(t, reader, router.ForRead(WithReadYourWrites(context.Background())))
+	})
+
+	t.Run("ignores writes outside a context prepared for it", func(t *testing.T) {
+		router := newRouter(0, nil)
+		router.check(context.Background())
+
+		ctx := context.Background()
+		router.ForWrite(ctx)
+		assert.Same(t, reader, router.ForRead(ctx))
+	})
+}
@@ -13,6 +13,7 @@
 package dependencies
 
 import (
+	"context"
 	"fmt"
 	"net/http"
 
@@ -21,8 +22,16 @@ import (
 	"go.uber.org/zap"
 
 	"github.com/deliveroo/apm-go"
+	"github.com/deliveroo/bnt-internal-test-go/internal/apikeys"
+	"github.com/deliveroo/bnt-internal-test-go/internal/auth"
 	"github.com/deliveroo/bnt-internal-test-go/internal/config"
+	"github.com/deliveroo/bnt-internal-test-go/internal/idempotency"
+	"github.com/deliveroo/bnt-internal-test-go/internal/loadshed"
+	"github.com/deliveroo/bnt-internal-test-go/internal/logging"
 	"github.com/deliveroo/bnt-internal-test-go/internal/orders"
+	"github.com/deliveroo/bnt-internal-test-go/internal/pagination"
+	"github.com/deliveroo/bnt-internal-test-go/internal/ratelimit"
+	"github.com/deliveroo/bnt-internal-test-go/internal/settings"
 	"github.com/deliveroo/determinator-go"
 )
 
@@ -33,81 +42,165 @@ type Dependencies struct {
 
 	WriterDB          *pgxpool.Pool
 	ReaderDB          *pgxpool.Pool
+	DB                *DBRouter
 	Determinator      determinator.Retriever
 	HTTPClientFactory HTTPClientFactory
 	Repository        orders.Repository
 	APM               apm.Service
+	Settings          *settings.Store
+	Cursors           *pagination.Codec
+	// Idempotency records responses to replay to retried requests. It is nil
+	// when retries are not deduplicated.
+	Idempotency idempotency.Store
+	// Auth verifies tokens from other services. It is nil when requests are
+	// not authenticated.
+	Auth *auth.Verifier
+	// APIKeys authenticates API keys from partners and tools. It is nil when
+	// they are disabled.
+	APIKeys *apikeys.Authenticator
+	// RateLimiter counts requests against rate limits. It is nil when rate
+	// limiting is disabled.
+	RateLimiter ratelimit.Limiter
+	// LoadShedder limits the number of concurrent requests. It is nil when
+	// load shedding is disabled.
+	LoadShedder *loadshed.Limiter
+
+	// stopBackground stops work done in the background, such as reporting
+	// pool statistics.
+	stopBackground context.CancelFunc
 }
 
 // Initialize loads all application dependencies.
 func Initialize(cfg config.Config) (*Dependencies, error) {
-	logger, err := newLogger(&cfg)
+	scrubber := logging.NewScrubber(config.Secrets(&cfg)...)
+
+	logger, levels, err := newLogger(&cfg, scrubber)
 	if err != nil {
 		return nil, fmt.Errorf("failed to initialize logger: %w", err)
 	}
 
-	apmService, err := NewAPM(&cfg, apm.WithLogger(logger))
+	runtimeSettings, err := NewSettings(&cfg, logger, levels)
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize runtime settings: %w", err)
+	}
+
+	apmOptions := append([]apm.Option{apm.WithLogger(logger)}, runtimeLogging(runtimeSettings, logger)...)
+	apmService, err := NewAPM(&cfg, apmOptions...)
 	if err != nil {
 		return nil, fmt.Errorf("failed to initialize APM: %w", err)
 	}
 
-	writeDB, err := InitDatabase(cfg.Database.URL, apmService)
+	writeDB, err := InitDatabase(cfg.Database.URL.Value(), cfg.Database, WriterRole, apmService)
 	if err != nil {
 		return nil, err
 	}
 
-	readDB, err := InitDatabase(cfg.Database.ReaderURL, apmService)
+	readDB, err := InitDatabase(cfg.Database.ReaderURL.Value(), cfg.Database, ReaderRole, apmService)
 	if err != nil {
 		return nil, err
 	}
 
 	circuitManager := newCircuitBreakerManager(cfg)
 
-	httpClientFactory := NewHTTPClientFactory(cfg.Circuit, circuitManager, apmService, http.DefaultClient)
+	httpClientFactory := NewHTTPClientFactory(cfg.Circuit, circuitManager, apmService, http.DefaultClient,
+		WithRuntimeSettings(runtimeSettings),
+		WithRequestLogging(logger.Named("httpclient"), scrubber),
+	)
 
 	determinator, err := InitDeterminator(cfg, httpClientFactory)
 	if err != nil {
 		return nil, fmt.Errorf("failed to initialize Determinator: %w", err)
 	}
 
+	cursors, err := NewCursorCodec(&cfg, logger)
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize pagination: %w", err)
+	}
+
+	backgroundCtx, stopBackground := context.WithCancel(context.Background())
+	verifier, err := NewAuth(backgroundCtx, &cfg, httpClientFactory, logger.Named("auth"))
+	if err != nil {
+		stopBackground()
+		return nil, fmt.Errorf("failed to initialize authentication: %w", err)
+	}
+
+	dbRouter := NewDBRouter(writeDB, readDB, cfg.Database, apmService)
+
+	go ReportPoolStats(backgroundCtx, writeDB, WriterRole, apmService.StatsD(), cfg.Database.StatsInterval)
+	go ReportPoolStats(backgroundCtx, readDB, ReaderRole, apmService.StatsD(), cfg.Database.StatsInterval)
+	go dbRouter.Monitor(backgroundCtx)
+
+	idempotencyStore := idempotency.NewPostgresStore(writeDB)
+	go deleteExpiredIdempotencyKeys(backgroundCtx, idempotencyStore, logger)
+	rateLimiter := NewRateLimiter(&cfg, writeDB)
+	if limiter, ok := rateLimiter.(*ratelimit.PostgresLimiter); ok {
+		go deleteExpiredRateLimitWindows(backgroundCtx, limiter, logger)
+	}
+	loadShedder := NewLoadShedder(&cfg)
+	if loadShedder != nil {
+		go loadShedder.ReportStats(backgroundCtx, apmService.StatsD(), loadShedStatsInterval)
+	}
+
 	dependencies := &Dependencies{
 		CircuitManager:    circuitManager,
 		Config:            cfg,
 		WriterDB:          writeDB,
 		ReaderDB:          readDB,
+		DB:                dbRouter,
 		Determinator:      determinator,
 		HTTPClientFactory: httpClientFactory,
-		Repository:        orders.NewRepository(writeDB, readDB),
+		Repository:        orders.NewRepository(dbRouter),
 		APM:               apmService,
+		Settings:          runtimeSettings,
+		Cursors:           cursors,
+		Idempotency:       idempotencyStore,
+		Auth:              verifier,
+		APIKeys:           NewAPIKeys(&cfg, writeDB, apmService.StatsD(), logger.Named("apikeys")),
+		RateLimiter:       rateLimiter,
+		LoadShedder:       loadShedder,
+		stopBackground:    stopBackground,
 	}
 
 	return dependencies, nil
 }
 
-func newLogger(cfg *config.Config) (*zap.Logger, error) {
-	var logger *zap.Logger
-	var err error
+// newLogger returns the application logger, and the levels it logs at, which
+// can be changed while it is in use. Secrets are removed from its entries by
+// scrubber.
+func newLogger(cfg *config.Config, scrubber *logging.Scrubber) (*zap.Logger, *logging.Levels, error) {
+	var development bool
 
 	switch cfg.Hopper.Environment {
 	case "development", "setup":
-		if cfg.Settings.SuppressLogging {
-			logger = zap.NewNop()
-		} else {
-			logger, err = zap.NewDevelopment()
-		}
-	default:
-		logger, err = zap.NewProduction()
+		development = true
+	}
+
+	level := logging.DefaultLevel(development)
+	if cfg.Settings.LogLevel != nil {
+		level = *cfg.Settings.LogLevel
+	}
+	levels := logging.NewLevels(level)
+	levels.SetOverrides(cfg.Settings.LoggerLevels)
+
+	if development && cfg.Settings.SuppressLogging {
+		return zap.NewNop(), levels, nil
 	}
+
+	logger, err := logging.New(development, levels, scrubber)
 	if err != nil {
-		return nil, fmt.Errorf("failed to initialise logger: %w", err)
+		return nil, nil, fmt.Errorf("failed to initialise logger: %w", err)
 	}
 
-	return logger, nil
+	return logger, levels, nil
 }
 
 // Shutdown should be called on application shutdown to allow dependencies to
 // shutdown gracefully.
 func (d *Dependencies) Shutdown() {
+	if d.stopBackground != nil {
+		d.stopBackground()
+	}
 	d.APM.Close()
 	CloseDatabaseConnection(d.WriterDB)
+	CloseDatabaseConnection(d.ReaderDB)
 }
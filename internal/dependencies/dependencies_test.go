@@ -1,22 +1,31 @@
 package dependencies
 
 import (
+	"context"
 	"testing"
 	"time"
 
 	"github.com/stretchr/testify/assert"
 
 	"github.com/deliveroo/bnt-internal-test-go/internal/config"
+	"github.com/deliveroo/bnt-internal-test-go/internal/database/databasetest"
 )
 
 func TestDependencies(t *testing.T) {
 	t.Run("dependencies are correctly loaded", func(t *testing.T) {
+		databaseURL := databasetest.NewURL(t)
+		t.Setenv("DATABASE_URL", databaseURL)
+		t.Setenv("DATABASE_URL_READER", databaseURL)
+		t.Setenv("SUPPRESS_LOGGING", "true")
+
 		cfg, err := config.Load()
 		assert.Nil(t, err)
 
 		deps, err := Initialize(cfg)
-		assert.Nil(t, err)
-		assert.NotNil(t, deps)
+		if !assert.Nil(t, err) {
+			return
+		}
+		defer deps.Shutdown()
 
 		assert.Equal(t, config.Server{
 			IdleTimeout:  60 * time.Second,
@@ -25,7 +34,9 @@ func TestDependencies(t *testing.T) {
 			WriteTimeout: 2 * time.Second,
 		}, deps.Config.Server)
 
-		assert.NotNil(t, deps.Config.Database)
-		assert.NotEmpty(t, deps.Config.Database.URL)
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		assert.Nil(t, deps.WriterDB.Ping(ctx))
+		assert.Nil(t, deps.ReaderDB.Ping(ctx))
 	})
 }
@@ -0,0 +1,70 @@
+// Package dependenciestest provides helpers for building application
+// dependencies in tests, without reaching the network.
+package dependenciestest
+
+import (
+	"testing"
+	"time"
+
+	"github.com/cep21/circuit/v3"
+	"go.uber.org/zap"
+
+	"github.com/deliveroo/apm-go"
+	"github.com/deliveroo/bnt-internal-test-go/internal/config"
+	"github.com/deliveroo/bnt-internal-test-go/internal/dependencies"
+	"github.com/deliveroo/bnt-internal-test-go/internal/httpclient/recorder"
+	"github.com/deliveroo/bnt-internal-test-go/internal/orders"
+	"github.com/deliveroo/bnt-internal-test-go/internal/pagination"
+)
+
+// NewAPM returns an APM service suitable for tests, which discards logs.
+func NewAPM(tb testing.TB) apm.Service {
+	tb.Helper()
+
+	cfg := config.Config{Hopper: config.Hopper{AppName: "test", Environment: "test"}}
+	apmService, err := dependencies.NewAPM(&cfg, apm.WithLogger(zap.NewNop()))
+	if err != nil {
+		tb.Fatalf("failed to initialize APM: %s", err)
+	}
+
+	return apmService
+}
+
+// NewHTTPClientFactory returns an HTTPClientFactory whose clients record to, or
+// replay from, the cassette at path. The cassette is written when the test
+// completes.
+func NewHTTPClientFactory(tb testing.TB, cassette string, opts ...recorder.Option) dependencies.HTTPClientFactory {
+	tb.Helper()
+
+	rec, err := recorder.New(cassette, opts...)
+	if err != nil {
+		tb.Fatalf("failed to load cassette: %s", err)
+	}
+	tb.Cleanup(func() {
+		if err := rec.Stop(); err != nil {
+			tb.Errorf("failed to save cassette: %s", err)
+		}
+	})
+
+	return dependencies.NewHTTPClientFactory(config.Circuit{}, &circuit.Manager{}, NewAPM(tb), rec.Client())
+}
+
+// NewDependencies returns Dependencies suitable for exercising HTTP handlers in
+// tests. Outbound HTTP requests are replayed from cassette, and orders are
+// served by repository. Retries are not deduplicated unless Idempotency is set.
+func NewDependencies(tb testing.TB, cassette string, repository orders.Repository) *dependencies.Dependencies {
+	tb.Helper()
+
+	factory := NewHTTPClientFactory(tb, cassette)
+
+	return &dependencies.Dependencies{
+		Config: config.Config{
+			Hopper:      config.Hopper{AppName: "test", Environment: "test"},
+			Idempotency: config.Idempotency{LockFor: time.Minute, KeepFor: time.Hour},
+		},
+		HTTPClientFactory: factory,
+		Repository:        repository,
+		APM:               NewAPM(tb),
+		Cursors:           pagination.NewCodec([]byte("test")),
+	}
+}
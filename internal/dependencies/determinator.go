@@ -11,6 +11,6 @@ func InitDeterminator(cfg config.Config, httpClientFactory HTTPClientFactory) (*
 		return nil, err
 	}
 
-	r := determinator.NewHTTPRetriever(cfg.Determinator.URL, httpClient, determinator.WithBasicAuthCredentials(cfg.Determinator.Username, cfg.Determinator.Password), determinator.WithUserAgent(cfg.Determinator.UserAgent))
+	r := determinator.NewHTTPRetriever(cfg.Determinator.URL, httpClient, determinator.WithBasicAuthCredentials(cfg.Determinator.Username, cfg.Determinator.Password.Value()), determinator.WithUserAgent(cfg.Determinator.UserAgent))
 	return determinator.NewCachedRetriever(&r, cfg.Determinator.CacheTTL), nil
 }
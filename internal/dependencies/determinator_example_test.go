@@ -3,17 +3,20 @@ package dependencies
 import (
 	"fmt"
 	"log"
-	"net/http"
+	"net/url"
 
 	"github.com/deliveroo/apm-go"
 	"github.com/deliveroo/bnt-internal-test-go/internal/config"
+	"github.com/deliveroo/bnt-internal-test-go/internal/httpclient/recorder"
+	"github.com/deliveroo/bnt-internal-test-go/internal/logging"
 	"github.com/deliveroo/determinator-go"
 )
 
 func ExampleInitDeterminator() {
 	cfg, _ := config.Load()
+	cfg.Determinator.URL, _ = url.Parse("https://determinator.example.com/api/features/")
 
-	logger, err := newLogger(&cfg)
+	logger, _, err := newLogger(&cfg, logging.NewScrubber())
 	if err != nil {
 		fmt.Printf("failed to initialize logger: %s\n", err.Error())
 		return
@@ -27,7 +30,16 @@ func ExampleInitDeterminator() {
 
 	circuitManager := newCircuitBreakerManager(cfg)
 
-	httpClientFactory := NewHTTPClientFactory(cfg.Circuit, circuitManager, apmService, http.DefaultClient)
+	// Replay responses from Determinator, rather than reaching the network.
+	// The cassette is written by hand, as determinator.example.com cannot be
+	// recorded, so it is always replayed.
+	rec, err := recorder.New("testdata/cassettes/determinator.json", recorder.WithMode(recorder.ModeReplay))
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer func() { _ = rec.Stop() }()
+
+	httpClientFactory := NewHTTPClientFactory(cfg.Circuit, circuitManager, apmService, rec.Client())
 
 	det, err := InitDeterminator(cfg, httpClientFactory)
 	if err != nil {
@@ -46,5 +58,5 @@ func ExampleInitDeterminator() {
 		fmt.Println("The feature flag is on!")
 	}
 	// Output:
-	// determinator: making request: Get "/example_feature_flag": failed to perform roundtrip using circuitbreaker: unsupported protocol scheme ""
+	// The feature flag is on!
 }
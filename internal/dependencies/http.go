@@ -7,10 +7,15 @@ import (
 	"time"
 
 	"github.com/cep21/circuit/v3"
+	"github.com/cep21/circuit/v3/closers/hystrix"
+	"go.uber.org/zap"
+	"golang.org/x/time/rate"
 
 	"github.com/deliveroo/apm-go"
 	"github.com/deliveroo/bnt-internal-test-go/internal/config"
 	"github.com/deliveroo/bnt-internal-test-go/internal/httpclient"
+	"github.com/deliveroo/bnt-internal-test-go/internal/logging"
+	"github.com/deliveroo/bnt-internal-test-go/internal/settings"
 )
 
 const circuitBreakerNamePrefix = "httpclient.circuit."
@@ -20,16 +25,43 @@ type HTTPClientFactory struct {
 	apmService        apm.Service
 	defaultCfg        config.Circuit
 	defaultHTTPClient *http.Client
+	settings          *settings.Store
+	requestLogger     *zap.Logger
+	scrubber          *logging.Scrubber
+}
+
+// HTTPClientFactoryOption configures an HTTPClientFactory.
+type HTTPClientFactoryOption func(*HTTPClientFactory)
+
+// WithRuntimeSettings applies the circuit and rate limit settings in store to
+// the clients the factory creates, including when the settings change.
+func WithRuntimeSettings(store *settings.Store) HTTPClientFactoryOption {
+	return func(h *HTTPClientFactory) {
+		h.settings = store
+	}
+}
+
+// WithRequestLogging logs the requests made by the clients the factory creates
+// to logger, at debug level, with secrets removed by scrubber.
+func WithRequestLogging(logger *zap.Logger, scrubber *logging.Scrubber) HTTPClientFactoryOption {
+	return func(h *HTTPClientFactory) {
+		h.requestLogger = logger
+		h.scrubber = scrubber
+	}
 }
 
 // NewHTTPClientFactory constructs a factory to create HTTP clients which are fully operable
-func NewHTTPClientFactory(defaultCfg config.Circuit, circuitManager *circuit.Manager, apmService apm.Service, defaultHTTPClient *http.Client) HTTPClientFactory {
-	return HTTPClientFactory{
+func NewHTTPClientFactory(defaultCfg config.Circuit, circuitManager *circuit.Manager, apmService apm.Service, defaultHTTPClient *http.Client, opts ...HTTPClientFactoryOption) HTTPClientFactory {
+	factory := HTTPClientFactory{
 		circuitManager:    circuitManager,
 		apmService:        apmService,
 		defaultCfg:        defaultCfg,
 		defaultHTTPClient: defaultHTTPClient,
 	}
+	for _, opt := range opts {
+		opt(&factory)
+	}
+	return factory
 }
 
 // Create a new HTTP client, wrapped in a Circuit Breaker, and set up with APM tracing.
@@ -69,8 +101,86 @@ func (h HTTPClientFactory) Create(circuitBreakerName string, cfg *config.Circuit
 		client = *http.DefaultClient
 	}
 
-	return httpclient.WithMiddleware(&client,
+	middlewares := []httpclient.Middleware{
 		httpclient.NewCircuitBreaker(circuitBreaker),
 		httpclient.Tracing(h.apmService),
-	), nil
+	}
+
+	if h.requestLogger != nil {
+		middlewares = append(middlewares, httpclient.Logging(h.requestLogger.With(zap.String("client", circuitBreakerName)), h.scrubber))
+	}
+
+	if h.settings != nil {
+		applyCircuitSettings(h.settings, circuitBreakerName, cfg, circuitBreaker)
+
+		limiter := rate.NewLimiter(rate.Inf, 0)
+		applyRateLimitSettings(h.settings, circuitBreakerName, limiter)
+		middlewares = append([]httpclient.Middleware{httpclient.RateLimit(limiter)}, middlewares...)
+	}
+
+	// Send the time left innermost, after waiting for the rate limit, so that
+	// it is as accurate as possible.
+	middlewares = append([]httpclient.Middleware{httpclient.Deadline()}, middlewares...)
+
+	return httpclient.WithMiddleware(&client, middlewares...), nil
+}
+
+// applyCircuitSettings keeps the configuration of circuitBreaker up to date
+// with the runtime settings for the client called name. Settings for the client
+// take precedence over cfg, which takes precedence over the default settings.
+func applyCircuitSettings(store *settings.Store, name string, cfg *config.Circuit, circuitBreaker *circuit.Circuit) {
+	// Zero values leave the configuration the circuit was created with.
+	created := circuitBreaker.Config()
+	opener, _ := circuitBreaker.ClosedToOpen.(*hystrix.Opener)
+	closer, _ := circuitBreaker.OpenToClose.(*hystrix.Closer)
+	var createdOpener hystrix.ConfigureOpener
+	var createdCloser hystrix.ConfigureCloser
+	if opener != nil {
+		createdOpener = opener.Config()
+	}
+	if closer != nil {
+		createdCloser = closer.Config()
+	}
+
+	settings.Subscribe(store, func(v settings.Values) config.Circuit {
+		if _, ok := v.Circuits[name]; !ok && cfg != nil {
+			return *cfg
+		}
+		return v.CircuitFor(name)
+	}, func(c config.Circuit) {
+		execution := circuit.Config{Execution: circuit.ExecutionConfig{
+			Timeout:               time.Duration(c.Timeout),
+			MaxConcurrentRequests: int64(c.MaxConcurrentRequests),
+		}}
+		circuitBreaker.SetConfigThreadSafe(*execution.Merge(created))
+
+		if opener != nil {
+			openerCfg := hystrix.ConfigureOpener{
+				ErrorThresholdPercentage: int64(c.ErrorPercentThreshold),
+				RequestVolumeThreshold:   int64(c.RequestVolumeThreshold),
+			}
+			openerCfg.Merge(createdOpener)
+			opener.SetConfigThreadSafe(openerCfg)
+		}
+		if closer != nil {
+			closerCfg := hystrix.ConfigureCloser{SleepWindow: time.Duration(c.SleepWindow)}
+			closerCfg.Merge(createdCloser)
+			closer.SetConfigThreadSafe(closerCfg)
+		}
+	})
+}
+
+// applyRateLimitSettings keeps limiter up to date with the runtime settings for
+// the client called name.
+func applyRateLimitSettings(store *settings.Store, name string, limiter *rate.Limiter) {
+	settings.Subscribe(store, func(v settings.Values) settings.RateLimit {
+		return v.RateLimits[name]
+	}, func(l settings.RateLimit) {
+		if l.RequestsPerSecond == 0 {
+			limiter.SetLimit(rate.Inf)
+			return
+		}
+		limiter.SetBurst(l.Burst)
+		limiter.SetLimit(rate.Limit(l.RequestsPerSecond))
+	})
 }
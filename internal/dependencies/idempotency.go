@@ -0,0 +1,35 @@
+package dependencies
+
+import (
+	"context"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/deliveroo/bnt-internal-test-go/internal/idempotency"
+)
+
+// idempotencyCleanupInterval is how often expired idempotency keys are deleted.
+const idempotencyCleanupInterval = time.Hour
+
+// deleteExpiredIdempotencyKeys deletes expired keys from store periodically,
+// until ctx is done.
+func deleteExpiredIdempotencyKeys(ctx context.Context, store *idempotency.PostgresStore, logger *zap.Logger) {
+	ticker := time.NewTicker(idempotencyCleanupInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+
+		deleted, err := store.DeleteExpired(ctx)
+		if err != nil {
+			logger.Warn("failed to delete expired idempotency keys", zap.Error(err))
+			continue
+		}
+		logger.Debug("deleted expired idempotency keys", zap.Int64("deleted", deleted))
+	}
+}
@@ -0,0 +1,25 @@
+package dependencies
+
+import (
+	"time"
+
+	"github.com/deliveroo/bnt-internal-test-go/internal/config"
+	"github.com/deliveroo/bnt-internal-test-go/internal/loadshed"
+)
+
+// loadShedStatsInterval is how often the load shedder reports its limit.
+const loadShedStatsInterval = 10 * time.Second
+
+// NewLoadShedder returns the limiter of concurrent requests, or nil when load
+// shedding is disabled.
+func NewLoadShedder(cfg *config.Config) *loadshed.Limiter {
+	if !cfg.LoadShedding.Enabled {
+		return nil
+	}
+	return loadshed.NewLimiter(
+		loadshed.WithInitialLimit(cfg.LoadShedding.InitialLimit),
+		loadshed.WithLimits(cfg.LoadShedding.MinLimit, cfg.LoadShedding.MaxLimit),
+		loadshed.WithTargetLatency(cfg.LoadShedding.TargetLatency),
+		loadshed.WithBackoff(cfg.LoadShedding.Backoff),
+	)
+}
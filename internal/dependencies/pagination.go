@@ -0,0 +1,32 @@
+package dependencies
+
+import (
+	"crypto/rand"
+	"fmt"
+
+	"go.uber.org/zap"
+
+	"github.com/deliveroo/bnt-internal-test-go/internal/config"
+	"github.com/deliveroo/bnt-internal-test-go/internal/pagination"
+)
+
+// cursorKeyLength is the length of the random key used to sign cursors when
+// none is configured.
+const cursorKeyLength = 32
+
+// NewCursorCodec returns the codec for pagination cursors, signing them with
+// the configured key. Without one, it uses a random key, so cursors are only
+// accepted by the instance which issued them.
+func NewCursorCodec(cfg *config.Config, logger *zap.Logger) (*pagination.Codec, error) {
+	if key := cfg.Pagination.CursorKey.Value(); key != "" {
+		return pagination.NewCodec([]byte(key)), nil
+	}
+
+	key := make([]byte, cursorKeyLength)
+	if _, err := rand.Read(key); err != nil {
+		return nil, fmt.Errorf("failed to generate cursor key: %w", err)
+	}
+	logger.Warn("PAGINATION_CURSOR_KEY is not set, so pagination cursors only work with this instance")
+
+	return pagination.NewCodec(key), nil
+}
@@ -0,0 +1,52 @@
+package dependencies
+
+import (
+	"context"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"go.uber.org/zap"
+
+	"github.com/deliveroo/bnt-internal-test-go/internal/config"
+	"github.com/deliveroo/bnt-internal-test-go/internal/ratelimit"
+)
+
+// rateLimitCleanupInterval is how often expired rate limit windows are
+// deleted.
+const rateLimitCleanupInterval = 10 * time.Minute
+
+// NewRateLimiter returns the limiter counting requests in RATE_LIMIT_STORE,
+// which is the database of pool for "postgres", or nil when rate limiting is
+// disabled.
+func NewRateLimiter(cfg *config.Config, pool *pgxpool.Pool) ratelimit.Limiter {
+	switch {
+	case !cfg.RateLimit.Enabled:
+		return nil
+	case cfg.RateLimit.Store == "postgres":
+		return ratelimit.NewPostgresLimiter(pool)
+	default:
+		return ratelimit.NewTokenBucket()
+	}
+}
+
+// deleteExpiredRateLimitWindows deletes expired windows from limiter
+// periodically, until ctx is done.
+func deleteExpiredRateLimitWindows(ctx context.Context, limiter *ratelimit.PostgresLimiter, logger *zap.Logger) {
+	ticker := time.NewTicker(rateLimitCleanupInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+
+		deleted, err := limiter.DeleteExpired(ctx)
+		if err != nil {
+			logger.Warn("failed to delete expired rate limit windows", zap.Error(err))
+			continue
+		}
+		logger.Debug("deleted expired rate limit windows", zap.Int64("deleted", deleted))
+	}
+}
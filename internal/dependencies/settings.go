@@ -0,0 +1,90 @@
+package dependencies
+
+import (
+	"sync/atomic"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+
+	"github.com/deliveroo/apm-go"
+	"github.com/deliveroo/bnt-internal-test-go/internal/config"
+	"github.com/deliveroo/bnt-internal-test-go/internal/logging"
+	"github.com/deliveroo/bnt-internal-test-go/internal/settings"
+)
+
+// statsDLogBuffer is the number of metrics which can be waiting to be logged
+// before recording a metric blocks.
+const statsDLogBuffer = 1024
+
+// NewSettings returns the runtime settings store, starting from the values in
+// cfg. levels are the levels of the application logger, which follow the
+// LogLevel and LoggerLevels settings.
+func NewSettings(cfg *config.Config, logger *zap.Logger, levels *logging.Levels) (*settings.Store, error) {
+	store, err := settings.New(settings.Values{
+		LogLevel:      levels.Level(),
+		LoggerLevels:  levels.Overrides(),
+		SpanLogging:   cfg.Settings.SpanLogging || isDevelopment(cfg),
+		StatsDLogging: cfg.Settings.StatsDLogging || isDevelopment(cfg),
+		Circuit:       cfg.Circuit,
+	}, settings.WithLogger(logger.Named("settings")))
+	if err != nil {
+		return nil, err //nolint:wrapcheck // already describes the failure
+	}
+
+	settings.Subscribe(store, func(v settings.Values) zapcore.Level { return v.LogLevel }, levels.SetLevel)
+	settings.Subscribe(store, func(v settings.Values) map[string]zapcore.Level { return v.LoggerLevels }, levels.SetOverrides)
+
+	return store, nil
+}
+
+// runtimeLogging returns APM options which write spans and StatsD metrics to
+// logger while the SpanLogging and StatsDLogging settings are enabled. They
+// replace the span and StatsD logging built into APM, which is fixed at start
+// up.
+func runtimeLogging(store *settings.Store, logger *zap.Logger) []apm.Option {
+	var spans, metrics atomic.Bool
+	settings.Subscribe(store, func(v settings.Values) bool { return v.SpanLogging }, spans.Store)
+	settings.Subscribe(store, func(v settings.Values) bool { return v.StatsDLogging }, metrics.Store)
+
+	// APM sends every metric to the channel, so it is always drained.
+	statsD := make(chan apm.StatsDMetric, statsDLogBuffer)
+	go func() {
+		for metric := range statsD {
+			if metrics.Load() {
+				logger.Info("statsd:"+string(metric.Type),
+					zap.String("name", metric.Name),
+					zap.Int64("int_value", metric.IntValue),
+					zap.Float64("float_value", metric.FloatValue),
+					zap.Duration("duration_value", metric.DurationValue),
+					zap.Strings("tags", metric.Tags),
+				)
+			}
+		}
+	}()
+
+	return []apm.Option{
+		apm.WithSpanLogging(false),
+		apm.WithStatsDLogging(false),
+		apm.WithStatsDChannel(statsD),
+		apm.WithCustomHandler(func(span *apm.Span) {
+			if !spans.Load() {
+				return
+			}
+			logger.Info(span.Name()+"::"+span.Resource(),
+				zap.String("type", string(span.SpanType())),
+				zap.Duration("elapsed", span.Finished().Sub(span.Started())),
+				zap.Uint64("trace_id", span.TraceID()),
+				zap.Error(span.Err()),
+			)
+		}),
+	}
+}
+
+func isDevelopment(cfg *config.Config) bool {
+	switch cfg.Hopper.Environment {
+	case "development", "setup", "test":
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,39 @@
+package httpclient
+
+import (
+	"net/http"
+
+	"github.com/deliveroo/bnt-internal-test-go/internal/deadline"
+)
+
+type deadlineRoundTripper struct {
+	inner http.RoundTripper
+}
+
+// Deadline is a middleware that sends the time left until the deadline of each
+// request's context in the X-Request-Timeout-Ms header, so that the service
+// called gives up when the caller does. Requests with no time left fail
+// without being sent.
+func Deadline() Middleware {
+	return func(c *http.Client) *http.Client {
+		inner := c.Transport
+		if inner == nil {
+			inner = http.DefaultTransport
+		}
+		c.Transport = &deadlineRoundTripper{inner: inner}
+		return c
+	}
+}
+
+func (d *deadlineRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	if _, ok := deadline.Remaining(req.Context()); !ok {
+		return d.inner.RoundTrip(req) //nolint:wrapcheck // errors come from the wrapped transport
+	}
+
+	// Round trippers must not modify the request they are given.
+	req = req.Clone(req.Context())
+	if err := deadline.SetHeader(req.Context(), req.Header); err != nil {
+		return nil, err //nolint:wrapcheck // already describes the failure
+	}
+	return d.inner.RoundTrip(req) //nolint:wrapcheck // errors come from the wrapped transport
+}
@@ -0,0 +1,51 @@
+package httpclient
+
+import (
+	"net/http"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/deliveroo/bnt-internal-test-go/internal/logging"
+)
+
+type loggingRoundTripper struct {
+	inner    http.RoundTripper
+	logger   *zap.Logger
+	scrubber *logging.Scrubber
+}
+
+// Logging is a middleware that logs each request at debug level. Passwords and
+// secret query parameters are removed from the logged URL by scrubber, and no
+// headers or bodies are logged.
+func Logging(logger *zap.Logger, scrubber *logging.Scrubber) Middleware {
+	return func(c *http.Client) *http.Client {
+		inner := c.Transport
+		if inner == nil {
+			inner = http.DefaultTransport
+		}
+		c.Transport = &loggingRoundTripper{inner: inner, logger: logger, scrubber: scrubber}
+		return c
+	}
+}
+
+func (l *loggingRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	start := time.Now()
+	res, err := l.inner.RoundTrip(req)
+
+	if entry := l.logger.Check(zap.DebugLevel, "outbound request"); entry != nil {
+		fields := []zap.Field{
+			zap.String("method", req.Method),
+			zap.String("url", l.scrubber.URL(req.URL)),
+			zap.Duration("duration", time.Since(start)),
+		}
+		if err != nil {
+			fields = append(fields, zap.Error(err))
+		} else {
+			fields = append(fields, zap.Int("status", res.StatusCode))
+		}
+		entry.Write(fields...)
+	}
+
+	return res, err //nolint:wrapcheck // errors come from the wrapped transport
+}
@@ -0,0 +1,34 @@
+package httpclient
+
+import (
+	"fmt"
+	"net/http"
+
+	"golang.org/x/time/rate"
+)
+
+type rateLimitRoundTripper struct {
+	inner   http.RoundTripper
+	limiter *rate.Limiter
+}
+
+// RateLimit is a middleware that waits for limiter to allow each request
+// before sending it. The limiter can be changed while the client is in use.
+func RateLimit(limiter *rate.Limiter) Middleware {
+	return func(c *http.Client) *http.Client {
+		inner := c.Transport
+		if inner == nil {
+			inner = http.DefaultTransport
+		}
+		c.Transport = &rateLimitRoundTripper{inner: inner, limiter: limiter}
+		return c
+	}
+}
+
+func (r *rateLimitRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	if err := r.limiter.Wait(req.Context()); err != nil {
+		return nil, fmt.Errorf("failed to wait for rate limit: %w", err)
+	}
+
+	return r.inner.RoundTrip(req) //nolint:wrapcheck // errors come from the wrapped transport
+}
@@ -0,0 +1,95 @@
+package recorder
+
+import (
+	"net/http"
+	"net/url"
+)
+
+// Matcher reports whether a live request matches a recorded one. Both
+// requests have already been passed through the recorder's scrubbers, so
+// scrubbed values compare equal.
+type Matcher func(live, recorded Request) bool
+
+// DefaultMatcher matches on method and full URL.
+var DefaultMatcher = MatchAll(MatchMethod, MatchURL)
+
+// MatchAll returns a Matcher which matches only when every matcher matches.
+func MatchAll(matchers ...Matcher) Matcher {
+	return func(live, recorded Request) bool {
+		for _, match := range matchers {
+			if !match(live, recorded) {
+				return false
+			}
+		}
+		return true
+	}
+}
+
+// MatchMethod matches requests with the same HTTP method.
+func MatchMethod(live, recorded Request) bool {
+	return live.Method == recorded.Method
+}
+
+// MatchURL matches requests with the same URL, ignoring the order of query
+// parameters.
+func MatchURL(live, recorded Request) bool {
+	liveURL, err := url.Parse(live.URL)
+	if err != nil {
+		return false
+	}
+	recordedURL, err := url.Parse(recorded.URL)
+	if err != nil {
+		return false
+	}
+
+	return liveURL.Scheme == recordedURL.Scheme &&
+		liveURL.Host == recordedURL.Host &&
+		liveURL.Path == recordedURL.Path &&
+		liveURL.Query().Encode() == recordedURL.Query().Encode()
+}
+
+// MatchPath matches requests with the same URL path, regardless of host and
+// query. It is useful when the host differs between environments.
+func MatchPath(live, recorded Request) bool {
+	liveURL, err := url.Parse(live.URL)
+	if err != nil {
+		return false
+	}
+	recordedURL, err := url.Parse(recorded.URL)
+	if err != nil {
+		return false
+	}
+
+	return liveURL.Path == recordedURL.Path
+}
+
+// MatchBody matches requests with identical bodies.
+func MatchBody(live, recorded Request) bool {
+	return live.Body == recorded.Body
+}
+
+// MatchHeaders returns a Matcher which matches requests having the same values
+// for each of the given headers.
+func MatchHeaders(names ...string) Matcher {
+	return func(live, recorded Request) bool {
+		for _, name := range names {
+			name = http.CanonicalHeaderKey(name)
+			if !equalValues(live.Headers[name], recorded.Headers[name]) {
+				return false
+			}
+		}
+		return true
+	}
+}
+
+func equalValues(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,311 @@
+// Package recorder provides an http.RoundTripper which records real HTTP
+// interactions to cassette files and replays them afterwards, so that tests of
+// HTTP clients are deterministic and run without network access.
+//
+// Cassettes are recorded once, by running the tests with
+// HTTP_RECORDER_MODE=record, and committed alongside the tests. Sensitive
+// values are scrubbed before a cassette is written. In CI, recorders only
+// replay, so that a missing cassette fails the test rather than reaching the
+// network.
+package recorder
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+)
+
+const (
+	// ModeEnvVar sets the mode of every Recorder not given one by WithMode,
+	// to "record", "replay" or "auto".
+	ModeEnvVar = "HTTP_RECORDER_MODE"
+	// CIEnvVar is set by CI providers. When it is, Recorders default to
+	// ModeReplay rather than ModeAuto.
+	CIEnvVar = "CI"
+)
+
+// Mode controls whether a Recorder talks to the network.
+type Mode int
+
+const (
+	// ModeAuto replays the cassette when it exists and records a new one
+	// otherwise.
+	ModeAuto Mode = iota
+	// ModeReplay only replays the cassette. Requests that match no recorded
+	// interaction fail.
+	ModeReplay
+	// ModeRecord sends every request to the network and overwrites the
+	// cassette when the Recorder is stopped.
+	ModeRecord
+)
+
+var (
+	// ErrNoInteraction is returned when replaying a request which was never
+	// recorded.
+	ErrNoInteraction = errors.New("no recorded interaction matches request")
+	// ErrNoCassette is returned when replaying a cassette which does not
+	// exist.
+	ErrNoCassette = errors.New("cassette does not exist")
+)
+
+// Recorder is an http.RoundTripper which records and replays interactions.
+type Recorder struct {
+	path      string
+	mode      Mode
+	modeSet   bool
+	inner     http.RoundTripper
+	matcher   Matcher
+	scrubbers []Scrubber
+
+	mu       sync.Mutex
+	cassette *Cassette
+	replayed []bool
+}
+
+// Option configures a Recorder.
+type Option func(*Recorder)
+
+// WithMode sets the mode of the Recorder, overriding ModeEnvVar, e.g.
+// ModeReplay for hand-written cassettes of services which cannot be recorded.
+func WithMode(mode Mode) Option {
+	return func(r *Recorder) {
+		r.mode = mode
+		r.modeSet = true
+	}
+}
+
+// WithTransport sets the transport used to reach the network while recording.
+func WithTransport(rt http.RoundTripper) Option {
+	return func(r *Recorder) {
+		r.inner = rt
+	}
+}
+
+// WithMatcher replaces DefaultMatcher.
+func WithMatcher(matcher Matcher) Option {
+	return func(r *Recorder) {
+		r.matcher = matcher
+	}
+}
+
+// WithScrubbers adds scrubbers to DefaultScrubbers.
+func WithScrubbers(scrubbers ...Scrubber) Option {
+	return func(r *Recorder) {
+		r.scrubbers = append(r.scrubbers, scrubbers...)
+	}
+}
+
+// New creates a Recorder backed by the cassette at path.
+func New(path string, opts ...Option) (*Recorder, error) {
+	r := &Recorder{
+		path:      path,
+		inner:     http.DefaultTransport,
+		matcher:   DefaultMatcher,
+		scrubbers: append([]Scrubber{}, DefaultScrubbers...),
+	}
+
+	for _, opt := range opts {
+		opt(r)
+	}
+
+	if !r.modeSet {
+		if os.Getenv(CIEnvVar) != "" {
+			r.mode = ModeReplay
+		}
+		mode, err := modeFromEnv(r.mode)
+		if err != nil {
+			return nil, err
+		}
+		r.mode = mode
+	}
+
+	if r.mode == ModeAuto {
+		r.mode = ModeRecord
+		if cassetteExists(path) {
+			r.mode = ModeReplay
+		}
+	}
+
+	if r.mode == ModeRecord {
+		r.cassette = &Cassette{}
+		return r, nil
+	}
+
+	if !cassetteExists(path) {
+		return nil, fmt.Errorf("%w: %s, record it with %s=record", ErrNoCassette, path, ModeEnvVar)
+	}
+	cassette, err := loadCassette(path)
+	if err != nil {
+		return nil, err
+	}
+	r.cassette = cassette
+	r.replayed = make([]bool, len(cassette.Interactions))
+
+	return r, nil
+}
+
+// Mode returns the effective mode of the Recorder.
+func (r *Recorder) Mode() Mode {
+	return r.mode
+}
+
+// Client returns a new HTTP client which uses the Recorder as its transport.
+func (r *Recorder) Client() *http.Client {
+	return &http.Client{Transport: r}
+}
+
+// Stop writes the cassette to disk when recording. It is a no-op when
+// replaying, or when nothing was recorded.
+func (r *Recorder) Stop() error {
+	if r.mode != ModeRecord {
+		return nil
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if len(r.cassette.Interactions) == 0 {
+		return nil
+	}
+
+	return r.cassette.save(r.path)
+}
+
+// RoundTrip implements http.RoundTripper.
+func (r *Recorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	live, err := newRequest(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if r.mode == ModeRecord {
+		return r.record(req, live)
+	}
+
+	return r.replay(req, live)
+}
+
+func (r *Recorder) record(req *http.Request, live Request) (*http.Response, error) {
+	res, err := r.inner.RoundTrip(req)
+	if err != nil {
+		return nil, err //nolint:wrapcheck // errors are passed through as they would be without the recorder
+	}
+	defer res.Body.Close()
+
+	body, err := io.ReadAll(res.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response body: %w", err)
+	}
+
+	interaction := Interaction{
+		Request: live,
+		Response: Response{
+			Status:  res.StatusCode,
+			Headers: res.Header.Clone(),
+			Body:    string(body),
+		},
+	}
+	r.scrub(&interaction)
+
+	r.mu.Lock()
+	r.cassette.Interactions = append(r.cassette.Interactions, interaction)
+	r.mu.Unlock()
+
+	res.Body = io.NopCloser(bytes.NewReader(body))
+	return res, nil
+}
+
+func (r *Recorder) replay(req *http.Request, live Request) (*http.Response, error) {
+	scrubbed := Interaction{Request: live}
+	r.scrub(&scrubbed)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	// Prefer interactions which have not been replayed yet, so that the same
+	// request can return different responses in the order they were recorded.
+	match := -1
+	for idx, interaction := range r.cassette.Interactions {
+		if !r.matcher(scrubbed.Request, interaction.Request) {
+			continue
+		}
+		if !r.replayed[idx] {
+			match = idx
+			break
+		}
+		if match == -1 {
+			match = idx
+		}
+	}
+	if match == -1 {
+		return nil, fmt.Errorf("%w: %s %s in %s", ErrNoInteraction, live.Method, live.URL, r.path)
+	}
+	r.replayed[match] = true
+
+	recorded := r.cassette.Interactions[match].Response
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", recorded.Status, http.StatusText(recorded.Status)),
+		StatusCode:    recorded.Status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        recorded.Headers.Clone(),
+		Body:          io.NopCloser(strings.NewReader(recorded.Body)),
+		ContentLength: int64(len(recorded.Body)),
+		Request:       req,
+	}, nil
+}
+
+func (r *Recorder) scrub(interaction *Interaction) {
+	if interaction.Request.Headers == nil {
+		interaction.Request.Headers = http.Header{}
+	}
+	if interaction.Response.Headers == nil {
+		interaction.Response.Headers = http.Header{}
+	}
+	for _, scrub := range r.scrubbers {
+		scrub(interaction)
+	}
+}
+
+// newRequest converts req to its recorded form, restoring its body so that it
+// can still be sent.
+func newRequest(req *http.Request) (Request, error) {
+	var body []byte
+	if req.Body != nil && req.Body != http.NoBody {
+		var err error
+		body, err = io.ReadAll(req.Body)
+		if err != nil {
+			return Request{}, fmt.Errorf("failed to read request body: %w", err)
+		}
+		_ = req.Body.Close()
+		req.Body = io.NopCloser(bytes.NewReader(body))
+	}
+
+	return Request{
+		Method:  req.Method,
+		URL:     req.URL.String(),
+		Headers: req.Header.Clone(),
+		Body:    string(body),
+	}, nil
+}
+
+func modeFromEnv(fallback Mode) (Mode, error) {
+	switch value := os.Getenv(ModeEnvVar); value {
+	case "":
+		return fallback, nil
+	case "auto":
+		return ModeAuto, nil
+	case "replay":
+		return ModeReplay, nil
+	case "record":
+		return ModeRecord, nil
+	default:
+		return fallback, fmt.Errorf("unknown %s %q", ModeEnvVar, value)
+	}
+}
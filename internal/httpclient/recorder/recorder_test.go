@@ -0,0 +1,140 @@
+package recorder
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRecorder(t *testing.T) {
+	t.Setenv(ModeEnvVar, "")
+	t.Setenv(CIEnvVar, "")
+
+	t.Run("replays recorded interactions without the network", func(t *testing.T) {
+		cassette := filepath.Join(t.TempDir(), "cassette.json")
+
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Set-Cookie", "session=abc")
+			_, _ = io.WriteString(w, "hello "+r.URL.Query().Get("name"))
+		}))
+
+		rec, err := New(cassette, WithMode(ModeRecord))
+		assert.Nil(t, err)
+
+		req, _ := http.NewRequest(http.MethodGet, server.URL+"/greet?name=roo", nil)
+		req.Header.Set("Authorization", "Bearer secret-token")
+		res, err := rec.Client().Do(req)
+		assert.Nil(t, err)
+		body, _ := io.ReadAll(res.Body)
+		_ = res.Body.Close()
+		assert.Equal(t, "hello roo", string(body))
+		assert.Nil(t, rec.Stop())
+		server.Close()
+
+		raw, err := os.ReadFile(cassette)
+		assert.Nil(t, err)
+		assert.NotContains(t, string(raw), "secret-token")
+		assert.NotContains(t, string(raw), "session=abc")
+
+		replay, err := New(cassette)
+		assert.Nil(t, err)
+		assert.Equal(t, ModeReplay, replay.Mode())
+
+		res, err = replay.Client().Get(server.URL + "/greet?name=roo")
+		assert.Nil(t, err)
+		body, _ = io.ReadAll(res.Body)
+		_ = res.Body.Close()
+		assert.Equal(t, http.StatusOK, res.StatusCode)
+		assert.Equal(t, "hello roo", string(body))
+		assert.Equal(t, []string{Redacted}, res.Header["Set-Cookie"])
+	})
+
+	t.Run("fails requests which were never recorded", func(t *testing.T) {
+		rec, err := New(writeCassette(t, Interaction{
+			Request:  Request{Method: http.MethodGet, URL: "https://example.com/a"},
+			Response: Response{Status: http.StatusOK},
+		}))
+		assert.Nil(t, err)
+
+		_, err = rec.Client().Get("https://example.com/b")
+		assert.True(t, errors.Is(err, ErrNoInteraction))
+	})
+
+	t.Run("replays matching interactions in recorded order", func(t *testing.T) {
+		rec, err := New(writeCassette(t,
+			Interaction{
+				Request:  Request{Method: http.MethodGet, URL: "https://example.com/status"},
+				Response: Response{Status: http.StatusOK, Body: "pending"},
+			},
+			Interaction{
+				Request:  Request{Method: http.MethodGet, URL: "https://example.com/status"},
+				Response: Response{Status: http.StatusOK, Body: "done"},
+			},
+		))
+		assert.Nil(t, err)
+
+		var bodies []string
+		for i := 0; i < 3; i++ {
+			res, err := rec.Client().Get("https://example.com/status")
+			assert.Nil(t, err)
+			body, _ := io.ReadAll(res.Body)
+			_ = res.Body.Close()
+			bodies = append(bodies, string(body))
+		}
+		assert.Equal(t, []string{"pending", "done", "pending"}, bodies)
+	})
+
+	t.Run("matches on scrubbed values", func(t *testing.T) {
+		rec, err := New(
+			writeCassette(t, Interaction{
+				Request:  Request{Method: http.MethodPost, URL: "https://example.com/login?token=" + Redacted, Body: `{"password":"[REDACTED]"}`},
+				Response: Response{Status: http.StatusNoContent},
+			}),
+			WithMatcher(MatchAll(MatchMethod, MatchURL, MatchBody)),
+			WithScrubbers(ScrubQueryParams("token"), ScrubValues("hunter2")),
+		)
+		assert.Nil(t, err)
+
+		res, err := rec.Client().Post("https://example.com/login?token=abc", "application/json", strings.NewReader(`{"password":"hunter2"}`))
+		assert.Nil(t, err)
+		_ = res.Body.Close()
+		assert.Equal(t, http.StatusNoContent, res.StatusCode)
+	})
+
+	t.Run("records when no cassette exists in auto mode", func(t *testing.T) {
+		rec, err := New(filepath.Join(t.TempDir(), "missing.json"))
+		assert.Nil(t, err)
+		assert.Equal(t, ModeRecord, rec.Mode())
+	})
+
+	t.Run("only replays in CI, failing when the cassette is missing", func(t *testing.T) {
+		t.Setenv(CIEnvVar, "true")
+
+		_, err := New(filepath.Join(t.TempDir(), "missing.json"))
+		assert.True(t, errors.Is(err, ErrNoCassette))
+	})
+
+	t.Run("keeps the mode set by the test", func(t *testing.T) {
+		t.Setenv(ModeEnvVar, "record")
+
+		rec, err := New(writeCassette(t), WithMode(ModeReplay))
+		assert.Nil(t, err)
+		assert.Equal(t, ModeReplay, rec.Mode())
+	})
+}
+
+func writeCassette(t *testing.T, interactions ...Interaction) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "cassette.json")
+	assert.Nil(t, (&Cassette{Interactions: interactions}).save(path))
+
+	return path
+}
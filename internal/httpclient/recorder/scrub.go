@@ -0,0 +1,93 @@
+package recorder
+
+import (
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// Redacted replaces any scrubbed value in a cassette.
+const Redacted = "[REDACTED]"
+
+// Scrubber removes sensitive data from an interaction before it is written to
+// a cassette or matched against one.
+type Scrubber func(*Interaction)
+
+// DefaultScrubbers remove credentials from the headers most commonly used to
+// carry them.
+var DefaultScrubbers = []Scrubber{
+	ScrubHeaders("Authorization", "Proxy-Authorization", "Cookie", "Set-Cookie", "X-Api-Key"),
+}
+
+// ScrubHeaders replaces the values of the given request and response headers.
+func ScrubHeaders(names ...string) Scrubber {
+	return func(i *Interaction) {
+		for _, name := range names {
+			scrubHeader(i.Request.Headers, name)
+			scrubHeader(i.Response.Headers, name)
+		}
+	}
+}
+
+// ScrubQueryParams replaces the values of the given query parameters in the
+// request URL.
+func ScrubQueryParams(names ...string) Scrubber {
+	return func(i *Interaction) {
+		u, err := url.Parse(i.Request.URL)
+		if err != nil {
+			return
+		}
+		query := u.Query()
+		for _, name := range names {
+			if _, ok := query[name]; ok {
+				query.Set(name, Redacted)
+			}
+		}
+		u.RawQuery = query.Encode()
+		i.Request.URL = u.String()
+	}
+}
+
+// ScrubBodies replaces every match of pattern in request and response bodies.
+func ScrubBodies(pattern *regexp.Regexp) Scrubber {
+	return func(i *Interaction) {
+		i.Request.Body = pattern.ReplaceAllString(i.Request.Body, Redacted)
+		i.Response.Body = pattern.ReplaceAllString(i.Response.Body, Redacted)
+	}
+}
+
+// ScrubValues replaces every occurrence of the given literal values, such as
+// passwords loaded from config, anywhere in the interaction.
+func ScrubValues(values ...string) Scrubber {
+	replace := func(s string) string {
+		for _, value := range values {
+			if value != "" {
+				s = strings.ReplaceAll(s, value, Redacted)
+			}
+		}
+		return s
+	}
+	replaceHeaders := func(h http.Header) {
+		for name, headerValues := range h {
+			for idx := range headerValues {
+				h[name][idx] = replace(headerValues[idx])
+			}
+		}
+	}
+
+	return func(i *Interaction) {
+		i.Request.URL = replace(i.Request.URL)
+		i.Request.Body = replace(i.Request.Body)
+		i.Response.Body = replace(i.Response.Body)
+		replaceHeaders(i.Request.Headers)
+		replaceHeaders(i.Response.Headers)
+	}
+}
+
+func scrubHeader(h http.Header, name string) {
+	name = http.CanonicalHeaderKey(name)
+	if _, ok := h[name]; ok {
+		h[name] = []string{Redacted}
+	}
+}
@@ -0,0 +1,257 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/deliveroo/bnt-internal-test-go/internal/httpserver/gorillautils"
+)
+
+// Version is the OpenAPI version of generated documents.
+const Version = "3.0.3"
+
+// Info describes the API, see
+// https://spec.openapis.org/oas/v3.0.3#info-object.
+type Info struct {
+	Title       string `json:"title"`
+	Description string `json:"description,omitempty"`
+	Version     string `json:"version"`
+}
+
+// Document is an OpenAPI document.
+type Document struct {
+	OpenAPI    string                           `json:"openapi"`
+	Info       Info                             `json:"info"`
+	Paths      map[string]map[string]*Operation `json:"paths"`
+	Components Components                       `json:"components"`
+}
+
+// Components holds the schemas and security schemes which operations refer to.
+type Components struct {
+	Schemas         map[string]*Schema        `json:"schemas,omitempty"`
+	SecuritySchemes map[string]SecurityScheme `json:"securitySchemes,omitempty"`
+}
+
+// Operation describes a route.
+type Operation struct {
+	OperationID string                `json:"operationId,omitempty"`
+	Summary     string                `json:"summary,omitempty"`
+	Tags        []string              `json:"tags,omitempty"`
+	Parameters  []Parameter           `json:"parameters,omitempty"`
+	RequestBody *RequestBody          `json:"requestBody,omitempty"`
+	Responses   map[string]Response   `json:"responses"`
+	Security    []map[string][]string `json:"security,omitempty"`
+}
+
+// Parameter describes a parameter of an operation.
+type Parameter struct {
+	Name        string  `json:"name"`
+	In          string  `json:"in"`
+	Description string  `json:"description,omitempty"`
+	Required    bool    `json:"required,omitempty"`
+	Schema      *Schema `json:"schema"`
+}
+
+// RequestBody describes the body of requests to an operation.
+type RequestBody struct {
+	Required bool                 `json:"required"`
+	Content  map[string]MediaType `json:"content"`
+}
+
+// Response describes a response of an operation.
+type Response struct {
+	Description string               `json:"description"`
+	Content     map[string]MediaType `json:"content,omitempty"`
+}
+
+// MediaType describes a body of a media type.
+type MediaType struct {
+	Schema *Schema `json:"schema"`
+}
+
+// problemSchema describes gorillautils.Problem, which encodes its extensions
+// alongside its other members.
+var problemSchema = &Schema{
+	Type: "object",
+	Properties: map[string]*Schema{
+		"type":   {Type: "string", Format: "uri"},
+		"title":  {Type: "string"},
+		"status": {Type: "integer", Format: "int32"},
+		"detail": {Type: "string"},
+	},
+	Required:             []string{"title", "status"},
+	AdditionalProperties: &Schema{},
+	Description:          "Problem details, see RFC 7807.",
+}
+
+// Document returns the OpenAPI document describing the routes registered on
+// the router and its subrouters.
+func (r *Router) Document(info Info) Document {
+	return documentOf(r.registry, info)
+}
+
+func documentOf(reg *registry, info Info) Document {
+	s := newSchemas()
+	s.override(gorillautils.Problem{}, "Problem", problemSchema)
+
+	doc := Document{
+		OpenAPI: Version,
+		Info:    info,
+		Paths:   map[string]map[string]*Operation{},
+	}
+	for _, route := range reg.routes {
+		path, _ := openAPIPath(route.path)
+		if doc.Paths[path] == nil {
+			doc.Paths[path] = map[string]*Operation{}
+		}
+		doc.Paths[path][strings.ToLower(route.Method)] = operation(s, route)
+	}
+
+	doc.Components.Schemas = s.components
+	if len(reg.securitySchemes) > 0 {
+		doc.Components.SecuritySchemes = reg.securitySchemes
+	}
+	return doc
+}
+
+func operation(s *schemas, route registeredRoute) *Operation {
+	op := &Operation{
+		OperationID: route.Name,
+		Summary:     route.Summary,
+		Tags:        route.Tags,
+		Parameters:  parameters(s, route),
+		Responses:   map[string]Response{},
+	}
+
+	if route.Request != nil {
+		op.RequestBody = &RequestBody{
+			Required: true,
+			Content:  map[string]MediaType{"application/json": {Schema: s.of(route.Request)}},
+		}
+	}
+
+	status := route.Status
+	if status == 0 {
+		status = http.StatusOK
+	}
+	success := Response{Description: http.StatusText(status)}
+	if route.Response != nil {
+		success.Content = map[string]MediaType{gorillautils.JSONContentType: {Schema: s.of(route.Response)}}
+		// Responses with a protobuf representation are rendered as protobuf
+		// to clients which prefer it.
+		if _, ok := route.Response.(gorillautils.ProtoValue); ok {
+			success.Content[gorillautils.ProtobufContentType] = MediaType{Schema: &Schema{Type: "string", Format: "binary"}}
+		}
+	}
+	op.Responses[strconv.Itoa(status)] = success
+
+	errorContent := map[string]MediaType{gorillautils.ProblemContentType: {Schema: s.of(gorillautils.Problem{})}}
+	if route.ErrorBody != nil {
+		errorContent = map[string]MediaType{"application/json": {Schema: s.of(route.ErrorBody)}}
+	}
+	for _, code := range route.Errors {
+		op.Responses[strconv.Itoa(code)] = Response{Description: http.StatusText(code), Content: errorContent}
+	}
+	if route.authenticated {
+		problem := map[string]MediaType{gorillautils.ProblemContentType: {Schema: s.of(gorillautils.Problem{})}}
+		op.Responses[strconv.Itoa(http.StatusUnauthorized)] = Response{Description: http.StatusText(http.StatusUnauthorized), Content: problem}
+		if len(route.Scopes) > 0 {
+			op.Responses[strconv.Itoa(http.StatusForbidden)] = Response{Description: http.StatusText(http.StatusForbidden), Content: problem}
+		}
+	}
+	if route.limited {
+		problem := map[string]MediaType{gorillautils.ProblemContentType: {Schema: s.of(gorillautils.Problem{})}}
+		op.Responses[strconv.Itoa(http.StatusTooManyRequests)] = Response{Description: http.StatusText(http.StatusTooManyRequests), Content: problem}
+	}
+
+	for _, requirement := range route.security {
+		scopes := requirement.scopes
+		if scopes == nil {
+			scopes = []string{}
+		}
+		op.Security = append(op.Security, map[string][]string{requirement.scheme: scopes})
+	}
+	return op
+}
+
+// parameters describes the parameters of route: those derived from its path,
+// followed by those it lists.
+func parameters(s *schemas, route registeredRoute) []Parameter {
+	listed := map[string]Param{}
+	for _, param := range route.Params {
+		if param.In == InPath {
+			listed[param.Name] = param
+		}
+	}
+
+	var params []Parameter
+	_, patterns := openAPIPath(route.path)
+	for _, name := range pathParams(route.path) {
+		param := listed[name]
+		param.Name, param.In = name, InPath
+		parameter := parameter(s, param)
+		parameter.Required = true
+		if pattern, ok := patterns[name]; ok && parameter.Schema.Type == "string" {
+			parameter.Schema.Pattern = pattern
+		}
+		params = append(params, parameter)
+	}
+
+	for _, param := range route.Params {
+		if param.In != InPath {
+			params = append(params, parameter(s, param))
+		}
+	}
+	return params
+}
+
+func parameter(s *schemas, param Param) Parameter {
+	example := param.Example
+	if example == nil {
+		example = ""
+	}
+	schema := *s.of(example)
+	return Parameter{
+		Name:        param.Name,
+		In:          param.In,
+		Description: param.Description,
+		Required:    param.Required,
+		Schema:      &schema,
+	}
+}
+
+// DocumentHandler serves the document describing the routes registered on r,
+// as JSON. The document is generated on the first request, by which time every
+// route has been registered.
+func DocumentHandler(r *Router, info Info) http.Handler {
+	var (
+		once    sync.Once
+		encoded []byte
+		err     error
+	)
+
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		once.Do(func() {
+			encoded, err = Marshal(r.Document(info))
+		})
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write(encoded)
+	})
+}
+
+// Marshal encodes doc as indented JSON, ending in a newline, as it is
+// committed to the repository.
+func Marshal(doc Document) ([]byte, error) {
+	encoded, err := json.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return nil, err //nolint:wrapcheck // the document holds nothing which can fail to encode
+	}
+	return append(encoded, '\n'), nil
+}
@@ -0,0 +1,240 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/deliveroo/bnt-internal-test-go/internal/httpserver/gorillautils"
+)
+
+type widget struct {
+	ID      int64     `json:"id"`
+	Name    string    `json:"name,omitempty"`
+	Created time.Time `json:"created"`
+	Parent  *widget   `json:"parent"`
+	Tags    []string  `json:"tags"`
+	Ignored string    `json:"-"`
+}
+
+func TestDocument(t *testing.T) {
+	noop := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+
+	t.Run("describes the parameters of a route", func(t *testing.T) {
+		routes := NewRouter(mux.NewRouter())
+		routes.Handle(Route{
+			Method:  http.MethodGet,
+			Path:    "/widgets/{id:[0-9]+}/parts/{name}",
+			Handler: noop,
+			Params: []Param{
+				{Name: "id", In: InPath, Description: "The widget.", Example: 0},
+				{Name: "limit", In: InQuery, Example: 0},
+			},
+		})
+
+		doc := routes.Document(Info{Title: "test", Version: "1"})
+		op := doc.Paths["/widgets/{id}/parts/{name}"]["get"]
+		if assert.NotNil(t, op) {
+			assert.Equal(t, []Parameter{
+				{Name: "id", In: InPath, Description: "The widget.", Required: true, Schema: &Schema{Type: "integer", Format: "int64"}},
+				{Name: "name", In: InPath, Required: true, Schema: &Schema{Type: "string"}},
+				{Name: "limit", In: InQuery, Schema: &Schema{Type: "integer", Format: "int64"}},
+			}, op.Parameters)
+		}
+	})
+
+	t.Run("describes path patterns of string parameters", func(t *testing.T) {
+		routes := NewRouter(mux.NewRouter())
+		routes.Handle(Route{Method: http.MethodGet, Path: "/widgets/{id:[a-z]+}", Handler: noop})
+
+		op := routes.Document(Info{}).Paths["/widgets/{id}"]["get"]
+		if assert.NotNil(t, op) {
+			assert.Equal(t, "^[a-z]+$", op.Parameters[0].Schema.Pattern)
+		}
+	})
+
+	t.Run("describes bodies as components", func(t *testing.T) {
+		routes := NewRouter(mux.NewRouter())
+		routes.Handle(Route{
+			Method:   http.MethodPost,
+			Path:     "/widgets",
+			Handler:  noop,
+			Request:  widget{},
+			Response: widget{},
+			Status:   http.StatusCreated,
+			Errors:   []int{http.StatusConflict},
+		})
+
+		doc := routes.Document(Info{})
+		op := doc.Paths["/widgets"]["post"]
+		ref := &Schema{Ref: "#/components/schemas/widget"}
+		assert.Equal(t, ref, op.RequestBody.Content["application/json"].Schema)
+		assert.Equal(t, ref, op.Responses["201"].Content["application/json"].Schema)
+		assert.Equal(t, &Schema{Ref: "#/components/schemas/Problem"}, op.Responses["409"].Content[gorillautils.ProblemContentType].Schema)
+		assert.Equal(t, &Schema{
+			Type: "object",
+			Properties: map[string]*Schema{
+				"id":      {Type: "integer", Format: "int64"},
+				"name":    {Type: "string"},
+				"created": {Type: "string", Format: "date-time"},
+				"parent":  ref,
+				"tags":    {Type: "array", Items: &Schema{Type: "string"}},
+			},
+			Required: []string{"id", "created", "parent", "tags"},
+		}, doc.Components.Schemas["widget"])
+		assert.Equal(t, problemSchema, doc.Components.Schemas["Problem"])
+	})
+
+	t.Run("describes the security of subrouters", func(t *testing.T) {
+		routes := NewRouter(mux.NewRouter())
+		routes.Handle(Route{Method: http.MethodGet, Path: "/public", Handler: noop})
+		admin := routes.Subrouter("/admin")
+		admin.RequireSecurity("token", SecurityScheme{Type: "http", Scheme: "bearer"})
+		admin.Handle(Route{Method: http.MethodGet, Path: "/private", Handler: noop})
+
+		doc := routes.Document(Info{})
+		assert.Nil(t, doc.Paths["/public"]["get"].Security)
+		assert.Equal(t, []map[string][]string{{"token": {}}}, doc.Paths["/admin/private"]["get"].Security)
+		assert.Equal(t, map[string]SecurityScheme{"token": {Type: "http", Scheme: "bearer"}}, doc.Components.SecuritySchemes)
+	})
+}
+
+func TestRouter(t *testing.T) {
+	t.Run("serves routes through their middleware", func(t *testing.T) {
+		r := mux.NewRouter()
+		routes := NewRouter(r)
+		routes.Handle(Route{
+			Method: http.MethodGet,
+			Path:   "/widgets",
+			Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusTeapot)
+			}),
+			Middleware: []mux.MiddlewareFunc{func(next http.Handler) http.Handler {
+				return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					w.Header().Set("X-Middleware", "true")
+					next.ServeHTTP(w, r)
+				})
+			}},
+		})
+
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/widgets", nil))
+		assert.Equal(t, http.StatusTeapot, rec.Code)
+		assert.Equal(t, "true", rec.Header().Get("X-Middleware"))
+
+		rec = httptest.NewRecorder()
+		r.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/widgets", nil))
+		assert.Equal(t, http.StatusMethodNotAllowed, rec.Code)
+	})
+}
+
+func TestAuthenticated(t *testing.T) {
+	var authorized [][]string
+	authorize := func(scopes []string) mux.MiddlewareFunc {
+		authorized = append(authorized, scopes)
+		return func(next http.Handler) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.Header.Get("Authorization") == "" {
+					w.WriteHeader(http.StatusUnauthorized)
+					return
+				}
+				next.ServeHTTP(w, r)
+			})
+		}
+	}
+	noop := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+
+	r := mux.NewRouter()
+	routes := NewRouter(r)
+	routes.Handle(Route{Method: http.MethodGet, Path: "/public", Handler: noop})
+	services := routes.Authenticated(map[string]SecurityScheme{
+		"jwt":    {Type: "http", Scheme: "bearer"},
+		"apiKey": {Type: "apiKey", In: InHeader, Name: "X-API-Key"},
+	}, authorize)
+	services.Handle(Route{Method: http.MethodGet, Path: "/widgets", Handler: noop, Scopes: []string{"widgets:read"}})
+
+	t.Run("wraps routes in the middleware for their scopes", func(t *testing.T) {
+		assert.Equal(t, [][]string{{"widgets:read"}}, authorized)
+
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/widgets", nil))
+		assert.Equal(t, http.StatusUnauthorized, rec.Code)
+
+		rec = httptest.NewRecorder()
+		r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/public", nil))
+		assert.Equal(t, http.StatusOK, rec.Code)
+	})
+
+	t.Run("describes the scopes and errors of routes, accepting any scheme", func(t *testing.T) {
+		doc := routes.Document(Info{})
+		op := doc.Paths["/widgets"]["get"]
+		assert.Equal(t, []map[string][]string{{"apiKey": {"widgets:read"}}, {"jwt": {"widgets:read"}}}, op.Security)
+		assert.Contains(t, op.Responses, "401")
+		assert.Contains(t, op.Responses, "403")
+		assert.Nil(t, doc.Paths["/public"]["get"].Security)
+	})
+}
+
+func TestLimit(t *testing.T) {
+	var limited []string
+	limit := func(route Route) mux.MiddlewareFunc {
+		if route.Name == "ping" {
+			return nil
+		}
+		limited = append(limited, route.Name)
+		return func(next http.Handler) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusTooManyRequests)
+			})
+		}
+	}
+	noop := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+
+	r := mux.NewRouter()
+	routes := NewRouter(r)
+	routes.Handle(Route{Method: http.MethodGet, Path: "/unlimited", Name: "unlimited", Handler: noop})
+	routes.Limit(limit)
+	routes.Handle(Route{Method: http.MethodGet, Path: "/ping", Name: "ping", Handler: noop})
+	routes.Subrouter("/v1").Handle(Route{Method: http.MethodGet, Path: "/widgets", Name: "listWidgets", Handler: noop})
+
+	t.Run("wraps routes registered from then on in their middleware", func(t *testing.T) {
+		assert.Equal(t, []string{"listWidgets"}, limited)
+
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/v1/widgets", nil))
+		assert.Equal(t, http.StatusTooManyRequests, rec.Code)
+
+		rec = httptest.NewRecorder()
+		r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/ping", nil))
+		assert.Equal(t, http.StatusOK, rec.Code)
+	})
+
+	t.Run("describes the errors of limited routes", func(t *testing.T) {
+		doc := routes.Document(Info{})
+		assert.Contains(t, doc.Paths["/v1/widgets"]["get"].Responses, "429")
+		assert.NotContains(t, doc.Paths["/ping"]["get"].Responses, "429")
+		assert.NotContains(t, doc.Paths["/unlimited"]["get"].Responses, "429")
+	})
+}
+
+func TestTimeout(t *testing.T) {
+	var left time.Duration
+	r := mux.NewRouter()
+	NewRouter(r).Handle(Route{
+		Method:  http.MethodGet,
+		Path:    "/widgets",
+		Timeout: 2 * time.Second,
+		Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			deadline, ok := r.Context().Deadline()
+			assert.True(t, ok)
+			left = time.Until(deadline)
+		}),
+	})
+
+	r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/widgets", nil))
+	assert.InDelta(t, float64(2*time.Second), float64(left), float64(100*time.Millisecond))
+}
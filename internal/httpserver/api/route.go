@@ -0,0 +1,276 @@
+// Package api registers HTTP routes together with a description of their
+// requests and responses, from which it generates an OpenAPI 3 document.
+//
+// Routes are registered on a Router, which wraps a mux.Router. Each Route
+// declares the types of its request and response bodies, its parameters and the
+// errors it can respond with, so that the document cannot drift from the
+// handlers serving it.
+package api
+
+import (
+	"context"
+	"net/http"
+	"regexp"
+	"sort"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// Parameter locations.
+const (
+	InPath   = "path"
+	InQuery  = "query"
+	InHeader = "header"
+)
+
+// Route describes an endpoint and the handler serving it.
+type Route struct {
+	// Method is the HTTP method of the route.
+	Method string
+	// Path is the mux path template of the route, relative to its Router,
+	// e.g. "/orders/{id:[0-9]+}".
+	Path string
+	// Name identifies the route in the document, as its operationId.
+	Name string
+	// Summary describes the route in a sentence.
+	Summary string
+	// Tags group the route with related ones.
+	Tags []string
+	// Handler serves the route.
+	Handler http.Handler
+	// Middleware wraps Handler for this route only.
+	Middleware []mux.MiddlewareFunc
+	// Scopes are the scopes requests must be granted, on routes registered on
+	// an authenticated Router.
+	Scopes []string
+	// Timeout bounds the time taken to serve a request, by the deadline of its
+	// context. The handler must respond when the context is done. Zero leaves
+	// requests bounded only by the server's timeouts.
+	Timeout time.Duration
+
+	// Params describes the parameters of the route. Path parameters are
+	// derived from Path, and only need to be listed to describe them further.
+	Params []Param
+	// Request is a value of the type of the JSON request body, or nil when the
+	// route takes no body.
+	Request interface{}
+	// Response is a value of the type of the JSON response body, or nil when
+	// the route responds without one.
+	Response interface{}
+	// Status is the status code of a successful response. Defaults to 200.
+	Status int
+	// Errors lists the status codes of the errors the route responds with.
+	Errors []int
+	// ErrorBody is a value of the type of the JSON body of errors. Defaults to
+	// problem details.
+	ErrorBody interface{}
+}
+
+// Param describes a parameter of a route.
+type Param struct {
+	// Name is the name of the parameter.
+	Name string
+	// In is where the parameter is, one of InPath, InQuery or InHeader.
+	In string
+	// Description describes the parameter.
+	Description string
+	// Required is whether requests must include the parameter. Path
+	// parameters are always required.
+	Required bool
+	// Example is a value of the type of the parameter. Defaults to a string.
+	Example interface{}
+}
+
+// SecurityScheme describes how requests authenticate, see
+// https://spec.openapis.org/oas/v3.0.3#security-scheme-object.
+type SecurityScheme struct {
+	Type         string `json:"type"`
+	Scheme       string `json:"scheme,omitempty"`
+	BearerFormat string `json:"bearerFormat,omitempty"`
+	Name         string `json:"name,omitempty"`
+	In           string `json:"in,omitempty"`
+	Description  string `json:"description,omitempty"`
+}
+
+// registry holds the routes of a Router and its subrouters.
+type registry struct {
+	routes          []registeredRoute
+	securitySchemes map[string]SecurityScheme
+	// validation validates the routes registered while it is set.
+	validation *validation
+}
+
+type registeredRoute struct {
+	Route
+	// path is the full mux path template of the route.
+	path     string
+	security []securityRequirement
+	// authenticated is whether the route is wrapped in authentication, which
+	// rejects requests with problem details.
+	authenticated bool
+	// limited is whether the route is wrapped in rate limiting, which rejects
+	// requests with problem details.
+	limited bool
+}
+
+// securityRequirement names a security scheme a route requires, with the
+// scopes it requires.
+type securityRequirement struct {
+	scheme string
+	scopes []string
+}
+
+// Router registers routes on a mux.Router, keeping their descriptions.
+type Router struct {
+	mux      *mux.Router
+	prefix   string
+	security []string
+	registry *registry
+
+	// authenticated are the security schemes authorize accepts, any one of
+	// which authenticates routes registered on an authenticated Router.
+	authenticated []string
+	authorize     func(scopes []string) mux.MiddlewareFunc
+	// limit returns the middleware limiting the rate of requests to a route,
+	// or nil when the route is not limited.
+	limit func(route Route) mux.MiddlewareFunc
+}
+
+// NewRouter returns a Router which registers routes on r.
+func NewRouter(r *mux.Router) *Router {
+	return &Router{mux: r, registry: &registry{securitySchemes: map[string]SecurityScheme{}}}
+}
+
+// Mux returns the mux.Router which routes are registered on.
+func (r *Router) Mux() *mux.Router {
+	return r.mux
+}
+
+// Use adds middleware to the routes of the router.
+func (r *Router) Use(mw ...mux.MiddlewareFunc) {
+	r.mux.Use(mw...)
+}
+
+// Handle registers route.
+func (r *Router) Handle(route Route) *mux.Route {
+	handler := route.Handler
+	for i := len(route.Middleware) - 1; i >= 0; i-- {
+		handler = route.Middleware[i](handler)
+	}
+
+	var limit mux.MiddlewareFunc
+	if r.limit != nil {
+		limit = r.limit(route)
+	}
+
+	registered := registeredRoute{Route: route, path: r.prefix + route.Path, limited: limit != nil}
+	for _, scheme := range r.security {
+		registered.security = append(registered.security, securityRequirement{scheme: scheme})
+	}
+	if r.authorize != nil {
+		for _, scheme := range r.authenticated {
+			registered.security = append(registered.security, securityRequirement{scheme: scheme, scopes: route.Scopes})
+		}
+		registered.authenticated = true
+	}
+	r.registry.routes = append(r.registry.routes, registered)
+
+	handler = r.registry.validated(registered, handler)
+	if limit != nil {
+		// Count invalid requests too, but only once callers are authenticated,
+		// so that they can be told apart.
+		handler = limit(handler)
+	}
+	if r.authorize != nil {
+		// Authenticate before validating, so that unauthenticated clients
+		// learn nothing about the API.
+		handler = r.authorize(route.Scopes)(handler)
+	}
+	if route.Timeout > 0 {
+		handler = withTimeout(route.Timeout, handler)
+	}
+
+	return r.mux.Handle(route.Path, handler).Methods(route.Method)
+}
+
+// withTimeout serves requests to next with a context which is done after
+// timeout.
+func withTimeout(timeout time.Duration, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), timeout)
+		defer cancel()
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// Subrouter returns a Router for the routes under prefix.
+func (r *Router) Subrouter(prefix string) *Router {
+	return &Router{
+		mux:           r.mux.PathPrefix(prefix).Subrouter(),
+		prefix:        r.prefix + prefix,
+		security:      r.security,
+		registry:      r.registry,
+		authenticated: r.authenticated,
+		authorize:     r.authorize,
+		limit:         r.limit,
+	}
+}
+
+// Authenticated returns a Router registering routes on the same mux.Router,
+// whose routes require any one of schemes, by name. Each route is wrapped in
+// the middleware returned by authorize for the scopes it declares.
+func (r *Router) Authenticated(schemes map[string]SecurityScheme, authorize func(scopes []string) mux.MiddlewareFunc) *Router {
+	authenticated := *r
+	authenticated.authenticated = make([]string, 0, len(schemes))
+	for name, scheme := range schemes {
+		r.registry.securitySchemes[name] = scheme
+		authenticated.authenticated = append(authenticated.authenticated, name)
+	}
+	// Sort the schemes, so that the document is stable.
+	sort.Strings(authenticated.authenticated)
+	authenticated.authorize = authorize
+	return &authenticated
+}
+
+// Limit limits the rate of requests to the routes registered on the router and
+// its subrouters from now on, wrapping each route in the middleware limit
+// returns for it, which rejects requests with 429 Too Many Requests problem
+// details. Routes for which limit returns nil are not limited.
+func (r *Router) Limit(limit func(route Route) mux.MiddlewareFunc) {
+	r.limit = limit
+}
+
+// RequireSecurity documents that the routes of the router require the security
+// scheme called name. The middleware enforcing it must be added separately.
+func (r *Router) RequireSecurity(name string, scheme SecurityScheme) {
+	r.registry.securitySchemes[name] = scheme
+	r.security = append(append([]string(nil), r.security...), name)
+}
+
+// pathParam matches a variable in a mux path template, e.g. {id:[0-9]+}.
+var pathParam = regexp.MustCompile(`\{([^}:]+)(?::([^}]+))?\}`)
+
+// openAPIPath converts a mux path template to an OpenAPI path, returning the
+// pattern of each variable which has one.
+func openAPIPath(template string) (string, map[string]string) {
+	patterns := map[string]string{}
+	path := pathParam.ReplaceAllStringFunc(template, func(variable string) string {
+		match := pathParam.FindStringSubmatch(variable)
+		if match[2] != "" {
+			patterns[match[1]] = "^" + match[2] + "$"
+		}
+		return "{" + match[1] + "}"
+	})
+	return path, patterns
+}
+
+// pathParams returns the names of the variables in a mux path template, in
+// order.
+func pathParams(template string) []string {
+	var names []string
+	for _, match := range pathParam.FindAllStringSubmatch(template, -1) {
+		names = append(names, match[1])
+	}
+	return names
+}
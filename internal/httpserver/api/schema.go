@@ -0,0 +1,238 @@
+package api
+
+import (
+	"encoding"
+	"encoding/json"
+	"path"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// Schema is an OpenAPI schema object, describing a JSON value.
+type Schema struct {
+	Ref                  string             `json:"$ref,omitempty"`
+	Type                 string             `json:"type,omitempty"`
+	Format               string             `json:"format,omitempty"`
+	Pattern              string             `json:"pattern,omitempty"`
+	Nullable             bool               `json:"nullable,omitempty"`
+	Minimum              *float64           `json:"minimum,omitempty"`
+	Maximum              *float64           `json:"maximum,omitempty"`
+	MinLength            *int               `json:"minLength,omitempty"`
+	MaxLength            *int               `json:"maxLength,omitempty"`
+	Enum                 []string           `json:"enum,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	AdditionalProperties *Schema            `json:"additionalProperties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	Description          string             `json:"description,omitempty"`
+}
+
+var (
+	timeType       = reflect.TypeOf(time.Time{})
+	jsonMarshaler  = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+	textMarshaler  = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+	rawMessageType = reflect.TypeOf(json.RawMessage{})
+	anyType        = reflect.TypeOf((*interface{})(nil)).Elem()
+)
+
+// schemas generates schemas for Go types, as encoded by encoding/json. Named
+// struct types become components, which schemas refer to.
+type schemas struct {
+	components map[string]*Schema
+	// names records the component name of each type, and types the type
+	// of each name, so that types with the same name in different packages
+	// get different names.
+	names map[reflect.Type]string
+	types map[string]reflect.Type
+	// overrides are schemas for types which encoding/json cannot describe,
+	// e.g. because they implement json.Marshaler.
+	overrides map[reflect.Type]*Schema
+}
+
+func newSchemas() *schemas {
+	return &schemas{
+		components: map[string]*Schema{},
+		names:      map[reflect.Type]string{},
+		types:      map[string]reflect.Type{},
+		overrides:  map[reflect.Type]*Schema{},
+	}
+}
+
+// override describes the type of value with schema, as the component called
+// name.
+func (s *schemas) override(value interface{}, name string, schema *Schema) {
+	t := reflect.TypeOf(value)
+	s.names[t] = name
+	s.types[name] = t
+	s.components[name] = schema
+	s.overrides[t] = &Schema{Ref: "#/components/schemas/" + name}
+}
+
+// Partial describes a request body of the type of value in which every field
+// is optional, such as the body of a PATCH request.
+func Partial(value interface{}) interface{} {
+	return partial{value}
+}
+
+type partial struct {
+	value interface{}
+}
+
+// of returns the schema of the type of value.
+func (s *schemas) of(value interface{}) *Schema {
+	if p, ok := value.(partial); ok {
+		return s.partial(reflect.TypeOf(p.value))
+	}
+	return s.forType(reflect.TypeOf(value))
+}
+
+// partial returns a reference to the component describing the struct type t
+// without required fields, called t's name followed by "Patch".
+func (s *schemas) partial(t reflect.Type) *Schema {
+	whole := s.forType(t)
+	if whole.Ref == "" {
+		return whole
+	}
+
+	base := strings.TrimPrefix(whole.Ref, "#/components/schemas/")
+	name := base + "Patch"
+	if _, ok := s.components[name]; !ok {
+		schema := *s.components[base]
+		schema.Required = nil
+		s.components[name] = &schema
+	}
+	return &Schema{Ref: "#/components/schemas/" + name}
+}
+
+func (s *schemas) forType(t reflect.Type) *Schema {
+	if schema, ok := s.overrides[t]; ok {
+		return schema
+	}
+
+	switch {
+	case t == timeType:
+		return &Schema{Type: "string", Format: "date-time"}
+	case t == rawMessageType || t == anyType:
+		return &Schema{}
+	case t.Kind() == reflect.Ptr:
+		schema := *s.forType(t.Elem())
+		if schema.Ref != "" {
+			return &schema
+		}
+		schema.Nullable = true
+		return &schema
+	case t.Implements(jsonMarshaler) || reflect.PtrTo(t).Implements(jsonMarshaler):
+		// The encoding is up to the type, but is a string more often than not.
+		return &Schema{Type: "string"}
+	case t.Implements(textMarshaler) || reflect.PtrTo(t).Implements(textMarshaler):
+		return &Schema{Type: "string"}
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int, reflect.Int64:
+		return &Schema{Type: "integer", Format: "int64"}
+	case reflect.Int8, reflect.Int16, reflect.Int32:
+		return &Schema{Type: "integer", Format: "int32"}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		zero := 0.0
+		return &Schema{Type: "integer", Minimum: &zero}
+	case reflect.Float32:
+		return &Schema{Type: "number", Format: "float"}
+	case reflect.Float64:
+		return &Schema{Type: "number", Format: "double"}
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return &Schema{Type: "string", Format: "byte"}
+		}
+		return &Schema{Type: "array", Items: s.forType(t.Elem())}
+	case reflect.Map:
+		return &Schema{Type: "object", AdditionalProperties: s.forType(t.Elem())}
+	case reflect.Struct:
+		return s.forStruct(t)
+	default:
+		return &Schema{}
+	}
+}
+
+// forStruct returns a reference to the component describing t, generating it
+// the first time. Anonymous structs are described in place.
+func (s *schemas) forStruct(t reflect.Type) *Schema {
+	if t.Name() == "" {
+		return s.structSchema(t)
+	}
+
+	name, ok := s.names[t]
+	if !ok {
+		name = s.nameFor(t)
+		s.names[t] = name
+		s.types[name] = t
+		// Register the name before describing the fields, so that recursive
+		// types refer to themselves.
+		s.components[name] = &Schema{}
+		*s.components[name] = *s.structSchema(t)
+	}
+
+	return &Schema{Ref: "#/components/schemas/" + name}
+}
+
+// nameFor returns a component name for t, qualifying it with its package when
+// another type already has its name.
+func (s *schemas) nameFor(t reflect.Type) string {
+	name := t.Name()
+	if other, ok := s.types[name]; ok && other != t {
+		pkg := path.Base(t.PkgPath())
+		name = strings.ToUpper(pkg[:1]) + pkg[1:] + name
+	}
+	return name
+}
+
+func (s *schemas) structSchema(t reflect.Type) *Schema {
+	schema := &Schema{Type: "object", Properties: map[string]*Schema{}}
+	s.addFields(schema, t)
+	return schema
+}
+
+// addFields adds the fields of t to schema as encoding/json encodes them,
+// including the fields of embedded structs.
+func (s *schemas) addFields(schema *Schema, t reflect.Type) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag := field.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, opts, _ := strings.Cut(tag, ",")
+
+		if field.Anonymous && name == "" {
+			embedded := field.Type
+			if embedded.Kind() == reflect.Ptr {
+				embedded = embedded.Elem()
+			}
+			if embedded.Kind() == reflect.Struct {
+				s.addFields(schema, embedded)
+				continue
+			}
+		}
+		if !field.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+
+		property := s.forType(field.Type)
+		if opts == "string" {
+			property = &Schema{Type: "string"}
+		}
+		schema.Properties[name] = property
+
+		if !strings.Contains(opts, "omitempty") {
+			schema.Required = append(schema.Required, name)
+		}
+	}
+}
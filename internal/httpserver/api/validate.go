@@ -0,0 +1,440 @@
+package api
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/gorilla/mux"
+	"go.uber.org/zap"
+
+	"github.com/deliveroo/bnt-internal-test-go/internal/httpserver/gorillautils"
+)
+
+// maxValidatedBody is the largest request body which is validated.
+const maxValidatedBody = 1 << 20
+
+// Violation is a way in which a request or response does not match its
+// description.
+type Violation struct {
+	// In is where the violation is: "path", "query", "header" or "body".
+	In string `json:"in"`
+	// Name is the parameter, or the path of the value within the body, e.g.
+	// "items[0].status". It is empty for the body as a whole.
+	Name string `json:"name,omitempty"`
+	// Reason explains the violation.
+	Reason string `json:"reason"`
+}
+
+func (v Violation) String() string {
+	if v.Name == "" {
+		return v.In + ": " + v.Reason
+	}
+	return v.In + " " + v.Name + ": " + v.Reason
+}
+
+type validation struct {
+	logger    *zap.Logger
+	responses bool
+
+	once      sync.Once
+	validator *validator
+}
+
+// ValidationOption configures the validation of routes.
+type ValidationOption func(*validation)
+
+// WithResponseValidation also validates the responses of routes, logging those
+// which do not match their description. Responses are sent regardless. It
+// should not be used in production, where it doubles the cost of encoding
+// responses.
+func WithResponseValidation(logger *zap.Logger) ValidationOption {
+	return func(v *validation) {
+		v.responses = true
+		v.logger = logger
+	}
+}
+
+// Validate validates requests to the routes registered on the router and its
+// subrouters from now on, against the OpenAPI document describing them. Invalid
+// requests are rejected with 400 Bad Request problem details, listing their
+// violations, before reaching the handler and the route's middleware.
+func (r *Router) Validate(opts ...ValidationOption) {
+	v := &validation{logger: zap.NewNop()}
+	for _, opt := range opts {
+		opt(v)
+	}
+	r.registry.validation = v
+}
+
+// validated wraps the handler of route with validation, when enabled. The
+// document is generated on the first request, once every route is registered.
+func (reg *registry) validated(route registeredRoute, next http.Handler) http.Handler {
+	v := reg.validation
+	if v == nil {
+		return next
+	}
+
+	path, _ := openAPIPath(route.path)
+	method := strings.ToLower(route.Method)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		v.once.Do(func() {
+			v.validator = newValidator(documentOf(reg, Info{}))
+		})
+		op := v.validator.doc.Paths[path][method]
+
+		violations, err := v.validator.request(op, r)
+		switch {
+		case errors.Is(err, errBodyTooLarge):
+			_ = gorillautils.RenderProblem(w, gorillautils.Problem{
+				Status: http.StatusRequestEntityTooLarge,
+				Detail: fmt.Sprintf("Request bodies must be at most %d bytes.", maxValidatedBody),
+			})
+			return
+		case err != nil:
+			_ = gorillautils.RenderProblem(w, gorillautils.Problem{Status: http.StatusBadRequest, Detail: "The request body could not be read."})
+			return
+		case len(violations) > 0:
+			_ = gorillautils.RenderProblem(w, gorillautils.Problem{
+				Status:     http.StatusBadRequest,
+				Detail:     "The request does not match the API description.",
+				Extensions: map[string]interface{}{"violations": violations},
+			})
+			return
+		}
+
+		if !v.responses {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		rec := &responseRecorder{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(rec, r)
+		if violations := v.validator.response(op, rec.status, rec.Header(), rec.body.Bytes()); len(violations) > 0 {
+			v.logger.Warn("response does not match the API description",
+				zap.String("method", route.Method),
+				zap.String("path", path),
+				zap.Int("status", rec.status),
+				zap.Stringers("violations", violations),
+			)
+		}
+	})
+}
+
+var errBodyTooLarge = errors.New("body too large")
+
+// validator validates requests and responses against a document.
+type validator struct {
+	doc Document
+	// patterns caches the compiled patterns of schemas.
+	patterns sync.Map
+}
+
+func newValidator(doc Document) *validator {
+	return &validator{doc: doc}
+}
+
+// request returns the violations of r against op. The body of r is read, and
+// replaced so that the handler can read it again.
+func (v *validator) request(op *Operation, r *http.Request) ([]Violation, error) {
+	var violations []Violation
+
+	vars := mux.Vars(r)
+	query := r.URL.Query()
+	for _, param := range op.Parameters {
+		var (
+			value   string
+			present bool
+		)
+		switch param.In {
+		case InPath:
+			value, present = vars[param.Name]
+		case InQuery:
+			present = query.Has(param.Name)
+			value = query.Get(param.Name)
+		case InHeader:
+			values := r.Header.Values(param.Name)
+			present = len(values) > 0
+			if present {
+				value = values[0]
+			}
+		}
+
+		if !present {
+			if param.Required {
+				violations = append(violations, Violation{In: param.In, Name: param.Name, Reason: "is required"})
+			}
+			continue
+		}
+		for _, reason := range v.param(param.Schema, value) {
+			violations = append(violations, Violation{In: param.In, Name: param.Name, Reason: reason})
+		}
+	}
+
+	if op.RequestBody == nil {
+		return violations, nil
+	}
+
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxValidatedBody+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read request body: %w", err)
+	}
+	if len(body) > maxValidatedBody {
+		return nil, errBodyTooLarge
+	}
+	r.Body = io.NopCloser(bytes.NewReader(body))
+
+	media, ok := op.RequestBody.Content["application/json"]
+	switch {
+	case !ok:
+		return violations, nil
+	case len(bytes.TrimSpace(body)) == 0:
+		if op.RequestBody.Required {
+			violations = append(violations, Violation{In: "body", Reason: "is required"})
+		}
+		return violations, nil
+	}
+	return append(violations, v.body(media.Schema, body)...), nil
+}
+
+// response returns the violations of a response with status, header and body
+// against op.
+func (v *validator) response(op *Operation, status int, header http.Header, body []byte) []Violation {
+	response, ok := op.Responses[strconv.Itoa(status)]
+	if !ok {
+		response, ok = op.Responses["default"]
+	}
+	if !ok {
+		return []Violation{{In: "status", Reason: fmt.Sprintf("%d is not described", status)}}
+	}
+	if len(response.Content) == 0 {
+		return nil
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(header.Get("Content-Type"))
+	media, ok := response.Content[mediaType]
+	if !ok {
+		return []Violation{{In: "header", Name: "Content-Type", Reason: fmt.Sprintf("%q is not described", mediaType)}}
+	}
+	// Binary bodies, such as protobuf, are not described by their schema.
+	if media.Schema.Type == "string" && media.Schema.Format == "binary" {
+		return nil
+	}
+	return v.body(media.Schema, body)
+}
+
+// body returns the violations of the JSON body against schema.
+func (v *validator) body(schema *Schema, body []byte) []Violation {
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber()
+	var value interface{}
+	if err := decoder.Decode(&value); err != nil {
+		return []Violation{{In: "body", Reason: "must be JSON: " + err.Error()}}
+	}
+
+	var violations []Violation
+	v.validate(schema, value, "", func(name, reason string) {
+		violations = append(violations, Violation{In: "body", Name: name, Reason: reason})
+	})
+	return violations
+}
+
+// param returns the reasons value, the text of a parameter, does not match
+// schema.
+func (v *validator) param(schema *Schema, value string) []string {
+	var decoded interface{} = value
+	switch schema.Type {
+	case "integer", "number":
+		decoded = json.Number(value)
+	case "boolean":
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return []string{"must be a boolean"}
+		}
+		decoded = b
+	}
+
+	var reasons []string
+	v.validate(schema, decoded, "", func(_, reason string) {
+		reasons = append(reasons, reason)
+	})
+	return reasons
+}
+
+// validate reports each way value, decoded from JSON with numbers as
+// json.Number, does not match schema. name is the path of value.
+func (v *validator) validate(schema *Schema, value interface{}, name string, report func(name, reason string)) {
+	schema = v.resolve(schema)
+	if schema == nil || schema.Type == "" {
+		return
+	}
+	if value == nil {
+		if !schema.Nullable {
+			report(name, "must not be null")
+		}
+		return
+	}
+
+	switch schema.Type {
+	case "object":
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			report(name, "must be an object")
+			return
+		}
+		for _, property := range schema.Required {
+			if _, ok := object[property]; !ok {
+				report(join(name, property), "is required")
+			}
+		}
+		for property, propertyValue := range object {
+			if propertySchema, ok := schema.Properties[property]; ok {
+				v.validate(propertySchema, propertyValue, join(name, property), report)
+			} else if schema.AdditionalProperties != nil {
+				v.validate(schema.AdditionalProperties, propertyValue, join(name, property), report)
+			}
+		}
+
+	case "array":
+		array, ok := value.([]interface{})
+		if !ok {
+			report(name, "must be an array")
+			return
+		}
+		for i, item := range array {
+			v.validate(schema.Items, item, fmt.Sprintf("%s[%d]", name, i), report)
+		}
+
+	case "string":
+		s, ok := value.(string)
+		if !ok {
+			report(name, "must be a string")
+			return
+		}
+		v.validateString(schema, s, name, report)
+
+	case "integer", "number":
+		number, ok := value.(json.Number)
+		if !ok {
+			report(name, "must be a number")
+			return
+		}
+		f, err := number.Float64()
+		if err != nil {
+			report(name, "must be a number")
+			return
+		}
+		if schema.Type == "integer" {
+			if _, err := number.Int64(); err != nil {
+				report(name, "must be an integer")
+				return
+			}
+		}
+		if schema.Minimum != nil && f < *schema.Minimum {
+			report(name, fmt.Sprintf("must be at least %v", *schema.Minimum))
+		}
+		if schema.Maximum != nil && f > *schema.Maximum {
+			report(name, fmt.Sprintf("must be at most %v", *schema.Maximum))
+		}
+
+	case "boolean":
+		if _, ok := value.(bool); !ok {
+			report(name, "must be a boolean")
+		}
+	}
+}
+
+func (v *validator) validateString(schema *Schema, s, name string, report func(name, reason string)) {
+	length := utf8.RuneCountInString(s)
+	if schema.MinLength != nil && length < *schema.MinLength {
+		report(name, fmt.Sprintf("must be at least %d characters", *schema.MinLength))
+	}
+	if schema.MaxLength != nil && length > *schema.MaxLength {
+		report(name, fmt.Sprintf("must be at most %d characters", *schema.MaxLength))
+	}
+	if len(schema.Enum) > 0 && !contains(schema.Enum, s) {
+		report(name, "must be one of "+strings.Join(schema.Enum, ", "))
+	}
+	if schema.Pattern != "" && !v.pattern(schema.Pattern).MatchString(s) {
+		report(name, "must match "+schema.Pattern)
+	}
+
+	switch schema.Format {
+	case "date-time":
+		if _, err := time.Parse(time.RFC3339, s); err != nil {
+			report(name, "must be an RFC 3339 date-time")
+		}
+	case "byte":
+		if _, err := base64.StdEncoding.DecodeString(s); err != nil {
+			report(name, "must be base64")
+		}
+	}
+}
+
+// resolve returns the schema schema refers to, if it is a reference.
+func (v *validator) resolve(schema *Schema) *Schema {
+	for schema != nil && schema.Ref != "" {
+		schema = v.doc.Components.Schemas[strings.TrimPrefix(schema.Ref, "#/components/schemas/")]
+	}
+	return schema
+}
+
+func (v *validator) pattern(pattern string) *regexp.Regexp {
+	if compiled, ok := v.patterns.Load(pattern); ok {
+		return compiled.(*regexp.Regexp) //nolint:forcetypeassert // only regexps are stored
+	}
+	compiled := regexp.MustCompile(pattern)
+	v.patterns.Store(pattern, compiled)
+	return compiled
+}
+
+func join(name, property string) string {
+	if name == "" {
+		return property
+	}
+	return name + "." + property
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// responseRecorder records the status and body of the response written through
+// it.
+type responseRecorder struct {
+	http.ResponseWriter
+	status      int
+	body        bytes.Buffer
+	wroteHeader bool
+}
+
+func (r *responseRecorder) WriteHeader(status int) {
+	if r.wroteHeader {
+		return
+	}
+	r.wroteHeader = true
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *responseRecorder) Write(p []byte) (int, error) {
+	r.WriteHeader(http.StatusOK)
+	r.body.Write(p)
+	return r.ResponseWriter.Write(p) //nolint:wrapcheck // passes on the error of the wrapped writer
+}
@@ -0,0 +1,199 @@
+package api
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/durationpb"
+
+	"github.com/deliveroo/bnt-internal-test-go/internal/httpserver/gorillautils"
+)
+
+// timeout is a response with a protobuf representation.
+type timeout struct {
+	Seconds int64 `json:"seconds"`
+}
+
+func (t timeout) Proto() proto.Message {
+	return &durationpb.Duration{Seconds: t.Seconds}
+}
+
+type part struct {
+	Name     string `json:"name"`
+	Quantity int    `json:"quantity"`
+	Note     string `json:"note,omitempty"`
+}
+
+// newValidatedRouter returns a router validating its routes, whose handlers
+// respond with the body of the request.
+func newValidatedRouter(opts ...ValidationOption) *mux.Router {
+	r := mux.NewRouter()
+	routes := NewRouter(r)
+	routes.Validate(opts...)
+
+	echo := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write(body)
+	})
+	routes.Handle(Route{
+		Method:   http.MethodPost,
+		Path:     "/widgets/{id:[0-9]+}/parts",
+		Handler:  echo,
+		Params:   []Param{{Name: "id", In: InPath, Example: 0}, {Name: "X-Request-Id", In: InHeader, Required: true}, {Name: "dry_run", In: InQuery, Example: false}},
+		Request:  []part{},
+		Response: []part{},
+	})
+	routes.Handle(Route{
+		Method:   http.MethodPatch,
+		Path:     "/parts",
+		Handler:  echo,
+		Request:  Partial(part{}),
+		Response: part{},
+	})
+	return r
+}
+
+func TestValidate(t *testing.T) {
+	r := newValidatedRouter()
+	serve := func(method, target, body string, header http.Header) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, target, strings.NewReader(body))
+		for name, values := range header {
+			req.Header[name] = values
+		}
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, req)
+		return rec
+	}
+	withRequestID := http.Header{"X-Request-Id": {"abc"}}
+
+	t.Run("passes valid requests to the handler", func(t *testing.T) {
+		body := `[{"name":"bolt","quantity":2}]`
+		rec := serve(http.MethodPost, "/widgets/1/parts?dry_run=true", body, withRequestID)
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, body, rec.Body.String())
+	})
+
+	t.Run("rejects invalid requests with their violations", func(t *testing.T) {
+		for _, tt := range []struct {
+			name       string
+			target     string
+			body       string
+			header     http.Header
+			violations []Violation
+		}{
+			{
+				name:       "missing header",
+				target:     "/widgets/1/parts",
+				body:       `[]`,
+				violations: []Violation{{In: InHeader, Name: "X-Request-Id", Reason: "is required"}},
+			},
+			{
+				name:       "invalid query",
+				target:     "/widgets/1/parts?dry_run=maybe",
+				body:       `[]`,
+				header:     withRequestID,
+				violations: []Violation{{In: InQuery, Name: "dry_run", Reason: "must be a boolean"}},
+			},
+			{
+				name:       "missing body",
+				target:     "/widgets/1/parts",
+				header:     withRequestID,
+				violations: []Violation{{In: "body", Reason: "is required"}},
+			},
+			{
+				name:   "invalid body",
+				target: "/widgets/1/parts",
+				body:   `[{"name":1,"quantity":1.5},{"quantity":1}]`,
+				header: withRequestID,
+				violations: []Violation{
+					{In: "body", Name: "[0].name", Reason: "must be a string"},
+					{In: "body", Name: "[0].quantity", Reason: "must be an integer"},
+					{In: "body", Name: "[1].name", Reason: "is required"},
+				},
+			},
+		} {
+			rec := serve(http.MethodPost, tt.target, tt.body, tt.header)
+			assert.Equal(t, http.StatusBadRequest, rec.Code, tt.name)
+			assert.Equal(t, gorillautils.ProblemContentType, rec.Header().Get("Content-Type"), tt.name)
+
+			var problem struct {
+				Violations []Violation `json:"violations"`
+			}
+			assert.Nil(t, json.Unmarshal(rec.Body.Bytes(), &problem), tt.name)
+			assert.ElementsMatch(t, tt.violations, problem.Violations, tt.name)
+		}
+	})
+
+	t.Run("rejects bodies which are not JSON", func(t *testing.T) {
+		rec := serve(http.MethodPost, "/widgets/1/parts", `[{`, withRequestID)
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+	})
+
+	t.Run("accepts partial bodies", func(t *testing.T) {
+		rec := serve(http.MethodPatch, "/parts", `{"note":"spare"}`, nil)
+		assert.Equal(t, http.StatusOK, rec.Code)
+
+		rec = serve(http.MethodPatch, "/parts", `{"quantity":"many"}`, nil)
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+	})
+}
+
+func TestValidateResponses(t *testing.T) {
+	core, logs := observer.New(zapcore.WarnLevel)
+	r := newValidatedRouter(WithResponseValidation(zap.New(core)))
+
+	t.Run("logs responses which do not match their description", func(t *testing.T) {
+		// The handler echoes the partial body, which lacks required fields.
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, httptest.NewRequest(http.MethodPatch, "/parts", strings.NewReader(`{"note":"spare"}`)))
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, `{"note":"spare"}`, rec.Body.String())
+
+		entries := logs.TakeAll()
+		if assert.Len(t, entries, 1) {
+			assert.Equal(t, "response does not match the API description", entries[0].Message)
+			assert.Equal(t, "/parts", entries[0].ContextMap()["path"])
+		}
+	})
+
+	t.Run("does not log valid responses", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, httptest.NewRequest(http.MethodPatch, "/parts", strings.NewReader(`{"name":"bolt","quantity":1}`)))
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Empty(t, logs.TakeAll())
+	})
+
+	t.Run("does not validate protobuf responses", func(t *testing.T) {
+		r := mux.NewRouter()
+		routes := NewRouter(r)
+		routes.Validate(WithResponseValidation(zap.New(core)))
+		routes.Handle(Route{
+			Method: http.MethodGet,
+			Path:   "/timeout",
+			Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				_ = gorillautils.Render(w, r, timeout{Seconds: 5})
+			}),
+			Response: timeout{},
+		})
+
+		for _, accept := range []string{gorillautils.ProtobufContentType, gorillautils.JSONContentType} {
+			req := httptest.NewRequest(http.MethodGet, "/timeout", nil)
+			req.Header.Set("Accept", accept)
+			rec := httptest.NewRecorder()
+			r.ServeHTTP(rec, req)
+			assert.Equal(t, accept, rec.Header().Get("Content-Type"))
+		}
+		assert.Empty(t, logs.TakeAll())
+	})
+}
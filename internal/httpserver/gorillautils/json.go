@@ -8,7 +8,19 @@ import (
 
 // RenderJSON renders value as JSON in the HTTP response.
 func RenderJSON(w http.ResponseWriter, value interface{}) error {
-	w.Header().Add("Content-Type", "application/json")
+	w.Header().Add("Content-Type", JSONContentType)
+
+	if err := json.NewEncoder(w).Encode(value); err != nil {
+		return fmt.Errorf("failed to write json response: %w", err)
+	}
+	return nil
+}
+
+// RenderJSONStatus renders value as JSON in the HTTP response, with the given
+// status code.
+func RenderJSONStatus(w http.ResponseWriter, status int, value interface{}) error {
+	w.Header().Add("Content-Type", JSONContentType)
+	w.WriteHeader(status)
 
 	if err := json.NewEncoder(w).Encode(value); err != nil {
 		return fmt.Errorf("failed to write json response: %w", err)
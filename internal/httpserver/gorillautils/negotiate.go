@@ -0,0 +1,123 @@
+package gorillautils
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"google.golang.org/protobuf/proto"
+)
+
+// Media types Render negotiates between.
+const (
+	JSONContentType     = "application/json"
+	ProtobufContentType = "application/x-protobuf"
+)
+
+// ProtoValue is implemented by values which can also be rendered as protobuf.
+type ProtoValue interface {
+	// Proto returns the protobuf representation of the value.
+	Proto() proto.Message
+}
+
+// ParseQualities returns the quality of each value listed in an Accept-style
+// header, e.g. "gzip;q=0.5, br" or "application/json, */*;q=0.1". Values are
+// lowercased and stripped of their parameters, and default to a quality of 1.
+// Only the first occurrence of a value counts.
+func ParseQualities(header string) map[string]float64 {
+	qualities := map[string]float64{}
+	for _, entry := range strings.Split(header, ",") {
+		value, params, _ := strings.Cut(entry, ";")
+		value = strings.ToLower(strings.TrimSpace(value))
+		if value == "" {
+			continue
+		}
+		if _, ok := qualities[value]; ok {
+			continue
+		}
+
+		q := 1.0
+		for _, param := range strings.Split(params, ";") {
+			name, raw, _ := strings.Cut(param, "=")
+			if strings.TrimSpace(name) != "q" {
+				continue
+			}
+			parsed, err := strconv.ParseFloat(strings.TrimSpace(raw), 64)
+			if err != nil || parsed < 0 || parsed > 1 {
+				parsed = 0
+			}
+			q = parsed
+		}
+		qualities[value] = q
+	}
+	return qualities
+}
+
+// NegotiateContentType returns the media type of offers which the Accept
+// header of r prefers, favouring earlier offers when the client has no
+// preference. It returns "" when the client accepts none of them. Requests
+// without an Accept header accept anything.
+func NegotiateContentType(r *http.Request, offers ...string) string {
+	header := strings.Join(r.Header.Values("Accept"), ",")
+	if strings.TrimSpace(header) == "" {
+		if len(offers) == 0 {
+			return ""
+		}
+		return offers[0]
+	}
+
+	qualities := ParseQualities(header)
+	best, bestQ := "", 0.0
+	for _, offer := range offers {
+		if q := mediaTypeQuality(qualities, offer); q > bestQ {
+			best, bestQ = offer, q
+		}
+	}
+	return best
+}
+
+// mediaTypeQuality returns the quality of mediaType by the most specific media
+// range of qualities which matches it.
+func mediaTypeQuality(qualities map[string]float64, mediaType string) float64 {
+	if q, ok := qualities[mediaType]; ok {
+		return q
+	}
+	if kind, _, ok := strings.Cut(mediaType, "/"); ok {
+		if q, ok := qualities[kind+"/*"]; ok {
+			return q
+		}
+	}
+	return qualities["*/*"]
+}
+
+// Render renders value in the HTTP response as protobuf when it is a
+// ProtoValue and the Accept header of r prefers protobuf, and as JSON
+// otherwise, including when the client accepts neither.
+func Render(w http.ResponseWriter, r *http.Request, value interface{}) error {
+	return RenderStatus(w, r, http.StatusOK, value)
+}
+
+// RenderStatus renders value like Render, with the given status code.
+func RenderStatus(w http.ResponseWriter, r *http.Request, status int, value interface{}) error {
+	protoValue, ok := value.(ProtoValue)
+	if !ok {
+		return RenderJSONStatus(w, status, value)
+	}
+
+	w.Header().Add("Vary", "Accept")
+	if NegotiateContentType(r, JSONContentType, ProtobufContentType) != ProtobufContentType {
+		return RenderJSONStatus(w, status, value)
+	}
+
+	body, err := proto.Marshal(protoValue.Proto())
+	if err != nil {
+		return fmt.Errorf("failed to marshal protobuf response: %w", err)
+	}
+	w.Header().Set("Content-Type", ProtobufContentType)
+	w.WriteHeader(status)
+	if _, err := w.Write(body); err != nil {
+		return fmt.Errorf("failed to write protobuf response: %w", err)
+	}
+	return nil
+}
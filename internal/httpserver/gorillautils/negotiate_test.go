@@ -0,0 +1,89 @@
+package gorillautils_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/durationpb"
+
+	"github.com/deliveroo/bnt-internal-test-go/internal/httpserver/gorillautils"
+)
+
+// duration is a value with a protobuf representation.
+type duration struct {
+	Seconds int64
+}
+
+func (d duration) Proto() proto.Message {
+	return &durationpb.Duration{Seconds: d.Seconds}
+}
+
+func TestParseQualities(t *testing.T) {
+	assert.Equal(t, map[string]float64{
+		"application/json": 1,
+		"text/*":           0.5,
+		"*/*":              0,
+	}, gorillautils.ParseQualities("Application/JSON;charset=utf-8, text/*;q=0.5, */*;q=2, application/json;q=0.1"))
+	assert.Empty(t, gorillautils.ParseQualities(""))
+}
+
+func TestNegotiateContentType(t *testing.T) {
+	offers := []string{gorillautils.JSONContentType, gorillautils.ProtobufContentType}
+	for accept, want := range map[string]string{
+		"":                       gorillautils.JSONContentType,
+		"*/*":                    gorillautils.JSONContentType,
+		"application/*":          gorillautils.JSONContentType,
+		"application/x-protobuf": gorillautils.ProtobufContentType,
+		"application/json;q=0.9, application/x-protobuf": gorillautils.ProtobufContentType,
+		"application/x-protobuf;q=0.5, */*":              gorillautils.JSONContentType,
+		"application/json;q=0, */*":                      gorillautils.ProtobufContentType,
+		"text/html":                                      "",
+	} {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		if accept != "" {
+			req.Header.Set("Accept", accept)
+		}
+		assert.Equal(t, want, gorillautils.NegotiateContentType(req, offers...), accept)
+	}
+}
+
+func TestRender(t *testing.T) {
+	render := func(accept string, value interface{}) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set("Accept", accept)
+		rec := httptest.NewRecorder()
+		assert.Nil(t, gorillautils.RenderStatus(rec, req, http.StatusCreated, value))
+		return rec
+	}
+
+	t.Run("renders protobuf to clients which prefer it", func(t *testing.T) {
+		rec := render(gorillautils.ProtobufContentType, duration{Seconds: 5})
+		assert.Equal(t, http.StatusCreated, rec.Code)
+		assert.Equal(t, gorillautils.ProtobufContentType, rec.Header().Get("Content-Type"))
+		assert.Equal(t, "Accept", rec.Header().Get("Vary"))
+
+		var got durationpb.Duration
+		assert.Nil(t, proto.Unmarshal(rec.Body.Bytes(), &got))
+		assert.Equal(t, int64(5), got.Seconds)
+	})
+
+	t.Run("renders JSON otherwise", func(t *testing.T) {
+		for _, accept := range []string{"application/json", "*/*", "text/html"} {
+			rec := render(accept, duration{Seconds: 5})
+			assert.Equal(t, http.StatusCreated, rec.Code)
+			assert.Equal(t, gorillautils.JSONContentType, rec.Header().Get("Content-Type"))
+			assert.Equal(t, "Accept", rec.Header().Get("Vary"))
+			assert.JSONEq(t, `{"Seconds":5}`, rec.Body.String())
+		}
+	})
+
+	t.Run("renders values without a protobuf representation as JSON", func(t *testing.T) {
+		rec := render(gorillautils.ProtobufContentType, map[string]int{"seconds": 5})
+		assert.Equal(t, gorillautils.JSONContentType, rec.Header().Get("Content-Type"))
+		assert.Empty(t, rec.Header().Get("Vary"))
+		assert.JSONEq(t, `{"seconds":5}`, rec.Body.String())
+	})
+}
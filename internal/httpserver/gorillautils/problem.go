@@ -0,0 +1,61 @@
+package gorillautils
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+// ProblemContentType is the media type of problem details, see RFC 7807.
+const ProblemContentType = "application/problem+json"
+
+// Problem describes why a request failed, as RFC 7807 problem details.
+type Problem struct {
+	// Type is a URI identifying the kind of problem. When empty, the problem
+	// is described by its status alone.
+	Type string `json:"type,omitempty"`
+	// Title summarises the kind of problem. Defaults to the status text.
+	Title string `json:"title"`
+	// Status is the HTTP status code of the response.
+	Status int `json:"status"`
+	// Detail explains this occurrence of the problem.
+	Detail string `json:"detail,omitempty"`
+	// Extensions are additional members of the problem, e.g. the constraint
+	// which a request violated.
+	Extensions map[string]interface{} `json:"-"`
+}
+
+// MarshalJSON encodes the problem with its extensions as members alongside the
+// standard ones.
+func (p Problem) MarshalJSON() ([]byte, error) {
+	type problem Problem
+	members := make(map[string]interface{}, len(p.Extensions)+4)
+	for key, value := range p.Extensions {
+		members[key] = value
+	}
+
+	raw, err := json.Marshal(problem(p))
+	if err != nil {
+		return nil, err //nolint:wrapcheck // called by encoding/json, which adds context
+	}
+	if err := json.Unmarshal(raw, &members); err != nil {
+		return nil, err //nolint:wrapcheck // called by encoding/json, which adds context
+	}
+
+	return json.Marshal(members) //nolint:wrapcheck // called by encoding/json, which adds context
+}
+
+// RenderProblem renders p as the HTTP response, with its status code.
+func RenderProblem(w http.ResponseWriter, p Problem) error {
+	if p.Title == "" {
+		p.Title = http.StatusText(p.Status)
+	}
+
+	w.Header().Set("Content-Type", ProblemContentType)
+	w.WriteHeader(p.Status)
+
+	if err := json.NewEncoder(w).Encode(p); err != nil {
+		return fmt.Errorf("failed to write problem response: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,68 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"go.uber.org/zap"
+
+	"github.com/deliveroo/apm-go"
+	"github.com/deliveroo/bnt-internal-test-go/internal/authz"
+	"github.com/deliveroo/bnt-internal-test-go/internal/database/dberrors"
+	"github.com/deliveroo/bnt-internal-test-go/internal/httpserver/gorillautils"
+	"github.com/deliveroo/bnt-internal-test-go/internal/orders"
+)
+
+// renderError renders err as problem details. Errors of known kinds get a
+// precise status, such as 403 for an action the caller is not allowed, or 409
+// for a conflict with the constraint which was violated; anything else is an
+// internal server error. Server errors are logged, with msg describing what
+// failed.
+func renderError(w http.ResponseWriter, r *http.Request, service apm.Service, msg string, err error) {
+	problem := problemFor(err)
+	if problem.Status >= http.StatusInternalServerError {
+		apm.LoggerFromContext(r.Context(), service).Error(msg, zap.Error(err))
+	}
+	_ = gorillautils.RenderProblem(w, problem)
+}
+
+// problemFor returns the problem details describing err.
+func problemFor(err error) gorillautils.Problem {
+	var problem gorillautils.Problem
+	var denied *authz.DeniedError
+	switch {
+	case errors.As(err, &denied):
+		// Denials are audited by the authorizer.
+		target := "this " + denied.Resource.Type
+		if denied.Resource.ID == "" {
+			target = denied.Resource.Type + "s"
+		}
+		return gorillautils.Problem{
+			Status:     http.StatusForbidden,
+			Detail:     "The caller is not allowed to " + string(denied.Action) + " " + target + ".",
+			Extensions: map[string]interface{}{"action": denied.Action},
+		}
+	case errors.Is(err, orders.ErrNotFound):
+		return gorillautils.Problem{Status: http.StatusNotFound, Detail: "The order does not exist."}
+	case errors.Is(err, orders.ErrInvalidTransition):
+		return gorillautils.Problem{Status: http.StatusConflict, Detail: "The order's status does not allow this."}
+	case errors.Is(err, dberrors.ErrConflict):
+		problem = gorillautils.Problem{Status: http.StatusConflict, Detail: "The request conflicts with an existing resource."}
+	case errors.Is(err, dberrors.ErrInvalidReference):
+		problem = gorillautils.Problem{Status: http.StatusUnprocessableEntity, Detail: "The request refers to a resource which does not exist."}
+	case errors.Is(err, dberrors.ErrValidation):
+		problem = gorillautils.Problem{Status: http.StatusUnprocessableEntity, Detail: "The request contains an invalid value."}
+	case errors.Is(err, dberrors.ErrTimeout):
+		problem = gorillautils.Problem{Status: http.StatusGatewayTimeout, Detail: "The request took too long."}
+	case errors.Is(err, dberrors.ErrUnavailable):
+		problem = gorillautils.Problem{Status: http.StatusServiceUnavailable, Detail: "The service is temporarily unavailable."}
+	default:
+		return gorillautils.Problem{Status: http.StatusInternalServerError}
+	}
+
+	var dbErr *dberrors.Error
+	if errors.As(err, &dbErr) && dbErr.Constraint != "" {
+		problem.Extensions = map[string]interface{}{"constraint": dbErr.Constraint}
+	}
+	return problem
+}
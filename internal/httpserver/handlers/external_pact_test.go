@@ -0,0 +1,43 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/deliveroo/bnt-internal-test-go/internal/contract"
+	"github.com/deliveroo/bnt-internal-test-go/internal/dependencies/dependenciestest"
+)
+
+func TestExternalContract(t *testing.T) {
+	provider := contract.NewMockProvider(t, "bnt-internal-test-go", "example")
+
+	provider.AddInteraction(contract.Interaction{
+		Description: "a request for the home page",
+		Request: contract.Request{
+			Method: http.MethodGet,
+			Path:   "/",
+		},
+		Response: contract.Response{
+			Status:  http.StatusOK,
+			Headers: map[string]string{"Content-Type": "text/html"},
+			Body:    "<html><body><h1>Example Domain</h1></body></html>",
+		},
+	})
+
+	provider.Verify(func() error {
+		handlers := NewExternalHandlersFunc(dependenciestest.NewAPM(t), provider.Client())
+
+		w := httptest.NewRecorder()
+		handlers.Get(w, httptest.NewRequest(http.MethodGet, "/external", nil))
+
+		if w.Code != http.StatusOK {
+			return fmt.Errorf("expected status 200 but got %d", w.Code)
+		}
+		if body := w.Body.String(); body != "<html><body><h1>Example Domain</h1></body></html>" {
+			return fmt.Errorf("expected the provider's page to be proxied but got %q", body)
+		}
+		return nil
+	})
+}
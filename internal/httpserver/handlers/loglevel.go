@@ -0,0 +1,92 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"go.uber.org/zap/zapcore"
+
+	"github.com/deliveroo/bnt-internal-test-go/internal/httpserver/gorillautils"
+	"github.com/deliveroo/bnt-internal-test-go/internal/settings"
+)
+
+const (
+	// defaultLogLevelTTL is how long a log level change lasts when the request
+	// does not say.
+	defaultLogLevelTTL = 15 * time.Minute
+	// maxLogLevelTTL is the longest a log level change can last. Permanent
+	// changes are made through the settings.
+	maxLogLevelTTL = 24 * time.Hour
+)
+
+// LogLevelHandlers serves the admin endpoints for changing log levels
+// temporarily.
+type LogLevelHandlers struct {
+	Store *settings.Store
+}
+
+// LogLevels are the current log levels.
+type LogLevels struct {
+	Level        zapcore.Level            `json:"level"`
+	LoggerLevels map[string]zapcore.Level `json:"logger_levels"`
+}
+
+// LogLevelRequest is the body of a request to change a log level.
+type LogLevelRequest struct {
+	// Logger is the name of the logger to change, or empty for the overall
+	// level.
+	Logger string        `json:"logger,omitempty"`
+	Level  zapcore.Level `json:"level"`
+	// TTL is how long the change lasts, e.g. "10m".
+	TTL string `json:"ttl,omitempty"`
+}
+
+// Get renders the current log levels.
+func (h *LogLevelHandlers) Get(w http.ResponseWriter, r *http.Request) {
+	current := h.Store.Current()
+	_ = gorillautils.RenderJSON(w, LogLevels{Level: current.LogLevel, LoggerLevels: current.LoggerLevels})
+}
+
+// Put changes a log level until the TTL in the request expires, and renders
+// the change.
+func (h *LogLevelHandlers) Put(w http.ResponseWriter, r *http.Request) {
+	var req LogLevelRequest
+	decoder := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxSettingsBody))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&req); err != nil {
+		_ = gorillautils.RenderProblem(w, gorillautils.Problem{Status: http.StatusBadRequest, Detail: err.Error()})
+		return
+	}
+
+	ttl, err := parseTTL(req.TTL)
+	if err != nil {
+		_ = gorillautils.RenderProblem(w, gorillautils.Problem{Status: http.StatusUnprocessableEntity, Detail: err.Error()})
+		return
+	}
+
+	change, err := h.Store.SetLogLevel("admin "+r.RemoteAddr, req.Logger, req.Level, ttl)
+	if err != nil {
+		_ = gorillautils.RenderProblem(w, gorillautils.Problem{Status: http.StatusUnprocessableEntity, Detail: err.Error()})
+		return
+	}
+
+	_ = gorillautils.RenderJSON(w, change)
+}
+
+func parseTTL(value string) (time.Duration, error) {
+	if value == "" {
+		return defaultLogLevelTTL, nil
+	}
+
+	ttl, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid ttl: %w", err)
+	}
+	if ttl <= 0 || ttl > maxLogLevelTTL {
+		return 0, fmt.Errorf("ttl must be between 0s and %s", maxLogLevelTTL)
+	}
+
+	return ttl, nil
+}
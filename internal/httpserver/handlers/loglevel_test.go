@@ -0,0 +1,47 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap/zapcore"
+
+	"github.com/deliveroo/bnt-internal-test-go/internal/settings"
+)
+
+func TestLogLevelHandlers(t *testing.T) {
+	store, err := settings.New(settings.Values{LogLevel: zapcore.InfoLevel})
+	assert.Nil(t, err)
+	handlers := LogLevelHandlers{Store: store}
+
+	put := func(body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		handlers.Put(w, httptest.NewRequest(http.MethodPut, "/admin/log-level", strings.NewReader(body)))
+		return w
+	}
+
+	t.Run("changes the level of a named logger", func(t *testing.T) {
+		w := put(`{"logger": "orders", "level": "debug", "ttl": "1m"}`)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, map[string]zapcore.Level{"orders": zapcore.DebugLevel}, store.Current().LoggerLevels)
+
+		w = httptest.NewRecorder()
+		handlers.Get(w, httptest.NewRequest(http.MethodGet, "/admin/log-level", nil))
+		assert.JSONEq(t, `{"level":"info","logger_levels":{"orders":"debug"}}`, w.Body.String())
+	})
+
+	t.Run("responds bad request for an unknown level", func(t *testing.T) {
+		w := put(`{"level": "loud"}`)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	t.Run("responds unprocessable entity for a TTL which is too long", func(t *testing.T) {
+		w := put(`{"level": "debug", "ttl": "48h"}`)
+		assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+		assert.JSONEq(t, `{"title":"Unprocessable Entity","status":422,"detail":"ttl must be between 0s and 24h0m0s"}`, w.Body.String())
+		assert.Equal(t, zapcore.InfoLevel, store.Current().LogLevel)
+	})
+}
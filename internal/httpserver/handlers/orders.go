@@ -1,35 +1,189 @@
 package handlers
 
 import (
+	"encoding/json"
+	"errors"
 	"net/http"
 	"strconv"
 
 	"github.com/gorilla/mux"
+	"google.golang.org/protobuf/proto"
 
+	"github.com/deliveroo/apm-go"
 	"github.com/deliveroo/bnt-internal-test-go/internal/httpserver/gorillautils"
+	"github.com/deliveroo/bnt-internal-test-go/internal/httpserver/handlers/orderspb"
 	"github.com/deliveroo/bnt-internal-test-go/internal/orders"
+	"github.com/deliveroo/bnt-internal-test-go/internal/pagination"
 	"github.com/deliveroo/determinator-go"
 )
 
 type Order struct {
 	ID     int
 	Status string
+	// RestaurantID is the restaurant the order is from, if known.
+	RestaurantID string `json:",omitempty"`
+}
+
+func orderOf(order orders.Order) Order {
+	return Order{ID: order.ID, Status: order.Status, RestaurantID: order.RestaurantID}
+}
+
+// Proto returns the protobuf representation of the order, rendered to clients
+// which accept application/x-protobuf.
+func (o Order) Proto() proto.Message {
+	return o.proto()
+}
+
+func (o Order) proto() *orderspb.Order {
+	return &orderspb.Order{Id: int64(o.ID), Status: o.Status, RestaurantId: o.RestaurantID}
+}
+
+// CreateOrderRequest is the body of a request to create an order.
+type CreateOrderRequest struct {
+	// Status is the status of the new order. Defaults to NEW.
+	Status string `json:",omitempty"`
+	// RestaurantID is the restaurant the order is from.
+	RestaurantID string `json:",omitempty"`
+}
+
+// maxOrderBody is the largest request body accepted when creating an order.
+const maxOrderBody = 64 << 10
+
+// OrderList is a page of orders, with links to the pages either side.
+type OrderList struct {
+	Items []Order          `json:"items"`
+	Links pagination.Links `json:"links"`
+}
+
+// Proto returns the protobuf representation of the page, rendered to clients
+// which accept application/x-protobuf.
+func (l OrderList) Proto() proto.Message {
+	list := &orderspb.OrderList{
+		Items: make([]*orderspb.Order, 0, len(l.Items)),
+		Links: &orderspb.Links{Next: l.Links.Next, Prev: l.Links.Prev},
+	}
+	for _, order := range l.Items {
+		list.Items = append(list.Items, order.proto())
+	}
+	return list
 }
 
 type OrderHandlers struct {
-	Repository   orders.Repository
+	APM          apm.Service
+	Orders       *orders.Service
 	Determinator determinator.Retriever
 	Client       *http.Client
+	Cursors      *pagination.Codec
 }
 
 func (o *OrderHandlers) Get(w http.ResponseWriter, r *http.Request) {
-	vars := mux.Vars(r)
-	orderID, err := strconv.Atoi(vars["id"])
+	orderID, ok := parseOrderID(w, r)
+	if !ok {
+		return
+	}
+
+	order, err := o.Orders.Get(r.Context(), orderID)
+	if err != nil {
+		renderError(w, r, o.APM, "failed to get order", err)
+		return
+	}
+
+	_ = gorillautils.Render(w, r, orderOf(*order))
+}
+
+// Cancel cancels a NEW order, and renders it.
+func (o *OrderHandlers) Cancel(w http.ResponseWriter, r *http.Request) {
+	orderID, ok := parseOrderID(w, r)
+	if !ok {
+		return
+	}
+
+	order, err := o.Orders.Cancel(r.Context(), orderID)
+	if err != nil {
+		renderError(w, r, o.APM, "failed to cancel order", err)
+		return
+	}
+
+	_ = gorillautils.Render(w, r, orderOf(*order))
+}
+
+// Fulfil fulfils a NEW order, and renders it.
+func (o *OrderHandlers) Fulfil(w http.ResponseWriter, r *http.Request) {
+	orderID, ok := parseOrderID(w, r)
+	if !ok {
+		return
+	}
+
+	order, err := o.Orders.Fulfil(r.Context(), orderID)
+	if err != nil {
+		renderError(w, r, o.APM, "failed to fulfil order", err)
+		return
+	}
+
+	_ = gorillautils.Render(w, r, orderOf(*order))
+}
+
+// parseOrderID returns the order ID in the path of r, rendering a problem when
+// it is not a number.
+func parseOrderID(w http.ResponseWriter, r *http.Request) (int, bool) {
+	orderID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		_ = gorillautils.RenderProblem(w, gorillautils.Problem{Status: http.StatusBadRequest, Detail: "The order ID must be a number."})
+		return 0, false
+	}
+	return orderID, true
+}
+
+// List renders a page of orders, newest first. The page is chosen by the limit
+// and cursor query parameters, and links to the pages either side are rendered
+// in the body and the Link header.
+func (o *OrderHandlers) List(w http.ResponseWriter, r *http.Request) {
+	page, err := o.Cursors.ParseRequest(r)
+	if err != nil {
+		_ = gorillautils.RenderProblem(w, gorillautils.Problem{Status: http.StatusBadRequest, Detail: err.Error()})
+		return
+	}
+
+	list, result, err := o.Orders.List(r.Context(), page)
+	if errors.Is(err, pagination.ErrInvalidCursor) {
+		_ = gorillautils.RenderProblem(w, gorillautils.Problem{Status: http.StatusBadRequest, Detail: err.Error()})
+		return
+	}
+	if err != nil {
+		renderError(w, r, o.APM, "failed to list orders", err)
+		return
+	}
+
+	response := OrderList{Items: make([]Order, 0, len(list)), Links: o.Cursors.Links(r, page, result)}
+	for _, order := range list {
+		response.Items = append(response.Items, orderOf(order))
+	}
+
+	pagination.SetLinkHeader(w, response.Links)
+	_ = gorillautils.Render(w, r, response)
+}
+
+// Create creates an order, and renders it with its location. Clients retrying
+// the request should send an Idempotency-Key header, so the order is only
+// created once.
+func (o *OrderHandlers) Create(w http.ResponseWriter, r *http.Request) {
+	var req CreateOrderRequest
+	decoder := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxOrderBody))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&req); err != nil {
+		_ = gorillautils.RenderProblem(w, gorillautils.Problem{Status: http.StatusBadRequest, Detail: err.Error()})
+		return
+	}
+	if req.Status == "" {
+		req.Status = orders.StatusNew
+	}
+
+	order, err := o.Orders.Create(r.Context(), orders.Order{Status: req.Status, RestaurantID: req.RestaurantID})
 	if err != nil {
-		w.WriteHeader(http.StatusBadRequest)
+		renderError(w, r, o.APM, "failed to create order", err)
 		return
 	}
-	temp := Order{orderID, "FULFILLED"}
 
-	_ = gorillautils.RenderJSON(w, temp)
+	w.Header().Set("Location", "/orders/"+strconv.Itoa(order.ID))
+	_ = gorillautils.RenderStatus(w, r, http.StatusCreated, orderOf(*order))
 }
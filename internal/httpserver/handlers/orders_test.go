@@ -0,0 +1,317 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/deliveroo/bnt-internal-test-go/internal/auth"
+	"github.com/deliveroo/bnt-internal-test-go/internal/authz"
+	"github.com/deliveroo/bnt-internal-test-go/internal/database/dberrors"
+	"github.com/deliveroo/bnt-internal-test-go/internal/dependencies/dependenciestest"
+	"github.com/deliveroo/bnt-internal-test-go/internal/httpserver/handlers/orderspb"
+	"github.com/deliveroo/bnt-internal-test-go/internal/orders"
+	"github.com/deliveroo/bnt-internal-test-go/internal/orders/ordertest"
+	"github.com/deliveroo/bnt-internal-test-go/internal/pagination"
+)
+
+func TestOrderHandlers(t *testing.T) {
+	handlers := OrderHandlers{
+		APM:    dependenciestest.NewAPM(t),
+		Orders: orders.NewService(ordertest.NewRepository(ordertest.NewOrder(ordertest.WithID(1), ordertest.WithStatus("NEW"))), nil),
+	}
+
+	get := func(id string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		r := mux.SetURLVars(httptest.NewRequest(http.MethodGet, "/orders/"+id, nil), map[string]string{"id": id})
+		handlers.Get(w, r)
+		return w
+	}
+
+	t.Run("renders an existing order", func(t *testing.T) {
+		w := get("1")
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.JSONEq(t, `{"ID":1,"Status":"NEW"}`, w.Body.String())
+	})
+
+	t.Run("renders an order as protobuf to clients which accept it", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		r := mux.SetURLVars(httptest.NewRequest(http.MethodGet, "/orders/1", nil), map[string]string{"id": "1"})
+		r.Header.Set("Accept", "application/x-protobuf")
+		handlers.Get(w, r)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "application/x-protobuf", w.Header().Get("Content-Type"))
+		var order orderspb.Order
+		assert.Nil(t, proto.Unmarshal(w.Body.Bytes(), &order))
+		assert.Equal(t, int64(1), order.Id)
+		assert.Equal(t, "NEW", order.Status)
+	})
+
+	t.Run("responds not found for a missing order", func(t *testing.T) {
+		w := get("2")
+		assert.Equal(t, http.StatusNotFound, w.Code)
+		assert.Equal(t, "application/problem+json", w.Header().Get("Content-Type"))
+	})
+
+	t.Run("responds bad request for an invalid ID", func(t *testing.T) {
+		w := get("abc")
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+	t.Run("responds with problem details when the database fails", func(t *testing.T) {
+		for _, tt := range []struct {
+			err    error
+			status int
+			body   string
+		}{
+			{
+				err:    &pgconn.PgError{Code: "23505", ConstraintName: "orders_pkey"},
+				status: http.StatusConflict,
+				body:   `{"title":"Conflict","status":409,"detail":"The request conflicts with an existing resource.","constraint":"orders_pkey"}`,
+			},
+			{
+				err:    &pgconn.PgError{Code: "23514", ConstraintName: "orders_status_check"},
+				status: http.StatusUnprocessableEntity,
+				body:   `{"title":"Unprocessable Entity","status":422,"detail":"The request contains an invalid value.","constraint":"orders_status_check"}`,
+			},
+			{
+				err:    &pgconn.PgError{Code: "57014"},
+				status: http.StatusGatewayTimeout,
+				body:   `{"title":"Gateway Timeout","status":504,"detail":"The request took too long."}`,
+			},
+			{
+				err:    &pgconn.PgError{Code: "42P01"},
+				status: http.StatusInternalServerError,
+				body:   `{"title":"Internal Server Error","status":500}`,
+			},
+		} {
+			failing := OrderHandlers{
+				APM:    dependenciestest.NewAPM(t),
+				Orders: orders.NewService(failingRepository{fmt.Errorf("failed to query database: %w", dberrors.Map(tt.err))}, nil),
+			}
+
+			w := httptest.NewRecorder()
+			r := mux.SetURLVars(httptest.NewRequest(http.MethodGet, "/orders/1", nil), map[string]string{"id": "1"})
+			failing.Get(w, r)
+
+			assert.Equal(t, tt.status, w.Code)
+			assert.Equal(t, "application/problem+json", w.Header().Get("Content-Type"))
+			assert.JSONEq(t, tt.body, w.Body.String())
+		}
+	})
+}
+
+func TestOrderHandlersTransitions(t *testing.T) {
+	repository := ordertest.NewRepository(
+		ordertest.NewOrder(ordertest.WithID(1), ordertest.WithRestaurantID("r-1")),
+		ordertest.NewOrder(ordertest.WithID(2), ordertest.WithRestaurantID("r-2")),
+	)
+	core, logs := observer.New(zapcore.InfoLevel)
+	handlers := OrderHandlers{
+		APM:    dependenciestest.NewAPM(t),
+		Orders: orders.NewService(repository, authz.NewAuthorizer(orders.Policy, zap.New(core))),
+	}
+	restaurant := &auth.Principal{
+		Subject:    "restaurant-app",
+		Roles:      []string{orders.RoleRestaurant},
+		Attributes: map[string]string{auth.AttrRestaurantID: "r-1"},
+	}
+
+	serve := func(handler http.HandlerFunc, id string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		r := mux.SetURLVars(httptest.NewRequest(http.MethodPost, "/orders/"+id, nil), map[string]string{"id": id})
+		handler(w, r.WithContext(auth.ContextWithPrincipal(r.Context(), restaurant)))
+		return w
+	}
+
+	t.Run("fulfils an order", func(t *testing.T) {
+		w := serve(handlers.Fulfil, "1")
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.JSONEq(t, `{"ID":1,"Status":"FULFILLED","RestaurantID":"r-1"}`, w.Body.String())
+	})
+
+	t.Run("responds conflict when the order's status does not allow it", func(t *testing.T) {
+		w := serve(handlers.Cancel, "1")
+		assert.Equal(t, http.StatusConflict, w.Code)
+		assert.Equal(t, "application/problem+json", w.Header().Get("Content-Type"))
+	})
+
+	t.Run("responds not found, and audits, when the caller may not read the order", func(t *testing.T) {
+		w := serve(handlers.Cancel, "2")
+		assert.Equal(t, http.StatusNotFound, w.Code)
+		assert.Equal(t, 1, logs.FilterMessage("authorization denied").Len())
+
+		order, _ := repository.GetOrder(context.Background(), 2)
+		assert.Equal(t, orders.StatusNew, order.Status)
+	})
+
+	t.Run("responds not found for a missing order", func(t *testing.T) {
+		w := serve(handlers.Cancel, "3")
+		assert.Equal(t, http.StatusNotFound, w.Code)
+	})
+}
+
+func TestOrderHandlersList(t *testing.T) {
+	repository := ordertest.NewRepository()
+	for id := 1; id <= 5; id++ {
+		repository.Add(ordertest.NewOrder(ordertest.WithID(id), ordertest.WithCreatedAt(ordertest.CreatedAt.Add(time.Duration(id)*time.Minute))))
+	}
+	handlers := OrderHandlers{
+		APM:     dependenciestest.NewAPM(t),
+		Orders:  orders.NewService(repository, nil),
+		Cursors: pagination.NewCodec([]byte("test")),
+	}
+
+	list := func(target string) (*httptest.ResponseRecorder, OrderList) {
+		w := httptest.NewRecorder()
+		handlers.List(w, httptest.NewRequest(http.MethodGet, target, nil))
+
+		var body OrderList
+		_ = json.Unmarshal(w.Body.Bytes(), &body)
+		return w, body
+	}
+
+	t.Run("renders pages of orders linked to each other", func(t *testing.T) {
+		w, first := list("/orders?limit=2&status=NEW")
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, []Order{{ID: 5, Status: "NEW"}, {ID: 4, Status: "NEW"}}, first.Items)
+		assert.Empty(t, first.Links.Prev)
+		assert.Contains(t, first.Links.Next, "status=NEW")
+		assert.Equal(t, []string{`<` + first.Links.Next + `>; rel="next"`}, w.Header().Values("Link"))
+
+		_, second := list(first.Links.Next)
+		assert.Equal(t, []Order{{ID: 3, Status: "NEW"}, {ID: 2, Status: "NEW"}}, second.Items)
+
+		_, back := list(second.Links.Prev)
+		assert.Equal(t, first.Items, back.Items)
+	})
+
+	t.Run("clamps the limit", func(t *testing.T) {
+		_, body := list("/orders?limit=1000")
+		assert.Len(t, body.Items, 5)
+		assert.Empty(t, body.Links.Next)
+
+		_, body = list("/orders?limit=0")
+		assert.Len(t, body.Items, 1)
+	})
+
+	t.Run("renders a page as protobuf to clients which accept it", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodGet, "/orders?limit=2", nil)
+		r.Header.Set("Accept", "application/x-protobuf")
+		handlers.List(w, r)
+
+		assert.Equal(t, "application/x-protobuf", w.Header().Get("Content-Type"))
+		var page orderspb.OrderList
+		assert.Nil(t, proto.Unmarshal(w.Body.Bytes(), &page))
+		assert.Len(t, page.Items, 2)
+		assert.Equal(t, int64(5), page.Items[0].Id)
+		assert.NotEmpty(t, page.Links.Next)
+	})
+
+	t.Run("renders an empty list without links", func(t *testing.T) {
+		empty := OrderHandlers{Orders: orders.NewService(ordertest.NewRepository(), nil), Cursors: handlers.Cursors}
+		w := httptest.NewRecorder()
+		empty.List(w, httptest.NewRequest(http.MethodGet, "/orders", nil))
+
+		assert.JSONEq(t, `{"items":[],"links":{}}`, w.Body.String())
+	})
+
+	t.Run("responds bad request for an invalid limit or cursor", func(t *testing.T) {
+		for _, target := range []string{"/orders?limit=many", "/orders?cursor=forged", "/orders?cursor=" + pagination.NewCodec([]byte("other")).Encode(pagination.Cursor{ID: 1})} {
+			w, _ := list(target)
+			assert.Equal(t, http.StatusBadRequest, w.Code, target)
+			assert.Equal(t, "application/problem+json", w.Header().Get("Content-Type"), target)
+		}
+	})
+}
+
+func TestOrderHandlersCreate(t *testing.T) {
+	repository := ordertest.NewRepository(ordertest.NewOrder(ordertest.WithID(1)))
+	handlers := OrderHandlers{
+		APM:    dependenciestest.NewAPM(t),
+		Orders: orders.NewService(repository, nil),
+	}
+
+	create := func(handlers OrderHandlers, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		handlers.Create(w, httptest.NewRequest(http.MethodPost, "/orders", strings.NewReader(body)))
+		return w
+	}
+
+	t.Run("creates an order", func(t *testing.T) {
+		w := create(handlers, `{"Status":"PLACED"}`)
+
+		assert.Equal(t, http.StatusCreated, w.Code)
+		assert.Equal(t, "/orders/2", w.Header().Get("Location"))
+		assert.JSONEq(t, `{"ID":2,"Status":"PLACED"}`, w.Body.String())
+
+		order, _ := repository.GetOrder(context.Background(), 2)
+		assert.Equal(t, "PLACED", order.Status)
+	})
+
+	t.Run("creates an order from a restaurant", func(t *testing.T) {
+		w := create(handlers, `{"RestaurantID":"r-1"}`)
+
+		assert.Equal(t, http.StatusCreated, w.Code)
+		assert.JSONEq(t, `{"ID":3,"Status":"NEW","RestaurantID":"r-1"}`, w.Body.String())
+	})
+
+	t.Run("creates a new order without a status", func(t *testing.T) {
+		w := create(handlers, `{}`)
+
+		assert.Equal(t, http.StatusCreated, w.Code)
+		assert.JSONEq(t, `{"ID":4,"Status":"NEW"}`, w.Body.String())
+	})
+
+	t.Run("responds bad request for an invalid body", func(t *testing.T) {
+		for _, body := range []string{``, `{"Status":`, `{"Colour":"red"}`} {
+			w := create(handlers, body)
+			assert.Equal(t, http.StatusBadRequest, w.Code, body)
+		}
+	})
+
+	t.Run("responds conflict when the order exists", func(t *testing.T) {
+		failing := OrderHandlers{
+			APM:    dependenciestest.NewAPM(t),
+			Orders: orders.NewService(failingRepository{dberrors.Map(&pgconn.PgError{Code: "23505", ConstraintName: "orders_pkey"})}, nil),
+		}
+
+		w := create(failing, `{}`)
+		assert.Equal(t, http.StatusConflict, w.Code)
+	})
+}
+
+// failingRepository fails to get any order.
+type failingRepository struct {
+	err error
+}
+
+func (r failingRepository) GetOrder(context.Context, int) (*orders.Order, error) {
+	return nil, r.err
+}
+
+func (r failingRepository) CreateOrder(context.Context, orders.Order) (*orders.Order, error) {
+	return nil, r.err
+}
+
+func (r failingRepository) UpdateOrderStatus(context.Context, int, string, string) (*orders.Order, error) {
+	return nil, r.err
+}
+
+func (r failingRepository) ListOrders(context.Context, pagination.Request) ([]orders.Order, pagination.Result, error) {
+	return nil, pagination.Result{}, r.err
+}
@@ -0,0 +1,5 @@
+// Package orderspb holds the protobuf representations of the orders API,
+// rendered to clients which accept application/x-protobuf.
+package orderspb
+
+//go:generate protoc --go_out=. --go_opt=paths=source_relative orders.proto
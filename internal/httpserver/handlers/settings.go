@@ -0,0 +1,56 @@
+package handlers
+
+import (
+	"io"
+	"net/http"
+
+	"github.com/deliveroo/bnt-internal-test-go/internal/httpserver/gorillautils"
+	"github.com/deliveroo/bnt-internal-test-go/internal/settings"
+)
+
+// maxSettingsBody is the largest settings update accepted, in bytes.
+const maxSettingsBody = 64 << 10
+
+// SettingsHandlers serves the admin endpoints for runtime settings.
+type SettingsHandlers struct {
+	Store *settings.Store
+}
+
+// Get renders the current settings.
+func (h *SettingsHandlers) Get(w http.ResponseWriter, r *http.Request) {
+	_ = gorillautils.RenderJSON(w, h.Store.Current())
+}
+
+// Patch changes the settings present in the JSON request body, a JSON merge
+// patch, leaving the others unchanged, and renders the change.
+func (h *SettingsHandlers) Patch(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxSettingsBody))
+	if err != nil {
+		_ = gorillautils.RenderProblem(w, gorillautils.Problem{Status: http.StatusBadRequest, Detail: "The request body could not be read."})
+		return
+	}
+
+	// Apply the patch before updating, so that a malformed body changes
+	// nothing.
+	if _, err := h.Store.Current().Patch(body); err != nil {
+		_ = gorillautils.RenderProblem(w, gorillautils.Problem{Status: http.StatusBadRequest, Detail: err.Error()})
+		return
+	}
+
+	change, err := h.Store.Update("admin "+r.RemoteAddr, func(v *settings.Values) {
+		if patched, err := v.Patch(body); err == nil {
+			*v = patched
+		}
+	})
+	if err != nil {
+		_ = gorillautils.RenderProblem(w, gorillautils.Problem{Status: http.StatusUnprocessableEntity, Detail: err.Error()})
+		return
+	}
+
+	_ = gorillautils.RenderJSON(w, change)
+}
+
+// History renders the most recent changes to the settings, oldest first.
+func (h *SettingsHandlers) History(w http.ResponseWriter, r *http.Request) {
+	_ = gorillautils.RenderJSON(w, h.Store.History())
+}
@@ -0,0 +1,69 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap/zapcore"
+
+	"github.com/deliveroo/bnt-internal-test-go/internal/config"
+	"github.com/deliveroo/bnt-internal-test-go/internal/settings"
+)
+
+func TestSettingsHandlers(t *testing.T) {
+	store, err := settings.New(settings.Values{LogLevel: zapcore.InfoLevel})
+	assert.Nil(t, err)
+	handlers := SettingsHandlers{Store: store}
+
+	patch := func(body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		handlers.Patch(w, httptest.NewRequest(http.MethodPatch, "/admin/settings", strings.NewReader(body)))
+		return w
+	}
+
+	t.Run("changes only the given settings", func(t *testing.T) {
+		w := patch(`{"span_logging": true}`)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), `"diffs":[{"setting":"span_logging","old":false,"new":true}]`)
+
+		assert.True(t, store.Current().SpanLogging)
+		assert.Equal(t, zapcore.InfoLevel, store.Current().LogLevel)
+	})
+
+	t.Run("changes only the given fields of a circuit", func(t *testing.T) {
+		assert.Equal(t, http.StatusOK, patch(`{"circuits": {"determinator": {"timeout": 1000, "max_concurrent_requests": 10}}}`).Code)
+		assert.Equal(t, http.StatusOK, patch(`{"circuits": {"determinator": {"timeout": 500}}}`).Code)
+
+		assert.Equal(t, config.Circuit{Timeout: 500, MaxConcurrentRequests: 10}, store.Current().Circuits["determinator"])
+	})
+
+	t.Run("removes settings patched to null", func(t *testing.T) {
+		assert.Equal(t, http.StatusOK, patch(`{"circuits": {"determinator": null}}`).Code)
+
+		assert.Empty(t, store.Current().Circuits)
+		assert.True(t, store.Current().SpanLogging)
+	})
+
+	t.Run("responds bad request for unknown settings", func(t *testing.T) {
+		w := patch(`{"span_loging": false}`)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.True(t, store.Current().SpanLogging)
+	})
+
+	t.Run("responds unprocessable entity for invalid settings", func(t *testing.T) {
+		w := patch(`{"span_logging": false, "rate_limits": {"determinator": {"requests_per_second": 5}}}`)
+		assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+		assert.JSONEq(t, `{"title":"Unprocessable Entity","status":422,"detail":"invalid settings: rate_limits.determinator.burst must be at least 1"}`, w.Body.String())
+		assert.True(t, store.Current().SpanLogging)
+	})
+
+	t.Run("renders the history of changes", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		handlers.History(w, httptest.NewRequest(http.MethodGet, "/admin/settings/history", nil))
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), `"source":"admin 192.0.2.1:1234"`)
+	})
+}
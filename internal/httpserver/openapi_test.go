@@ -0,0 +1,48 @@
+package httpserver
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/deliveroo/bnt-internal-test-go/internal/config"
+	"github.com/deliveroo/bnt-internal-test-go/internal/dependencies/dependenciestest"
+	"github.com/deliveroo/bnt-internal-test-go/internal/orders/ordertest"
+	"github.com/deliveroo/bnt-internal-test-go/internal/rate"
+	"github.com/deliveroo/bnt-internal-test-go/internal/ratelimit"
+	"github.com/deliveroo/bnt-internal-test-go/internal/settings"
+)
+
+// openAPIDocument is the committed OpenAPI document. Run the tests with
+// UPDATE_OPENAPI=1 (or make openapi) to regenerate it after changing a route.
+const openAPIDocument = "../../docs/openapi.json"
+
+func TestOpenAPIDocument(t *testing.T) {
+	deps := dependenciestest.NewDependencies(t, "testdata/cassettes/router.json", ordertest.NewRepository())
+	// Serve the admin routes too, so that they are documented.
+	deps.Config.Admin.Token = config.NewSecret("token")
+	store, err := settings.New(settings.Values{})
+	assert.Nil(t, err)
+	deps.Settings = store
+	// Limit the rate of requests too, so that 429 responses are documented.
+	deps.RateLimiter = ratelimit.NewTokenBucket()
+	deps.Config.RateLimit.Default = rate.Limit{Requests: 600, Per: time.Minute}
+
+	rec := httptest.NewRecorder()
+	NewRouter(deps).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, OpenAPIPath, nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "application/json", rec.Header().Get("Content-Type"))
+
+	if os.Getenv("UPDATE_OPENAPI") != "" {
+		assert.Nil(t, os.WriteFile(openAPIDocument, rec.Body.Bytes(), 0o644)) //nolint:gosec // the document is public
+		return
+	}
+
+	committed, err := os.ReadFile(openAPIDocument)
+	assert.Nil(t, err)
+	assert.Equal(t, string(committed), rec.Body.String(), "docs/openapi.json is out of date with the routes: run make openapi")
+}
@@ -1,11 +1,73 @@
 package httpserver
 
 import (
+	"net/http"
+	"strings"
+	"time"
+
 	"github.com/gorilla/mux"
 
 	"github.com/deliveroo/apm-go/integrations/gorillatrace"
+	"github.com/deliveroo/bnt-internal-test-go/internal/apikeys"
+	"github.com/deliveroo/bnt-internal-test-go/internal/auth"
+	"github.com/deliveroo/bnt-internal-test-go/internal/authz"
+	"github.com/deliveroo/bnt-internal-test-go/internal/compress"
+	"github.com/deliveroo/bnt-internal-test-go/internal/cors"
+	"github.com/deliveroo/bnt-internal-test-go/internal/deadline"
 	"github.com/deliveroo/bnt-internal-test-go/internal/dependencies"
+	"github.com/deliveroo/bnt-internal-test-go/internal/httpserver/api"
+	"github.com/deliveroo/bnt-internal-test-go/internal/httpserver/gorillautils"
 	"github.com/deliveroo/bnt-internal-test-go/internal/httpserver/handlers"
+	"github.com/deliveroo/bnt-internal-test-go/internal/idempotency"
+	"github.com/deliveroo/bnt-internal-test-go/internal/loadshed"
+	"github.com/deliveroo/bnt-internal-test-go/internal/orders"
+	"github.com/deliveroo/bnt-internal-test-go/internal/pagination"
+	"github.com/deliveroo/bnt-internal-test-go/internal/ratelimit"
+	"github.com/deliveroo/bnt-internal-test-go/internal/securityheaders"
+	"github.com/deliveroo/bnt-internal-test-go/internal/settings"
+)
+
+// OpenAPIPath is where the OpenAPI document describing the routes is served.
+const OpenAPIPath = "/openapi.json"
+
+// apiInfo describes the API in its OpenAPI document.
+var apiInfo = api.Info{
+	Title:   "bnt-internal-test-go",
+	Version: "1.0.0",
+}
+
+// Timeouts of the routes, which must respond within the server's
+// HTTP_SERVER_WRITE_TIMEOUT. Writes, and calls to other services, are given
+// longer than reads.
+const (
+	readRouteTimeout  = time.Second
+	writeRouteTimeout = 1500 * time.Millisecond
+)
+
+var (
+	limitParam = api.Param{
+		Name:        pagination.LimitParam,
+		In:          api.InQuery,
+		Description: "The number of items per page.",
+		Example:     0,
+	}
+	cursorParam = api.Param{
+		Name:        pagination.CursorParam,
+		In:          api.InQuery,
+		Description: "The cursor of the page, from the links of another page.",
+	}
+	orderIDParam        = api.Param{Name: "id", In: api.InPath, Description: "The ID of the order.", Example: 0}
+	idempotencyKeyParam = api.Param{
+		Name:        idempotency.Header,
+		In:          api.InHeader,
+		Description: "A key unique to the request, so that retries of it are only processed once.",
+	}
+
+	// orderTransitionErrors are the errors of changing the status of an order.
+	orderTransitionErrors = []int{
+		http.StatusBadRequest, http.StatusNotFound, http.StatusConflict,
+		http.StatusInternalServerError, http.StatusServiceUnavailable, http.StatusGatewayTimeout,
+	}
 )
 
 func NewRouter(deps *dependencies.Dependencies) *mux.Router {
@@ -16,22 +78,325 @@ func NewRouter(deps *dependencies.Dependencies) *mux.Router {
 	}
 
 	orderHandlers := handlers.OrderHandlers{
-		Repository:   deps.Repository,
+		APM:          deps.APM,
+		Orders:       orders.NewService(deps.Repository, authorizer(deps, orders.Policy)),
 		Determinator: deps.Determinator,
 		Client:       orderHandlersHTTPClient,
+		Cursors:      deps.Cursors,
 	}
 
 	externalHandlers := handlers.NewExternalHandlersFunc(deps.APM, orderHandlersHTTPClient)
 	pingHandlers := handlers.Ping{}
 
-	r.HandleFunc("/orders/{id:[0-9]+}", orderHandlers.Get)
-	r.HandleFunc("/external", externalHandlers.Get)
-	r.HandleFunc("/ping", pingHandlers.Get)
+	routes := api.NewRouter(r)
+	routes.Validate(validationOptions(deps)...)
+	services := routes.Authenticated(map[string]api.SecurityScheme{
+		"serviceToken": {
+			Type:         "http",
+			Scheme:       "bearer",
+			BearerFormat: "JWT",
+			Description:  "A JWT issued to the calling service by the identity provider.",
+		},
+		"apiKey": {
+			Type:        "apiKey",
+			Name:        apikeys.Header,
+			In:          api.InHeader,
+			Description: "An API key minted for a partner or tool.",
+		},
+	}, authorize(deps))
+	if deps.RateLimiter != nil {
+		services.Limit(rateLimit(deps))
+	}
+	services.Handle(api.Route{
+		Method:   http.MethodGet,
+		Path:     "/orders",
+		Name:     "listOrders",
+		Summary:  "Lists orders, newest first.",
+		Tags:     []string{"orders"},
+		Handler:  http.HandlerFunc(orderHandlers.List),
+		Timeout:  readRouteTimeout,
+		Scopes:   []string{"orders:read"},
+		Params:   []api.Param{limitParam, cursorParam},
+		Response: handlers.OrderList{},
+		Errors:   []int{http.StatusBadRequest, http.StatusInternalServerError, http.StatusServiceUnavailable, http.StatusGatewayTimeout},
+	})
+	services.Handle(api.Route{
+		Method:     http.MethodPost,
+		Path:       "/orders",
+		Name:       "createOrder",
+		Summary:    "Creates an order.",
+		Tags:       []string{"orders"},
+		Handler:    http.HandlerFunc(orderHandlers.Create),
+		Timeout:    writeRouteTimeout,
+		Scopes:     []string{"orders:write"},
+		Middleware: []mux.MiddlewareFunc{idempotent(deps)},
+		Params:     []api.Param{idempotencyKeyParam},
+		Request:    handlers.CreateOrderRequest{},
+		Response:   handlers.Order{},
+		Status:     http.StatusCreated,
+		Errors: []int{
+			http.StatusBadRequest, http.StatusConflict, http.StatusRequestEntityTooLarge, http.StatusUnprocessableEntity,
+			http.StatusInternalServerError, http.StatusServiceUnavailable, http.StatusGatewayTimeout,
+		},
+	})
+	services.Handle(api.Route{
+		Method:   http.MethodGet,
+		Path:     "/orders/{id:[0-9]+}",
+		Name:     "getOrder",
+		Summary:  "Gets an order.",
+		Tags:     []string{"orders"},
+		Handler:  http.HandlerFunc(orderHandlers.Get),
+		Timeout:  readRouteTimeout,
+		Scopes:   []string{"orders:read"},
+		Params:   []api.Param{orderIDParam},
+		Response: handlers.Order{},
+		Errors:   []int{http.StatusBadRequest, http.StatusNotFound, http.StatusInternalServerError, http.StatusServiceUnavailable, http.StatusGatewayTimeout},
+	})
+	services.Handle(api.Route{
+		Method:   http.MethodPost,
+		Path:     "/orders/{id:[0-9]+}/cancel",
+		Name:     "cancelOrder",
+		Summary:  "Cancels a NEW order.",
+		Tags:     []string{"orders"},
+		Handler:  http.HandlerFunc(orderHandlers.Cancel),
+		Timeout:  writeRouteTimeout,
+		Scopes:   []string{"orders:write"},
+		Params:   []api.Param{orderIDParam},
+		Response: handlers.Order{},
+		Errors:   orderTransitionErrors,
+	})
+	services.Handle(api.Route{
+		Method:   http.MethodPost,
+		Path:     "/orders/{id:[0-9]+}/fulfil",
+		Name:     "fulfilOrder",
+		Summary:  "Fulfils a NEW order.",
+		Tags:     []string{"orders"},
+		Handler:  http.HandlerFunc(orderHandlers.Fulfil),
+		Timeout:  writeRouteTimeout,
+		Scopes:   []string{"orders:write"},
+		Params:   []api.Param{orderIDParam},
+		Response: handlers.Order{},
+		Errors:   orderTransitionErrors,
+	})
+	services.Handle(api.Route{
+		Method:  http.MethodGet,
+		Path:    "/external",
+		Name:    "getExternal",
+		Summary: "Proxies a request to an external service, responding with its response.",
+		Handler: http.HandlerFunc(externalHandlers.Get),
+		Timeout: writeRouteTimeout,
+		Errors:  []int{http.StatusInternalServerError},
+	})
+	routes.Handle(api.Route{
+		Method:  http.MethodGet,
+		Path:    "/ping",
+		Name:    "ping",
+		Summary: "Responds when the service is up.",
+		Handler: http.HandlerFunc(pingHandlers.Get),
+	})
+
+	// The admin endpoints are only served when a token to protect them is
+	// configured.
+	if deps.Config.Admin.Token.Value() != "" && deps.Settings != nil {
+		admin := routes.Subrouter("/admin")
+		admin.Use(gorillautils.RequireBearerToken(deps.Config.Admin.Token.Value()))
+		admin.RequireSecurity("adminToken", api.SecurityScheme{Type: "http", Scheme: "bearer", Description: "The ADMIN_TOKEN."})
+
+		settingsHandlers := handlers.SettingsHandlers{Store: deps.Settings}
+		admin.Handle(api.Route{
+			Method:   http.MethodGet,
+			Path:     "/settings",
+			Name:     "getSettings",
+			Summary:  "Gets the runtime settings.",
+			Tags:     []string{"admin"},
+			Handler:  http.HandlerFunc(settingsHandlers.Get),
+			Response: settings.Values{},
+		})
+		admin.Handle(api.Route{
+			Method:   http.MethodPatch,
+			Path:     "/settings",
+			Name:     "updateSettings",
+			Summary:  "Changes the runtime settings in the body, leaving the others unchanged.",
+			Tags:     []string{"admin"},
+			Handler:  http.HandlerFunc(settingsHandlers.Patch),
+			Request:  api.Partial(settings.Values{}),
+			Response: settings.Change{},
+			Errors:   []int{http.StatusBadRequest, http.StatusUnprocessableEntity},
+		})
+		admin.Handle(api.Route{
+			Method:   http.MethodGet,
+			Path:     "/settings/history",
+			Name:     "getSettingsHistory",
+			Summary:  "Lists the most recent changes to the runtime settings, oldest first.",
+			Tags:     []string{"admin"},
+			Handler:  http.HandlerFunc(settingsHandlers.History),
+			Response: []settings.Change{},
+		})
+
+		logLevelHandlers := handlers.LogLevelHandlers{Store: deps.Settings}
+		admin.Handle(api.Route{
+			Method:   http.MethodGet,
+			Path:     "/log-level",
+			Name:     "getLogLevels",
+			Summary:  "Gets the current log levels.",
+			Tags:     []string{"admin"},
+			Handler:  http.HandlerFunc(logLevelHandlers.Get),
+			Response: handlers.LogLevels{},
+		})
+		admin.Handle(api.Route{
+			Method:   http.MethodPut,
+			Path:     "/log-level",
+			Name:     "setLogLevel",
+			Summary:  "Changes a log level temporarily.",
+			Tags:     []string{"admin"},
+			Handler:  http.HandlerFunc(logLevelHandlers.Put),
+			Request:  handlers.LogLevelRequest{},
+			Response: settings.Change{},
+			Errors:   []int{http.StatusBadRequest, http.StatusUnprocessableEntity},
+		})
+	}
+
+	r.Handle(OpenAPIPath, api.DocumentHandler(routes, apiInfo)).Methods(http.MethodGet)
 
 	r.Use(gorillatrace.TracingWithStatusError(deps.APM))
+	if cfg := deps.Config.CORS; len(cfg.AllowedOrigins) > 0 {
+		r.Methods(http.MethodOptions).Handler(cors.PreflightHandler(r))
+		r.Use(cors.Middleware(cors.Policy{
+			AllowedOrigins:   cfg.AllowedOrigins,
+			AllowedMethods:   cfg.AllowedMethods,
+			AllowedHeaders:   cfg.AllowedHeaders,
+			ExposedHeaders:   cfg.ExposedHeaders,
+			AllowCredentials: cfg.AllowCredentials,
+			MaxAge:           cfg.MaxAge,
+		}))
+	}
+	r.Use(securityheaders.Middleware(securityheaders.Policy{
+		HSTSMaxAge:            deps.Config.SecurityHeaders.HSTSMaxAge,
+		HSTSIncludeSubdomains: deps.Config.SecurityHeaders.HSTSIncludeSubdomains,
+		FrameOptions:          deps.Config.SecurityHeaders.FrameOptions,
+		ContentSecurityPolicy: deps.Config.SecurityHeaders.ContentSecurityPolicy,
+		ReferrerPolicy:        deps.Config.SecurityHeaders.ReferrerPolicy,
+	}))
+	if deps.Config.Compression.Enabled {
+		r.Use(compress.Middleware(
+			compress.WithMinSize(deps.Config.Compression.MinSize),
+			compress.WithGzipLevel(deps.Config.Compression.GzipLevel),
+			compress.WithBrotliLevel(deps.Config.Compression.BrotliLevel),
+		))
+	}
+	if deps.LoadShedder != nil {
+		r.Use(loadshed.Middleware(deps.LoadShedder, deps.APM.StatsD(), loadshed.WithExempt(exemptFromLoadShedding)))
+	}
+	r.Use(deadline.Middleware)
+	r.Use(readYourWrites)
 
 	// Use gorillatrace.SpanLogging(deps.APM) to print a log line for every HTTP request.
 	// Be aware that this could be very costly and should not be enabled in production.
 
 	return r
 }
+
+// exemptFromLoadShedding reports whether r is for a health check or an admin
+// endpoint, which must respond even when the service is overloaded.
+func exemptFromLoadShedding(r *http.Request) bool {
+	return r.URL.Path == "/ping" || strings.HasPrefix(r.URL.Path, "/admin/")
+}
+
+// readYourWrites lets each request read what it has written, rather than a
+// replica which may not have caught up.
+func readYourWrites(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		next.ServeHTTP(w, r.WithContext(dependencies.WithReadYourWrites(r.Context())))
+	})
+}
+
+// idempotent returns a middleware which replays responses to requests retried
+// with the same Idempotency-Key. Requests are processed as usual when there is
+// no store to record responses in.
+func idempotent(deps *dependencies.Dependencies) mux.MiddlewareFunc {
+	if deps.Idempotency == nil {
+		return func(next http.Handler) http.Handler { return next }
+	}
+	return idempotency.Middleware(deps.Idempotency,
+		idempotency.WithLockFor(deps.Config.Idempotency.LockFor),
+		idempotency.WithKeepFor(deps.Config.Idempotency.KeepFor),
+		idempotency.WithLogger(deps.APM.Logger().Named("idempotency")),
+		idempotency.WithScope(idempotencyScope),
+	)
+}
+
+// idempotencyScope scopes Idempotency-Keys to the caller as well as the route,
+// so that callers which choose the same key are not replayed each other's
+// responses.
+func idempotencyScope(r *http.Request) string {
+	scope := r.Method + " " + r.URL.Path
+	if principal := auth.PrincipalFromContext(r.Context()); principal != nil {
+		return principal.Issuer + " " + principal.Subject + " " + scope
+	}
+	return scope
+}
+
+// validationOptions configures the validation of requests against the OpenAPI
+// document. Responses are validated too outside production, when enabled.
+func validationOptions(deps *dependencies.Dependencies) []api.ValidationOption {
+	if !deps.Config.Server.ValidateResponses || deps.Config.Hopper.Environment == "production" {
+		return nil
+	}
+	return []api.ValidationOption{api.WithResponseValidation(deps.APM.Logger().Named("api"))}
+}
+
+// rateLimit returns the middleware limiting the requests each caller makes to
+// a route, to its limit in RATE_LIMIT_ROUTES or else RATE_LIMIT_DEFAULT.
+func rateLimit(deps *dependencies.Dependencies) func(route api.Route) mux.MiddlewareFunc {
+	opts := []ratelimit.Option{ratelimit.WithLogger(deps.APM.Logger().Named("ratelimit"))}
+	if deps.Config.RateLimit.TrustForwardedFor {
+		opts = append(opts, ratelimit.WithForwardedFor())
+	}
+
+	return func(route api.Route) mux.MiddlewareFunc {
+		limit, ok := deps.Config.RateLimit.Routes[route.Name]
+		if !ok {
+			limit = deps.Config.RateLimit.Default
+		}
+		if limit.Requests == 0 {
+			return nil
+		}
+		return ratelimit.Middleware(deps.RateLimiter, route.Name, limit, deps.APM.StatsD(), opts...)
+	}
+}
+
+// authorize returns the middleware authenticating requests from other services,
+// by token, and from partners and tools, by API key, and requiring scopes.
+// Requests are not authenticated when neither is configured, e.g. in
+// development.
+func authorize(deps *dependencies.Dependencies) func(scopes []string) mux.MiddlewareFunc {
+	authenticators := authenticators(deps)
+
+	return func(scopes []string) mux.MiddlewareFunc {
+		if len(authenticators) == 0 {
+			return func(next http.Handler) http.Handler { return next }
+		}
+		return auth.Middleware(auth.FirstOf(authenticators...), scopes...)
+	}
+}
+
+// authenticators returns the configured ways of authenticating requests.
+func authenticators(deps *dependencies.Dependencies) []auth.Authenticator {
+	var authenticators []auth.Authenticator
+	if deps.Auth != nil {
+		authenticators = append(authenticators, deps.Auth)
+	}
+	if deps.APIKeys != nil {
+		authenticators = append(authenticators, deps.APIKeys)
+	}
+	return authenticators
+}
+
+// authorizer returns the authorizer enforcing policy on authenticated
+// requests, or nil when requests are not authenticated.
+func authorizer(deps *dependencies.Dependencies, policy *authz.Policy) *authz.Authorizer {
+	if len(authenticators(deps)) == 0 {
+		return nil
+	}
+	return authz.NewAuthorizer(policy, deps.APM.Logger().Named("authz"))
+}
@@ -0,0 +1,47 @@
+package httpserver
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/deliveroo/bnt-internal-test-go/internal/apikeys"
+	"github.com/deliveroo/bnt-internal-test-go/internal/database/databasetest"
+	"github.com/deliveroo/bnt-internal-test-go/internal/dependencies/dependenciestest"
+	"github.com/deliveroo/bnt-internal-test-go/internal/orders"
+	"github.com/deliveroo/bnt-internal-test-go/internal/orders/ordertest"
+)
+
+func TestRouterAPIKeys(t *testing.T) {
+	repository := ordertest.NewRepository(ordertest.NewOrder(ordertest.WithID(1)))
+	deps := dependenciestest.NewDependencies(t, "testdata/cassettes/router.json", repository)
+	keys := apikeys.NewPostgresStore(databasetest.NewPool(t))
+	deps.APIKeys = apikeys.NewAuthenticator(keys, deps.APM.StatsD())
+	router := NewRouter(deps)
+
+	serve := func(key, method, target, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, target, strings.NewReader(body))
+		if key != "" {
+			req.Header.Set(apikeys.Header, key)
+		}
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		return rec
+	}
+
+	t.Run("serves requests with an API key granted the route's scopes", func(t *testing.T) {
+		key, _, err := apikeys.Mint(context.Background(), keys, apikeys.Key{Owner: "partner", Scopes: []string{"orders:read"}, Roles: []string{orders.RoleService}})
+		assert.Nil(t, err)
+
+		assert.Equal(t, http.StatusOK, serve(key, http.MethodGet, "/orders/1", "").Code)
+		assert.Equal(t, http.StatusForbidden, serve(key, http.MethodPost, "/orders", `{}`).Code)
+	})
+
+	t.Run("rejects unknown API keys", func(t *testing.T) {
+		assert.Equal(t, http.StatusUnauthorized, serve("bntk_00000000_unknown", http.MethodGet, "/orders/1", "").Code)
+	})
+}
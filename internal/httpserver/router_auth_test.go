@@ -0,0 +1,86 @@
+package httpserver
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/deliveroo/bnt-internal-test-go/internal/auth/authtest"
+	"github.com/deliveroo/bnt-internal-test-go/internal/dependencies/dependenciestest"
+	"github.com/deliveroo/bnt-internal-test-go/internal/orders"
+	"github.com/deliveroo/bnt-internal-test-go/internal/orders/ordertest"
+)
+
+func TestRouterAuthentication(t *testing.T) {
+	signer := authtest.NewRSASigner(t)
+	repository := ordertest.NewRepository(
+		ordertest.NewOrder(ordertest.WithID(1), ordertest.WithRestaurantID("r-1")),
+		ordertest.NewOrder(ordertest.WithID(2), ordertest.WithRestaurantID("r-2")),
+	)
+	deps := dependenciestest.NewDependencies(t, "testdata/cassettes/router.json", repository)
+	deps.Auth = signer.Verifier()
+	router := NewRouter(deps)
+
+	serveAs := func(claims map[string]interface{}, method, target, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, target, strings.NewReader(body))
+		if claims != nil {
+			req.Header.Set("Authorization", "Bearer "+signer.Token(t, claims))
+		}
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		return rec
+	}
+	serve := func(method, target, body string, scopes ...string) *httptest.ResponseRecorder {
+		if scopes == nil {
+			return serveAs(nil, method, target, body)
+		}
+		claims := authtest.Claims(scopes...)
+		claims["roles"] = []string{orders.RoleService}
+		return serveAs(claims, method, target, body)
+	}
+
+	t.Run("serves requests granted the route's scopes", func(t *testing.T) {
+		assert.Equal(t, http.StatusOK, serve(http.MethodGet, "/orders/1", "", "orders:read").Code)
+		assert.Equal(t, http.StatusCreated, serve(http.MethodPost, "/orders", `{}`, "orders:write").Code)
+	})
+
+	t.Run("rejects requests without a token", func(t *testing.T) {
+		assert.Equal(t, http.StatusUnauthorized, serve(http.MethodGet, "/orders/1", "").Code)
+		// Before validating them.
+		assert.Equal(t, http.StatusUnauthorized, serve(http.MethodGet, "/orders?limit=many", "").Code)
+	})
+
+	t.Run("rejects requests without the route's scopes", func(t *testing.T) {
+		assert.Equal(t, http.StatusForbidden, serve(http.MethodPost, "/orders", `{}`, "orders:read").Code)
+	})
+
+	t.Run("authorizes restaurants to act on their own orders only", func(t *testing.T) {
+		claims := authtest.Claims("orders:read", "orders:write")
+		claims["roles"] = []string{orders.RoleRestaurant}
+		claims["restaurant_id"] = "r-1"
+
+		assert.Equal(t, http.StatusOK, serveAs(claims, http.MethodGet, "/orders/1", "").Code)
+		assert.Equal(t, http.StatusOK, serveAs(claims, http.MethodPost, "/orders/1/fulfil", "").Code)
+
+		// Orders of other restaurants are not found, rather than forbidden,
+		// so that restaurants cannot tell which orders exist.
+		rec := serveAs(claims, http.MethodPost, "/orders/2/cancel", "")
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+		assert.Equal(t, "application/problem+json", rec.Header().Get("Content-Type"))
+		assert.Equal(t, http.StatusNotFound, serveAs(claims, http.MethodGet, "/orders/2", "").Code)
+		assert.Equal(t, http.StatusForbidden, serveAs(claims, http.MethodGet, "/orders", "").Code)
+	})
+
+	t.Run("denies services without a role", func(t *testing.T) {
+		assert.Equal(t, http.StatusForbidden, serveAs(authtest.Claims("orders:read"), http.MethodGet, "/orders", "").Code)
+		assert.Equal(t, http.StatusNotFound, serveAs(authtest.Claims("orders:read"), http.MethodGet, "/orders/1", "").Code)
+	})
+
+	t.Run("serves public routes without a token", func(t *testing.T) {
+		assert.Equal(t, http.StatusOK, serve(http.MethodGet, "/ping", "").Code)
+		assert.Equal(t, http.StatusOK, serve(http.MethodGet, OpenAPIPath, "").Code)
+	})
+}
@@ -0,0 +1,65 @@
+package httpserver
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/deliveroo/bnt-internal-test-go/internal/dependencies/dependenciestest"
+	"github.com/deliveroo/bnt-internal-test-go/internal/httpserver/handlers/orderspb"
+	"github.com/deliveroo/bnt-internal-test-go/internal/orders/ordertest"
+)
+
+func TestRouterCompression(t *testing.T) {
+	deps := dependenciestest.NewDependencies(t, "testdata/cassettes/router.json", ordertest.NewRepository(ordertest.NewOrder(ordertest.WithID(1))))
+	deps.Config.Compression.Enabled = true
+	deps.Config.Compression.MinSize = 1024
+	deps.Config.Compression.GzipLevel = gzip.DefaultCompression
+	deps.Config.Compression.BrotliLevel = 4
+	router := NewRouter(deps)
+
+	serve := func(target string, header http.Header) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, target, nil)
+		for name, values := range header {
+			req.Header[name] = values
+		}
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		return rec
+	}
+
+	t.Run("compresses large responses", func(t *testing.T) {
+		rec := serve(OpenAPIPath, http.Header{"Accept-Encoding": {"gzip"}})
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "gzip", rec.Header().Get("Content-Encoding"))
+		assert.Contains(t, rec.Header().Values("Vary"), "Accept-Encoding")
+
+		reader, err := gzip.NewReader(rec.Body)
+		assert.Nil(t, err)
+		var document map[string]interface{}
+		assert.Nil(t, json.NewDecoder(reader).Decode(&document))
+		assert.Equal(t, "3.0.3", document["openapi"])
+	})
+
+	t.Run("sends small responses uncompressed", func(t *testing.T) {
+		rec := serve("/orders/1", http.Header{"Accept-Encoding": {"gzip, br"}})
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Empty(t, rec.Header().Get("Content-Encoding"))
+		assert.Equal(t, []string{"Accept-Encoding", "Accept"}, rec.Header().Values("Vary"))
+	})
+
+	t.Run("renders orders as protobuf to clients which accept it", func(t *testing.T) {
+		rec := serve("/orders/1", http.Header{"Accept": {"application/x-protobuf"}})
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "application/x-protobuf", rec.Header().Get("Content-Type"))
+
+		var order orderspb.Order
+		assert.Nil(t, proto.Unmarshal(rec.Body.Bytes(), &order))
+		assert.Equal(t, int64(1), order.Id)
+	})
+}
@@ -0,0 +1,65 @@
+package httpserver
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/deliveroo/bnt-internal-test-go/internal/cors"
+	"github.com/deliveroo/bnt-internal-test-go/internal/dependencies/dependenciestest"
+	"github.com/deliveroo/bnt-internal-test-go/internal/orders/ordertest"
+)
+
+func TestRouterBrowserHeaders(t *testing.T) {
+	const backOffice = "https://backoffice.example.com"
+	deps := dependenciestest.NewDependencies(t, "testdata/cassettes/router.json", ordertest.NewRepository(ordertest.NewOrder(ordertest.WithID(1))))
+	deps.Config.CORS.AllowedOrigins = []string{backOffice}
+	deps.Config.CORS.AllowedMethods = []string{http.MethodGet, http.MethodPost}
+	deps.Config.CORS.AllowedHeaders = []string{"Authorization", "Content-Type"}
+	deps.Config.SecurityHeaders.HSTSMaxAge = 24 * time.Hour
+	deps.Config.SecurityHeaders.FrameOptions = "DENY"
+	router := NewRouter(deps)
+
+	serve := func(method, target string, header http.Header) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, target, nil)
+		for name, values := range header {
+			req.Header[name] = values
+		}
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		return rec
+	}
+
+	t.Run("answers preflight requests for the routes", func(t *testing.T) {
+		rec := serve(http.MethodOptions, "/orders", http.Header{
+			cors.OriginHeader:         {backOffice},
+			cors.RequestMethodHeader:  {http.MethodPost},
+			cors.RequestHeadersHeader: {"Content-Type"},
+		})
+		assert.Equal(t, http.StatusNoContent, rec.Code)
+		assert.Equal(t, backOffice, rec.Header().Get(cors.AllowOriginHeader))
+		assert.Equal(t, "GET, POST", rec.Header().Get(cors.AllowMethodsHeader))
+
+		rec = serve(http.MethodOptions, "/orders/1", http.Header{
+			cors.OriginHeader:        {backOffice},
+			cors.RequestMethodHeader: {http.MethodPost},
+		})
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+	})
+
+	t.Run("lets allowed origins read responses", func(t *testing.T) {
+		rec := serve(http.MethodGet, "/orders/1", http.Header{cors.OriginHeader: {backOffice}})
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, backOffice, rec.Header().Get(cors.AllowOriginHeader))
+	})
+
+	t.Run("sets the security headers", func(t *testing.T) {
+		rec := serve(http.MethodGet, "/ping", nil)
+		assert.Equal(t, "max-age=86400", rec.Header().Get("Strict-Transport-Security"))
+		assert.Equal(t, "nosniff", rec.Header().Get("X-Content-Type-Options"))
+		assert.Equal(t, "DENY", rec.Header().Get("X-Frame-Options"))
+	})
+}
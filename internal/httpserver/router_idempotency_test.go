@@ -0,0 +1,60 @@
+package httpserver
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/deliveroo/bnt-internal-test-go/internal/auth/authtest"
+	"github.com/deliveroo/bnt-internal-test-go/internal/database/databasetest"
+	"github.com/deliveroo/bnt-internal-test-go/internal/dependencies/dependenciestest"
+	"github.com/deliveroo/bnt-internal-test-go/internal/idempotency"
+	"github.com/deliveroo/bnt-internal-test-go/internal/orders"
+	"github.com/deliveroo/bnt-internal-test-go/internal/orders/ordertest"
+)
+
+func TestRouterIdempotency(t *testing.T) {
+	signer := authtest.NewRSASigner(t)
+	deps := dependenciestest.NewDependencies(t, "testdata/cassettes/router.json", ordertest.NewRepository())
+	deps.Auth = signer.Verifier()
+	deps.Idempotency = idempotency.NewPostgresStore(databasetest.NewPool(t))
+	router := NewRouter(deps)
+
+	create := func(subject string) *httptest.ResponseRecorder {
+		claims := authtest.Claims("orders:write")
+		claims["sub"] = subject
+		claims["roles"] = []string{orders.RoleService}
+		req := httptest.NewRequest(http.MethodPost, "/orders", strings.NewReader(`{}`))
+		req.Header.Set("Authorization", "Bearer "+signer.Token(t, claims))
+		req.Header.Set(idempotency.Header, "shared-key")
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		return rec
+	}
+
+	t.Run("scopes idempotency keys to the caller", func(t *testing.T) {
+		first := create("service-a")
+		second := create("service-b")
+		assert.Equal(t, http.StatusCreated, second.Code)
+		assert.Empty(t, second.Header().Get(idempotency.ReplayedHeader))
+		assert.NotEqual(t, first.Header().Get("Location"), second.Header().Get("Location"))
+
+		retry := create("service-a")
+		assert.Equal(t, "true", retry.Header().Get(idempotency.ReplayedHeader))
+		assert.Equal(t, first.Header().Get("Location"), retry.Header().Get("Location"))
+	})
+
+	t.Run("processes requests without a key as usual", func(t *testing.T) {
+		claims := authtest.Claims("orders:write")
+		claims["roles"] = []string{orders.RoleService}
+		req := httptest.NewRequest(http.MethodPost, "/orders", strings.NewReader(`{}`))
+		req.Header.Set("Authorization", "Bearer "+signer.Token(t, claims))
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		assert.Equal(t, http.StatusCreated, rec.Code)
+		assert.Empty(t, rec.Header().Get(idempotency.ReplayedHeader))
+	})
+}
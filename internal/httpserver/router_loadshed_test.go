@@ -0,0 +1,49 @@
+package httpserver
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/deliveroo/bnt-internal-test-go/internal/config"
+	"github.com/deliveroo/bnt-internal-test-go/internal/dependencies/dependenciestest"
+	"github.com/deliveroo/bnt-internal-test-go/internal/loadshed"
+	"github.com/deliveroo/bnt-internal-test-go/internal/orders/ordertest"
+	"github.com/deliveroo/bnt-internal-test-go/internal/settings"
+)
+
+func TestRouterLoadShedding(t *testing.T) {
+	deps := dependenciestest.NewDependencies(t, "testdata/cassettes/router.json", ordertest.NewRepository())
+	deps.Config.Admin.Token = config.NewSecret("token")
+	store, err := settings.New(settings.Values{})
+	assert.Nil(t, err)
+	deps.Settings = store
+	deps.LoadShedder = loadshed.NewLimiter(loadshed.WithInitialLimit(1), loadshed.WithLimits(1, 1))
+	router := NewRouter(deps)
+
+	// Take up the only slot, as a request in flight would.
+	done, ok := deps.LoadShedder.Acquire()
+	assert.True(t, ok)
+	defer done(false)
+
+	serve := func(target string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, target, nil)
+		req.Header.Set("Authorization", "Bearer token")
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		return rec
+	}
+
+	t.Run("sheds requests beyond the limit", func(t *testing.T) {
+		rec := serve("/orders")
+		assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+		assert.Equal(t, "1", rec.Header().Get("Retry-After"))
+	})
+
+	t.Run("serves health checks and admin endpoints regardless", func(t *testing.T) {
+		assert.Equal(t, http.StatusOK, serve("/ping").Code)
+		assert.Equal(t, http.StatusOK, serve("/admin/settings").Code)
+	})
+}
@@ -0,0 +1,29 @@
+package httpserver
+
+import (
+	"testing"
+
+	"github.com/deliveroo/bnt-internal-test-go/internal/contract"
+	"github.com/deliveroo/bnt-internal-test-go/internal/dependencies/dependenciestest"
+	"github.com/deliveroo/bnt-internal-test-go/internal/orders/ordertest"
+)
+
+func TestProviderContract(t *testing.T) {
+	repository := ordertest.NewRepository()
+	deps := dependenciestest.NewDependencies(t, "testdata/cassettes/router.json", repository)
+
+	contract.VerifyProvider(t, contract.VerifyRequest{
+		Provider:   "bnt-internal-test-go",
+		Handler:    NewRouter(deps),
+		BeforeEach: repository.Reset,
+		StateHandlers: map[string]contract.StateHandler{
+			"order 1 exists": func() error {
+				repository.Add(ordertest.NewOrder(ordertest.WithID(1), ordertest.WithStatus("NEW")))
+				return nil
+			},
+			"order 2 does not exist": func() error {
+				return nil
+			},
+		},
+	})
+}
@@ -0,0 +1,53 @@
+package httpserver
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/deliveroo/bnt-internal-test-go/internal/dependencies/dependenciestest"
+	"github.com/deliveroo/bnt-internal-test-go/internal/orders/ordertest"
+	"github.com/deliveroo/bnt-internal-test-go/internal/rate"
+	"github.com/deliveroo/bnt-internal-test-go/internal/ratelimit"
+)
+
+func TestRouterRateLimiting(t *testing.T) {
+	repository := ordertest.NewRepository(ordertest.NewOrder(ordertest.WithID(1)))
+	deps := dependenciestest.NewDependencies(t, "testdata/cassettes/router.json", repository)
+	deps.RateLimiter = ratelimit.NewTokenBucket()
+	deps.Config.RateLimit.Default = rate.Limit{Requests: 100, Per: time.Minute}
+	deps.Config.RateLimit.Routes = map[string]rate.Limit{"getOrder": {Requests: 1, Per: time.Minute}}
+	router := NewRouter(deps)
+
+	serve := func(target string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, target, nil))
+		return rec
+	}
+
+	t.Run("limits routes to their configured limit", func(t *testing.T) {
+		assert.Equal(t, http.StatusOK, serve("/orders/1").Code)
+
+		rec := serve("/orders/1")
+		assert.Equal(t, http.StatusTooManyRequests, rec.Code)
+		assert.Equal(t, "1", rec.Header().Get(ratelimit.LimitHeader))
+		assert.Equal(t, "60", rec.Header().Get("Retry-After"))
+	})
+
+	t.Run("limits other routes to the default limit", func(t *testing.T) {
+		rec := serve("/orders")
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "100", rec.Header().Get(ratelimit.LimitHeader))
+		assert.Equal(t, "99", rec.Header().Get(ratelimit.RemainingHeader))
+	})
+
+	t.Run("does not limit health checks", func(t *testing.T) {
+		for i := 0; i < 3; i++ {
+			assert.Equal(t, http.StatusOK, serve("/ping").Code)
+		}
+		assert.Empty(t, serve("/ping").Header().Get(ratelimit.LimitHeader))
+	})
+}
@@ -0,0 +1,55 @@
+// Package idempotency makes retried requests safe: a request carrying an
+// Idempotency-Key header is processed once, and retries with the same key get
+// the response of the first attempt instead of repeating its effects.
+//
+// The Middleware records each key in a Store with a fingerprint of the request,
+// so that a key reused for a different request is rejected rather than
+// replaying an unrelated response.
+package idempotency
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+)
+
+var (
+	// ErrInProgress means a request with the key is still being processed.
+	ErrInProgress = errors.New("a request with this idempotency key is in progress")
+	// ErrMismatch means the key was used for a request with a different
+	// fingerprint.
+	ErrMismatch = errors.New("idempotency key was used for a different request")
+)
+
+// Key identifies a request. Keys chosen by clients are scoped, e.g. to the
+// endpoint, so that they cannot collide across scopes.
+type Key struct {
+	Scope string
+	Key   string
+}
+
+// Response is a response recorded to be replayed.
+type Response struct {
+	StatusCode int
+	Header     http.Header
+	Body       []byte
+}
+
+// Store records idempotency keys and the responses to their requests.
+type Store interface {
+	// Begin claims key for a request with fingerprint, for at most lockFor.
+	// It returns nil when the request should be processed, or the recorded
+	// response when it has been processed already. It fails with
+	// ErrInProgress when the key is claimed by another request, and
+	// ErrMismatch when the key was used for a request with a different
+	// fingerprint. Keys are forgotten after keepFor.
+	Begin(ctx context.Context, key Key, fingerprint string, lockFor, keepFor time.Duration) (*Response, error)
+
+	// Complete records the response to the request which claimed key.
+	Complete(ctx context.Context, key Key, response Response) error
+
+	// Release gives up the claim on key without recording a response, so that
+	// the request can be retried.
+	Release(ctx context.Context, key Key) error
+}
@@ -0,0 +1,227 @@
+package idempotency
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/deliveroo/bnt-internal-test-go/internal/httpserver/gorillautils"
+)
+
+const (
+	// Header carries the idempotency key chosen by the client.
+	Header = "Idempotency-Key"
+	// ReplayedHeader is set on responses replayed from the store.
+	ReplayedHeader = "Idempotent-Replayed"
+
+	// maxKeyLength is the length of the longest key accepted.
+	maxKeyLength = 255
+	// maxBodySize is the largest request body which can be fingerprinted.
+	maxBodySize = 1 << 20
+
+	defaultLockFor = 30 * time.Second
+	defaultKeepFor = 24 * time.Hour
+)
+
+type middleware struct {
+	store   Store
+	lockFor time.Duration
+	keepFor time.Duration
+	scope   func(r *http.Request) string
+	logger  *zap.Logger
+}
+
+// Option configures the Middleware.
+type Option func(*middleware)
+
+// WithLockFor sets how long a request holds its key, after which a retry may
+// process it again, e.g. because the instance processing it crashed. It should
+// exceed the longest time a request can take. Defaults to 30s.
+func WithLockFor(d time.Duration) Option {
+	return func(m *middleware) {
+		m.lockFor = d
+	}
+}
+
+// WithKeepFor sets how long responses are kept to be replayed. Defaults to
+// 24h.
+func WithKeepFor(d time.Duration) Option {
+	return func(m *middleware) {
+		m.keepFor = d
+	}
+}
+
+// WithScope sets how keys are scoped, e.g. to the client making the request.
+// Defaults to the method and path of the request.
+func WithScope(scope func(r *http.Request) string) Option {
+	return func(m *middleware) {
+		m.scope = scope
+	}
+}
+
+// WithLogger sets the logger for failures of the store. Defaults to a no-op
+// logger.
+func WithLogger(logger *zap.Logger) Option {
+	return func(m *middleware) {
+		m.logger = logger
+	}
+}
+
+// Middleware processes each request with an Idempotency-Key header once,
+// replaying the recorded response to retries. Requests without the header are
+// processed as usual. Server errors are not recorded, so that they can be
+// retried.
+func Middleware(store Store, opts ...Option) func(http.Handler) http.Handler {
+	m := &middleware{
+		store:   store,
+		lockFor: defaultLockFor,
+		keepFor: defaultKeepFor,
+		scope:   func(r *http.Request) string { return r.Method + " " + r.URL.Path },
+		logger:  zap.NewNop(),
+	}
+	for _, opt := range opts {
+		opt(m)
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			m.serve(next, w, r)
+		})
+	}
+}
+
+func (m *middleware) serve(next http.Handler, w http.ResponseWriter, r *http.Request) {
+	values, ok := r.Header[http.CanonicalHeaderKey(Header)]
+	if !ok {
+		next.ServeHTTP(w, r)
+		return
+	}
+	if len(values) != 1 || values[0] == "" || len(values[0]) > maxKeyLength {
+		renderProblem(w, http.StatusBadRequest, fmt.Sprintf("%s must be a single value of 1 to %d characters.", Header, maxKeyLength))
+		return
+	}
+
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxBodySize))
+	if err != nil {
+		renderProblem(w, http.StatusRequestEntityTooLarge, fmt.Sprintf("Requests with an %s must be at most %d bytes.", Header, maxBodySize))
+		return
+	}
+	r.Body = io.NopCloser(bytes.NewReader(body))
+
+	key := Key{Scope: m.scope(r), Key: values[0]}
+	recorded, err := m.store.Begin(r.Context(), key, fingerprint(r, body), m.lockFor, m.keepFor)
+	switch {
+	case errors.Is(err, ErrMismatch):
+		renderProblem(w, http.StatusUnprocessableEntity, "The "+Header+" was already used for a different request.")
+		return
+	case errors.Is(err, ErrInProgress):
+		w.Header().Set("Retry-After", "1")
+		renderProblem(w, http.StatusConflict, "A request with this "+Header+" is being processed.")
+		return
+	case err != nil:
+		m.logger.Error("failed to claim idempotency key", zap.Error(err))
+		renderProblem(w, http.StatusInternalServerError, "")
+		return
+	case recorded != nil:
+		replay(w, recorded)
+		return
+	}
+
+	rec := &recorder{ResponseWriter: w, status: http.StatusOK}
+	completed := false
+	defer func() {
+		// Release the key when the request panics or fails, so that it can be
+		// retried. The request context may be done by now.
+		if !completed {
+			if err := m.store.Release(context.Background(), key); err != nil {
+				m.logger.Error("failed to release idempotency key", zap.Error(err))
+			}
+		}
+	}()
+
+	next.ServeHTTP(rec, r)
+
+	if !rec.wroteHeader {
+		rec.header = recordedHeader(w.Header())
+	}
+	if rec.status >= http.StatusInternalServerError {
+		return
+	}
+	response := Response{StatusCode: rec.status, Header: rec.header, Body: rec.body.Bytes()}
+	if err := m.store.Complete(context.Background(), key, response); err != nil {
+		m.logger.Error("failed to record idempotent response", zap.Error(err))
+		return
+	}
+	completed = true
+}
+
+// fingerprint identifies the request, so that a key reused for a different
+// request can be told apart from a retry.
+func fingerprint(r *http.Request, body []byte) string {
+	hash := sha256.New()
+	// The response depends on the media type accepted, as well as the request.
+	_, _ = fmt.Fprintf(hash, "%s %s?%s\n%s\n", r.Method, r.URL.Path, r.URL.RawQuery, r.Header.Get("Accept"))
+	hash.Write(body)
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+func replay(w http.ResponseWriter, recorded *Response) {
+	for name, values := range recorded.Header {
+		w.Header()[name] = values
+	}
+	w.Header().Set(ReplayedHeader, "true")
+	w.WriteHeader(recorded.StatusCode)
+	_, _ = w.Write(recorded.Body)
+}
+
+func renderProblem(w http.ResponseWriter, status int, detail string) {
+	_ = gorillautils.RenderProblem(w, gorillautils.Problem{Status: status, Detail: detail})
+}
+
+// recorder records the response written through it.
+type recorder struct {
+	http.ResponseWriter
+	status      int
+	header      http.Header
+	body        bytes.Buffer
+	wroteHeader bool
+}
+
+func (r *recorder) WriteHeader(status int) {
+	if r.wroteHeader {
+		return
+	}
+	r.wroteHeader = true
+	r.status = status
+	r.header = recordedHeader(r.Header())
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *recorder) Write(p []byte) (int, error) {
+	r.WriteHeader(http.StatusOK)
+	r.body.Write(p)
+	return r.ResponseWriter.Write(p) //nolint:wrapcheck // passes on the error of the wrapped writer
+}
+
+// recordedHeader returns the headers worth replaying: those describing the
+// response rather than the connection it was sent on.
+func recordedHeader(header http.Header) http.Header {
+	recorded := http.Header{}
+	for name, values := range header {
+		switch strings.ToLower(name) {
+		case "date", "connection", "keep-alive", "transfer-encoding", "set-cookie":
+			continue
+		}
+		recorded[name] = append([]string(nil), values...)
+	}
+	return recorded
+}
@@ -0,0 +1,171 @@
+package idempotency_test
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/deliveroo/bnt-internal-test-go/internal/idempotency"
+)
+
+// stubStore records responses in memory. Unlike the Postgres store, it never
+// expires keys or takes over abandoned ones.
+type stubStore struct {
+	mu      sync.Mutex
+	claimed map[idempotency.Key]string
+	records map[idempotency.Key]idempotency.Response
+}
+
+func newStubStore() *stubStore {
+	return &stubStore{claimed: map[idempotency.Key]string{}, records: map[idempotency.Key]idempotency.Response{}}
+}
+
+func (s *stubStore) Begin(_ context.Context, key idempotency.Key, fingerprint string, _, _ time.Duration) (*idempotency.Response, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	claimed, ok := s.claimed[key]
+	switch {
+	case !ok:
+		s.claimed[key] = fingerprint
+		return nil, nil
+	case claimed != fingerprint:
+		return nil, idempotency.ErrMismatch
+	}
+	response, ok := s.records[key]
+	if !ok {
+		return nil, idempotency.ErrInProgress
+	}
+	return &response, nil
+}
+
+func (s *stubStore) Complete(_ context.Context, key idempotency.Key, response idempotency.Response) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.records[key] = response
+	return nil
+}
+
+func (s *stubStore) Release(_ context.Context, key idempotency.Key) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.records[key]; !ok {
+		delete(s.claimed, key)
+	}
+	return nil
+}
+
+func TestMiddleware(t *testing.T) {
+	var created atomic.Int32
+	create := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		id := created.Add(1)
+		w.Header().Set("Location", fmt.Sprintf("/orders/%d", id))
+		w.WriteHeader(http.StatusCreated)
+		_, _ = fmt.Fprintf(w, `{"ID":%d,"Request":%s}`, id, body)
+	})
+
+	post := func(handler http.Handler, key, body string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodPost, "/orders", strings.NewReader(body))
+		if key != "" {
+			r.Header.Set(idempotency.Header, key)
+		}
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		return w
+	}
+
+	t.Run("replays the response to a retry", func(t *testing.T) {
+		created.Store(0)
+		handler := idempotency.Middleware(newStubStore())(create)
+
+		first := post(handler, "abc", `{"Status":"NEW"}`)
+		retry := post(handler, "abc", `{"Status":"NEW"}`)
+
+		assert.Equal(t, int32(1), created.Load())
+		assert.Equal(t, http.StatusCreated, retry.Code)
+		assert.Equal(t, first.Body.String(), retry.Body.String())
+		assert.Equal(t, "/orders/1", retry.Header().Get("Location"))
+		assert.Equal(t, "true", retry.Header().Get(idempotency.ReplayedHeader))
+		assert.Empty(t, first.Header().Get(idempotency.ReplayedHeader))
+	})
+
+	t.Run("rejects a key reused for a different request", func(t *testing.T) {
+		handler := idempotency.Middleware(newStubStore())(create)
+
+		post(handler, "abc", `{"Status":"NEW"}`)
+		w := post(handler, "abc", `{"Status":"CANCELLED"}`)
+
+		assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+		assert.Equal(t, "application/problem+json", w.Header().Get("Content-Type"))
+	})
+
+	t.Run("rejects a key reused for a different media type", func(t *testing.T) {
+		handler := idempotency.Middleware(newStubStore())(create)
+
+		post(handler, "abc", `{"Status":"NEW"}`)
+		r := httptest.NewRequest(http.MethodPost, "/orders", strings.NewReader(`{"Status":"NEW"}`))
+		r.Header.Set(idempotency.Header, "abc")
+		r.Header.Set("Accept", "application/x-protobuf")
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+
+		assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+	})
+
+	t.Run("rejects a retry while the request is in progress", func(t *testing.T) {
+		store := newStubStore()
+		var handler http.Handler
+		var concurrent *httptest.ResponseRecorder
+		handler = idempotency.Middleware(store)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			concurrent = post(handler, "abc", `{}`)
+			w.WriteHeader(http.StatusCreated)
+		}))
+
+		post(handler, "abc", `{}`)
+
+		assert.Equal(t, http.StatusConflict, concurrent.Code)
+		assert.Equal(t, "1", concurrent.Header().Get("Retry-After"))
+	})
+
+	t.Run("lets server errors and panics be retried", func(t *testing.T) {
+		store := newStubStore()
+		failing := idempotency.Middleware(store)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}))
+		panicking := idempotency.Middleware(store)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			panic("boom")
+		}))
+
+		assert.Equal(t, http.StatusServiceUnavailable, post(failing, "abc", `{}`).Code)
+		assert.Panics(t, func() { post(panicking, "abc", `{}`) })
+		assert.Equal(t, http.StatusCreated, post(idempotency.Middleware(store)(create), "abc", `{}`).Code)
+	})
+
+	t.Run("processes requests without a key as usual", func(t *testing.T) {
+		created.Store(0)
+		handler := idempotency.Middleware(newStubStore())(create)
+
+		post(handler, "", `{}`)
+		post(handler, "", `{}`)
+
+		assert.Equal(t, int32(2), created.Load())
+	})
+
+	t.Run("rejects keys which are too long", func(t *testing.T) {
+		handler := idempotency.Middleware(newStubStore())(create)
+
+		w := post(handler, strings.Repeat("k", 256), `{}`)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+}
@@ -0,0 +1,128 @@
+package idempotency
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/deliveroo/bnt-internal-test-go/internal/database/dberrors"
+)
+
+// PostgresStore is a Store in the idempotency_keys table, shared by every
+// instance of the service.
+type PostgresStore struct {
+	pool *pgxpool.Pool
+}
+
+var _ Store = (*PostgresStore)(nil)
+
+// NewPostgresStore returns a Store in the database of pool, which must be
+// writable.
+func NewPostgresStore(pool *pgxpool.Pool) *PostgresStore {
+	return &PostgresStore{pool: pool}
+}
+
+// Begin claims key, taking over keys which have expired, and keys whose
+// request was abandoned before its lock ran out, e.g. by a crashed instance.
+func (s *PostgresStore) Begin(ctx context.Context, key Key, fingerprint string, lockFor, keepFor time.Duration) (*Response, error) {
+	var claimed bool
+	err := s.pool.QueryRow(ctx, `
+		INSERT INTO idempotency_keys (scope, key, fingerprint, locked_until, expires_at)
+		VALUES ($1, $2, $3, now() + $4::interval, now() + $5::interval)
+		ON CONFLICT (scope, key) DO UPDATE SET
+			fingerprint = EXCLUDED.fingerprint,
+			status_code = NULL,
+			headers = NULL,
+			body = NULL,
+			locked_until = EXCLUDED.locked_until,
+			expires_at = EXCLUDED.expires_at,
+			created_at = now()
+		WHERE idempotency_keys.expires_at < now()
+			OR (idempotency_keys.status_code IS NULL
+				AND idempotency_keys.locked_until < now()
+				AND idempotency_keys.fingerprint = EXCLUDED.fingerprint)
+		RETURNING true`,
+		key.Scope, key.Key, fingerprint, lockFor, keepFor,
+	).Scan(&claimed)
+	if err == nil {
+		return nil, nil
+	}
+	if !errors.Is(err, pgx.ErrNoRows) {
+		return nil, fmt.Errorf("failed to claim idempotency key: %w", dberrors.Map(err))
+	}
+
+	// The key is held by another request, so find out whether its response is
+	// ready to replay.
+	var (
+		recordedFingerprint string
+		statusCode          *int
+		response            Response
+	)
+	err = s.pool.QueryRow(ctx, `
+		SELECT fingerprint, status_code, headers, body FROM idempotency_keys
+		WHERE scope = $1 AND key = $2`,
+		key.Scope, key.Key,
+	).Scan(&recordedFingerprint, &statusCode, &response.Header, &response.Body)
+	if errors.Is(err, pgx.ErrNoRows) {
+		// The other request released the key in the meantime.
+		return nil, ErrInProgress
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read idempotency key: %w", dberrors.Map(err))
+	}
+
+	switch {
+	case recordedFingerprint != fingerprint:
+		return nil, ErrMismatch
+	case statusCode == nil:
+		return nil, ErrInProgress
+	}
+
+	response.StatusCode = *statusCode
+	if response.Header == nil {
+		response.Header = http.Header{}
+	}
+	return &response, nil
+}
+
+// Complete records the response to the request which claimed key.
+func (s *PostgresStore) Complete(ctx context.Context, key Key, response Response) error {
+	_, err := s.pool.Exec(ctx, `
+		UPDATE idempotency_keys
+		SET status_code = $3, headers = $4, body = $5, locked_until = NULL
+		WHERE scope = $1 AND key = $2`,
+		key.Scope, key.Key, response.StatusCode, response.Header, response.Body,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to record idempotent response: %w", dberrors.Map(err))
+	}
+	return nil
+}
+
+// Release forgets key, unless its response has been recorded.
+func (s *PostgresStore) Release(ctx context.Context, key Key) error {
+	_, err := s.pool.Exec(ctx, `
+		DELETE FROM idempotency_keys
+		WHERE scope = $1 AND key = $2 AND status_code IS NULL`,
+		key.Scope, key.Key,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to release idempotency key: %w", dberrors.Map(err))
+	}
+	return nil
+}
+
+// DeleteExpired deletes the keys which have expired, returning how many there
+// were.
+func (s *PostgresStore) DeleteExpired(ctx context.Context) (int64, error) {
+	tag, err := s.pool.Exec(ctx, `DELETE FROM idempotency_keys WHERE expires_at < now()`)
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete expired idempotency keys: %w", dberrors.Map(err))
+	}
+	return tag.RowsAffected(), nil
+}
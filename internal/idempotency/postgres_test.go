@@ -0,0 +1,113 @@
+package idempotency_test
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/deliveroo/bnt-internal-test-go/internal/database/databasetest"
+	"github.com/deliveroo/bnt-internal-test-go/internal/idempotency"
+)
+
+func TestPostgresStore(t *testing.T) {
+	ctx := context.Background()
+	key := idempotency.Key{Scope: "POST /orders", Key: "abc"}
+	response := idempotency.Response{
+		StatusCode: http.StatusCreated,
+		Header:     http.Header{"Location": {"/orders/1"}},
+		Body:       []byte(`{"ID":1}`),
+	}
+	newStore := func(t *testing.T) *idempotency.PostgresStore {
+		t.Helper()
+		return idempotency.NewPostgresStore(databasetest.NewPool(t))
+	}
+
+	t.Run("Begin claims a new key", func(t *testing.T) {
+		store := newStore(t)
+
+		recorded, err := store.Begin(ctx, key, "fingerprint", time.Minute, time.Hour)
+		assert.Nil(t, err)
+		assert.Nil(t, recorded)
+	})
+
+	t.Run("Begin rejects a key in progress", func(t *testing.T) {
+		store := newStore(t)
+		_, _ = store.Begin(ctx, key, "fingerprint", time.Minute, time.Hour)
+
+		_, err := store.Begin(ctx, key, "fingerprint", time.Minute, time.Hour)
+		assert.ErrorIs(t, err, idempotency.ErrInProgress)
+	})
+
+	t.Run("Begin returns the recorded response", func(t *testing.T) {
+		store := newStore(t)
+		_, _ = store.Begin(ctx, key, "fingerprint", time.Minute, time.Hour)
+		assert.Nil(t, store.Complete(ctx, key, response))
+
+		recorded, err := store.Begin(ctx, key, "fingerprint", time.Minute, time.Hour)
+		assert.Nil(t, err)
+		assert.Equal(t, &response, recorded)
+	})
+
+	t.Run("Begin rejects a key used for a different request", func(t *testing.T) {
+		store := newStore(t)
+		_, _ = store.Begin(ctx, key, "fingerprint", time.Minute, time.Hour)
+		assert.Nil(t, store.Complete(ctx, key, response))
+
+		_, err := store.Begin(ctx, key, "other", time.Minute, time.Hour)
+		assert.ErrorIs(t, err, idempotency.ErrMismatch)
+	})
+
+	t.Run("Begin scopes keys", func(t *testing.T) {
+		store := newStore(t)
+		_, _ = store.Begin(ctx, key, "fingerprint", time.Minute, time.Hour)
+
+		recorded, err := store.Begin(ctx, idempotency.Key{Scope: "POST /payments", Key: key.Key}, "other", time.Minute, time.Hour)
+		assert.Nil(t, err)
+		assert.Nil(t, recorded)
+	})
+
+	t.Run("Begin takes over a key whose lock ran out", func(t *testing.T) {
+		store := newStore(t)
+		_, _ = store.Begin(ctx, key, "fingerprint", time.Millisecond, time.Hour)
+		time.Sleep(10 * time.Millisecond)
+
+		recorded, err := store.Begin(ctx, key, "fingerprint", time.Minute, time.Hour)
+		assert.Nil(t, err)
+		assert.Nil(t, recorded)
+	})
+
+	t.Run("Begin takes over an expired key", func(t *testing.T) {
+		store := newStore(t)
+		_, _ = store.Begin(ctx, key, "fingerprint", time.Minute, time.Millisecond)
+		assert.Nil(t, store.Complete(ctx, key, response))
+		time.Sleep(10 * time.Millisecond)
+
+		recorded, err := store.Begin(ctx, key, "other", time.Minute, time.Hour)
+		assert.Nil(t, err)
+		assert.Nil(t, recorded)
+	})
+
+	t.Run("Release lets the key be claimed again", func(t *testing.T) {
+		store := newStore(t)
+		_, _ = store.Begin(ctx, key, "fingerprint", time.Minute, time.Hour)
+		assert.Nil(t, store.Release(ctx, key))
+
+		recorded, err := store.Begin(ctx, key, "other", time.Minute, time.Hour)
+		assert.Nil(t, err)
+		assert.Nil(t, recorded)
+	})
+
+	t.Run("Release keeps a recorded response", func(t *testing.T) {
+		store := newStore(t)
+		_, _ = store.Begin(ctx, key, "fingerprint", time.Minute, time.Hour)
+		assert.Nil(t, store.Complete(ctx, key, response))
+		assert.Nil(t, store.Release(ctx, key))
+
+		recorded, err := store.Begin(ctx, key, "fingerprint", time.Minute, time.Hour)
+		assert.Nil(t, err)
+		assert.Equal(t, &response, recorded)
+	})
+}
@@ -0,0 +1,183 @@
+// Package loadshed rejects requests beyond the concurrency the service can
+// handle, so that it fails some requests fast under overload rather than
+// queueing every request until they all time out.
+//
+// A Limiter admits up to a limit of concurrent requests, which it adapts to
+// their latency: additive increase, multiplicative decrease (AIMD). While
+// requests complete within the target latency and the limit is in use, it
+// grows by one for every limit's worth of requests; when a request is slow, or
+// reports overload, it shrinks by a factor. Middleware enforces the limit on
+// HTTP requests.
+package loadshed
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/deliveroo/apm-go"
+)
+
+// metricPrefix starts the names of the metrics reported by the limiter and
+// the middleware.
+const metricPrefix = "loadshed."
+
+const (
+	defaultInitialLimit  = 100
+	defaultMinLimit      = 10
+	defaultMaxLimit      = 1000
+	defaultTargetLatency = 500 * time.Millisecond
+	defaultBackoff       = 0.9
+)
+
+// Limiter admits up to an adaptive limit of concurrent requests.
+type Limiter struct {
+	minLimit      float64
+	maxLimit      float64
+	targetLatency time.Duration
+	backoff       float64
+	now           func() time.Time
+
+	mu       sync.Mutex
+	limit    float64
+	inflight int
+	// decreasedAt is when the limit last decreased. Requests which started
+	// before then do not decrease it again, so that a burst of slow requests
+	// backs off once rather than once per request.
+	decreasedAt time.Time
+}
+
+// Option configures a Limiter.
+type Option func(*Limiter)
+
+// WithInitialLimit sets the limit the limiter starts with. Defaults to 100.
+func WithInitialLimit(limit int) Option {
+	return func(l *Limiter) {
+		l.limit = float64(limit)
+	}
+}
+
+// WithLimits sets the bounds of the limit. Defaults to 10 and 1000.
+func WithLimits(minLimit, maxLimit int) Option {
+	return func(l *Limiter) {
+		l.minLimit = float64(minLimit)
+		l.maxLimit = float64(maxLimit)
+	}
+}
+
+// WithTargetLatency sets the latency beyond which requests decrease the
+// limit. It should exceed the latency of healthy requests. Defaults to 500ms.
+func WithTargetLatency(d time.Duration) Option {
+	return func(l *Limiter) {
+		l.targetLatency = d
+	}
+}
+
+// WithBackoff sets the factor the limit is multiplied by when it decreases,
+// between 0 and 1. Defaults to 0.9.
+func WithBackoff(factor float64) Option {
+	return func(l *Limiter) {
+		l.backoff = factor
+	}
+}
+
+// WithClock sets the source of the current time. Defaults to time.Now.
+func WithClock(now func() time.Time) Option {
+	return func(l *Limiter) {
+		l.now = now
+	}
+}
+
+// NewLimiter returns a Limiter with no requests in flight.
+func NewLimiter(opts ...Option) *Limiter {
+	l := &Limiter{
+		limit:         defaultInitialLimit,
+		minLimit:      defaultMinLimit,
+		maxLimit:      defaultMaxLimit,
+		targetLatency: defaultTargetLatency,
+		backoff:       defaultBackoff,
+		now:           time.Now,
+	}
+	for _, opt := range opts {
+		opt(l)
+	}
+	l.limit = math.Max(l.minLimit, math.Min(l.maxLimit, l.limit))
+	return l
+}
+
+// Acquire admits a request when fewer than the limit are in flight. The
+// request must call done when it completes, saying whether it was overloaded
+// regardless of its latency, e.g. because a dependency timed out.
+func (l *Limiter) Acquire() (done func(overloaded bool), ok bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.inflight >= int(l.limit) {
+		return nil, false
+	}
+	l.inflight++
+
+	start := l.now()
+	var once sync.Once
+	return func(overloaded bool) {
+		once.Do(func() { l.release(start, overloaded) })
+	}, true
+}
+
+// release completes a request which started at start, adapting the limit to
+// its outcome.
+func (l *Limiter) release(start time.Time, overloaded bool) {
+	now := l.now()
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	inflight := l.inflight
+	l.inflight--
+
+	switch {
+	case overloaded || now.Sub(start) > l.targetLatency:
+		if start.Before(l.decreasedAt) {
+			return
+		}
+		l.limit = math.Max(l.minLimit, l.limit*l.backoff)
+		l.decreasedAt = now
+	case float64(inflight)*2 >= l.limit:
+		// Only grow a limit in use, so that it does not run away while the
+		// service is idle.
+		l.limit = math.Min(l.maxLimit, l.limit+1/l.limit)
+	}
+}
+
+// Limit returns the current limit of concurrent requests.
+func (l *Limiter) Limit() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return int(l.limit)
+}
+
+// Inflight returns the number of requests in flight.
+func (l *Limiter) Inflight() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.inflight
+}
+
+// ReportStats reports the limit and the requests in flight to metrics every
+// interval, until ctx is done.
+func (l *Limiter) ReportStats(ctx context.Context, metrics apm.Metrics, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+
+		metrics.Gauge(metricPrefix+"limit", float64(l.Limit()), 1)
+		metrics.Gauge(metricPrefix+"inflight", float64(l.Inflight()), 1)
+	}
+}
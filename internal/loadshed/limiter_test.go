@@ -0,0 +1,110 @@
+package loadshed_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/deliveroo/bnt-internal-test-go/internal/loadshed"
+)
+
+func TestLimiter(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	clock := func() time.Time { return now }
+	newLimiter := func() *loadshed.Limiter {
+		return loadshed.NewLimiter(
+			loadshed.WithInitialLimit(4),
+			loadshed.WithLimits(2, 5),
+			loadshed.WithTargetLatency(100*time.Millisecond),
+			loadshed.WithBackoff(0.5),
+			loadshed.WithClock(clock),
+		)
+	}
+	// acquire admits n requests, failing the test if any is rejected.
+	acquire := func(t *testing.T, l *loadshed.Limiter, n int) []func(bool) {
+		t.Helper()
+		var dones []func(bool)
+		for i := 0; i < n; i++ {
+			done, ok := l.Acquire()
+			if !assert.True(t, ok) {
+				return dones
+			}
+			dones = append(dones, done)
+		}
+		return dones
+	}
+
+	t.Run("admits requests up to the limit", func(t *testing.T) {
+		l := newLimiter()
+		dones := acquire(t, l, 4)
+
+		_, ok := l.Acquire()
+		assert.False(t, ok)
+		assert.Equal(t, 4, l.Inflight())
+
+		dones[0](false)
+		_, ok = l.Acquire()
+		assert.True(t, ok)
+	})
+
+	t.Run("decreases the limit once for a burst of slow requests", func(t *testing.T) {
+		l := newLimiter()
+		dones := acquire(t, l, 3)
+		now = now.Add(time.Second)
+
+		for _, done := range dones {
+			done(false)
+		}
+		assert.Equal(t, 2, l.Limit())
+		assert.Equal(t, 0, l.Inflight())
+	})
+
+	t.Run("decreases the limit for overloaded requests", func(t *testing.T) {
+		l := newLimiter()
+		done, _ := l.Acquire()
+		done(true)
+
+		assert.Equal(t, 2, l.Limit())
+	})
+
+	t.Run("does not decrease below the minimum", func(t *testing.T) {
+		l := newLimiter()
+		for i := 0; i < 5; i++ {
+			done, _ := l.Acquire()
+			done(true)
+		}
+
+		assert.Equal(t, 2, l.Limit())
+	})
+
+	t.Run("increases a limit in use while requests are fast", func(t *testing.T) {
+		l := newLimiter()
+		for i := 0; i < 20; i++ {
+			for _, done := range acquire(t, l, l.Limit()) {
+				done(false)
+			}
+		}
+
+		assert.Equal(t, 5, l.Limit(), "up to the maximum")
+	})
+
+	t.Run("does not increase an idle limit", func(t *testing.T) {
+		l := newLimiter()
+		for i := 0; i < 20; i++ {
+			done, _ := l.Acquire()
+			done(false)
+		}
+
+		assert.Equal(t, 4, l.Limit())
+	})
+
+	t.Run("ignores repeated calls to done", func(t *testing.T) {
+		l := newLimiter()
+		done, _ := l.Acquire()
+		done(false)
+		done(false)
+
+		assert.Equal(t, 0, l.Inflight())
+	})
+}
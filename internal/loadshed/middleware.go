@@ -0,0 +1,93 @@
+package loadshed
+
+import (
+	"net/http"
+
+	"github.com/deliveroo/apm-go"
+	"github.com/deliveroo/bnt-internal-test-go/internal/httpserver/gorillautils"
+)
+
+type middleware struct {
+	limiter *Limiter
+	metrics apm.Metrics
+	exempt  func(r *http.Request) bool
+}
+
+// MiddlewareOption configures the Middleware.
+type MiddlewareOption func(*middleware)
+
+// WithExempt exempts the requests for which exempt returns true from the
+// limit, e.g. health checks, which must respond even under overload.
+func WithExempt(exempt func(r *http.Request) bool) MiddlewareOption {
+	return func(m *middleware) {
+		m.exempt = exempt
+	}
+}
+
+// Middleware rejects requests beyond the limit of limiter with 503 Service
+// Unavailable and a Retry-After header, counting them in loadshed.rejected.
+// Responses with 503 or 504 report overload to the limiter, as they are sent
+// when the service or its dependencies are overloaded.
+func Middleware(limiter *Limiter, metrics apm.Metrics, opts ...MiddlewareOption) func(http.Handler) http.Handler {
+	m := &middleware{
+		limiter: limiter,
+		metrics: metrics,
+		exempt:  func(*http.Request) bool { return false },
+	}
+	for _, opt := range opts {
+		opt(m)
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			m.serve(next, w, r)
+		})
+	}
+}
+
+func (m *middleware) serve(next http.Handler, w http.ResponseWriter, r *http.Request) {
+	if m.exempt(r) {
+		next.ServeHTTP(w, r)
+		return
+	}
+
+	done, ok := m.limiter.Acquire()
+	if !ok {
+		m.metrics.Incr(metricPrefix+"rejected", 1)
+		w.Header().Set("Retry-After", "1")
+		_ = gorillautils.RenderProblem(w, gorillautils.Problem{
+			Status: http.StatusServiceUnavailable,
+			Detail: "The service is overloaded. Retry shortly.",
+		})
+		return
+	}
+
+	rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+	overloaded := true
+	// A panicking request counts as overloaded.
+	defer func() { done(overloaded) }()
+
+	next.ServeHTTP(rec, r)
+
+	overloaded = rec.status == http.StatusServiceUnavailable || rec.status == http.StatusGatewayTimeout
+}
+
+// statusRecorder records the status code written through it.
+type statusRecorder struct {
+	http.ResponseWriter
+	status      int
+	wroteHeader bool
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	if !r.wroteHeader {
+		r.wroteHeader = true
+		r.status = status
+	}
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *statusRecorder) Write(p []byte) (int, error) {
+	r.wroteHeader = true
+	return r.ResponseWriter.Write(p) //nolint:wrapcheck // passes on the error of the wrapped writer
+}
@@ -0,0 +1,73 @@
+package loadshed_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/deliveroo/bnt-internal-test-go/internal/loadshed"
+	"github.com/deliveroo/bnt-internal-test-go/internal/metricstest"
+)
+
+func TestMiddleware(t *testing.T) {
+	serve := func(handler http.Handler, target string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, target, nil))
+		return rec
+	}
+	// blocking serves requests until release is closed.
+	blocking := func(release chan struct{}, started *sync.WaitGroup) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			started.Done()
+			<-release
+		})
+	}
+
+	t.Run("rejects requests beyond the limit fast", func(t *testing.T) {
+		limiter := loadshed.NewLimiter(loadshed.WithInitialLimit(1), loadshed.WithLimits(1, 1))
+		metrics := metricstest.NewRecorder()
+		release := make(chan struct{})
+		var started sync.WaitGroup
+		started.Add(1)
+		handler := loadshed.Middleware(limiter, metrics)(blocking(release, &started))
+
+		go serve(handler, "/orders")
+		started.Wait()
+		rec := serve(handler, "/orders")
+		close(release)
+
+		assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+		assert.Equal(t, "1", rec.Header().Get("Retry-After"))
+		assert.Equal(t, "application/problem+json", rec.Header().Get("Content-Type"))
+		assert.Equal(t, 1, metrics.Calls("loadshed.rejected"))
+	})
+
+	t.Run("serves exempt requests regardless", func(t *testing.T) {
+		limiter := loadshed.NewLimiter(loadshed.WithInitialLimit(1), loadshed.WithLimits(1, 1))
+		release := make(chan struct{})
+		var started sync.WaitGroup
+		started.Add(2)
+		exempt := loadshed.WithExempt(func(r *http.Request) bool { return strings.HasPrefix(r.URL.Path, "/ping") })
+		handler := loadshed.Middleware(limiter, metricstest.NewRecorder(), exempt)(blocking(release, &started))
+
+		go serve(handler, "/orders")
+		go serve(handler, "/ping")
+		started.Wait()
+		close(release)
+	})
+
+	t.Run("reports overloaded responses to the limiter", func(t *testing.T) {
+		limiter := loadshed.NewLimiter(loadshed.WithInitialLimit(100), loadshed.WithBackoff(0.5))
+		handler := loadshed.Middleware(limiter, metricstest.NewRecorder())(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusGatewayTimeout)
+		}))
+
+		assert.Equal(t, http.StatusGatewayTimeout, serve(handler, "/orders").Code)
+		assert.Equal(t, 50, limiter.Limit())
+		assert.Equal(t, 0, limiter.Inflight())
+	})
+}
@@ -0,0 +1,88 @@
+// Package logging builds the application logger. Its level can be changed while
+// it is in use, both overall and for individual named loggers, such as one
+// created with logger.Named("orders").
+package logging
+
+import (
+	"strings"
+	"sync/atomic"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// Levels holds the level of the logger, and the levels of named loggers which
+// override it.
+type Levels struct {
+	level     zap.AtomicLevel
+	overrides atomic.Pointer[map[string]zapcore.Level]
+	// minimum is the lowest of level and overrides, below which nothing is
+	// logged.
+	minimum atomic.Int32
+}
+
+// NewLevels returns Levels logging at level, without overrides.
+func NewLevels(level zapcore.Level) *Levels {
+	l := &Levels{level: zap.NewAtomicLevelAt(level)}
+	l.SetOverrides(nil)
+	return l
+}
+
+// Level returns the level of loggers without an override.
+func (l *Levels) Level() zapcore.Level {
+	return l.level.Level()
+}
+
+// SetLevel changes the level of loggers without an override.
+func (l *Levels) SetLevel(level zapcore.Level) {
+	l.level.SetLevel(level)
+	l.updateMinimum()
+}
+
+// Overrides returns the levels of named loggers.
+func (l *Levels) Overrides() map[string]zapcore.Level {
+	out := map[string]zapcore.Level{}
+	for name, level := range *l.overrides.Load() {
+		out[name] = level
+	}
+	return out
+}
+
+// SetOverrides replaces the levels of named loggers. An override applies to
+// the logger with that name and to the loggers named under it, so "orders"
+// applies to "orders.repository" too.
+func (l *Levels) SetOverrides(overrides map[string]zapcore.Level) {
+	copied := make(map[string]zapcore.Level, len(overrides))
+	for name, level := range overrides {
+		copied[name] = level
+	}
+	l.overrides.Store(&copied)
+	l.updateMinimum()
+}
+
+// LevelFor returns the level of the logger called name, which is set by the
+// override for the longest matching name.
+func (l *Levels) LevelFor(name string) zapcore.Level {
+	level, matched := l.Level(), ""
+	for prefix, override := range *l.overrides.Load() {
+		if (name == prefix || strings.HasPrefix(name, prefix+".")) && len(prefix) > len(matched) {
+			level, matched = override, prefix
+		}
+	}
+	return level
+}
+
+// Enabled reports whether any logger logs at level.
+func (l *Levels) Enabled(level zapcore.Level) bool {
+	return level >= zapcore.Level(l.minimum.Load())
+}
+
+func (l *Levels) updateMinimum() {
+	minimum := l.Level()
+	for _, level := range *l.overrides.Load() {
+		if level < minimum {
+			minimum = level
+		}
+	}
+	l.minimum.Store(int32(minimum))
+}
@@ -0,0 +1,62 @@
+package logging
+
+import (
+	"fmt"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// New returns a logger which logs at levels, formatted for development or
+// production. Secrets are removed from entries by scrubber.
+func New(development bool, levels *Levels, scrubber *Scrubber) (*zap.Logger, error) {
+	cfg := zap.NewProductionConfig()
+	if development {
+		cfg = zap.NewDevelopmentConfig()
+	}
+	// Levels are applied by the core wrapping the one zap builds.
+	cfg.Level = zap.NewAtomicLevelAt(zapcore.DebugLevel)
+
+	logger, err := cfg.Build(zap.WrapCore(func(c zapcore.Core) zapcore.Core {
+		return NewScrubbingCore(NewCore(c, levels), scrubber)
+	}))
+	if err != nil {
+		return nil, fmt.Errorf("failed to build logger: %w", err)
+	}
+
+	return logger, nil
+}
+
+// DefaultLevel returns the level logged at when none is configured.
+func DefaultLevel(development bool) zapcore.Level {
+	if development {
+		return zapcore.DebugLevel
+	}
+	return zapcore.InfoLevel
+}
+
+type core struct {
+	zapcore.Core
+	levels *Levels
+}
+
+// NewCore wraps inner so that entries are only written when they are enabled
+// by levels, for the logger which wrote them.
+func NewCore(inner zapcore.Core, levels *Levels) zapcore.Core {
+	return &core{Core: inner, levels: levels}
+}
+
+func (c *core) Enabled(level zapcore.Level) bool {
+	return c.levels.Enabled(level) && c.Core.Enabled(level)
+}
+
+func (c *core) With(fields []zapcore.Field) zapcore.Core {
+	return &core{Core: c.Core.With(fields), levels: c.levels}
+}
+
+func (c *core) Check(entry zapcore.Entry, checked *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if entry.Level < c.levels.LevelFor(entry.LoggerName) {
+		return checked
+	}
+	return c.Core.Check(entry, checked)
+}
@@ -0,0 +1,53 @@
+package logging
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestCore(t *testing.T) {
+	levels := NewLevels(zapcore.InfoLevel)
+	inner, logs := observer.New(zapcore.DebugLevel)
+	logger := zap.New(NewCore(inner, levels))
+
+	messages := func() []string {
+		var out []string
+		for _, entry := range logs.TakeAll() {
+			out = append(out, entry.LoggerName+":"+entry.Message)
+		}
+		return out
+	}
+
+	t.Run("logs at the overall level", func(t *testing.T) {
+		logger.Debug("hidden")
+		logger.Info("shown")
+		assert.Equal(t, []string{":shown"}, messages())
+	})
+
+	t.Run("follows changes to the level", func(t *testing.T) {
+		levels.SetLevel(zapcore.DebugLevel)
+		logger.Debug("shown")
+		levels.SetLevel(zapcore.InfoLevel)
+		logger.Debug("hidden")
+		assert.Equal(t, []string{":shown"}, messages())
+	})
+
+	t.Run("applies overrides to named loggers and their children", func(t *testing.T) {
+		levels.SetOverrides(map[string]zapcore.Level{
+			"orders":            zapcore.DebugLevel,
+			"orders.repository": zapcore.ErrorLevel,
+		})
+
+		logger.Named("orders").Debug("shown")
+		logger.Named("orders").Named("handlers").With(zap.Int("id", 1)).Debug("shown")
+		logger.Named("orders").Named("repository").Warn("hidden")
+		logger.Named("ordersfeed").Debug("hidden")
+		logger.Debug("hidden")
+
+		assert.Equal(t, []string{"orders:shown", "orders.handlers:shown"}, messages())
+	})
+}
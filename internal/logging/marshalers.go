@@ -0,0 +1,92 @@
+package logging
+
+import (
+	"go.uber.org/zap/zapcore"
+)
+
+// scrubbedObject encodes an object with secrets removed from the strings and
+// reflected values it holds, including those of nested objects and arrays.
+type scrubbedObject struct {
+	marshaler zapcore.ObjectMarshaler
+	scrubber  *Scrubber
+}
+
+func (o scrubbedObject) MarshalLogObject(enc zapcore.ObjectEncoder) error {
+	return o.marshaler.MarshalLogObject(objectEncoder{ObjectEncoder: enc, scrubber: o.scrubber}) //nolint:wrapcheck // errors come from the wrapped marshaler
+}
+
+// scrubbedArray encodes an array with secrets removed from the strings and
+// reflected values it holds, including those of nested objects and arrays.
+type scrubbedArray struct {
+	marshaler zapcore.ArrayMarshaler
+	scrubber  *Scrubber
+}
+
+func (a scrubbedArray) MarshalLogArray(enc zapcore.ArrayEncoder) error {
+	return a.marshaler.MarshalLogArray(arrayEncoder{ArrayEncoder: enc, scrubber: a.scrubber}) //nolint:wrapcheck // errors come from the wrapped marshaler
+}
+
+// objectEncoder removes secrets from the values added to an object. Values of
+// keys which name secrets are removed entirely, as Scrubber.Value does.
+type objectEncoder struct {
+	zapcore.ObjectEncoder
+	scrubber *Scrubber
+}
+
+func (e objectEncoder) AddString(key, value string) {
+	e.ObjectEncoder.AddString(key, e.scrubber.Value(key, value))
+}
+
+func (e objectEncoder) AddByteString(key string, value []byte) {
+	e.ObjectEncoder.AddString(key, e.scrubber.Value(key, string(value)))
+}
+
+func (e objectEncoder) AddReflected(key string, value interface{}) error {
+	if sensitiveKey.MatchString(key) {
+		e.ObjectEncoder.AddString(key, Redacted)
+		return nil
+	}
+	return e.ObjectEncoder.AddReflected(key, e.scrubber.reflected(value)) //nolint:wrapcheck // errors come from the wrapped encoder
+}
+
+func (e objectEncoder) AddObject(key string, marshaler zapcore.ObjectMarshaler) error {
+	if sensitiveKey.MatchString(key) {
+		e.ObjectEncoder.AddString(key, Redacted)
+		return nil
+	}
+	return e.ObjectEncoder.AddObject(key, scrubbedObject{marshaler: marshaler, scrubber: e.scrubber}) //nolint:wrapcheck // errors come from the wrapped encoder
+}
+
+func (e objectEncoder) AddArray(key string, marshaler zapcore.ArrayMarshaler) error {
+	if sensitiveKey.MatchString(key) {
+		e.ObjectEncoder.AddString(key, Redacted)
+		return nil
+	}
+	return e.ObjectEncoder.AddArray(key, scrubbedArray{marshaler: marshaler, scrubber: e.scrubber}) //nolint:wrapcheck // errors come from the wrapped encoder
+}
+
+// arrayEncoder removes secrets from the values appended to an array.
+type arrayEncoder struct {
+	zapcore.ArrayEncoder
+	scrubber *Scrubber
+}
+
+func (e arrayEncoder) AppendString(value string) {
+	e.ArrayEncoder.AppendString(e.scrubber.String(value))
+}
+
+func (e arrayEncoder) AppendByteString(value []byte) {
+	e.ArrayEncoder.AppendString(e.scrubber.String(string(value)))
+}
+
+func (e arrayEncoder) AppendReflected(value interface{}) error {
+	return e.ArrayEncoder.AppendReflected(e.scrubber.reflected(value)) //nolint:wrapcheck // errors come from the wrapped encoder
+}
+
+func (e arrayEncoder) AppendObject(marshaler zapcore.ObjectMarshaler) error {
+	return e.ArrayEncoder.AppendObject(scrubbedObject{marshaler: marshaler, scrubber: e.scrubber}) //nolint:wrapcheck // errors come from the wrapped encoder
+}
+
+func (e arrayEncoder) AppendArray(marshaler zapcore.ArrayMarshaler) error {
+	return e.ArrayEncoder.AppendArray(scrubbedArray{marshaler: marshaler, scrubber: e.scrubber}) //nolint:wrapcheck // errors come from the wrapped encoder
+}
@@ -0,0 +1,86 @@
+// Package metricstest records the metrics reported in tests.
+package metricstest
+
+import (
+	"sync"
+	"time"
+
+	"github.com/deliveroo/apm-go"
+)
+
+// Recorder is an apm.Metrics which records how often each metric is
+// reported, and the value and tags it was last reported with. Events are
+// discarded. It is safe for concurrent use.
+type Recorder struct {
+	mu    sync.Mutex
+	calls map[string]int
+	last  map[string]report
+}
+
+type report struct {
+	value float64
+	tags  []string
+}
+
+var _ apm.Metrics = (*Recorder)(nil)
+
+// NewRecorder returns a Recorder which has recorded nothing.
+func NewRecorder() *Recorder {
+	return &Recorder{calls: map[string]int{}, last: map[string]report{}}
+}
+
+// Calls returns how many times the metric called name was reported.
+func (r *Recorder) Calls(name string) int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.calls[name]
+}
+
+// Value returns the value the metric called name was last reported with.
+// Increments have a value of 1, and timings are in milliseconds.
+func (r *Recorder) Value(name string) float64 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.last[name].value
+}
+
+// Tags returns the tags the metric called name was last reported with.
+func (r *Recorder) Tags(name string) []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.last[name].tags
+}
+
+func (r *Recorder) record(name string, value float64, tagPairs []string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.calls[name]++
+	// Callers may reuse the backing array of their tags.
+	r.last[name] = report{value: value, tags: append([]string(nil), tagPairs...)}
+}
+
+func (r *Recorder) Count(name string, value int64, _ float64, tagPairs ...string) {
+	r.record(name, float64(value), tagPairs)
+}
+
+func (r *Recorder) Distribution(name string, value, _ float64, tagPairs ...string) {
+	r.record(name, value, tagPairs)
+}
+
+func (r *Recorder) Event(string, string, apm.EventOptions) {}
+
+func (r *Recorder) Gauge(name string, value, _ float64, tagPairs ...string) {
+	r.record(name, value, tagPairs)
+}
+
+func (r *Recorder) Histogram(name string, value, _ float64, tagPairs ...string) {
+	r.record(name, value, tagPairs)
+}
+
+func (r *Recorder) Incr(name string, _ float64, tagPairs ...string) {
+	r.record(name, 1, tagPairs)
+}
+
+func (r *Recorder) Timing(name string, value time.Duration, _ float64, tagPairs ...string) {
+	r.record(name, float64(value.Milliseconds()), tagPairs)
+}
@@ -0,0 +1,178 @@
+package ordertest
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/deliveroo/bnt-internal-test-go/internal/orders"
+	"github.com/deliveroo/bnt-internal-test-go/internal/pagination"
+)
+
+// RepositoryFactory returns an empty orders.Repository seeded with the given
+// orders. It is called once per conformance test.
+type RepositoryFactory func(t *testing.T, seed ...orders.Order) orders.Repository
+
+// RunConformanceTests verifies that a Repository implementation behaves like
+// every other. Run it against each implementation so they can't drift apart.
+func RunConformanceTests(t *testing.T, newRepository RepositoryFactory) {
+	t.Helper()
+
+	t.Run("GetOrder returns a stored order", func(t *testing.T) {
+		order := NewOrder(WithID(42), WithStatus("FULFILLED"))
+		repository := newRepository(t, order)
+
+		got, err := repository.GetOrder(context.Background(), 42)
+		assert.Nil(t, err)
+		assert.Equal(t, &order, got)
+	})
+
+	t.Run("GetOrder returns nil without an error when the order does not exist", func(t *testing.T) {
+		repository := newRepository(t, NewOrder(WithID(1)))
+
+		got, err := repository.GetOrder(context.Background(), 2)
+		assert.Nil(t, err)
+		assert.Nil(t, got)
+	})
+
+	t.Run("GetOrder returns a copy which callers may modify", func(t *testing.T) {
+		repository := newRepository(t, NewOrder(WithID(7), WithStatus("NEW")))
+
+		got, err := repository.GetOrder(context.Background(), 7)
+		assert.Nil(t, err)
+		got.Status = "CANCELLED"
+
+		again, err := repository.GetOrder(context.Background(), 7)
+		assert.Nil(t, err)
+		assert.Equal(t, "NEW", again.Status)
+	})
+
+	t.Run("GetOrder fails when the context is cancelled", func(t *testing.T) {
+		repository := newRepository(t, NewOrder(WithID(3)))
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		got, err := repository.GetOrder(ctx, 3)
+		assert.NotNil(t, err)
+		assert.Nil(t, got)
+	})
+
+	t.Run("ListOrders pages through orders newest first", func(t *testing.T) {
+		// Orders 3 and 4 were created at the same time, so are ordered by ID.
+		repository := newRepository(t,
+			NewOrder(WithID(1), WithCreatedAt(CreatedAt)),
+			NewOrder(WithID(2), WithCreatedAt(CreatedAt.Add(time.Minute))),
+			NewOrder(WithID(3), WithCreatedAt(CreatedAt.Add(2*time.Minute))),
+			NewOrder(WithID(4), WithCreatedAt(CreatedAt.Add(2*time.Minute))),
+			NewOrder(WithID(5), WithCreatedAt(CreatedAt.Add(3*time.Minute))),
+		)
+		ids := func(list []orders.Order) []int {
+			var ids []int
+			for _, order := range list {
+				ids = append(ids, order.ID)
+			}
+			return ids
+		}
+
+		first, result, err := repository.ListOrders(context.Background(), pagination.Request{Limit: 2})
+		assert.Nil(t, err)
+		assert.Equal(t, []int{5, 4}, ids(first))
+		assert.Nil(t, result.Prev)
+
+		second, result, err := repository.ListOrders(context.Background(), pagination.Request{Limit: 2, Cursor: result.Next})
+		assert.Nil(t, err)
+		assert.Equal(t, []int{3, 2}, ids(second))
+		assert.NotNil(t, result.Prev)
+
+		last, result, err := repository.ListOrders(context.Background(), pagination.Request{Limit: 2, Cursor: result.Next})
+		assert.Nil(t, err)
+		assert.Equal(t, []int{1}, ids(last))
+		assert.Nil(t, result.Next)
+
+		back, result, err := repository.ListOrders(context.Background(), pagination.Request{Limit: 2, Cursor: result.Prev})
+		assert.Nil(t, err)
+		assert.Equal(t, []int{3, 2}, ids(back))
+		assert.NotNil(t, result.Next)
+
+		back, result, err = repository.ListOrders(context.Background(), pagination.Request{Limit: 2, Cursor: result.Prev})
+		assert.Nil(t, err)
+		assert.Equal(t, []int{5, 4}, ids(back))
+		assert.Nil(t, result.Prev)
+		assert.NotNil(t, result.Next)
+	})
+
+	t.Run("ListOrders returns nothing when there are no orders", func(t *testing.T) {
+		repository := newRepository(t)
+
+		list, result, err := repository.ListOrders(context.Background(), pagination.Request{Limit: 2})
+		assert.Nil(t, err)
+		assert.Empty(t, list)
+		assert.Equal(t, pagination.Result{}, result)
+	})
+
+	t.Run("ListOrders rejects cursors which are not positions in lists of orders", func(t *testing.T) {
+		repository := newRepository(t, NewOrder(WithID(1)))
+
+		_, _, err := repository.ListOrders(context.Background(), pagination.Request{Limit: 2, Cursor: &pagination.Cursor{SortKey: "yesterday", ID: 1}})
+		assert.ErrorIs(t, err, pagination.ErrInvalidCursor)
+	})
+
+	t.Run("CreateOrder stores an order with a new ID", func(t *testing.T) {
+		repository := newRepository(t, NewOrder(WithID(1)))
+
+		created, err := repository.CreateOrder(context.Background(), NewOrder(WithStatus("PLACED")))
+		assert.Nil(t, err)
+		assert.NotEqual(t, 1, created.ID)
+		assert.Equal(t, "PLACED", created.Status)
+		assert.False(t, created.CreatedAt.IsZero())
+
+		got, err := repository.GetOrder(context.Background(), created.ID)
+		assert.Nil(t, err)
+		assert.Equal(t, created, got)
+	})
+
+	t.Run("CreateOrder stores the restaurant of an order", func(t *testing.T) {
+		repository := newRepository(t)
+
+		created, err := repository.CreateOrder(context.Background(), NewOrder(WithRestaurantID("r-1")))
+		assert.Nil(t, err)
+		assert.Equal(t, "r-1", created.RestaurantID)
+
+		got, err := repository.GetOrder(context.Background(), created.ID)
+		assert.Nil(t, err)
+		assert.Equal(t, "r-1", got.RestaurantID)
+	})
+
+	t.Run("UpdateOrderStatus changes the status of an order", func(t *testing.T) {
+		order := NewOrder(WithID(5), WithRestaurantID("r-1"))
+		repository := newRepository(t, order)
+
+		updated, err := repository.UpdateOrderStatus(context.Background(), 5, "NEW", "CANCELLED")
+		assert.Nil(t, err)
+		order.Status = "CANCELLED"
+		assert.Equal(t, &order, updated)
+
+		got, err := repository.GetOrder(context.Background(), 5)
+		assert.Nil(t, err)
+		assert.Equal(t, &order, got)
+	})
+
+	t.Run("UpdateOrderStatus returns nil when the order does not have the status", func(t *testing.T) {
+		repository := newRepository(t, NewOrder(WithID(5), WithStatus("FULFILLED")))
+
+		updated, err := repository.UpdateOrderStatus(context.Background(), 5, "NEW", "CANCELLED")
+		assert.Nil(t, err)
+		assert.Nil(t, updated)
+
+		updated, err = repository.UpdateOrderStatus(context.Background(), 6, "NEW", "CANCELLED")
+		assert.Nil(t, err)
+		assert.Nil(t, updated)
+
+		got, err := repository.GetOrder(context.Background(), 5)
+		assert.Nil(t, err)
+		assert.Equal(t, "FULFILLED", got.Status)
+	})
+}
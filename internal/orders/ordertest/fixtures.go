@@ -0,0 +1,58 @@
+package ordertest
+
+import (
+	"time"
+
+	"github.com/deliveroo/bnt-internal-test-go/internal/orders"
+)
+
+// OrderOption customises an order built by NewOrder.
+type OrderOption func(*orders.Order)
+
+// CreatedAt is the creation time of orders built by NewOrder, unless they are
+// built WithCreatedAt.
+var CreatedAt = time.Date(2024, time.January, 1, 12, 0, 0, 0, time.UTC)
+
+// NewOrder builds a valid order for tests. Without options it returns a NEW
+// order with ID 1, created at CreatedAt.
+func NewOrder(opts ...OrderOption) orders.Order {
+	order := orders.Order{
+		ID:        1,
+		Status:    "NEW",
+		CreatedAt: CreatedAt,
+	}
+
+	for _, opt := range opts {
+		opt(&order)
+	}
+
+	return order
+}
+
+// WithID sets the ID of the order.
+func WithID(id int) OrderOption {
+	return func(o *orders.Order) {
+		o.ID = id
+	}
+}
+
+// WithStatus sets the status of the order.
+func WithStatus(status string) OrderOption {
+	return func(o *orders.Order) {
+		o.Status = status
+	}
+}
+
+// WithRestaurantID sets the restaurant of the order.
+func WithRestaurantID(id string) OrderOption {
+	return func(o *orders.Order) {
+		o.RestaurantID = id
+	}
+}
+
+// WithCreatedAt sets the creation time of the order.
+func WithCreatedAt(createdAt time.Time) OrderOption {
+	return func(o *orders.Order) {
+		o.CreatedAt = createdAt
+	}
+}
@@ -0,0 +1,156 @@
+// Package ordertest provides an in-memory orders.Repository and fixtures for
+// tests which should not depend on Postgres.
+package ordertest
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/deliveroo/bnt-internal-test-go/internal/orders"
+	"github.com/deliveroo/bnt-internal-test-go/internal/pagination"
+)
+
+// Repository is a concurrency-safe, in-memory orders.Repository with the same
+// semantics as the Postgres-backed implementation.
+type Repository struct {
+	mu     sync.RWMutex
+	orders map[int]orders.Order
+}
+
+var _ orders.Repository = (*Repository)(nil)
+
+// NewRepository returns a Repository containing the given orders.
+func NewRepository(seed ...orders.Order) *Repository {
+	r := &Repository{orders: map[int]orders.Order{}}
+	r.Add(seed...)
+
+	return r
+}
+
+// GetOrder returns a copy of the order with the given ID, or nil when it does
+// not exist.
+func (r *Repository) GetOrder(ctx context.Context, id int) (*orders.Order, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err //nolint:wrapcheck // mirrors the context error returned by pgx
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	order, ok := r.orders[id]
+	if !ok {
+		return nil, nil
+	}
+
+	return &order, nil
+}
+
+// ListOrders returns the requested page of orders, newest first, and the
+// positions of the pages either side.
+func (r *Repository) ListOrders(ctx context.Context, page pagination.Request) ([]orders.Order, pagination.Result, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, pagination.Result{}, err //nolint:wrapcheck // mirrors the context error returned by pgx
+	}
+
+	inPage := func(orders.Order) bool { return true }
+	if page.Cursor != nil {
+		createdAt, id, err := orders.CursorPosition(*page.Cursor)
+		if err != nil {
+			return nil, pagination.Result{}, err //nolint:wrapcheck // mirrors the Postgres-backed implementation
+		}
+		cursor := orders.Order{ID: id, CreatedAt: createdAt}
+		inPage = func(o orders.Order) bool { return newerThan(cursor, o) }
+		if page.Backward() {
+			inPage = func(o orders.Order) bool { return newerThan(o, cursor) }
+		}
+	}
+
+	r.mu.RLock()
+	var list []orders.Order
+	for _, order := range r.orders {
+		if inPage(order) {
+			list = append(list, order)
+		}
+	}
+	r.mu.RUnlock()
+
+	// Pages after the cursor are fetched newest first, and pages before it
+	// oldest first, as the Postgres-backed implementation does.
+	sort.Slice(list, func(i, j int) bool { return newerThan(list[i], list[j]) != page.Backward() })
+	if len(list) > page.Limit+1 {
+		list = list[:page.Limit+1]
+	}
+
+	list, result := pagination.Paginate(page, list, orders.Order.Cursor)
+	return list, result, nil
+}
+
+// newerThan reports whether a comes before b in lists of orders.
+func newerThan(a, b orders.Order) bool {
+	if !a.CreatedAt.Equal(b.CreatedAt) {
+		return a.CreatedAt.After(b.CreatedAt)
+	}
+	return a.ID > b.ID
+}
+
+// CreateOrder stores a new order with the status and restaurant of order, and
+// returns it with the next free ID and the current time.
+func (r *Repository) CreateOrder(ctx context.Context, order orders.Order) (*orders.Order, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err //nolint:wrapcheck // mirrors the context error returned by pgx
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	created := orders.Order{ID: 1, Status: order.Status, RestaurantID: order.RestaurantID, CreatedAt: time.Now().UTC()}
+	for id := range r.orders {
+		if id >= created.ID {
+			created.ID = id + 1
+		}
+	}
+	r.orders[created.ID] = created
+
+	return &created, nil
+}
+
+// UpdateOrderStatus changes the status of the order with the given ID from
+// one status to another, and returns a copy of the updated order. It returns
+// nil when the order does not exist or no longer has the status from.
+func (r *Repository) UpdateOrderStatus(ctx context.Context, id int, from, to string) (*orders.Order, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err //nolint:wrapcheck // mirrors the context error returned by pgx
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	order, ok := r.orders[id]
+	if !ok || order.Status != from {
+		return nil, nil
+	}
+	order.Status = to
+	r.orders[id] = order
+
+	return &order, nil
+}
+
+// Add stores orders, replacing any existing orders with the same IDs.
+func (r *Repository) Add(seed ...orders.Order) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, order := range seed {
+		r.orders[order.ID] = order
+	}
+}
+
+// Reset removes every order.
+func (r *Repository) Reset() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.orders = map[int]orders.Order{}
+}
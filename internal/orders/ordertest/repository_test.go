@@ -0,0 +1,13 @@
+package ordertest
+
+import (
+	"testing"
+
+	"github.com/deliveroo/bnt-internal-test-go/internal/orders"
+)
+
+func TestRepository(t *testing.T) {
+	RunConformanceTests(t, func(t *testing.T, seed ...orders.Order) orders.Repository {
+		return NewRepository(seed...)
+	})
+}
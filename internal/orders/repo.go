@@ -4,45 +4,183 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"time"
 
 	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/deliveroo/bnt-internal-test-go/internal/database"
+	"github.com/deliveroo/bnt-internal-test-go/internal/database/dberrors"
+	"github.com/deliveroo/bnt-internal-test-go/internal/pagination"
 )
 
 type Order struct {
 	ID     int
 	Status string
+	// RestaurantID is the restaurant the order is from, if known.
+	RestaurantID string
+	CreatedAt    time.Time
+}
+
+// Cursor returns the position of the order in lists of orders, which are
+// ordered newest first.
+func (o Order) Cursor() pagination.Cursor {
+	return pagination.Cursor{SortKey: o.CreatedAt.UTC().Format(time.RFC3339Nano), ID: int64(o.ID)}
+}
+
+// CursorPosition returns the creation time and ID of the order at cursor, or
+// pagination.ErrInvalidCursor when cursor is not a position in a list of
+// orders.
+func CursorPosition(cursor pagination.Cursor) (time.Time, int, error) {
+	createdAt, err := time.Parse(time.RFC3339Nano, cursor.SortKey)
+	if err != nil {
+		return time.Time{}, 0, pagination.ErrInvalidCursor
+	}
+	return createdAt, int(cursor.ID), nil
 }
 
+// Repository stores orders.
 type Repository interface {
+	// GetOrder returns the order with the given ID, or nil when it does not
+	// exist.
 	GetOrder(ctx context.Context, id int) (*Order, error)
+
+	// ListOrders returns the requested page of orders, newest first, and the
+	// positions of the pages either side.
+	ListOrders(ctx context.Context, page pagination.Request) ([]Order, pagination.Result, error)
+
+	// CreateOrder stores a new order with the status and restaurant of order,
+	// and returns it with its generated ID and creation time.
+	CreateOrder(ctx context.Context, order Order) (*Order, error)
+
+	// UpdateOrderStatus changes the status of the order with the given ID from
+	// one status to another, and returns the updated order. It returns nil
+	// when the order does not exist or no longer has the status from.
+	UpdateOrderStatus(ctx context.Context, id int, from, to string) (*Order, error)
 }
 
-func NewRepository(readDB, writeDB *pgxpool.Pool) Repository {
-	return postgresBackedRepo{writeDB, readDB}
+// DB chooses the database pool for each query, e.g. so that reads go to a
+// replica while it is healthy.
+type DB interface {
+	// ForRead returns the pool to read from.
+	ForRead(ctx context.Context) *pgxpool.Pool
+	// ForWrite returns the pool to write to.
+	ForWrite(ctx context.Context) *pgxpool.Pool
+}
+
+// Pools is a DB which always reads from Reader and writes to Writer.
+type Pools struct {
+	Writer *pgxpool.Pool
+	Reader *pgxpool.Pool
+}
+
+// ForRead returns the Reader pool.
+func (p Pools) ForRead(context.Context) *pgxpool.Pool {
+	return p.Reader
+}
+
+// ForWrite returns the Writer pool.
+func (p Pools) ForWrite(context.Context) *pgxpool.Pool {
+	return p.Writer
+}
+
+// NewRepository returns a Repository storing orders in Postgres. Queries run in
+// the transaction carried by their context, if any; see database.WithTx.
+func NewRepository(db DB) Repository {
+	return postgresBackedRepo{db}
 }
 
 type postgresBackedRepo struct {
-	writeDB *pgxpool.Pool
-	readDB  *pgxpool.Pool
+	db DB
 }
 
-func (r postgresBackedRepo) GetOrder(ctx context.Context, id int) (*Order, error) {
+// orderColumns are the columns read by scanOrder.
+const orderColumns = `id, status, coalesce(restaurant_id, ''), created_at`
+
+func scanOrder(row pgx.Row) (Order, error) {
 	var order Order
+	err := row.Scan(&order.ID, &order.Status, &order.RestaurantID, &order.CreatedAt)
+	order.CreatedAt = order.CreatedAt.UTC()
+	return order, err //nolint:wrapcheck // wrapped by the caller
+}
 
-	rows, err := r.readDB.Query(ctx, `SELECT id, status FROM orders WHERE id = $1`, id)
+func (r postgresBackedRepo) GetOrder(ctx context.Context, id int) (*Order, error) {
+	order, err := scanOrder(database.QuerierFrom(ctx, r.db.ForRead(ctx)).QueryRow(ctx, `SELECT `+orderColumns+` FROM orders WHERE id = $1`, id))
 	if err != nil {
-		return nil, fmt.Errorf("failed to query database: %w", err)
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to query database: %w", dberrors.Map(err))
+	}
+
+	return &order, nil
+}
+
+func (r postgresBackedRepo) ListOrders(ctx context.Context, page pagination.Request) ([]Order, pagination.Result, error) {
+	query := `SELECT ` + orderColumns + ` FROM orders`
+	orderBy := `ORDER BY created_at DESC, id DESC`
+	var args []any
+
+	if page.Cursor != nil {
+		createdAt, id, err := CursorPosition(*page.Cursor)
+		if err != nil {
+			return nil, pagination.Result{}, err
+		}
+		args = append(args, createdAt, id)
+
+		if page.Backward() {
+			query += ` WHERE (created_at, id) > ($1, $2)`
+			orderBy = `ORDER BY created_at, id`
+		} else {
+			query += ` WHERE (created_at, id) < ($1, $2)`
+		}
+	}
+	args = append(args, page.Limit+1)
+	query += fmt.Sprintf(" %s LIMIT $%d", orderBy, len(args))
+
+	rows, err := database.QuerierFrom(ctx, r.db.ForRead(ctx)).Query(ctx, query, args...)
+	if err != nil {
+		return nil, pagination.Result{}, fmt.Errorf("failed to query database: %w", dberrors.Map(err))
 	}
 	defer rows.Close()
 
-	err = rows.Scan(&order.ID, &order.Status)
+	var list []Order
+	for rows.Next() {
+		order, err := scanOrder(rows)
+		if err != nil {
+			return nil, pagination.Result{}, fmt.Errorf("failed to read order: %w", err)
+		}
+		list = append(list, order)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, pagination.Result{}, fmt.Errorf("failed to query database: %w", dberrors.Map(err))
+	}
+
+	list, result := pagination.Paginate(page, list, Order.Cursor)
+	return list, result, nil
+}
+
+func (r postgresBackedRepo) CreateOrder(ctx context.Context, order Order) (*Order, error) {
+	created, err := scanOrder(database.QuerierFrom(ctx, r.db.ForWrite(ctx)).QueryRow(ctx,
+		`INSERT INTO orders (status, restaurant_id) VALUES ($1, nullif($2, '')) RETURNING `+orderColumns, order.Status, order.RestaurantID,
+	))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create order: %w", dberrors.Map(err))
+	}
+
+	return &created, nil
+}
+
+func (r postgresBackedRepo) UpdateOrderStatus(ctx context.Context, id int, from, to string) (*Order, error) {
+	updated, err := scanOrder(database.QuerierFrom(ctx, r.db.ForWrite(ctx)).QueryRow(ctx,
+		`UPDATE orders SET status = $3 WHERE id = $1 AND status = $2 RETURNING `+orderColumns, id, from, to,
+	))
 	if err != nil {
 		if errors.Is(err, pgx.ErrNoRows) {
 			return nil, nil
 		}
-		return nil, fmt.Errorf("failed to retrieve order from database: %w", err)
+		return nil, fmt.Errorf("failed to update order: %w", dberrors.Map(err))
 	}
 
-	return &order, nil
+	return &updated, nil
 }
@@ -0,0 +1,29 @@
+package orders_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/deliveroo/bnt-internal-test-go/internal/database/databasetest"
+	"github.com/deliveroo/bnt-internal-test-go/internal/orders"
+	"github.com/deliveroo/bnt-internal-test-go/internal/orders/ordertest"
+)
+
+func TestPostgresRepository(t *testing.T) {
+	ordertest.RunConformanceTests(t, func(t *testing.T, seed ...orders.Order) orders.Repository {
+		t.Helper()
+
+		pool := databasetest.NewPool(t)
+		for _, order := range seed {
+			if _, err := pool.Exec(context.Background(), `INSERT INTO orders (id, status, restaurant_id, created_at) VALUES ($1, $2, nullif($3, ''), $4)`, order.ID, order.Status, order.RestaurantID, order.CreatedAt); err != nil {
+				t.Fatalf("failed to seed order: %s", err)
+			}
+		}
+		// Generate IDs after the seeded ones, as the in-memory repository does.
+		if _, err := pool.Exec(context.Background(), `SELECT setval(pg_get_serial_sequence('orders', 'id'), COALESCE(max(id), 0) + 1, false) FROM orders`); err != nil {
+			t.Fatalf("failed to reset order IDs: %s", err)
+		}
+
+		return orders.NewRepository(orders.Pools{Writer: pool, Reader: pool})
+	})
+}
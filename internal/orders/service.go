@@ -0,0 +1,202 @@
+package orders
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/deliveroo/bnt-internal-test-go/internal/auth"
+	"github.com/deliveroo/bnt-internal-test-go/internal/authz"
+	"github.com/deliveroo/bnt-internal-test-go/internal/pagination"
+)
+
+// Statuses of orders. Orders are created NEW, and are then either cancelled
+// or fulfilled.
+const (
+	StatusNew       = "NEW"
+	StatusCancelled = "CANCELLED"
+	StatusFulfilled = "FULFILLED"
+)
+
+// ResourceType is the type of orders in authorization policies.
+const ResourceType = "order"
+
+// Actions on orders, which Policy allows.
+const (
+	ActionList   authz.Action = "list"
+	ActionRead   authz.Action = "read"
+	ActionCreate authz.Action = "create"
+	ActionCancel authz.Action = "cancel"
+	ActionFulfil authz.Action = "fulfil"
+)
+
+// Roles of the callers Policy allows.
+const (
+	// RoleService is the role of services placing orders for customers.
+	RoleService = "service"
+	// RoleOps is the role of operations staff supporting customers.
+	RoleOps = "ops"
+	// RoleRestaurant is the role of restaurants, which only act on their own
+	// orders.
+	RoleRestaurant = "restaurant"
+	// RoleFulfilment is the role of services delivering orders.
+	RoleFulfilment = "fulfilment"
+)
+
+// Policy says who may do what with orders.
+var Policy = authz.MustNewPolicy(
+	authz.Rule{
+		Name:     "services place and manage every order",
+		Resource: ResourceType,
+		Actions:  []authz.Action{ActionList, ActionRead, ActionCreate, ActionCancel},
+		Subject:  map[string][]string{authz.AttrRole: {RoleService}},
+	},
+	authz.Rule{
+		Name:     "operations staff read and cancel every order",
+		Resource: ResourceType,
+		Actions:  []authz.Action{ActionList, ActionRead, ActionCancel},
+		Subject:  map[string][]string{authz.AttrRole: {RoleOps}},
+	},
+	authz.Rule{
+		Name:     "restaurants read, cancel and fulfil their own orders",
+		Resource: ResourceType,
+		Actions:  []authz.Action{ActionRead, ActionCancel, ActionFulfil},
+		Subject:  map[string][]string{authz.AttrRole: {RoleRestaurant}},
+		Match:    map[string]string{auth.AttrRestaurantID: auth.AttrRestaurantID},
+	},
+	authz.Rule{
+		Name:     "fulfilment services read and fulfil every order",
+		Resource: ResourceType,
+		Actions:  []authz.Action{ActionRead, ActionFulfil},
+		Subject:  map[string][]string{authz.AttrRole: {RoleFulfilment}},
+	},
+)
+
+var (
+	// ErrNotFound is returned for orders which do not exist.
+	ErrNotFound = errors.New("order not found")
+	// ErrInvalidTransition is returned when the status of an order does not
+	// allow an action, e.g. cancelling a fulfilled order.
+	ErrInvalidTransition = errors.New("invalid order status transition")
+)
+
+// Service carries out operations on orders, authorizing each with Policy.
+type Service struct {
+	repository Repository
+	authorizer *authz.Authorizer
+}
+
+// NewService returns a Service storing orders in repository, and authorizing
+// operations with authorizer. Operations are not authorized when authorizer
+// is nil, as when requests are not authenticated, e.g. in development.
+func NewService(repository Repository, authorizer *authz.Authorizer) *Service {
+	return &Service{repository: repository, authorizer: authorizer}
+}
+
+// Get returns the order with the given ID, or ErrNotFound when it does not
+// exist or the caller may not read it.
+func (s *Service) Get(ctx context.Context, id int) (*Order, error) {
+	order, err := s.repository.GetOrder(ctx, id)
+	if err != nil {
+		return nil, err //nolint:wrapcheck // the repository describes the failure
+	}
+	if order == nil {
+		return nil, ErrNotFound
+	}
+	if err := s.authorizeOrder(ctx, ActionRead, *order); err != nil {
+		return nil, err
+	}
+	return order, nil
+}
+
+// List returns the requested page of orders, newest first, and the positions
+// of the pages either side.
+func (s *Service) List(ctx context.Context, page pagination.Request) ([]Order, pagination.Result, error) {
+	if err := s.authorize(ctx, ActionList, authz.Resource{Type: ResourceType}); err != nil {
+		return nil, pagination.Result{}, err
+	}
+	return s.repository.ListOrders(ctx, page) //nolint:wrapcheck // the repository describes the failure
+}
+
+// Create creates order, and returns it with its generated ID and creation
+// time.
+func (s *Service) Create(ctx context.Context, order Order) (*Order, error) {
+	if err := s.authorize(ctx, ActionCreate, resource(order)); err != nil {
+		return nil, err
+	}
+	return s.repository.CreateOrder(ctx, order) //nolint:wrapcheck // the repository describes the failure
+}
+
+// Cancel cancels the NEW order with the given ID, and returns it.
+func (s *Service) Cancel(ctx context.Context, id int) (*Order, error) {
+	return s.transition(ctx, id, ActionCancel, StatusCancelled)
+}
+
+// Fulfil fulfils the NEW order with the given ID, and returns it.
+func (s *Service) Fulfil(ctx context.Context, id int) (*Order, error) {
+	return s.transition(ctx, id, ActionFulfil, StatusFulfilled)
+}
+
+// transition moves the NEW order with the given ID to status, authorizing
+// action on it first.
+func (s *Service) transition(ctx context.Context, id int, action authz.Action, status string) (*Order, error) {
+	order, err := s.repository.GetOrder(ctx, id)
+	if err != nil {
+		return nil, err //nolint:wrapcheck // the repository describes the failure
+	}
+	if order == nil {
+		return nil, ErrNotFound
+	}
+	if err := s.authorizeOrder(ctx, action, *order); err != nil {
+		return nil, err
+	}
+	if order.Status != StatusNew {
+		return nil, fmt.Errorf("%w: cannot %s a %s order", ErrInvalidTransition, action, order.Status)
+	}
+
+	updated, err := s.repository.UpdateOrderStatus(ctx, id, StatusNew, status)
+	if err != nil {
+		return nil, err //nolint:wrapcheck // the repository describes the failure
+	}
+	if updated == nil {
+		// The order changed since it was read.
+		return nil, fmt.Errorf("%w: the order is no longer %s", ErrInvalidTransition, StatusNew)
+	}
+	return updated, nil
+}
+
+// authorizeOrder authorizes action on order. Callers which may not read the
+// order are told that it does not exist, with ErrNotFound, so that they cannot
+// learn which orders other restaurants have. Callers which may read it, but
+// not perform action, are denied.
+func (s *Service) authorizeOrder(ctx context.Context, action authz.Action, order Order) error {
+	r := resource(order)
+	err := s.authorize(ctx, action, r)
+	if err == nil || !errors.Is(err, authz.ErrDenied) {
+		return err
+	}
+	if action == ActionRead || !s.authorizer.Allows(ctx, ActionRead, r) {
+		return ErrNotFound
+	}
+	return err
+}
+
+func (s *Service) authorize(ctx context.Context, action authz.Action, resource authz.Resource) error {
+	if s.authorizer == nil {
+		return nil
+	}
+	return s.authorizer.Authorize(ctx, action, resource) //nolint:wrapcheck // describes the denial
+}
+
+// resource describes order in authorization policies.
+func resource(order Order) authz.Resource {
+	r := authz.Resource{Type: ResourceType, Attributes: map[string]string{}}
+	if order.ID != 0 {
+		r.ID = strconv.Itoa(order.ID)
+	}
+	if order.RestaurantID != "" {
+		r.Attributes[auth.AttrRestaurantID] = order.RestaurantID
+	}
+	return r
+}
@@ -0,0 +1,115 @@
+package orders_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+
+	"github.com/deliveroo/bnt-internal-test-go/internal/auth"
+	"github.com/deliveroo/bnt-internal-test-go/internal/authz"
+	"github.com/deliveroo/bnt-internal-test-go/internal/orders"
+	"github.com/deliveroo/bnt-internal-test-go/internal/orders/ordertest"
+	"github.com/deliveroo/bnt-internal-test-go/internal/pagination"
+)
+
+func TestService(t *testing.T) {
+	newService := func() *orders.Service {
+		repository := ordertest.NewRepository(
+			ordertest.NewOrder(ordertest.WithID(1), ordertest.WithRestaurantID("r-1")),
+			ordertest.NewOrder(ordertest.WithID(2), ordertest.WithRestaurantID("r-2")),
+			ordertest.NewOrder(ordertest.WithID(3), ordertest.WithRestaurantID("r-1"), ordertest.WithStatus(orders.StatusFulfilled)),
+		)
+		return orders.NewService(repository, authz.NewAuthorizer(orders.Policy, zap.NewNop()))
+	}
+	as := func(role, restaurantID string) context.Context {
+		principal := &auth.Principal{Subject: role, Roles: []string{role}}
+		if restaurantID != "" {
+			principal.Attributes = map[string]string{auth.AttrRestaurantID: restaurantID}
+		}
+		return auth.ContextWithPrincipal(context.Background(), principal)
+	}
+
+	t.Run("restaurants read, cancel and fulfil only their own orders", func(t *testing.T) {
+		service := newService()
+		ctx := as(orders.RoleRestaurant, "r-1")
+
+		order, err := service.Get(ctx, 1)
+		assert.Nil(t, err)
+		assert.Equal(t, 1, order.ID)
+
+		_, _, err = service.List(ctx, pagination.Request{Limit: 10})
+		assert.ErrorIs(t, err, authz.ErrDenied)
+
+		order, err = service.Fulfil(ctx, 1)
+		assert.Nil(t, err)
+		assert.Equal(t, orders.StatusFulfilled, order.Status)
+	})
+
+	t.Run("returns not found for orders the caller may not read", func(t *testing.T) {
+		service := newService()
+		ctx := as(orders.RoleRestaurant, "r-1")
+
+		_, err := service.Get(ctx, 2)
+		assert.ErrorIs(t, err, orders.ErrNotFound)
+		_, err = service.Cancel(ctx, 2)
+		assert.ErrorIs(t, err, orders.ErrNotFound)
+
+		_, err = service.Get(as(orders.RoleRestaurant, ""), 1)
+		assert.ErrorIs(t, err, orders.ErrNotFound)
+	})
+
+	t.Run("operations staff cancel but do not fulfil orders", func(t *testing.T) {
+		service := newService()
+		ctx := as(orders.RoleOps, "")
+
+		order, err := service.Cancel(ctx, 2)
+		assert.Nil(t, err)
+		assert.Equal(t, orders.StatusCancelled, order.Status)
+
+		_, err = service.Fulfil(ctx, 1)
+		assert.ErrorIs(t, err, authz.ErrDenied)
+	})
+
+	t.Run("services create and list orders", func(t *testing.T) {
+		service := newService()
+		ctx := as(orders.RoleService, "")
+
+		created, err := service.Create(ctx, orders.Order{Status: orders.StatusNew, RestaurantID: "r-3"})
+		assert.Nil(t, err)
+		assert.Equal(t, "r-3", created.RestaurantID)
+
+		list, _, err := service.List(ctx, pagination.Request{Limit: 10})
+		assert.Nil(t, err)
+		assert.Len(t, list, 4)
+	})
+
+	t.Run("only NEW orders are cancelled or fulfilled", func(t *testing.T) {
+		_, err := newService().Cancel(as(orders.RoleOps, ""), 3)
+		assert.ErrorIs(t, err, orders.ErrInvalidTransition)
+	})
+
+	t.Run("returns not found for orders which do not exist", func(t *testing.T) {
+		service := newService()
+		ctx := as(orders.RoleOps, "")
+
+		_, err := service.Get(ctx, 4)
+		assert.ErrorIs(t, err, orders.ErrNotFound)
+
+		_, err = service.Cancel(ctx, 4)
+		assert.ErrorIs(t, err, orders.ErrNotFound)
+	})
+
+	t.Run("denies callers without a principal", func(t *testing.T) {
+		_, _, err := newService().List(context.Background(), pagination.Request{Limit: 10})
+		assert.ErrorIs(t, err, authz.ErrDenied)
+	})
+
+	t.Run("allows everything without an authorizer", func(t *testing.T) {
+		service := orders.NewService(ordertest.NewRepository(ordertest.NewOrder()), nil)
+
+		_, err := service.Fulfil(context.Background(), 1)
+		assert.Nil(t, err)
+	})
+}
@@ -0,0 +1,138 @@
+package pagination
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// Query parameters of a page request.
+const (
+	LimitParam  = "limit"
+	CursorParam = "cursor"
+)
+
+// ErrInvalidCursor means a cursor was not issued by the Codec, or was altered.
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// Codec encodes cursors as opaque, signed strings, and decodes them.
+type Codec struct {
+	key []byte
+}
+
+// NewCodec returns a Codec signing cursors with key. Every instance of the
+// service must share the key, so that they accept each other's cursors.
+func NewCodec(key []byte) *Codec {
+	return &Codec{key: key}
+}
+
+// Encode returns cursor as an opaque string.
+func (c *Codec) Encode(cursor Cursor) string {
+	payload, _ := json.Marshal(cursor) // Cursors always encode.
+	return base64.RawURLEncoding.EncodeToString(payload) + "." + base64.RawURLEncoding.EncodeToString(c.sign(payload))
+}
+
+// Decode returns the cursor encoded by Encode, or ErrInvalidCursor.
+func (c *Codec) Decode(encoded string) (Cursor, error) {
+	var cursor Cursor
+
+	payloadPart, signaturePart, ok := strings.Cut(encoded, ".")
+	if !ok {
+		return cursor, ErrInvalidCursor
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(payloadPart)
+	if err != nil {
+		return cursor, ErrInvalidCursor
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(signaturePart)
+	if err != nil || !hmac.Equal(signature, c.sign(payload)) {
+		return cursor, ErrInvalidCursor
+	}
+	if err := json.Unmarshal(payload, &cursor); err != nil {
+		return cursor, ErrInvalidCursor
+	}
+
+	return cursor, nil
+}
+
+func (c *Codec) sign(payload []byte) []byte {
+	mac := hmac.New(sha256.New, c.key)
+	mac.Write(payload)
+	return mac.Sum(nil)
+}
+
+// ParseRequest returns the page requested by the limit and cursor query
+// parameters of r. Limits are clamped between 1 and MaxLimit, defaulting to
+// DefaultLimit.
+func (c *Codec) ParseRequest(r *http.Request) (Request, error) {
+	query := r.URL.Query()
+	req := Request{Limit: DefaultLimit}
+
+	if value := query.Get(LimitParam); value != "" {
+		limit, err := strconv.Atoi(value)
+		if err != nil {
+			return req, fmt.Errorf("invalid %s: %q is not a number", LimitParam, value)
+		}
+		req.Limit = clamp(limit, 1, MaxLimit)
+	}
+
+	if value := query.Get(CursorParam); value != "" {
+		cursor, err := c.Decode(value)
+		if err != nil {
+			return req, err
+		}
+		req.Cursor = &cursor
+	}
+
+	return req, nil
+}
+
+func clamp(value, lower, upper int) int {
+	switch {
+	case value < lower:
+		return lower
+	case value > upper:
+		return upper
+	default:
+		return value
+	}
+}
+
+// Links are the URLs of the pages either side of a page.
+type Links struct {
+	Next string `json:"next,omitempty"`
+	Prev string `json:"prev,omitempty"`
+}
+
+// Links returns the URLs of the pages in result, relative to the request r for
+// the current page, keeping its other query parameters.
+func (c *Codec) Links(r *http.Request, req Request, result Result) Links {
+	link := func(cursor *Cursor) string {
+		if cursor == nil {
+			return ""
+		}
+		query := r.URL.Query()
+		query.Set(LimitParam, strconv.Itoa(req.Limit))
+		query.Set(CursorParam, c.Encode(*cursor))
+		return (&url.URL{Path: r.URL.Path, RawQuery: query.Encode()}).String()
+	}
+
+	return Links{Next: link(result.Next), Prev: link(result.Prev)}
+}
+
+// SetLinkHeader adds links to the Link header of the response, see RFC 8288.
+func SetLinkHeader(w http.ResponseWriter, links Links) {
+	if links.Next != "" {
+		w.Header().Add("Link", fmt.Sprintf(`<%s>; rel="next"`, links.Next))
+	}
+	if links.Prev != "" {
+		w.Header().Add("Link", fmt.Sprintf(`<%s>; rel="prev"`, links.Prev))
+	}
+}
@@ -0,0 +1,94 @@
+// Package pagination pages through collections with keyset cursors, which stay
+// fast however deep the page, unlike offsets.
+//
+// A collection is ordered by a sort key and then by ID, which breaks ties. A
+// cursor records the sort key and ID of the item a page starts after (or, for
+// the previous page, ends before), so that repositories can fetch the page
+// with a keyset condition such as:
+//
+//	WHERE (created_at, id) < ($1, $2) ORDER BY created_at DESC, id DESC LIMIT $3
+//
+// Cursors are signed by a Codec, so clients cannot forge them, and are opaque
+// to clients, so their format can change.
+package pagination
+
+const (
+	// DefaultLimit is the number of items in a page when the request does not
+	// say.
+	DefaultLimit = 20
+	// MaxLimit is the most items in a page. Larger limits are reduced to it.
+	MaxLimit = 100
+)
+
+// Cursor is a position in a collection.
+type Cursor struct {
+	// SortKey is the sort key of the item at the position, e.g. a timestamp
+	// in RFC 3339 format.
+	SortKey string `json:"k"`
+	// ID is the ID of the item at the position.
+	ID int64 `json:"i"`
+	// Backward means the page ends before the item, rather than starting
+	// after it.
+	Backward bool `json:"b,omitempty"`
+}
+
+// Request is a page of a collection requested by a client.
+type Request struct {
+	// Limit is the most items in the page, between 1 and MaxLimit.
+	Limit int
+	// Cursor is the position of the page, or nil for the first page.
+	Cursor *Cursor
+}
+
+// Backward reports whether the page ends before the cursor, so its items
+// must be fetched in reverse order.
+func (r Request) Backward() bool {
+	return r.Cursor != nil && r.Cursor.Backward
+}
+
+// Result is the position of the pages either side of a page.
+type Result struct {
+	// Next is the position of the next page, or nil on the last page.
+	Next *Cursor
+	// Prev is the position of the previous page, or nil on the first page.
+	Prev *Cursor
+}
+
+// Paginate returns the page of items requested by req, and the cursors of the
+// neighbouring pages. Repositories fetch up to req.Limit+1 items after the
+// cursor, in collection order, or before it, in reverse order, when
+// req.Backward; the extra item shows whether there are more. cursorOf returns
+// the position of an item.
+func Paginate[T any](req Request, items []T, cursorOf func(T) Cursor) ([]T, Result) {
+	more := len(items) > req.Limit
+	if more {
+		items = items[:req.Limit]
+	}
+
+	if req.Backward() {
+		for i, j := 0, len(items)-1; i < j; i, j = i+1, j-1 {
+			items[i], items[j] = items[j], items[i]
+		}
+	}
+
+	// Coming from a page means there is a page to go back to.
+	hasNext := more || req.Backward()
+	hasPrev := req.Cursor != nil && (more || !req.Backward())
+
+	var result Result
+	if len(items) == 0 {
+		return items, result
+	}
+	if hasNext {
+		next := cursorOf(items[len(items)-1])
+		next.Backward = false
+		result.Next = &next
+	}
+	if hasPrev {
+		prev := cursorOf(items[0])
+		prev.Backward = true
+		result.Prev = &prev
+	}
+
+	return items, result
+}
@@ -0,0 +1,102 @@
+package pagination
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCodec(t *testing.T) {
+	codec := NewCodec([]byte("key"))
+	cursor := Cursor{SortKey: "2024-01-01T12:00:00Z", ID: 42, Backward: true}
+
+	t.Run("decodes the cursors it encodes", func(t *testing.T) {
+		decoded, err := codec.Decode(codec.Encode(cursor))
+		assert.Nil(t, err)
+		assert.Equal(t, cursor, decoded)
+	})
+
+	t.Run("rejects cursors which were altered or signed with another key", func(t *testing.T) {
+		encoded := codec.Encode(cursor)
+		forged := NewCodec([]byte("other")).Encode(cursor)
+
+		for _, value := range []string{"", "abc", encoded + "x", "x" + encoded, forged} {
+			_, err := codec.Decode(value)
+			assert.ErrorIs(t, err, ErrInvalidCursor, value)
+		}
+	})
+
+	t.Run("parses requests, clamping the limit", func(t *testing.T) {
+		for target, limit := range map[string]int{
+			"/orders":            DefaultLimit,
+			"/orders?limit=5":    5,
+			"/orders?limit=0":    1,
+			"/orders?limit=-3":   1,
+			"/orders?limit=1000": MaxLimit,
+		} {
+			req, err := codec.ParseRequest(httptest.NewRequest("GET", target, nil))
+			assert.Nil(t, err, target)
+			assert.Equal(t, Request{Limit: limit}, req, target)
+		}
+
+		req, err := codec.ParseRequest(httptest.NewRequest("GET", "/orders?cursor="+codec.Encode(cursor), nil))
+		assert.Nil(t, err)
+		assert.Equal(t, &cursor, req.Cursor)
+
+		_, err = codec.ParseRequest(httptest.NewRequest("GET", "/orders?limit=ten", nil))
+		assert.EqualError(t, err, `invalid limit: "ten" is not a number`)
+	})
+
+	t.Run("links to the pages either side, keeping other parameters", func(t *testing.T) {
+		r := httptest.NewRequest("GET", "/orders?status=NEW&cursor=old", nil)
+		next := Cursor{SortKey: "a", ID: 1}
+
+		links := codec.Links(r, Request{Limit: 5}, Result{Next: &next})
+
+		assert.Equal(t, "/orders?cursor="+codec.Encode(next)+"&limit=5&status=NEW", links.Next)
+		assert.Empty(t, links.Prev)
+	})
+}
+
+func TestPaginate(t *testing.T) {
+	cursorOf := func(id int) Cursor { return Cursor{ID: int64(id)} }
+	forward := func(id int64) *Cursor { return &Cursor{ID: id} }
+	backward := func(id int64) *Cursor { return &Cursor{ID: id, Backward: true} }
+
+	t.Run("first page", func(t *testing.T) {
+		items, result := Paginate(Request{Limit: 2}, []int{9, 8, 7}, cursorOf)
+		assert.Equal(t, []int{9, 8}, items)
+		assert.Equal(t, Result{Next: forward(8)}, result)
+	})
+
+	t.Run("only page", func(t *testing.T) {
+		items, result := Paginate(Request{Limit: 2}, []int{9}, cursorOf)
+		assert.Equal(t, []int{9}, items)
+		assert.Equal(t, Result{}, result)
+	})
+
+	t.Run("middle page", func(t *testing.T) {
+		items, result := Paginate(Request{Limit: 2, Cursor: forward(8)}, []int{7, 6, 5}, cursorOf)
+		assert.Equal(t, []int{7, 6}, items)
+		assert.Equal(t, Result{Next: forward(6), Prev: backward(7)}, result)
+	})
+
+	t.Run("last page", func(t *testing.T) {
+		items, result := Paginate(Request{Limit: 2, Cursor: forward(6)}, []int{5}, cursorOf)
+		assert.Equal(t, []int{5}, items)
+		assert.Equal(t, Result{Prev: backward(5)}, result)
+	})
+
+	t.Run("previous page, fetched in reverse", func(t *testing.T) {
+		items, result := Paginate(Request{Limit: 2, Cursor: backward(7)}, []int{8, 9}, cursorOf)
+		assert.Equal(t, []int{9, 8}, items)
+		assert.Equal(t, Result{Next: forward(8)}, result)
+	})
+
+	t.Run("empty page", func(t *testing.T) {
+		items, result := Paginate(Request{Limit: 2}, []int(nil), cursorOf)
+		assert.Empty(t, items)
+		assert.Equal(t, Result{}, result)
+	})
+}
@@ -0,0 +1,66 @@
+// Package rate describes rates of requests, such as the limits enforced by
+// package ratelimit. It has no dependencies, so that limits can be configured
+// without the configuration depending on how they are enforced.
+package rate
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Limit is a number of requests allowed per period of time.
+type Limit struct {
+	Requests int
+	Per      time.Duration
+}
+
+// ParseLimit parses a limit written as requests/period, e.g. "100/1m". The
+// number of a period of one unit may be left out, e.g. "10/s".
+func ParseLimit(s string) (Limit, error) {
+	requests, per, ok := strings.Cut(strings.TrimSpace(s), "/")
+	if !ok {
+		return Limit{}, fmt.Errorf("invalid rate limit %q: must be requests/period, e.g. 100/1m", s)
+	}
+
+	n, err := strconv.Atoi(requests)
+	if err != nil || n < 1 {
+		return Limit{}, fmt.Errorf("invalid rate limit %q: requests must be a positive integer", s)
+	}
+	if per != "" && (per[0] < '0' || per[0] > '9') {
+		per = "1" + per
+	}
+	d, err := time.ParseDuration(per)
+	if err != nil || d <= 0 {
+		return Limit{}, fmt.Errorf("invalid rate limit %q: period must be a positive duration", s)
+	}
+
+	return Limit{Requests: n, Per: d}, nil
+}
+
+// UnmarshalText parses a limit written as for ParseLimit, so that limits can
+// be configured.
+func (l *Limit) UnmarshalText(text []byte) error {
+	limit, err := ParseLimit(string(text))
+	if err != nil {
+		return err
+	}
+	*l = limit
+	return nil
+}
+
+func (l Limit) String() string {
+	if l.Requests == 0 {
+		return ""
+	}
+	per := l.Per.String()
+	// Drop the zero units time.Duration spells out, e.g. 1m0s.
+	if strings.HasSuffix(per, "m0s") {
+		per = strings.TrimSuffix(per, "0s")
+	}
+	if strings.HasSuffix(per, "h0m") {
+		per = strings.TrimSuffix(per, "0m")
+	}
+	return strconv.Itoa(l.Requests) + "/" + per
+}
@@ -0,0 +1,44 @@
+package rate_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/deliveroo/bnt-internal-test-go/internal/rate"
+)
+
+func TestParseLimit(t *testing.T) {
+	for _, tc := range []struct {
+		in   string
+		want rate.Limit
+	}{
+		{in: "100/1m", want: rate.Limit{Requests: 100, Per: time.Minute}},
+		{in: "10/s", want: rate.Limit{Requests: 10, Per: time.Second}},
+		{in: " 5/90s ", want: rate.Limit{Requests: 5, Per: 90 * time.Second}},
+	} {
+		t.Run("parses "+tc.in, func(t *testing.T) {
+			limit, err := rate.ParseLimit(tc.in)
+			assert.Nil(t, err)
+			assert.Equal(t, tc.want, limit)
+		})
+	}
+
+	for _, in := range []string{"", "100", "0/1m", "-1/1m", "many/1m", "100/", "100/0s", "100/soon"} {
+		t.Run("rejects "+in, func(t *testing.T) {
+			_, err := rate.ParseLimit(in)
+			assert.NotNil(t, err)
+		})
+	}
+}
+
+func TestLimitString(t *testing.T) {
+	for in, want := range map[string]string{"100/1m": "100/1m", "10/s": "10/1s", "5/90s": "5/1m30s", "1/2h": "1/2h"} {
+		t.Run("formats "+in, func(t *testing.T) {
+			limit, err := rate.ParseLimit(in)
+			assert.Nil(t, err)
+			assert.Equal(t, want, limit.String())
+		})
+	}
+}
@@ -0,0 +1,160 @@
+package ratelimit
+
+import (
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/deliveroo/apm-go"
+	"github.com/deliveroo/bnt-internal-test-go/internal/apikeys"
+	"github.com/deliveroo/bnt-internal-test-go/internal/auth"
+	"github.com/deliveroo/bnt-internal-test-go/internal/httpserver/gorillautils"
+	"github.com/deliveroo/bnt-internal-test-go/internal/rate"
+)
+
+// Headers describing limits, from the IETF draft "RateLimit header fields for
+// HTTP".
+const (
+	LimitHeader     = "RateLimit-Limit"
+	RemainingHeader = "RateLimit-Remaining"
+	ResetHeader     = "RateLimit-Reset"
+	PolicyHeader    = "RateLimit-Policy"
+)
+
+// metricPrefix starts the names of the metrics reported by the middleware.
+const metricPrefix = "ratelimit."
+
+// Callers are identified by their principal, the API key they authenticated
+// with or their address, in that order of preference.
+const (
+	CallerPrincipal = "principal"
+	CallerAPIKey    = "apikey"
+	CallerIP        = "ip"
+)
+
+type middleware struct {
+	limiter      Limiter
+	name         string
+	limit        rate.Limit
+	metrics      apm.Metrics
+	logger       *zap.Logger
+	forwardedFor bool
+}
+
+// Option configures the Middleware.
+type Option func(*middleware)
+
+// WithLogger sets the logger for failures of the limiter. Defaults to a no-op
+// logger.
+func WithLogger(logger *zap.Logger) Option {
+	return func(m *middleware) {
+		m.logger = logger
+	}
+}
+
+// WithForwardedFor identifies callers without a principal or API key by the
+// last address in their X-Forwarded-For header, as added by the load balancer
+// in front of the service, rather than by the address connecting to it. It
+// must only be used behind a load balancer which adds the header, as callers
+// can set it themselves.
+func WithForwardedFor() Option {
+	return func(m *middleware) {
+		m.forwardedFor = true
+	}
+}
+
+// Middleware limits the requests each caller makes to the route called name
+// to limit, rejecting the others with 429 Too Many Requests and a Retry-After
+// header. Every response describes the limit with the RateLimit-* headers.
+// Callers are identified by the principal authenticating the request, so the
+// middleware must run after authentication, or else by their address.
+// Rejected requests are counted to metrics, tagged by route and kind of
+// caller. Requests are allowed when the limiter fails, so that an outage of
+// its store does not take the service down.
+func Middleware(limiter Limiter, name string, limit rate.Limit, metrics apm.Metrics, opts ...Option) func(http.Handler) http.Handler {
+	m := &middleware{
+		limiter: limiter,
+		name:    name,
+		limit:   limit,
+		metrics: metrics,
+		logger:  zap.NewNop(),
+	}
+	for _, opt := range opts {
+		opt(m)
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			m.serve(next, w, r)
+		})
+	}
+}
+
+func (m *middleware) serve(next http.Handler, w http.ResponseWriter, r *http.Request) {
+	kind, id := m.caller(r)
+	result, err := m.limiter.Allow(r.Context(), m.name+" "+kind+":"+id, m.limit)
+	if err != nil {
+		m.metrics.Incr(metricPrefix+"errors", 1, "route", m.name)
+		m.logger.Warn("failed to count rate limited request, so allowing it", zap.String("route", m.name), zap.Error(err))
+		next.ServeHTTP(w, r)
+		return
+	}
+
+	header := w.Header()
+	header.Set(LimitHeader, strconv.Itoa(m.limit.Requests))
+	header.Set(RemainingHeader, strconv.Itoa(result.Remaining))
+	header.Set(ResetHeader, seconds(result.Reset))
+	header.Set(PolicyHeader, fmt.Sprintf("%d;w=%s", m.limit.Requests, seconds(m.limit.Per)))
+
+	if !result.Allowed {
+		m.metrics.Incr(metricPrefix+"limited", 1, "route", m.name, "caller", kind)
+		retryAfter := seconds(result.RetryAfter)
+		if retryAfter == "0" {
+			retryAfter = "1"
+		}
+		header.Set("Retry-After", retryAfter)
+		_ = gorillautils.RenderProblem(w, gorillautils.Problem{
+			Status: http.StatusTooManyRequests,
+			Detail: fmt.Sprintf("The rate limit of %s requests was exceeded. Retry in %s seconds.", m.limit, retryAfter),
+		})
+		return
+	}
+
+	next.ServeHTTP(w, r)
+}
+
+// caller returns the kind of caller making r, and what identifies them. API
+// keys are identified by their ID once authenticated; an X-API-Key header
+// which was not authenticated identifies nobody, as anyone may send one.
+func (m *middleware) caller(r *http.Request) (string, string) {
+	if principal := auth.PrincipalFromContext(r.Context()); principal != nil && principal.Subject != "" {
+		if id := strings.TrimPrefix(principal.Subject, apikeys.SubjectPrefix); id != principal.Subject {
+			return CallerAPIKey, id
+		}
+		return CallerPrincipal, principal.Subject
+	}
+	if m.forwardedFor {
+		if forwarded := r.Header.Values("X-Forwarded-For"); len(forwarded) > 0 {
+			addrs := strings.Split(forwarded[len(forwarded)-1], ",")
+			if addr := strings.TrimSpace(addrs[len(addrs)-1]); addr != "" {
+				return CallerIP, addr
+			}
+		}
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return CallerIP, r.RemoteAddr
+	}
+	return CallerIP, host
+}
+
+// seconds formats d as a whole number of seconds, rounded up.
+func seconds(d time.Duration) string {
+	return strconv.FormatInt(int64(math.Ceil(d.Seconds())), 10)
+}
@@ -0,0 +1,144 @@
+package ratelimit_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/deliveroo/bnt-internal-test-go/internal/apikeys"
+	"github.com/deliveroo/bnt-internal-test-go/internal/auth"
+	"github.com/deliveroo/bnt-internal-test-go/internal/metricstest"
+	"github.com/deliveroo/bnt-internal-test-go/internal/rate"
+	"github.com/deliveroo/bnt-internal-test-go/internal/ratelimit"
+)
+
+type failingLimiter struct{}
+
+func (failingLimiter) Allow(context.Context, string, rate.Limit) (ratelimit.Result, error) {
+	return ratelimit.Result{}, errors.New("database is down")
+}
+
+func TestMiddleware(t *testing.T) {
+	limit := rate.Limit{Requests: 2, Per: time.Minute}
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	})
+
+	get := func(handler http.Handler, prepare func(r *http.Request)) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodGet, "/orders", nil)
+		r.RemoteAddr = "192.0.2.1:1234"
+		if prepare != nil {
+			prepare(r)
+		}
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		return w
+	}
+	as := func(subject string) func(r *http.Request) {
+		return func(r *http.Request) {
+			*r = *r.WithContext(auth.ContextWithPrincipal(r.Context(), &auth.Principal{Subject: subject}))
+		}
+	}
+
+	t.Run("describes the limit on allowed requests", func(t *testing.T) {
+		handler := ratelimit.Middleware(ratelimit.NewTokenBucket(), "listOrders", limit, metricstest.NewRecorder())(ok)
+
+		w := get(handler, nil)
+
+		assert.Equal(t, http.StatusNoContent, w.Code)
+		assert.Equal(t, "2", w.Header().Get(ratelimit.LimitHeader))
+		assert.Equal(t, "1", w.Header().Get(ratelimit.RemainingHeader))
+		assert.Equal(t, "30", w.Header().Get(ratelimit.ResetHeader))
+		assert.Equal(t, "2;w=60", w.Header().Get(ratelimit.PolicyHeader))
+	})
+
+	t.Run("rejects requests beyond the limit, and counts them", func(t *testing.T) {
+		metrics := metricstest.NewRecorder()
+		handler := ratelimit.Middleware(ratelimit.NewTokenBucket(), "listOrders", limit, metrics)(ok)
+		get(handler, nil)
+		get(handler, nil)
+
+		w := get(handler, nil)
+
+		assert.Equal(t, http.StatusTooManyRequests, w.Code)
+		assert.Equal(t, "application/problem+json", w.Header().Get("Content-Type"))
+		assert.Equal(t, "30", w.Header().Get("Retry-After"))
+		assert.Equal(t, "0", w.Header().Get(ratelimit.RemainingHeader))
+		assert.Equal(t, []string{"route", "listOrders", "caller", ratelimit.CallerIP}, metrics.Tags("ratelimit.limited"))
+	})
+
+	t.Run("limits each principal separately", func(t *testing.T) {
+		metrics := metricstest.NewRecorder()
+		handler := ratelimit.Middleware(ratelimit.NewTokenBucket(), "listOrders", limit, metrics)(ok)
+		get(handler, as("service-a"))
+		get(handler, as("service-a"))
+
+		assert.Equal(t, http.StatusTooManyRequests, get(handler, as("service-a")).Code)
+		assert.Equal(t, []string{"route", "listOrders", "caller", ratelimit.CallerPrincipal}, metrics.Tags("ratelimit.limited"))
+		assert.Equal(t, http.StatusNoContent, get(handler, as("service-b")).Code)
+	})
+
+	t.Run("limits each authenticated API key separately", func(t *testing.T) {
+		metrics := metricstest.NewRecorder()
+		handler := ratelimit.Middleware(ratelimit.NewTokenBucket(), "listOrders", limit, metrics)(ok)
+		get(handler, as(apikeys.SubjectPrefix+"key-a"))
+		get(handler, as(apikeys.SubjectPrefix+"key-a"))
+
+		assert.Equal(t, http.StatusTooManyRequests, get(handler, as(apikeys.SubjectPrefix+"key-a")).Code)
+		assert.Equal(t, []string{"route", "listOrders", "caller", ratelimit.CallerAPIKey}, metrics.Tags("ratelimit.limited"))
+		assert.Equal(t, http.StatusNoContent, get(handler, as(apikeys.SubjectPrefix+"key-b")).Code)
+	})
+
+	t.Run("identifies callers by address when their API key is not authenticated", func(t *testing.T) {
+		handler := ratelimit.Middleware(ratelimit.NewTokenBucket(), "listOrders", limit, metricstest.NewRecorder())(ok)
+		withKey := func(key string) func(r *http.Request) {
+			return func(r *http.Request) { r.Header.Set(apikeys.Header, key) }
+		}
+		get(handler, withKey("bntk_a"))
+		get(handler, withKey("bntk_a"))
+
+		assert.Equal(t, http.StatusTooManyRequests, get(handler, withKey("bntk_b")).Code)
+	})
+
+	t.Run("identifies callers by X-Forwarded-For only when trusted", func(t *testing.T) {
+		from := func(addr string) func(r *http.Request) {
+			return func(r *http.Request) { r.Header.Set("X-Forwarded-For", "203.0.113.9, "+addr) }
+		}
+
+		trusting := ratelimit.Middleware(ratelimit.NewTokenBucket(), "listOrders", limit, metricstest.NewRecorder(), ratelimit.WithForwardedFor())(ok)
+		get(trusting, from("198.51.100.1"))
+		get(trusting, from("198.51.100.1"))
+		assert.Equal(t, http.StatusNoContent, get(trusting, from("198.51.100.2")).Code)
+
+		untrusting := ratelimit.Middleware(ratelimit.NewTokenBucket(), "listOrders", limit, metricstest.NewRecorder())(ok)
+		get(untrusting, from("198.51.100.1"))
+		get(untrusting, from("198.51.100.1"))
+		assert.Equal(t, http.StatusTooManyRequests, get(untrusting, from("198.51.100.2")).Code)
+	})
+
+	t.Run("limits each route separately", func(t *testing.T) {
+		limiter := ratelimit.NewTokenBucket()
+		list := ratelimit.Middleware(limiter, "listOrders", limit, metricstest.NewRecorder())(ok)
+		create := ratelimit.Middleware(limiter, "createOrder", limit, metricstest.NewRecorder())(ok)
+		get(list, nil)
+		get(list, nil)
+
+		assert.Equal(t, http.StatusNoContent, get(create, nil).Code)
+	})
+
+	t.Run("allows requests when the limiter fails", func(t *testing.T) {
+		metrics := metricstest.NewRecorder()
+		handler := ratelimit.Middleware(failingLimiter{}, "listOrders", limit, metrics)(ok)
+
+		w := get(handler, nil)
+
+		assert.Equal(t, http.StatusNoContent, w.Code)
+		assert.Empty(t, w.Header().Get(ratelimit.LimitHeader))
+		assert.Equal(t, []string{"route", "listOrders"}, metrics.Tags("ratelimit.errors"))
+	})
+}
@@ -0,0 +1,115 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/deliveroo/bnt-internal-test-go/internal/database/dberrors"
+	"github.com/deliveroo/bnt-internal-test-go/internal/rate"
+)
+
+// PostgresLimiter is a Limiter counting requests in the rate_limit_windows
+// table, shared by every instance of the service.
+//
+// Requests are counted in fixed windows of a limit's period, aligned to the
+// Unix epoch. The requests made in the last period are estimated from the
+// count of the current window and the share of the previous window's count
+// which the period still overlaps, which smooths out bursts at the edges of
+// windows. Rejected requests are counted too, so that callers which retry
+// without waiting stay limited.
+type PostgresLimiter struct {
+	pool *pgxpool.Pool
+}
+
+var _ Limiter = (*PostgresLimiter)(nil)
+
+// NewPostgresLimiter returns a Limiter counting in the database of pool, which
+// must be writable.
+func NewPostgresLimiter(pool *pgxpool.Pool) *PostgresLimiter {
+	return &PostgresLimiter{pool: pool}
+}
+
+// Allow counts a request made with key in the current window, and estimates
+// whether the requests of the last period are within limit. Windows are kept
+// for two periods, so that the next window can see them.
+func (l *PostgresLimiter) Allow(ctx context.Context, key string, limit rate.Limit) (Result, error) {
+	var elapsed, previous, current int64
+	err := l.pool.QueryRow(ctx, `
+		WITH clock AS (
+			SELECT (extract(epoch FROM now()) * 1000000)::bigint AS now_us
+		), bounds AS (
+			SELECT now_us, now_us / $2::bigint * $2::bigint AS start_us FROM clock
+		), current_window AS (
+			INSERT INTO rate_limit_windows AS w (key, window_start, requests, expires_at)
+			SELECT $1,
+				to_timestamp(start_us / 1000000.0),
+				1,
+				to_timestamp((start_us + 2 * $2::bigint) / 1000000.0)
+			FROM bounds
+			ON CONFLICT (key, window_start) DO UPDATE SET requests = w.requests + 1
+			RETURNING window_start, requests
+		)
+		SELECT
+			(SELECT now_us - start_us FROM bounds),
+			coalesce(previous.requests, 0),
+			current_window.requests
+		FROM current_window
+		LEFT JOIN rate_limit_windows previous
+			ON previous.key = $1
+			AND previous.window_start = current_window.window_start - $2::bigint * interval '1 microsecond'`,
+		key, limit.Per.Microseconds(),
+	).Scan(&elapsed, &previous, &current)
+	if err != nil {
+		return Result{}, fmt.Errorf("failed to count rate limited request: %w", dberrors.Map(err))
+	}
+
+	return slidingWindow(limit, time.Duration(elapsed)*time.Microsecond, int(previous), int(current)), nil
+}
+
+// DeleteExpired deletes the windows which are too old to be counted,
+// returning how many there were.
+func (l *PostgresLimiter) DeleteExpired(ctx context.Context) (int64, error) {
+	tag, err := l.pool.Exec(ctx, `DELETE FROM rate_limit_windows WHERE expires_at < now()`)
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete expired rate limit windows: %w", dberrors.Map(err))
+	}
+	return tag.RowsAffected(), nil
+}
+
+// slidingWindow decides whether a request is within limit, elapsed into the
+// current window, given the requests counted in the previous and current
+// windows, including this one.
+func slidingWindow(limit rate.Limit, elapsed time.Duration, previous, current int) Result {
+	per := float64(limit.Per)
+	requests := float64(limit.Requests)
+	overlap := 1 - float64(elapsed)/per
+	estimate := float64(previous)*overlap + float64(current)
+
+	result := Result{
+		Allowed:   estimate <= requests,
+		Limit:     limit,
+		Remaining: int(math.Max(0, math.Floor(requests-estimate))),
+		// The requests of this window count until the end of the next one.
+		Reset: 2*limit.Per - elapsed,
+	}
+	if result.Allowed {
+		return result
+	}
+
+	// Find when the estimate leaves room for one more request.
+	var wait float64
+	switch {
+	case current+1 <= limit.Requests:
+		// Once enough of the previous window has slid out of the period.
+		wait = per*(1-(requests-float64(current)-1)/float64(previous)) - float64(elapsed)
+	default:
+		// Once the next window starts, and enough of this one has slid out.
+		wait = per - float64(elapsed) + per*(1-(requests-1)/float64(current))
+	}
+	result.RetryAfter = time.Duration(math.Max(0, wait))
+	return result
+}
@@ -0,0 +1,77 @@
+package ratelimit_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/deliveroo/bnt-internal-test-go/internal/database/databasetest"
+	"github.com/deliveroo/bnt-internal-test-go/internal/rate"
+	"github.com/deliveroo/bnt-internal-test-go/internal/ratelimit"
+)
+
+func TestPostgresLimiter(t *testing.T) {
+	ctx := context.Background()
+	limit := rate.Limit{Requests: 3, Per: time.Hour}
+	newLimiter := func(t *testing.T) *ratelimit.PostgresLimiter {
+		t.Helper()
+		return ratelimit.NewPostgresLimiter(databasetest.NewPool(t))
+	}
+
+	t.Run("Allow allows requests up to the limit", func(t *testing.T) {
+		limiter := newLimiter(t)
+
+		for remaining := 2; remaining >= 0; remaining-- {
+			result, err := limiter.Allow(ctx, "caller", limit)
+			assert.Nil(t, err)
+			assert.True(t, result.Allowed)
+			assert.Equal(t, limit, result.Limit)
+			assert.Equal(t, remaining, result.Remaining)
+			assert.Greater(t, result.Reset, time.Duration(0))
+			assert.Equal(t, time.Duration(0), result.RetryAfter)
+		}
+	})
+
+	t.Run("Allow rejects requests beyond the limit", func(t *testing.T) {
+		limiter := newLimiter(t)
+		for i := 0; i < limit.Requests; i++ {
+			_, _ = limiter.Allow(ctx, "caller", limit)
+		}
+
+		result, err := limiter.Allow(ctx, "caller", limit)
+		assert.Nil(t, err)
+		assert.False(t, result.Allowed)
+		assert.Equal(t, 0, result.Remaining)
+		assert.Greater(t, result.RetryAfter, time.Duration(0))
+		assert.LessOrEqual(t, result.RetryAfter, 2*limit.Per)
+	})
+
+	t.Run("Allow counts each key separately", func(t *testing.T) {
+		limiter := newLimiter(t)
+		for i := 0; i < limit.Requests; i++ {
+			_, _ = limiter.Allow(ctx, "caller", limit)
+		}
+
+		result, err := limiter.Allow(ctx, "other", limit)
+		assert.Nil(t, err)
+		assert.True(t, result.Allowed)
+		assert.Equal(t, limit.Requests-1, result.Remaining)
+	})
+}
+
+func TestPostgresLimiterDeleteExpired(t *testing.T) {
+	ctx := context.Background()
+	pool := databasetest.NewPool(t)
+	limiter := ratelimit.NewPostgresLimiter(pool)
+
+	_, err := limiter.Allow(ctx, "caller", rate.Limit{Requests: 1, Per: time.Hour})
+	assert.Nil(t, err)
+	_, err = pool.Exec(ctx, `UPDATE rate_limit_windows SET expires_at = now() - interval '1 second'`)
+	assert.Nil(t, err)
+
+	deleted, err := limiter.DeleteExpired(ctx)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(1), deleted)
+}
@@ -0,0 +1,38 @@
+// Package ratelimit limits the rate of requests from each caller.
+//
+// A Limiter counts the requests made with a key, e.g. identifying the caller
+// of a route, and decides whether each is within a rate.Limit. TokenBucket counts
+// in memory, so each instance of the service allows the full limit, and
+// PostgresLimiter counts in a sliding window shared by every instance.
+// Middleware enforces limits on HTTP requests, describing them with the
+// RateLimit-* headers.
+package ratelimit
+
+import (
+	"context"
+	"time"
+
+	"github.com/deliveroo/bnt-internal-test-go/internal/rate"
+)
+
+// Result is the outcome of counting a request.
+type Result struct {
+	// Allowed is whether the request is within the limit.
+	Allowed bool
+	// Limit is the limit the request was counted against.
+	Limit rate.Limit
+	// Remaining is how many more requests are allowed now.
+	Remaining int
+	// Reset is how long until the full limit is available again.
+	Reset time.Duration
+	// RetryAfter is how long until another request is allowed, when this one
+	// is not.
+	RetryAfter time.Duration
+}
+
+// Limiter counts requests against limits.
+type Limiter interface {
+	// Allow counts a request made with key, and returns whether it is within
+	// limit.
+	Allow(ctx context.Context, key string, limit rate.Limit) (Result, error)
+}
@@ -0,0 +1,37 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/deliveroo/bnt-internal-test-go/internal/rate"
+)
+
+func TestSlidingWindow(t *testing.T) {
+	limit := rate.Limit{Requests: 10, Per: time.Minute}
+
+	t.Run("counts the share of the previous window the period overlaps", func(t *testing.T) {
+		// 10 * 0.5 + 4 = 9
+		result := slidingWindow(limit, 30*time.Second, 10, 4)
+		assert.True(t, result.Allowed)
+		assert.Equal(t, 1, result.Remaining)
+		assert.Equal(t, 90*time.Second, result.Reset)
+	})
+
+	t.Run("waits for the previous window to slide out", func(t *testing.T) {
+		// 10 * 0.5 + 6 = 11, and 12s later 10 * 0.3 + 6 + 1 = 10 leaves room.
+		result := slidingWindow(limit, 30*time.Second, 10, 6)
+		assert.False(t, result.Allowed)
+		assert.Equal(t, 0, result.Remaining)
+		assert.Equal(t, 12*time.Second, result.RetryAfter)
+	})
+
+	t.Run("waits for the next window when the current one is full", func(t *testing.T) {
+		result := slidingWindow(limit, 45*time.Second, 0, 11)
+		assert.False(t, result.Allowed)
+		// 15s until the next window, then 11 * (1 - t/60) + 1 <= 10.
+		assert.InDelta(t, float64(15*time.Second+time.Minute*2/11), float64(result.RetryAfter), float64(time.Millisecond))
+	})
+}
@@ -0,0 +1,109 @@
+package ratelimit
+
+import (
+	"container/list"
+	"context"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/deliveroo/bnt-internal-test-go/internal/rate"
+)
+
+// defaultMaxBuckets bounds the number of keys counted, so that requests from
+// many callers, e.g. with spoofed addresses, cannot exhaust memory.
+const defaultMaxBuckets = 100_000
+
+// TokenBucket is a Limiter counting requests in memory. Each key has a bucket
+// holding up to a limit's worth of tokens, refilled steadily over its period,
+// and each request takes a token, so that callers may burst up to the limit.
+// Every instance of the service counts separately.
+type TokenBucket struct {
+	now        func() time.Time
+	maxBuckets int
+
+	mu      sync.Mutex
+	buckets map[string]*list.Element
+	// recent orders the buckets from the most to the least recently updated.
+	recent *list.List
+}
+
+type bucket struct {
+	key       string
+	tokens    float64
+	updatedAt time.Time
+}
+
+var _ Limiter = (*TokenBucket)(nil)
+
+// TokenBucketOption configures a TokenBucket.
+type TokenBucketOption func(*TokenBucket)
+
+// WithClock sets the source of the current time. Defaults to time.Now.
+func WithClock(now func() time.Time) TokenBucketOption {
+	return func(b *TokenBucket) {
+		b.now = now
+	}
+}
+
+// WithMaxBuckets sets how many keys are counted. Beyond it, the buckets of
+// the least recently seen keys are evicted. Defaults to 100,000.
+func WithMaxBuckets(n int) TokenBucketOption {
+	return func(b *TokenBucket) {
+		b.maxBuckets = n
+	}
+}
+
+// NewTokenBucket returns an empty TokenBucket.
+func NewTokenBucket(opts ...TokenBucketOption) *TokenBucket {
+	b := &TokenBucket{now: time.Now, maxBuckets: defaultMaxBuckets, buckets: map[string]*list.Element{}, recent: list.New()}
+	for _, opt := range opts {
+		opt(b)
+	}
+	return b
+}
+
+// Allow takes a token from the bucket of key, when it has one.
+func (b *TokenBucket) Allow(_ context.Context, key string, limit rate.Limit) (Result, error) {
+	now := b.now()
+	capacity := float64(limit.Requests)
+	perToken := limit.Per / time.Duration(limit.Requests)
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	bkt := b.bucket(key, capacity, now)
+	elapsed := now.Sub(bkt.updatedAt)
+	bkt.tokens = math.Min(capacity, bkt.tokens+float64(elapsed)/float64(perToken))
+	bkt.updatedAt = now
+
+	result := Result{Limit: limit}
+	if bkt.tokens >= 1 {
+		bkt.tokens--
+		result.Allowed = true
+	} else {
+		result.RetryAfter = time.Duration((1 - bkt.tokens) * float64(perToken))
+	}
+	result.Remaining = int(bkt.tokens)
+	result.Reset = time.Duration((capacity - bkt.tokens) * float64(perToken))
+	return result, nil
+}
+
+// bucket returns the bucket of key, marked as the most recently updated. A
+// full bucket is created for new keys, evicting the least recently updated
+// bucket when there are maxBuckets already. It must be called with mu held.
+func (b *TokenBucket) bucket(key string, capacity float64, now time.Time) *bucket {
+	if elem, ok := b.buckets[key]; ok {
+		b.recent.MoveToFront(elem)
+		return elem.Value.(*bucket) //nolint:forcetypeassert // only buckets are listed
+	}
+
+	if len(b.buckets) >= b.maxBuckets {
+		oldest := b.recent.Back()
+		b.recent.Remove(oldest)
+		delete(b.buckets, oldest.Value.(*bucket).key) //nolint:forcetypeassert // only buckets are listed
+	}
+	bkt := &bucket{key: key, tokens: capacity, updatedAt: now}
+	b.buckets[key] = b.recent.PushFront(bkt)
+	return bkt
+}
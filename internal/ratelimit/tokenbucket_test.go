@@ -0,0 +1,112 @@
+package ratelimit_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/deliveroo/bnt-internal-test-go/internal/rate"
+	"github.com/deliveroo/bnt-internal-test-go/internal/ratelimit"
+)
+
+func TestTokenBucket(t *testing.T) {
+	ctx := context.Background()
+	limit := rate.Limit{Requests: 3, Per: time.Hour}
+
+	t.Run("Allow allows requests up to the limit", func(t *testing.T) {
+		limiter := ratelimit.NewTokenBucket()
+
+		for remaining := 2; remaining >= 0; remaining-- {
+			result, err := limiter.Allow(ctx, "caller", limit)
+			assert.Nil(t, err)
+			assert.True(t, result.Allowed)
+			assert.Equal(t, limit, result.Limit)
+			assert.Equal(t, remaining, result.Remaining)
+			assert.Greater(t, result.Reset, time.Duration(0))
+			assert.Equal(t, time.Duration(0), result.RetryAfter)
+		}
+	})
+
+	t.Run("Allow rejects requests beyond the limit", func(t *testing.T) {
+		limiter := ratelimit.NewTokenBucket()
+		for i := 0; i < limit.Requests; i++ {
+			_, _ = limiter.Allow(ctx, "caller", limit)
+		}
+
+		result, err := limiter.Allow(ctx, "caller", limit)
+		assert.Nil(t, err)
+		assert.False(t, result.Allowed)
+		assert.Equal(t, 0, result.Remaining)
+		assert.Greater(t, result.RetryAfter, time.Duration(0))
+		assert.LessOrEqual(t, result.RetryAfter, limit.Per/time.Duration(limit.Requests))
+	})
+
+	t.Run("Allow counts each key separately", func(t *testing.T) {
+		limiter := ratelimit.NewTokenBucket()
+		for i := 0; i < limit.Requests; i++ {
+			_, _ = limiter.Allow(ctx, "caller", limit)
+		}
+
+		result, err := limiter.Allow(ctx, "other", limit)
+		assert.Nil(t, err)
+		assert.True(t, result.Allowed)
+		assert.Equal(t, limit.Requests-1, result.Remaining)
+	})
+}
+
+func TestTokenBucketRefills(t *testing.T) {
+	ctx := context.Background()
+	limit := rate.Limit{Requests: 2, Per: time.Minute}
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	bucket := ratelimit.NewTokenBucket(ratelimit.WithClock(func() time.Time { return now }))
+
+	t.Run("a drained bucket says when it has a token again", func(t *testing.T) {
+		_, _ = bucket.Allow(ctx, "caller", limit)
+		_, _ = bucket.Allow(ctx, "caller", limit)
+
+		result, err := bucket.Allow(ctx, "caller", limit)
+		assert.Nil(t, err)
+		assert.False(t, result.Allowed)
+		assert.Equal(t, 30*time.Second, result.RetryAfter)
+		assert.Equal(t, time.Minute, result.Reset)
+	})
+
+	t.Run("tokens are refilled steadily", func(t *testing.T) {
+		now = now.Add(30 * time.Second)
+
+		result, err := bucket.Allow(ctx, "caller", limit)
+		assert.Nil(t, err)
+		assert.True(t, result.Allowed)
+		assert.Equal(t, 0, result.Remaining)
+	})
+
+	t.Run("buckets refill up to the limit", func(t *testing.T) {
+		now = now.Add(time.Hour)
+
+		result, err := bucket.Allow(ctx, "caller", limit)
+		assert.Nil(t, err)
+		assert.True(t, result.Allowed)
+		assert.Equal(t, 1, result.Remaining)
+	})
+}
+
+func TestTokenBucketEviction(t *testing.T) {
+	ctx := context.Background()
+	limit := rate.Limit{Requests: 1, Per: time.Minute}
+	bucket := ratelimit.NewTokenBucket(ratelimit.WithMaxBuckets(2))
+	allowed := func(key string) bool {
+		result, err := bucket.Allow(ctx, key, limit)
+		assert.Nil(t, err)
+		return result.Allowed
+	}
+
+	allowed("busy")
+	allowed("idle")
+	assert.False(t, allowed("busy"))
+
+	assert.True(t, allowed("new"), "evicts the least recently seen bucket")
+	assert.False(t, allowed("busy"), "keeps the buckets of recent callers")
+	assert.True(t, allowed("idle"), "the evicted bucket starts full")
+}
@@ -0,0 +1,65 @@
+// Package securityheaders sets the response headers which tell browsers to
+// protect the users of the service: to only reach it over HTTPS, not to guess
+// the type of responses, not to render them in frames, and which content they
+// may load.
+package securityheaders
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Policy is which security headers are set. Headers with a zero value are
+// not set, except X-Content-Type-Options, which always is.
+type Policy struct {
+	// HSTSMaxAge is how long browsers should only reach the service, and its
+	// subdomains when HSTSIncludeSubdomains is set, over HTTPS.
+	HSTSMaxAge            time.Duration
+	HSTSIncludeSubdomains bool
+	// FrameOptions is the X-Frame-Options header, DENY or SAMEORIGIN.
+	FrameOptions string
+	// ContentSecurityPolicy restricts the content which responses may load.
+	ContentSecurityPolicy string
+	// ReferrerPolicy restricts the Referer header browsers send when leaving
+	// the responses.
+	ReferrerPolicy string
+}
+
+// Middleware sets the security headers of policy on every response. They are
+// set before the handler runs, so that handlers may relax them, e.g. the
+// Content-Security-Policy of a page which loads scripts.
+func Middleware(policy Policy) func(http.Handler) http.Handler {
+	headers := policy.headers()
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			header := w.Header()
+			for name, value := range headers {
+				header.Set(name, value)
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// headers returns the headers set by the policy, by name.
+func (p Policy) headers() map[string]string {
+	headers := map[string]string{"X-Content-Type-Options": "nosniff"}
+	if p.HSTSMaxAge > 0 {
+		hsts := "max-age=" + strconv.FormatInt(int64(p.HSTSMaxAge.Seconds()), 10)
+		if p.HSTSIncludeSubdomains {
+			hsts += "; includeSubDomains"
+		}
+		headers["Strict-Transport-Security"] = hsts
+	}
+	if p.FrameOptions != "" {
+		headers["X-Frame-Options"] = p.FrameOptions
+	}
+	if p.ContentSecurityPolicy != "" {
+		headers["Content-Security-Policy"] = p.ContentSecurityPolicy
+	}
+	if p.ReferrerPolicy != "" {
+		headers["Referrer-Policy"] = p.ReferrerPolicy
+	}
+	return headers
+}
@@ -0,0 +1,51 @@
+package securityheaders_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/deliveroo/bnt-internal-test-go/internal/securityheaders"
+)
+
+func TestMiddleware(t *testing.T) {
+	serve := func(policy securityheaders.Policy, handler http.Handler) http.Header {
+		rec := httptest.NewRecorder()
+		securityheaders.Middleware(policy)(handler).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+		return rec.Header()
+	}
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+
+	t.Run("sets the headers of the policy", func(t *testing.T) {
+		header := serve(securityheaders.Policy{
+			HSTSMaxAge:            365 * 24 * time.Hour,
+			HSTSIncludeSubdomains: true,
+			FrameOptions:          "DENY",
+			ContentSecurityPolicy: "default-src 'none'",
+			ReferrerPolicy:        "no-referrer",
+		}, ok)
+
+		assert.Equal(t, "max-age=31536000; includeSubDomains", header.Get("Strict-Transport-Security"))
+		assert.Equal(t, "nosniff", header.Get("X-Content-Type-Options"))
+		assert.Equal(t, "DENY", header.Get("X-Frame-Options"))
+		assert.Equal(t, "default-src 'none'", header.Get("Content-Security-Policy"))
+		assert.Equal(t, "no-referrer", header.Get("Referrer-Policy"))
+	})
+
+	t.Run("omits the headers the policy leaves empty", func(t *testing.T) {
+		header := serve(securityheaders.Policy{}, ok)
+
+		assert.Equal(t, http.Header{"X-Content-Type-Options": {"nosniff"}}, header)
+	})
+
+	t.Run("lets handlers relax the headers", func(t *testing.T) {
+		header := serve(securityheaders.Policy{ContentSecurityPolicy: "default-src 'none'"}, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Security-Policy", "default-src 'self'")
+		}))
+
+		assert.Equal(t, "default-src 'self'", header.Get("Content-Security-Policy"))
+	})
+}
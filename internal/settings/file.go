@@ -0,0 +1,73 @@
+package settings
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"go.uber.org/zap"
+	"gopkg.in/yaml.v3"
+)
+
+// LoadFile replaces the settings with those in the YAML file at path, applied
+// over the base settings: settings missing from the file revert to their base
+// value. A missing file reverts every setting.
+func (s *Store) LoadFile(path string) (Change, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return Change{}, fmt.Errorf("failed to read settings file: %w", err)
+	}
+
+	values, err := decodeFile(s.Base(), raw)
+	if err != nil {
+		return Change{}, fmt.Errorf("failed to parse settings file %s: %w", path, err)
+	}
+
+	return s.Replace("file "+path, values)
+}
+
+// WatchFile loads the settings file at path, then checks it for changes every
+// interval until ctx is done. Files which cannot be loaded are logged, and the
+// current settings are kept.
+func (s *Store) WatchFile(ctx context.Context, path string, interval time.Duration) {
+	var last []byte
+	load := func() {
+		raw, err := os.ReadFile(path)
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			s.logger.Error("failed to read settings file", zap.String("path", path), zap.Error(err))
+			return
+		}
+		if last != nil && bytes.Equal(raw, last) {
+			return
+		}
+		last = append([]byte{}, raw...)
+
+		if _, err := s.LoadFile(path); err != nil {
+			s.logger.Error("failed to load settings file", zap.String("path", path), zap.Error(err))
+		}
+	}
+
+	load()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			load()
+		}
+	}
+}
+
+func decodeFile(base Values, raw []byte) (Values, error) {
+	if err := yaml.Unmarshal(raw, &base); err != nil {
+		return Values{}, err //nolint:wrapcheck // wrapped by LoadFile
+	}
+	return base, nil
+}
@@ -0,0 +1,83 @@
+package settings
+
+import (
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// SetLogLevel changes the level of the logger called name, or the overall level
+// when name is empty. When ttl is positive the change is temporary: after ttl
+// the previous level is restored, unless the level has been changed again in
+// the meantime.
+func (s *Store) SetLogLevel(source, name string, level zapcore.Level, ttl time.Duration) (Change, error) {
+	var previous *zapcore.Level
+	change, err := s.Update(source, func(v *Values) {
+		previous = v.levelOf(name)
+		v.setLevelOf(name, &level)
+	})
+	if err != nil || ttl <= 0 || len(change.Diffs) == 0 {
+		return change, err
+	}
+
+	time.AfterFunc(ttl, func() {
+		_, err := s.Update(source+" (expired after "+ttl.String()+")", func(v *Values) {
+			if current := v.levelOf(name); current != nil && *current == level {
+				v.setLevelOf(name, previous)
+			}
+		})
+		if err != nil {
+			s.logger.Error("failed to restore log level", zap.String("logger", name), zap.Error(err))
+		}
+	})
+
+	return change, nil
+}
+
+// ToggleDebug switches the overall log level to debug, or when it is already
+// debug, back to the level the store was created with. When that is debug too,
+// it switches to info.
+func (s *Store) ToggleDebug(source string) (Change, error) {
+	return s.Update(source, func(v *Values) {
+		switch {
+		case v.LogLevel != zapcore.DebugLevel:
+			v.LogLevel = zapcore.DebugLevel
+		case s.base.LogLevel != zapcore.DebugLevel:
+			v.LogLevel = s.base.LogLevel
+		default:
+			v.LogLevel = zapcore.InfoLevel
+		}
+	})
+}
+
+// levelOf returns the level of the logger called name, or nil when it has no
+// override.
+func (v *Values) levelOf(name string) *zapcore.Level {
+	if name == "" {
+		level := v.LogLevel
+		return &level
+	}
+	if level, ok := v.LoggerLevels[name]; ok {
+		return &level
+	}
+	return nil
+}
+
+// setLevelOf sets the level of the logger called name, removing its override
+// when level is nil.
+func (v *Values) setLevelOf(name string, level *zapcore.Level) {
+	switch {
+	case name == "":
+		if level != nil {
+			v.LogLevel = *level
+		}
+	case level == nil:
+		delete(v.LoggerLevels, name)
+	default:
+		if v.LoggerLevels == nil {
+			v.LoggerLevels = map[string]zapcore.Level{}
+		}
+		v.LoggerLevels[name] = *level
+	}
+}
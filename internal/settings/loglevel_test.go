@@ -0,0 +1,57 @@
+package settings
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap/zapcore"
+)
+
+func TestSetLogLevel(t *testing.T) {
+	t.Run("restores the previous level after the TTL", func(t *testing.T) {
+		store := newStore(t)
+
+		_, err := store.SetLogLevel("test", "", zapcore.DebugLevel, 10*time.Millisecond)
+		assert.Nil(t, err)
+		assert.Equal(t, zapcore.DebugLevel, store.Current().LogLevel)
+
+		assert.Eventually(t, func() bool {
+			return store.Current().LogLevel == zapcore.InfoLevel
+		}, time.Second, time.Millisecond)
+		assert.Equal(t, "test (expired after 10ms)", store.History()[1].Source)
+	})
+
+	t.Run("removes overrides for named loggers after the TTL", func(t *testing.T) {
+		store := newStore(t)
+
+		_, err := store.SetLogLevel("test", "orders", zapcore.DebugLevel, 10*time.Millisecond)
+		assert.Nil(t, err)
+		assert.Equal(t, zapcore.DebugLevel, store.Current().LoggerLevels["orders"])
+
+		assert.Eventually(t, func() bool {
+			_, ok := store.Current().LoggerLevels["orders"]
+			return !ok
+		}, time.Second, time.Millisecond)
+	})
+
+	t.Run("keeps levels changed again before the TTL", func(t *testing.T) {
+		store := newStore(t)
+
+		_, _ = store.SetLogLevel("test", "", zapcore.DebugLevel, 10*time.Millisecond)
+		_, _ = store.SetLogLevel("test", "", zapcore.WarnLevel, 0)
+
+		time.Sleep(50 * time.Millisecond)
+		assert.Equal(t, zapcore.WarnLevel, store.Current().LogLevel)
+	})
+}
+
+func TestToggleDebug(t *testing.T) {
+	store := newStore(t)
+
+	_, _ = store.ToggleDebug("test")
+	assert.Equal(t, zapcore.DebugLevel, store.Current().LogLevel)
+
+	_, _ = store.ToggleDebug("test")
+	assert.Equal(t, zapcore.InfoLevel, store.Current().LogLevel)
+}
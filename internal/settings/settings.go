@@ -0,0 +1,199 @@
+// Package settings holds the settings which can be changed while the service is
+// running, such as the log level and circuit breaker thresholds.
+//
+// Settings are kept in a Store. Components subscribe to the part of the
+// settings they use, and are notified with its new value whenever it changes.
+// Settings can be changed by a watched file (see WatchFile) or through the
+// admin endpoints, and every change is logged and kept in the store's history.
+package settings
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"go.uber.org/zap/zapcore"
+
+	"github.com/deliveroo/bnt-internal-test-go/internal/config"
+)
+
+// Values are the runtime settings.
+type Values struct {
+	// LogLevel is the minimum level of log entries which are written.
+	LogLevel zapcore.Level `json:"log_level" yaml:"log_level"`
+
+	// LoggerLevels overrides LogLevel for named loggers, by name.
+	LoggerLevels map[string]zapcore.Level `json:"logger_levels,omitempty" yaml:"logger_levels,omitempty"`
+
+	// SpanLogging writes every APM span to the logger, for debugging.
+	SpanLogging bool `json:"span_logging" yaml:"span_logging"`
+
+	// StatsDLogging writes every StatsD metric to the logger, for debugging.
+	StatsDLogging bool `json:"statsd_logging" yaml:"statsd_logging"`
+
+	// Circuit configures the circuit breakers of HTTP clients. Zero values
+	// leave the value the circuit was created with.
+	Circuit config.Circuit `json:"circuit" yaml:"circuit"`
+
+	// Circuits overrides Circuit for HTTP clients, by client name.
+	Circuits map[string]config.Circuit `json:"circuits,omitempty" yaml:"circuits,omitempty"`
+
+	// RateLimits limits the rate of requests made by HTTP clients, by client
+	// name. Clients without a rate limit are not limited.
+	RateLimits map[string]RateLimit `json:"rate_limits,omitempty" yaml:"rate_limits,omitempty"`
+}
+
+// RateLimit limits the rate of requests with a token bucket.
+type RateLimit struct {
+	// RequestsPerSecond is the rate the bucket refills at. Zero means no limit.
+	RequestsPerSecond float64 `json:"requests_per_second" yaml:"requests_per_second"`
+
+	// Burst is the size of the bucket: the number of requests which can be made
+	// at once.
+	Burst int `json:"burst" yaml:"burst"`
+}
+
+// CircuitFor returns the circuit configuration for the HTTP client called name.
+func (v Values) CircuitFor(name string) config.Circuit {
+	if circuit, ok := v.Circuits[name]; ok {
+		return circuit
+	}
+	return v.Circuit
+}
+
+// Validate checks the values are usable, so that invalid settings are rejected
+// as a whole rather than being partially applied.
+func (v Values) Validate() error {
+	if !validLevel(v.LogLevel) {
+		return fmt.Errorf("log_level %q is not supported", v.LogLevel)
+	}
+	for name, level := range v.LoggerLevels {
+		if name == "" {
+			return errors.New("logger_levels must name a logger")
+		}
+		if !validLevel(level) {
+			return fmt.Errorf("logger_levels.%s %q is not supported", name, level)
+		}
+	}
+
+	if err := validateCircuit("circuit", v.Circuit); err != nil {
+		return err
+	}
+	for name, circuit := range v.Circuits {
+		if err := validateCircuit("circuits."+name, circuit); err != nil {
+			return err
+		}
+	}
+
+	for name, limit := range v.RateLimits {
+		if limit.RequestsPerSecond < 0 {
+			return fmt.Errorf("rate_limits.%s.requests_per_second must not be negative", name)
+		}
+		if limit.RequestsPerSecond > 0 && limit.Burst < 1 {
+			return fmt.Errorf("rate_limits.%s.burst must be at least 1", name)
+		}
+	}
+
+	return nil
+}
+
+// Patch returns v with the JSON merge patch (RFC 7396) applied: settings
+// missing from patch keep their value, including the fields of a circuit or
+// rate limit which is only partly given, and null removes a setting, such as
+// the circuit of a client. Unknown settings are rejected.
+func (v Values) Patch(patch []byte) (Values, error) {
+	var changes interface{}
+	if err := json.Unmarshal(patch, &changes); err != nil {
+		return v, fmt.Errorf("failed to parse patch: %w", err)
+	}
+	if _, ok := changes.(map[string]interface{}); !ok {
+		return v, errors.New("patch must be a JSON object")
+	}
+
+	raw, err := json.Marshal(v)
+	if err != nil {
+		return v, fmt.Errorf("failed to encode settings: %w", err)
+	}
+	var current interface{}
+	if err := json.Unmarshal(raw, &current); err != nil {
+		return v, fmt.Errorf("failed to decode settings: %w", err)
+	}
+	if raw, err = json.Marshal(mergePatch(current, changes)); err != nil {
+		return v, fmt.Errorf("failed to encode settings: %w", err)
+	}
+
+	var out Values
+	decoder := json.NewDecoder(bytes.NewReader(raw))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&out); err != nil {
+		return v, fmt.Errorf("failed to apply patch: %w", err)
+	}
+	return out, nil
+}
+
+// mergePatch applies patch to target as RFC 7396 describes.
+func mergePatch(target, patch interface{}) interface{} {
+	changes, ok := patch.(map[string]interface{})
+	if !ok {
+		return patch
+	}
+	values, ok := target.(map[string]interface{})
+	if !ok {
+		values = map[string]interface{}{}
+	}
+	for key, change := range changes {
+		if change == nil {
+			delete(values, key)
+			continue
+		}
+		values[key] = mergePatch(values[key], change)
+	}
+	return values
+}
+
+func validLevel(level zapcore.Level) bool {
+	return level >= zapcore.DebugLevel && level <= zapcore.FatalLevel
+}
+
+func validateCircuit(path string, c config.Circuit) error {
+	for name, value := range map[string]int{
+		"timeout":                  c.Timeout,
+		"max_concurrent_requests":  c.MaxConcurrentRequests,
+		"request_volume_threshold": c.RequestVolumeThreshold,
+		"sleep_window":             c.SleepWindow,
+		"error_percent_threshold":  c.ErrorPercentThreshold,
+	} {
+		if value < 0 {
+			return fmt.Errorf("%s.%s must not be negative", path, name)
+		}
+	}
+	if c.ErrorPercentThreshold > 100 {
+		return fmt.Errorf("%s.error_percent_threshold must be at most 100", path)
+	}
+	return nil
+}
+
+// clone returns a copy of v which shares no maps with it.
+func (v Values) clone() Values {
+	out := v
+	if v.LoggerLevels != nil {
+		out.LoggerLevels = make(map[string]zapcore.Level, len(v.LoggerLevels))
+		for name, level := range v.LoggerLevels {
+			out.LoggerLevels[name] = level
+		}
+	}
+	if v.Circuits != nil {
+		out.Circuits = make(map[string]config.Circuit, len(v.Circuits))
+		for name, circuit := range v.Circuits {
+			out.Circuits[name] = circuit
+		}
+	}
+	if v.RateLimits != nil {
+		out.RateLimits = make(map[string]RateLimit, len(v.RateLimits))
+		for name, limit := range v.RateLimits {
+			out.RateLimits[name] = limit
+		}
+	}
+	return out
+}
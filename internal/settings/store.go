@@ -0,0 +1,233 @@
+package settings
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const defaultHistorySize = 100
+
+// Change records an update to the settings, for auditing.
+type Change struct {
+	// Time is when the change was applied.
+	Time time.Time `json:"time"`
+	// Source describes who or what made the change, e.g. "file
+	// config/runtime.yaml" or "admin 10.0.0.1:52514".
+	Source string `json:"source"`
+	// Diffs lists each setting which changed.
+	Diffs []Diff `json:"diffs"`
+}
+
+// Diff is a change to a single setting.
+type Diff struct {
+	// Setting is the path of the setting, e.g. circuits.determinator.timeout.
+	Setting string `json:"setting"`
+	// Old is the previous value, or nil when the setting was not set.
+	Old interface{} `json:"old"`
+	// New is the new value, or nil when the setting was removed.
+	New interface{} `json:"new"`
+}
+
+// Store holds the current settings, and notifies subscribers when they change.
+type Store struct {
+	logger      *zap.Logger
+	historySize int
+
+	// base is the settings the store was created with.
+	base    Values
+	current atomic.Pointer[Values]
+
+	// mu serialises updates, so that subscribers see every change in order.
+	mu          sync.Mutex
+	subscribers map[int]func(before, after Values)
+	nextID      int
+	history     []Change
+}
+
+// Option configures a Store.
+type Option func(*Store)
+
+// WithLogger sets the logger changes are written to.
+func WithLogger(logger *zap.Logger) Option {
+	return func(s *Store) {
+		s.logger = logger
+	}
+}
+
+// WithHistorySize sets how many changes the store keeps for auditing.
+func WithHistorySize(size int) Option {
+	return func(s *Store) {
+		s.historySize = size
+	}
+}
+
+// New returns a Store holding initial, which are also the base settings that
+// settings files are applied to.
+func New(initial Values, opts ...Option) (*Store, error) {
+	if err := initial.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid initial settings: %w", err)
+	}
+
+	s := &Store{
+		logger:      zap.NewNop(),
+		historySize: defaultHistorySize,
+		base:        initial.clone(),
+		subscribers: map[int]func(before, after Values){},
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	current := initial.clone()
+	s.current.Store(&current)
+
+	return s, nil
+}
+
+// Current returns the current settings. The returned values must not be
+// modified.
+func (s *Store) Current() Values {
+	return *s.current.Load()
+}
+
+// Base returns the settings the store was created with.
+func (s *Store) Base() Values {
+	return s.base.clone()
+}
+
+// Update changes the settings with update, which is given a copy of the current
+// settings to modify. The new settings are validated as a whole: when they are
+// invalid nothing is changed and an error is returned. Otherwise every
+// subscriber to a setting which changed is notified before Update returns.
+func (s *Store) Update(source string, update func(v *Values)) (Change, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	old := s.Current()
+	updated := old.clone()
+	update(&updated)
+
+	if err := updated.Validate(); err != nil {
+		s.logger.Warn("rejected runtime settings", zap.String("source", source), zap.Error(err))
+		return Change{}, fmt.Errorf("invalid settings: %w", err)
+	}
+
+	change := Change{Time: time.Now(), Source: source, Diffs: diff(old, updated)}
+	if len(change.Diffs) == 0 {
+		return change, nil
+	}
+
+	// Changes are logged at warn level, and before they are applied, so that
+	// changing the log level cannot hide them.
+	s.logger.Warn("runtime settings changed", zap.String("source", source), zap.Any("diffs", change.Diffs))
+	s.history = append(s.history, change)
+	if len(s.history) > s.historySize {
+		s.history = s.history[len(s.history)-s.historySize:]
+	}
+
+	s.current.Store(&updated)
+	for _, notify := range s.subscribers {
+		notify(old, updated)
+	}
+
+	return change, nil
+}
+
+// Replace changes every setting to the given values. See Update.
+func (s *Store) Replace(source string, v Values) (Change, error) {
+	return s.Update(source, func(current *Values) {
+		*current = v.clone()
+	})
+}
+
+// History returns the most recent changes, oldest first.
+func (s *Store) History() []Change {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return append([]Change(nil), s.history...)
+}
+
+// Subscribe calls apply with the part of the settings selected by get, and
+// again whenever it changes. It returns a function which cancels the
+// subscription.
+//
+// apply is called while the store is being updated, so it must not update the
+// store itself, and should return quickly.
+func Subscribe[T any](s *Store, get func(Values) T, apply func(T)) (unsubscribe func()) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	apply(get(s.Current()))
+
+	id := s.nextID
+	s.nextID++
+	s.subscribers[id] = func(before, after Values) {
+		if value := get(after); !reflect.DeepEqual(get(before), value) {
+			apply(value)
+		}
+	}
+
+	return func() {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+
+		delete(s.subscribers, id)
+	}
+}
+
+// diff lists the settings which differ between old and updated, sorted by
+// path.
+func diff(old, updated Values) []Diff {
+	before, after := flatten(old), flatten(updated)
+
+	var diffs []Diff
+	for setting, value := range after {
+		if previous, ok := before[setting]; !ok || !reflect.DeepEqual(previous, value) {
+			diffs = append(diffs, Diff{Setting: setting, Old: before[setting], New: value})
+		}
+	}
+	for setting, value := range before {
+		if _, ok := after[setting]; !ok {
+			diffs = append(diffs, Diff{Setting: setting, Old: value})
+		}
+	}
+
+	sort.Slice(diffs, func(i, j int) bool { return diffs[i].Setting < diffs[j].Setting })
+	return diffs
+}
+
+// flatten returns the JSON representation of v as a map of dotted paths to
+// values.
+func flatten(v Values) map[string]interface{} {
+	raw, _ := json.Marshal(v)
+	var tree map[string]interface{}
+	_ = json.Unmarshal(raw, &tree)
+
+	out := map[string]interface{}{}
+	var walk func(prefix string, node interface{})
+	walk = func(prefix string, node interface{}) {
+		object, ok := node.(map[string]interface{})
+		if !ok {
+			out[prefix] = node
+			return
+		}
+		for key, child := range object {
+			path := key
+			if prefix != "" {
+				path = prefix + "." + key
+			}
+			walk(path, child)
+		}
+	}
+	walk("", tree)
+
+	return out
+}
@@ -0,0 +1,144 @@
+package settings
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap/zapcore"
+
+	"github.com/deliveroo/bnt-internal-test-go/internal/config"
+)
+
+func newStore(t *testing.T, opts ...Option) *Store {
+	t.Helper()
+
+	store, err := New(Values{
+		LogLevel: zapcore.InfoLevel,
+		Circuit:  config.Circuit{ErrorPercentThreshold: 50},
+	}, opts...)
+	if err != nil {
+		t.Fatalf("failed to create store: %s", err)
+	}
+	return store
+}
+
+func TestStore(t *testing.T) {
+	t.Run("notifies subscribers of changes to their settings", func(t *testing.T) {
+		store := newStore(t)
+
+		var levels []zapcore.Level
+		Subscribe(store, func(v Values) zapcore.Level { return v.LogLevel }, func(l zapcore.Level) {
+			levels = append(levels, l)
+		})
+
+		_, err := store.Update("test", func(v *Values) { v.LogLevel = zapcore.DebugLevel })
+		assert.Nil(t, err)
+		_, err = store.Update("test", func(v *Values) { v.SpanLogging = true })
+		assert.Nil(t, err)
+
+		assert.Equal(t, []zapcore.Level{zapcore.InfoLevel, zapcore.DebugLevel}, levels)
+	})
+
+	t.Run("rejects invalid settings as a whole", func(t *testing.T) {
+		store := newStore(t)
+
+		_, err := store.Update("test", func(v *Values) {
+			v.SpanLogging = true
+			v.Circuits = map[string]config.Circuit{"determinator": {ErrorPercentThreshold: 101}}
+		})
+		assert.EqualError(t, err, "invalid settings: circuits.determinator.error_percent_threshold must be at most 100")
+		assert.False(t, store.Current().SpanLogging)
+		assert.Empty(t, store.History())
+	})
+
+	t.Run("records the history of changes", func(t *testing.T) {
+		store := newStore(t, WithHistorySize(1))
+
+		_, _ = store.Update("first", func(v *Values) { v.SpanLogging = true })
+		change, err := store.Update("second", func(v *Values) {
+			v.Circuits = map[string]config.Circuit{"determinator": {Timeout: 100}}
+			v.RateLimits = map[string]RateLimit{"determinator": {RequestsPerSecond: 10, Burst: 1}}
+		})
+		assert.Nil(t, err)
+
+		assert.Equal(t, "second", change.Source)
+		assert.Equal(t, []Diff{
+			{Setting: "circuits.determinator.error_percent_threshold", New: float64(0)},
+			{Setting: "circuits.determinator.max_concurrent_requests", New: float64(0)},
+			{Setting: "circuits.determinator.request_volume_threshold", New: float64(0)},
+			{Setting: "circuits.determinator.sleep_window", New: float64(0)},
+			{Setting: "circuits.determinator.timeout", New: float64(100)},
+			{Setting: "rate_limits.determinator.burst", New: float64(1)},
+			{Setting: "rate_limits.determinator.requests_per_second", New: float64(10)},
+		}, change.Diffs)
+		assert.Equal(t, []Change{change}, store.History())
+	})
+
+	t.Run("ignores updates which change nothing", func(t *testing.T) {
+		store := newStore(t)
+
+		change, err := store.Update("test", func(v *Values) { v.LogLevel = zapcore.InfoLevel })
+		assert.Nil(t, err)
+		assert.Empty(t, change.Diffs)
+		assert.Empty(t, store.History())
+	})
+
+	t.Run("stops notifying after unsubscribing", func(t *testing.T) {
+		store := newStore(t)
+
+		calls := 0
+		unsubscribe := Subscribe(store, func(v Values) bool { return v.SpanLogging }, func(bool) { calls++ })
+		unsubscribe()
+
+		_, _ = store.Update("test", func(v *Values) { v.SpanLogging = true })
+		assert.Equal(t, 1, calls)
+	})
+}
+
+func TestLoadFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "runtime.yaml")
+	store := newStore(t)
+
+	t.Run("applies the file over the base settings", func(t *testing.T) {
+		writeFile(t, path, `
+log_level: warn
+circuits:
+  determinator:
+    error_percent_threshold: 25
+`)
+		change, err := store.LoadFile(path)
+		assert.Nil(t, err)
+		assert.Equal(t, "file "+path, change.Source)
+
+		current := store.Current()
+		assert.Equal(t, zapcore.WarnLevel, current.LogLevel)
+		assert.Equal(t, config.Circuit{ErrorPercentThreshold: 25}, current.CircuitFor("determinator"))
+		assert.Equal(t, config.Circuit{ErrorPercentThreshold: 50}, current.CircuitFor("other"))
+	})
+
+	t.Run("keeps the current settings when the file is invalid", func(t *testing.T) {
+		writeFile(t, path, "log_level: loud\n")
+
+		_, err := store.LoadFile(path)
+		assert.NotNil(t, err)
+		assert.Equal(t, zapcore.WarnLevel, store.Current().LogLevel)
+	})
+
+	t.Run("reverts to the base settings when the file is removed", func(t *testing.T) {
+		assert.Nil(t, os.Remove(path))
+
+		_, err := store.LoadFile(path)
+		assert.Nil(t, err)
+		assert.Equal(t, store.Base(), store.Current())
+	})
+}
+
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatalf("failed to write %s: %s", path, err)
+	}
+}